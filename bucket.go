@@ -2,7 +2,9 @@ package witchbolt
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
+	"io"
 	"unsafe"
 
 	"github.com/delaneyj/witchbolt/errors"
@@ -111,6 +113,23 @@ func (b *Bucket) Bucket(name []byte) *Bucket {
 	return child
 }
 
+// Exists returns true if the nested bucket path exists beneath this bucket.
+// Each element of path names a bucket inside the previous one, and the walk
+// stops with false at the first missing level or non-bucket key.
+// Returns false if path is empty.
+func (b *Bucket) Exists(path ...[]byte) bool {
+	if len(path) == 0 {
+		return false
+	}
+	child := b
+	for _, name := range path {
+		if child = child.Bucket(name); child == nil {
+			return false
+		}
+	}
+	return true
+}
+
 // Helper method that re-interprets a sub-bucket value
 // from a parent into a Bucket
 func (b *Bucket) openBucket(value []byte) *Bucket {
@@ -316,6 +335,9 @@ func (b *Bucket) DeleteBucket(key []byte) (err error) {
 
 	// Remove cached copy.
 	delete(b.buckets, string(newKey))
+	if b == &b.tx.root {
+		b.tx.detachBucket(newKey)
+	}
 
 	// Release all bucket pages to freelist.
 	child.nodes = nil
@@ -333,7 +355,12 @@ func (b *Bucket) DeleteBucket(key []byte) (err error) {
 //  1. the sub-bucket cannot be found in the source bucket;
 //  2. or the key already exists in the destination bucket;
 //  3. or the key represents a non-bucket value;
-//  4. the source and destination buckets are the same.
+//  4. the source and destination buckets are the same;
+//  5. or the destination bucket is the sub-bucket itself or one of its
+//     descendants.
+//
+// Only the bucket entry is moved: the sub-bucket keeps its root page and its
+// contents aren't rewritten.
 func (b *Bucket) MoveBucket(key []byte, dstBucket *Bucket) (err error) {
 	lg := b.tx.db.Logger()
 	if lg != discardLogger {
@@ -379,6 +406,13 @@ func (b *Bucket) MoveBucket(key []byte, dstBucket *Bucket) (err error) {
 		return errors.ErrSameBuckets
 	}
 
+	// Moving the bucket under itself would detach it from the tree.
+	child := b.buckets[string(newKey)]
+	if child != nil && child.hasCachedDescendant(dstBucket) {
+		lg.Errorf("The target bucket (%s) is the bucket %q or one of its descendants", dstBucket, newKey)
+		return errors.ErrMoveToDescendant
+	}
+
 	// check whether the key already exists in the destination bucket
 	curDst := dstBucket.Cursor()
 	k, _, flags = curDst.seek(newKey)
@@ -395,14 +429,39 @@ func (b *Bucket) MoveBucket(key []byte, dstBucket *Bucket) (err error) {
 	// remove the sub-bucket from the source bucket
 	delete(b.buckets, string(newKey))
 	c.node().del(newKey)
+	if b == &b.tx.root || dstBucket == &b.tx.root {
+		b.tx.detachBucket(newKey)
+	}
 
 	// add te sub-bucket to the destination bucket
 	newValue := cloneBytes(v)
 	curDst.node().put(newKey, newKey, newValue, 0, common.BucketLeafFlag)
 
+	// Hand over the cached sub-bucket, if any, so that the changes made to it
+	// earlier in this transaction are spilled under its new parent rather
+	// than lost along with the stale header copied above.
+	if child != nil {
+		dstBucket.buckets[string(newKey)] = child
+	}
+
 	return nil
 }
 
+// hasCachedDescendant reports whether target is b or a bucket opened through
+// b. A writable transaction caches every sub-bucket it opens, so any
+// descendant of b the caller can hold is found.
+func (b *Bucket) hasCachedDescendant(target *Bucket) bool {
+	if b == target {
+		return true
+	}
+	for _, child := range b.buckets {
+		if child.hasCachedDescendant(target) {
+			return true
+		}
+	}
+	return false
+}
+
 // Inspect returns the structure of the bucket.
 func (b *Bucket) Inspect() BucketStructure {
 	return b.recursivelyInspect([]byte("root"))
@@ -446,6 +505,24 @@ func (b *Bucket) Get(key []byte) []byte {
 	return v
 }
 
+// GetMany retrieves the values for several keys in the bucket, in the order
+// of keys. As with Get, a value is nil if its key does not exist or is a
+// nested bucket, and the returned values are only valid for the life of the
+// transaction and must never be modified. A single cursor serves every key,
+// so sorted keys that share a leaf page are found without descending the tree
+// again.
+func (b *Bucket) GetMany(keys [][]byte) [][]byte {
+	values := make([][]byte, len(keys))
+	c := b.Cursor()
+	for i, key := range keys {
+		k, v, flags := c.seekNear(key)
+		if (flags&common.BucketLeafFlag) == 0 && bytes.Equal(key, k) {
+			values[i] = v
+		}
+	}
+	return values
+}
+
 // Put sets the value for a key in the bucket.
 // If the key exist then its previous value will be overwritten.
 // Supplied value must remain valid for the life of the transaction.
@@ -540,7 +617,10 @@ func (b *Bucket) Sequence() uint64 {
 	return b.InSequence()
 }
 
-// SetSequence updates the sequence number for the bucket.
+// SetSequence updates the sequence number for the bucket. The new value is
+// visible to the rest of the transaction and is persisted when it commits,
+// even if nothing else in the bucket changed, so NextSequence continues from
+// v afterwards.
 func (b *Bucket) SetSequence(v uint64) error {
 	if b.tx.db == nil {
 		return errors.ErrTxClosed
@@ -559,6 +639,12 @@ func (b *Bucket) SetSequence(v uint64) error {
 	return nil
 }
 
+// ResetSequence sets the sequence number for the bucket back to 0, so the next
+// call to NextSequence returns 1.
+func (b *Bucket) ResetSequence() error {
+	return b.SetSequence(0)
+}
+
 // NextSequence returns an autoincrementing integer for the bucket.
 func (b *Bucket) NextSequence() (uint64, error) {
 	if b.tx.db == nil {
@@ -582,13 +668,44 @@ func (b *Bucket) NextSequence() (uint64, error) {
 // Because ForEach uses a Cursor, the iteration over keys is in lexicographical order.
 // If the provided function returns an error then the iteration is stopped and
 // the error is returned to the caller. The provided function must not modify
-// the bucket; this will result in undefined behavior.
+// the bucket; this will result in undefined behavior. In a transaction started
+// by DB.ViewContext or DB.UpdateContext, the iteration also stops with the
+// context's error once it is done; the context is checked before the first
+// key and then every 256 keys.
 func (b *Bucket) ForEach(fn func(k, v []byte) error) error {
 	if b.tx.db == nil {
 		return errors.ErrTxClosed
 	}
 	c := b.Cursor()
+	var i int
 	for k, v := c.First(); k != nil; k, v = c.Next() {
+		if err := b.tx.iterErr(i); err != nil {
+			return err
+		}
+		i++
+		if err := fn(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ForEachReverse executes a function for each key/value pair in a bucket, in
+// reverse lexicographical order, walking the cursor from Last with Prev. It
+// otherwise behaves like ForEach: nested buckets are passed with a nil value,
+// an error returned by fn stops the iteration and is returned to the caller,
+// and fn must not modify the bucket.
+func (b *Bucket) ForEachReverse(fn func(k, v []byte) error) error {
+	if b.tx.db == nil {
+		return errors.ErrTxClosed
+	}
+	c := b.Cursor()
+	var i int
+	for k, v := c.Last(); k != nil; k, v = c.Prev() {
+		if err := b.tx.iterErr(i); err != nil {
+			return err
+		}
+		i++
 		if err := fn(k, v); err != nil {
 			return err
 		}
@@ -596,12 +713,75 @@ func (b *Bucket) ForEach(fn func(k, v []byte) error) error {
 	return nil
 }
 
+// ForEachPrefix executes a function for each key/value pair in a bucket whose
+// key starts with prefix, in lexicographical order. It seeks straight to the
+// prefix and stops at the first key past it, so it only reads the pages that
+// hold matching keys. As with ForEach, nested buckets are passed with a nil
+// value, an empty prefix visits every key, an error returned by fn stops the
+// iteration and is returned to the caller, and fn must not modify the bucket.
+func (b *Bucket) ForEachPrefix(prefix []byte, fn func(k, v []byte) error) error {
+	if b.tx.db == nil {
+		return errors.ErrTxClosed
+	}
+	c := b.Cursor()
+	var i int
+	for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		if err := b.tx.iterErr(i); err != nil {
+			return err
+		}
+		i++
+		if err := fn(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Range executes a function for each key/value pair in a bucket with a key in
+// [start, end), in lexicographical order: start is inclusive and end is
+// exclusive. A nil or empty start begins at the first key and a nil or empty
+// end runs to the last one; a range whose start isn't before its end visits
+// nothing. As with ForEach, nested buckets are passed with a nil value, an
+// error returned by fn stops the iteration and is returned to the caller,
+// and fn must not modify the bucket.
+func (b *Bucket) Range(start, end []byte, fn func(k, v []byte) error) error {
+	if b.tx.db == nil {
+		return errors.ErrTxClosed
+	}
+	c := b.Cursor()
+	k, v := c.First()
+	if len(start) > 0 {
+		k, v = c.Seek(start)
+	}
+	var i int
+	for ; k != nil; k, v = c.Next() {
+		if err := b.tx.iterErr(i); err != nil {
+			return err
+		}
+		i++
+		if len(end) > 0 && bytes.Compare(k, end) >= 0 {
+			break
+		}
+		if err := fn(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ForEachBucket executes a function for the key of each nested bucket in a
+// bucket, in lexicographical order. It stops like ForEach.
 func (b *Bucket) ForEachBucket(fn func(k []byte) error) error {
 	if b.tx.db == nil {
 		return errors.ErrTxClosed
 	}
 	c := b.Cursor()
+	var i int
 	for k, _, flags := c.first(); k != nil; k, _, flags = c.next() {
+		if err := b.tx.iterErr(i); err != nil {
+			return err
+		}
+		i++
 		if flags&common.BucketLeafFlag != 0 {
 			if err := fn(k); err != nil {
 				return err
@@ -611,6 +791,100 @@ func (b *Bucket) ForEachBucket(fn func(k []byte) error) error {
 	return nil
 }
 
+// Framing selects how Bucket.NewValueReader delimits consecutive values.
+type Framing int
+
+const (
+	// FramingLengthPrefixed writes each value after its length, encoded as a
+	// 4-byte big-endian unsigned integer.
+	FramingLengthPrefixed Framing = iota
+
+	// FramingNewline writes each value followed by a newline. Reading fails
+	// on a value that itself contains a newline.
+	FramingNewline
+)
+
+// NewValueReader returns a reader that yields every value in the bucket, in
+// key order, delimited by framing. Nested buckets are skipped. Values are read
+// lazily from the bucket's cursor, so the reader is only valid for the life of
+// the transaction: reads after the transaction is closed return ErrTxClosed,
+// and a writable transaction must not modify the bucket until reading is done.
+func (b *Bucket) NewValueReader(framing Framing) io.Reader {
+	return &valueReader{bucket: b, framing: framing}
+}
+
+// valueReader implements the reader returned by Bucket.NewValueReader.
+type valueReader struct {
+	bucket  *Bucket
+	framing Framing
+	cursor  *Cursor
+	done    bool
+
+	// parts holds the unread remainder of the current frame.
+	parts  [][]byte
+	header [4]byte
+}
+
+func (r *valueReader) Read(p []byte) (int, error) {
+	if r.bucket.tx.db == nil {
+		return 0, errors.ErrTxClosed
+	}
+	n := 0
+	for n < len(p) {
+		if len(r.parts) == 0 {
+			if r.done {
+				break
+			}
+			if err := r.advance(); err != nil {
+				return n, err
+			}
+			continue
+		}
+		copied := copy(p[n:], r.parts[0])
+		n += copied
+		if r.parts[0] = r.parts[0][copied:]; len(r.parts[0]) == 0 {
+			r.parts = r.parts[1:]
+		}
+	}
+	if n == 0 && r.done && len(p) > 0 {
+		return 0, io.EOF
+	}
+	return n, nil
+}
+
+// advance moves the cursor to the next value and frames it.
+func (r *valueReader) advance() error {
+	var k, v []byte
+	var flags uint32
+	if r.cursor == nil {
+		r.cursor = r.bucket.Cursor()
+		k, v, flags = r.cursor.first()
+	} else {
+		k, v, flags = r.cursor.next()
+	}
+	for k != nil && flags&common.BucketLeafFlag != 0 {
+		k, v, flags = r.cursor.next()
+	}
+	if k == nil {
+		r.done = true
+		return nil
+	}
+	switch r.framing {
+	case FramingLengthPrefixed:
+		binary.BigEndian.PutUint32(r.header[:], uint32(len(v)))
+		r.parts = append(r.parts[:0], r.header[:], v)
+	case FramingNewline:
+		if bytes.IndexByte(v, '\n') >= 0 {
+			return fmt.Errorf("value for key %q contains a newline", k)
+		}
+		r.header[0] = '\n'
+		r.parts = append(r.parts[:0], v, r.header[:1])
+	default:
+		return fmt.Errorf("unknown value framing %d", r.framing)
+	}
+	return nil
+}
+
 // Stats returns stats on a bucket.
 func (b *Bucket) Stats() BucketStats {
 	var s, subStats BucketStats
@@ -5,12 +5,15 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"math/rand"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"testing"
+	"testing/iotest"
 	"testing/quick"
 
 	"github.com/stretchr/testify/assert"
@@ -122,6 +125,70 @@ func TestBucket_Get_Capacity(t *testing.T) {
 	}
 }
 
+// Ensure that GetMany returns the same values as Get for sorted, unsorted,
+// repeated, missing and nested bucket keys, from pages and from nodes.
+func TestBucket_GetMany(t *testing.T) {
+	db := btesting.MustCreateDB(t)
+
+	err := db.Update(func(tx *witchbolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("widgets"))
+		require.NoError(t, err)
+		for i := 0; i < 2000; i += 2 {
+			require.NoError(t, b.Put([]byte(fmt.Sprintf("%05d", i)), []byte(fmt.Sprintf("value-%d", i))))
+		}
+		_, err = b.CreateBucket([]byte("01001"))
+		return err
+	})
+	require.NoError(t, err)
+
+	var sorted [][]byte
+	for i := -1; i < 2002; i++ {
+		sorted = append(sorted, []byte(fmt.Sprintf("%05d", i)))
+	}
+	reversed := slices.Clone(sorted)
+	slices.Reverse(reversed)
+	shuffled := slices.Clone(sorted)
+	rand.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+	tests := map[string][][]byte{
+		"none":     nil,
+		"sorted":   sorted,
+		"reversed": reversed,
+		"shuffled": shuffled,
+		"repeated": {[]byte("00002"), []byte("00002"), []byte("00001"), []byte("00002")},
+		"boundary": {[]byte(""), []byte("0"), []byte("00000"), []byte("01998"), []byte("01999"), []byte("9")},
+	}
+
+	verify := func(t *testing.T, b *witchbolt.Bucket) {
+		for name, keys := range tests {
+			t.Run(name, func(t *testing.T) {
+				values := b.GetMany(keys)
+				require.Len(t, values, len(keys))
+				for i, key := range keys {
+					require.Equal(t, b.Get(key), values[i], "key %q", key)
+				}
+			})
+		}
+		values := b.GetMany([][]byte{[]byte("00002"), []byte("00003"), []byte("01001")})
+		require.Equal(t, [][]byte{[]byte("value-2"), nil, nil}, values)
+	}
+
+	t.Run("pages", func(t *testing.T) {
+		require.NoError(t, db.View(func(tx *witchbolt.Tx) error {
+			verify(t, tx.Bucket([]byte("widgets")))
+			return nil
+		}))
+	})
+	t.Run("nodes", func(t *testing.T) {
+		require.NoError(t, db.Update(func(tx *witchbolt.Tx) error {
+			b := tx.Bucket([]byte("widgets"))
+			require.NoError(t, b.Put([]byte("00003"), []byte("value-3")))
+			require.NoError(t, b.Delete([]byte("00003")))
+			verify(t, b)
+			return nil
+		}))
+	})
+}
+
 // Ensure that a bucket can write a key/value.
 func TestBucket_Put(t *testing.T) {
 	db := btesting.MustCreateDB(t)
@@ -855,6 +922,80 @@ func TestBucket_Sequence(t *testing.T) {
 	}
 }
 
+// Ensure that a sequence set on its own, on top-level and nested buckets,
+// survives reopening the database and seeds NextSequence.
+func TestBucket_SetSequence_Persist(t *testing.T) {
+	db := btesting.MustCreateDB(t)
+
+	err := db.Update(func(tx *witchbolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("widgets"))
+		require.NoError(t, err)
+		_, err = b.CreateBucket([]byte("nested"))
+		return err
+	})
+	require.NoError(t, err)
+
+	err = db.Update(func(tx *witchbolt.Tx) error {
+		b := tx.Bucket([]byte("widgets"))
+		require.NoError(t, b.SetSequence(1000))
+		require.NoError(t, b.Bucket([]byte("nested")).SetSequence(42))
+		require.Equal(t, uint64(1000), b.Sequence())
+		return nil
+	})
+	require.NoError(t, err)
+
+	db.MustClose()
+	db.MustReopen()
+
+	err = db.Update(func(tx *witchbolt.Tx) error {
+		b := tx.Bucket([]byte("widgets"))
+		require.Equal(t, uint64(1000), b.Sequence())
+		require.Equal(t, uint64(42), b.Bucket([]byte("nested")).Sequence())
+
+		seq, err := b.NextSequence()
+		require.NoError(t, err)
+		require.Equal(t, uint64(1001), seq)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+// Ensure that ResetSequence restarts NextSequence at 1 and persists.
+func TestBucket_ResetSequence(t *testing.T) {
+	db := btesting.MustCreateDB(t)
+
+	err := db.Update(func(tx *witchbolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("widgets"))
+		require.NoError(t, err)
+		return b.SetSequence(7)
+	})
+	require.NoError(t, err)
+
+	err = db.Update(func(tx *witchbolt.Tx) error {
+		return tx.Bucket([]byte("widgets")).ResetSequence()
+	})
+	require.NoError(t, err)
+
+	db.MustClose()
+	db.MustReopen()
+
+	err = db.Update(func(tx *witchbolt.Tx) error {
+		b := tx.Bucket([]byte("widgets"))
+		require.Equal(t, uint64(0), b.Sequence())
+		seq, err := b.NextSequence()
+		require.NoError(t, err)
+		require.Equal(t, uint64(1), seq)
+		return nil
+	})
+	require.NoError(t, err)
+
+	err = db.View(func(tx *witchbolt.Tx) error {
+		require.ErrorIs(t, tx.Bucket([]byte("widgets")).ResetSequence(), berrors.ErrTxNotWritable)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
 // Ensure that a bucket can return an autoincrementing sequence.
 func TestBucket_NextSequence(t *testing.T) {
 	db := btesting.MustCreateDB(t)
@@ -1167,6 +1308,365 @@ func TestBucket_ForEach_Closed(t *testing.T) {
 	}
 }
 
+// Ensure that ForEachReverse visits the keys of ForEach in reverse order,
+// including nested buckets and keys spread over several pages.
+func TestBucket_ForEachReverse(t *testing.T) {
+	db := btesting.MustCreateDB(t)
+
+	collect := func(b *witchbolt.Bucket, reverse bool) (keys []string, buckets []string) {
+		each := b.ForEach
+		if reverse {
+			each = b.ForEachReverse
+		}
+		err := each(func(k, v []byte) error {
+			keys = append(keys, string(k))
+			if v == nil {
+				buckets = append(buckets, string(k))
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		return keys, buckets
+	}
+
+	err := db.Update(func(tx *witchbolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("widgets"))
+		require.NoError(t, err)
+		for i := 0; i < 1000; i++ {
+			require.NoError(t, b.Put([]byte(fmt.Sprintf("%04d", i)), make([]byte, 100)))
+		}
+		_, err = b.CreateBucket([]byte("0500sub"))
+		require.NoError(t, err)
+		_, err = tx.CreateBucket([]byte("empty"))
+		return err
+	})
+	require.NoError(t, err)
+
+	err = db.View(func(tx *witchbolt.Tx) error {
+		forward, forwardBuckets := collect(tx.Bucket([]byte("widgets")), false)
+		reverse, reverseBuckets := collect(tx.Bucket([]byte("widgets")), true)
+		require.Len(t, forward, 1001)
+		slices.Reverse(reverse)
+		assert.Equal(t, forward, reverse)
+		assert.Equal(t, []string{"0500sub"}, forwardBuckets)
+		assert.Equal(t, forwardBuckets, reverseBuckets)
+
+		keys, _ := collect(tx.Bucket([]byte("empty")), true)
+		assert.Empty(t, keys)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+// Ensure that ForEachReverse stops when the function returns an error.
+func TestBucket_ForEachReverse_ShortCircuit(t *testing.T) {
+	db := btesting.MustCreateDB(t)
+	err := db.Update(func(tx *witchbolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("widgets"))
+		require.NoError(t, err)
+		for _, k := range []string{"a", "b", "c"} {
+			require.NoError(t, b.Put([]byte(k), []byte("v")))
+		}
+
+		var seen []string
+		err = b.ForEachReverse(func(k, v []byte) error {
+			seen = append(seen, string(k))
+			if string(k) == "b" {
+				return errors.New("marker")
+			}
+			return nil
+		})
+		require.EqualError(t, err, "marker")
+		require.Equal(t, []string{"c", "b"}, seen)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+// Ensure that ForEachReverse on a closed transaction returns an error.
+func TestBucket_ForEachReverse_Closed(t *testing.T) {
+	db := btesting.MustCreateDB(t)
+
+	tx, err := db.Begin(true)
+	require.NoError(t, err)
+	b, err := tx.CreateBucket([]byte("widgets"))
+	require.NoError(t, err)
+	require.NoError(t, tx.Rollback())
+
+	err = b.ForEachReverse(func(k, v []byte) error { return nil })
+	require.ErrorIs(t, err, berrors.ErrTxClosed)
+}
+
+// Ensure that ForEachPrefix visits exactly the keys sharing the prefix.
+func TestBucket_ForEachPrefix(t *testing.T) {
+	db := btesting.MustCreateDB(t)
+
+	keys := []string{"a", "ab", "ab\x00", "abc", "abd", "ab\xff", "ab\xff\xff", "ac", "b"}
+	err := db.Update(func(tx *witchbolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("widgets"))
+		require.NoError(t, err)
+		for _, k := range keys {
+			require.NoError(t, b.Put([]byte(k), []byte("v-"+k)))
+		}
+		_, err = b.CreateBucket([]byte("abz"))
+		return err
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		prefix string
+		want   []string
+	}{
+		{name: "empty prefix", prefix: "", want: []string{"a", "ab", "ab\x00", "abc", "abd", "abz", "ab\xff", "ab\xff\xff", "ac", "b"}},
+		{name: "key equal to prefix", prefix: "ab", want: []string{"ab", "ab\x00", "abc", "abd", "abz", "ab\xff", "ab\xff\xff"}},
+		{name: "trailing 0xff", prefix: "ab\xff", want: []string{"ab\xff", "ab\xff\xff"}},
+		{name: "last key", prefix: "b", want: []string{"b"}},
+		{name: "no match between keys", prefix: "abe", want: nil},
+		{name: "no match before first key", prefix: "0", want: nil},
+		{name: "no match after last key", prefix: "c", want: nil},
+		{name: "prefix longer than keys", prefix: "abcd", want: nil},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := db.View(func(tx *witchbolt.Tx) error {
+				var got []string
+				err := tx.Bucket([]byte("widgets")).ForEachPrefix([]byte(tc.prefix), func(k, v []byte) error {
+					if string(k) == "abz" {
+						assert.Nil(t, v, "nested bucket should have a nil value")
+					} else {
+						assert.Equal(t, "v-"+string(k), string(v))
+					}
+					got = append(got, string(k))
+					return nil
+				})
+				require.NoError(t, err)
+				assert.Equal(t, tc.want, got)
+				return nil
+			})
+			require.NoError(t, err)
+		})
+	}
+}
+
+// Ensure that ForEachPrefix stops when the function returns an error.
+func TestBucket_ForEachPrefix_ShortCircuit(t *testing.T) {
+	db := btesting.MustCreateDB(t)
+	err := db.Update(func(tx *witchbolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("widgets"))
+		require.NoError(t, err)
+		for _, k := range []string{"p1", "p2", "p3"} {
+			require.NoError(t, b.Put([]byte(k), []byte("v")))
+		}
+
+		var index int
+		err = b.ForEachPrefix([]byte("p"), func(k, v []byte) error {
+			index++
+			if bytes.Equal(k, []byte("p2")) {
+				return errors.New("marker")
+			}
+			return nil
+		})
+		require.EqualError(t, err, "marker")
+		require.Equal(t, 2, index)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+// Ensure that ForEachPrefix on a closed transaction returns an error.
+func TestBucket_ForEachPrefix_Closed(t *testing.T) {
+	db := btesting.MustCreateDB(t)
+
+	tx, err := db.Begin(true)
+	require.NoError(t, err)
+	b, err := tx.CreateBucket([]byte("widgets"))
+	require.NoError(t, err)
+	require.NoError(t, tx.Rollback())
+
+	err = b.ForEachPrefix(nil, func(k, v []byte) error { return nil })
+	require.ErrorIs(t, err, berrors.ErrTxClosed)
+}
+
+// Ensure that Range visits the keys in [start, end).
+func TestBucket_Range(t *testing.T) {
+	db := btesting.MustCreateDB(t)
+
+	err := db.Update(func(tx *witchbolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("widgets"))
+		require.NoError(t, err)
+		for _, k := range []string{"b", "d", "f", "h"} {
+			require.NoError(t, b.Put([]byte(k), []byte("v-"+k)))
+		}
+		_, err = b.CreateBucket([]byte("e"))
+		return err
+	})
+	require.NoError(t, err)
+
+	bound := func(s string) []byte {
+		if s == "" {
+			return nil
+		}
+		return []byte(s)
+	}
+	tests := []struct {
+		name       string
+		start, end string
+		want       []string
+	}{
+		{name: "unbounded", want: []string{"b", "d", "e", "f", "h"}},
+		{name: "missing start", end: "e", want: []string{"b", "d"}},
+		{name: "missing end", start: "e", want: []string{"e", "f", "h"}},
+		{name: "existing endpoints", start: "d", end: "h", want: []string{"d", "e", "f"}},
+		{name: "endpoints between keys", start: "c", end: "g", want: []string{"d", "e", "f"}},
+		{name: "endpoints outside keys", start: "a", end: "z", want: []string{"b", "d", "e", "f", "h"}},
+		{name: "single key", start: "f", end: "f\x00", want: []string{"f"}},
+		{name: "empty range", start: "d", end: "d", want: nil},
+		{name: "inverted range", start: "h", end: "b", want: nil},
+		{name: "start after last key", start: "i", want: nil},
+		{name: "end before first key", end: "a", want: nil},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := db.View(func(tx *witchbolt.Tx) error {
+				var got []string
+				err := tx.Bucket([]byte("widgets")).Range(bound(tc.start), bound(tc.end), func(k, v []byte) error {
+					if string(k) == "e" {
+						assert.Nil(t, v, "nested bucket should have a nil value")
+					} else {
+						assert.Equal(t, "v-"+string(k), string(v))
+					}
+					got = append(got, string(k))
+					return nil
+				})
+				require.NoError(t, err)
+				assert.Equal(t, tc.want, got)
+				return nil
+			})
+			require.NoError(t, err)
+		})
+	}
+}
+
+// Ensure that Range stops when the function returns an error.
+func TestBucket_Range_ShortCircuit(t *testing.T) {
+	db := btesting.MustCreateDB(t)
+	err := db.Update(func(tx *witchbolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("widgets"))
+		require.NoError(t, err)
+		for _, k := range []string{"a", "b", "c"} {
+			require.NoError(t, b.Put([]byte(k), []byte("v")))
+		}
+
+		var seen []string
+		err = b.Range([]byte("a"), nil, func(k, v []byte) error {
+			seen = append(seen, string(k))
+			if string(k) == "b" {
+				return errors.New("marker")
+			}
+			return nil
+		})
+		require.EqualError(t, err, "marker")
+		require.Equal(t, []string{"a", "b"}, seen)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+// Ensure that Range on a closed transaction returns an error.
+func TestBucket_Range_Closed(t *testing.T) {
+	db := btesting.MustCreateDB(t)
+
+	tx, err := db.Begin(true)
+	require.NoError(t, err)
+	b, err := tx.CreateBucket([]byte("widgets"))
+	require.NoError(t, err)
+	require.NoError(t, tx.Rollback())
+
+	err = b.Range(nil, nil, func(k, v []byte) error { return nil })
+	require.ErrorIs(t, err, berrors.ErrTxClosed)
+}
+
+// Ensure that a value reader streams every value in key order and skips
+// nested buckets.
+func TestBucket_NewValueReader(t *testing.T) {
+	db := btesting.MustCreateDB(t)
+
+	values := map[string][]byte{
+		"a": []byte("first"),
+		"b": {},
+		"c": bytes.Repeat([]byte("x"), 10000),
+		"d": []byte("last"),
+	}
+	require.NoError(t, db.Update(func(tx *witchbolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("widgets"))
+		if err != nil {
+			return err
+		}
+		for k, v := range values {
+			if err := b.Put([]byte(k), v); err != nil {
+				return err
+			}
+		}
+		_, err = b.CreateBucket([]byte("nested"))
+		return err
+	}))
+	want := [][]byte{values["a"], values["b"], values["c"], values["d"]}
+
+	t.Run("length prefixed", func(t *testing.T) {
+		require.NoError(t, db.View(func(tx *witchbolt.Tx) error {
+			var buf bytes.Buffer
+			_, err := io.Copy(&buf, iotest.OneByteReader(tx.Bucket([]byte("widgets")).NewValueReader(witchbolt.FramingLengthPrefixed)))
+			require.NoError(t, err)
+
+			var got [][]byte
+			for buf.Len() > 0 {
+				n := binary.BigEndian.Uint32(buf.Next(4))
+				got = append(got, bytes.Clone(buf.Next(int(n))))
+			}
+			require.Equal(t, len(want), len(got))
+			for i := range want {
+				require.True(t, bytes.Equal(want[i], got[i]), "value %d", i)
+			}
+			return nil
+		}))
+	})
+
+	t.Run("newline", func(t *testing.T) {
+		require.NoError(t, db.View(func(tx *witchbolt.Tx) error {
+			out, err := io.ReadAll(tx.Bucket([]byte("widgets")).NewValueReader(witchbolt.FramingNewline))
+			require.NoError(t, err)
+			require.Equal(t, "first\n\n"+strings.Repeat("x", 10000)+"\nlast\n", string(out))
+			return nil
+		}))
+	})
+
+	t.Run("newline in value", func(t *testing.T) {
+		require.NoError(t, db.Update(func(tx *witchbolt.Tx) error {
+			b := tx.Bucket([]byte("widgets"))
+			require.NoError(t, b.Put([]byte("e"), []byte("two\nlines")))
+			_, err := io.ReadAll(b.NewValueReader(witchbolt.FramingNewline))
+			require.ErrorContains(t, err, "contains a newline")
+			return nil
+		}))
+	})
+}
+
+// Ensure that reading a value reader after its transaction closes fails.
+func TestBucket_NewValueReader_Closed(t *testing.T) {
+	db := btesting.MustCreateDB(t)
+
+	tx, err := db.Begin(true)
+	require.NoError(t, err)
+	b, err := tx.CreateBucket([]byte("widgets"))
+	require.NoError(t, err)
+	r := b.NewValueReader(witchbolt.FramingLengthPrefixed)
+	require.NoError(t, tx.Rollback())
+
+	_, err = r.Read(make([]byte, 16))
+	require.ErrorIs(t, err, berrors.ErrTxClosed)
+}
+
 // Ensure that an error is returned when inserting with an empty key.
 func TestBucket_Put_EmptyKey(t *testing.T) {
 	db := btesting.MustCreateDB(t)
@@ -15,13 +15,18 @@ var CLI struct {
 	Inspect InspectCmd `cmd:"" help:"Inspect the structure of the database"`
 	Check   CheckCmd   `cmd:"" help:"Verify integrity of witchbolt database"`
 	Info    InfoCmd    `cmd:"" help:"Print basic info about witchbolt database"`
+	Txid    TxidCmd    `cmd:"" help:"Print the active transaction ID and meta page fields"`
 	Stats   StatsCmd   `cmd:"" help:"Iterate over all pages in a database"`
+	Top     TopCmd     `cmd:"" help:"Print the largest buckets by keys, bytes or depth"`
 
 	// Data access commands
 	Buckets BucketsCmd `cmd:"" help:"Print a list of buckets"`
 	Keys    KeysCmd    `cmd:"" help:"Print a list of keys in a bucket"`
 	Get     GetCmd     `cmd:"" help:"Get the value of a key from a bucket"`
 	Dump    DumpCmd    `cmd:"" help:"Dump all key/value pairs from specified buckets or entire database"`
+	Diff    DiffCmd    `cmd:"" help:"Print the buckets and keys added, removed or changed between two databases"`
+	Export  ExportCmd  `cmd:"" help:"Export every bucket and key to a json, jsonl or cbor archive"`
+	Import  ImportCmd  `cmd:"" help:"Create a database from an archive written by export"`
 
 	// Page-level commands
 	Pages    PagesCmd    `cmd:"" help:"Dump page IDs for all page types"`
@@ -29,9 +34,14 @@ var CLI struct {
 	PageItem PageItemCmd `cmd:"" aliases:"page-item" help:"Print the key and value of a page item"`
 
 	// Database modification commands
+	Put     PutCmd     `cmd:"" help:"Write the value of a key in a bucket"`
+	Delete  DeleteCmd  `cmd:"" help:"Delete a key or a bucket"`
 	Compact CompactCmd `cmd:"" help:"Creates a compacted copy of the database"`
 	Surgery SurgeryCmd `cmd:"" help:"Perform surgery on a witchbolt database"`
 
+	// Replication commands
+	Stream StreamCmd `cmd:"" help:"Inspect and maintain stream replication artefacts"`
+
 	// Performance commands
 	Bench BenchCmd `cmd:"" help:"Benchmark the database"`
 
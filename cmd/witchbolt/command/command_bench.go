@@ -2,24 +2,35 @@ package command
 
 import (
 	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"math"
+	"math/bits"
 	"math/rand"
 	"os"
 	"runtime"
 	"runtime/pprof"
+	"slices"
+	"strconv"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/delaneyj/witchbolt"
 	"github.com/valyala/bytebufferpool"
+	"golang.org/x/sync/errgroup"
 )
 
 var benchBucketName = []byte("bench")
 
+// benchDefaultCount is the iteration count, and the batch size of
+// duration-based runs, used when neither is given.
+const benchDefaultCount = 1000
+
 type benchOptions struct {
 	profileMode     string
 	writeMode       string
@@ -39,6 +50,12 @@ type benchOptions struct {
 	pageSize        int
 	initialMmapSize int
 	deleteFraction  float64 // Fraction of keys of last tx to delete during writes. works only with "seq-del" write mode.
+	latency         bool
+	workers         int
+	duration        time.Duration // Run writes and reads for this long instead of a fixed iteration count.
+	readRatio       float64       // Fraction of operations that are reads in "rw-mixed" profile mode.
+	outputFormat    string
+	deadline        time.Time // End of the write phase of a duration-based run, set by runWrites.
 	explicitPath    bool
 }
 
@@ -48,23 +65,29 @@ type benchIO struct {
 }
 
 type BenchCmd struct {
-	ProfileMode     string  `name:"profile-mode" default:"rw" help:"Profiling mode: rw (writes then reads), r (reads only), w (writes only)."`
-	WriteMode       string  `name:"write-mode" default:"seq" enum:"seq,rnd,seq-nest,rnd-nest,seq-del" help:"Pattern used for write operations."`
-	ReadMode        string  `name:"read-mode" default:"seq" enum:"seq,rnd" help:"Pattern used for read operations."`
-	Count           int64   `name:"count" default:"1000" help:"Number of benchmark iterations."`
-	BatchSize       int64   `name:"batch-size" default:"0" help:"Batch size per transaction. Defaults to count when zero."`
-	KeySize         int     `name:"key-size" default:"8" help:"Size of keys in bytes."`
-	ValueSize       int     `name:"value-size" default:"32" help:"Size of values in bytes."`
-	CPUProfile      string  `name:"cpuprofile" help:"Write CPU profile to the specified file."`
-	MemProfile      string  `name:"memprofile" help:"Write heap profile to the specified file."`
-	BlockProfile    string  `name:"blockprofile" help:"Write block profile to the specified file."`
-	FillPercent     float64 `name:"fill-percent" default:"0.5" help:"Fill percentage used for buckets."`
-	NoSync          bool    `name:"no-sync" help:"Disable fsync for the destination database."`
-	Work            bool    `name:"work" help:"Keep the generated database file (implies printing its path)."`
-	Path            string  `name:"path" help:"Existing database file to benchmark; if omitted, a temporary file is created." type:"path"`
-	GoBenchOutput   bool    `name:"gobench-output" help:"Emit results in go test benchmark format."`
-	PageSize        int     `name:"page-size" default:"4096" help:"Database page size in bytes."`
-	InitialMmapSize int     `name:"initial-mmap-size" default:"0" help:"Initial mmap size in bytes for database file."`
+	ProfileMode     string        `name:"profile-mode" default:"rw" help:"Profiling mode: rw (writes then reads), r (reads only), w (writes only), rw-mixed (reads run concurrently with writes)."`
+	WriteMode       string        `name:"write-mode" default:"seq" enum:"seq,rnd,seq-nest,rnd-nest,seq-del" help:"Pattern used for write operations."`
+	ReadMode        string        `name:"read-mode" default:"seq" enum:"seq,rnd" help:"Pattern used for read operations."`
+	Count           int64         `name:"count" help:"Number of benchmark iterations. Defaults to 1000 unless --duration is set."`
+	BatchSize       int64         `name:"batch-size" default:"0" help:"Batch size per transaction. Defaults to count when zero."`
+	KeySize         int           `name:"key-size" default:"8" help:"Size of keys in bytes."`
+	ValueSize       int           `name:"value-size" default:"32" help:"Size of values in bytes."`
+	CPUProfile      string        `name:"cpuprofile" help:"Write CPU profile to the specified file."`
+	MemProfile      string        `name:"memprofile" help:"Write heap profile to the specified file."`
+	BlockProfile    string        `name:"blockprofile" help:"Write block profile to the specified file."`
+	FillPercent     float64       `name:"fill-percent" default:"0.5" help:"Fill percentage used for buckets."`
+	NoSync          bool          `name:"no-sync" help:"Disable fsync for the destination database."`
+	Work            bool          `name:"work" help:"Keep the generated database file (implies printing its path)."`
+	Path            string        `name:"path" help:"Existing database file to benchmark; if omitted, a temporary file is created." type:"path"`
+	GoBenchOutput   bool          `name:"gobench-output" help:"Emit results in go test benchmark format."`
+	PageSize        int           `name:"page-size" default:"4096" help:"Database page size in bytes."`
+	InitialMmapSize int           `name:"initial-mmap-size" default:"0" help:"Initial mmap size in bytes for database file."`
+	DeleteFraction  float64       `name:"delete-fraction" default:"0.1" help:"Fraction of the previous batch's keys deleted before each batch in seq-del write mode."`
+	Latency         bool          `name:"latency" help:"Record per-operation latencies and report p50/p90/p99/max. Timing every operation adds overhead."`
+	Workers         int           `name:"workers" default:"1" help:"Number of concurrent writer and reader goroutines. Each writer commits count/workers keys in transactions of batch-size."`
+	Duration        time.Duration `name:"duration" help:"Write, then read, for this long each instead of a fixed --count, e.g. 30s."`
+	ReadRatio       float64       `name:"read-ratio" default:"0.5" help:"Fraction of operations that are reads in rw-mixed profile mode."`
+	OutputFormat    string        `name:"output-format" default:"text" enum:"text,json,csv" help:"Output format for results: text|json|csv."`
 }
 
 func (c *BenchCmd) Run() error {
@@ -86,6 +109,12 @@ func (c *BenchCmd) Run() error {
 		goBenchOutput:   c.GoBenchOutput,
 		pageSize:        c.PageSize,
 		initialMmapSize: c.InitialMmapSize,
+		deleteFraction:  c.DeleteFraction,
+		latency:         c.Latency,
+		workers:         c.Workers,
+		duration:        c.Duration,
+		readRatio:       c.ReadRatio,
+		outputFormat:    c.OutputFormat,
 		explicitPath:    c.Path != "",
 	}
 
@@ -102,17 +131,48 @@ func (c *BenchCmd) Run() error {
 
 // Returns an error if `bench` options are not valid.
 func (o *benchOptions) Validate() error {
-	// Require that batch size can be evenly divided by the iteration count if set.
-	if o.batchSize > 0 && o.iterations%o.batchSize != 0 {
+	if o.duration != 0 {
+		if o.iterations != 0 {
+			return ErrBatchCountAndDuration
+		}
+		if o.duration < 0 {
+			return ErrBatchInvalidDuration
+		}
+	} else if o.iterations == 0 {
+		o.iterations = benchDefaultCount
+	}
+
+	if o.workers < 1 {
+		return ErrBatchInvalidWorkers
+	}
+	if o.iterations%int64(o.workers) != 0 {
+		return ErrBatchNonDivisibleWorkers
+	}
+
+	// Require that batch size can be evenly divided by the iteration count
+	// of each worker if set.
+	if o.batchSize > 0 && (o.iterations/int64(o.workers))%o.batchSize != 0 {
 		return ErrBatchNonDivisibleBatchSize
 	}
 
 	switch o.writeMode {
-	case "seq", "rnd", "seq-nest", "rnd-nest":
+	case "seq", "rnd", "seq-nest", "rnd-nest", "seq-del":
 	default:
 		return ErrBatchInvalidWriteMode
 	}
 
+	if o.deleteFraction < 0 || o.deleteFraction > 1 {
+		return ErrBatchInvalidDeleteFraction
+	}
+
+	if o.goBenchOutput && o.outputFormat != "" && o.outputFormat != "text" {
+		return ErrBatchConflictingOutput
+	}
+
+	if o.profileMode == "rw-mixed" && (o.readRatio <= 0 || o.readRatio >= 1) {
+		return ErrBatchInvalidReadRatio
+	}
+
 	// Generate temp path if one is not passed in.
 	if o.path == "" {
 		f, err := os.CreateTemp("", "bolt-bench-")
@@ -127,6 +187,25 @@ func (o *benchOptions) Validate() error {
 	return nil
 }
 
+// moreWrites reports whether a writer that has written i keys should start
+// another batch: until it reaches its iteration count, or in a duration-based
+// run until the deadline passes.
+func (o *benchOptions) moreWrites(i int64) bool {
+	if o.duration > 0 {
+		return time.Now().Before(o.deadline)
+	}
+	return i < o.iterations
+}
+
+// readDuration returns how long each read benchmark repeats its pass over the
+// data set.
+func (o *benchOptions) readDuration() time.Duration {
+	if o.duration > 0 {
+		return o.duration
+	}
+	return time.Second
+}
+
 // Sets the `bench` option values that are dependent on other options.
 func (o *benchOptions) SetOptionValues() error {
 	// Generate temp path if one is not passed in.
@@ -140,9 +219,12 @@ func (o *benchOptions) SetOptionValues() error {
 		o.path = f.Name()
 	}
 
-	// Set batch size to iteration size if not set.
+	// Set batch size to the iteration size of a worker if not set.
 	if o.batchSize == 0 {
-		o.batchSize = o.iterations
+		o.batchSize = o.iterations / int64(o.workers)
+		if o.duration > 0 {
+			o.batchSize = benchDefaultCount
+		}
 	}
 
 	return nil
@@ -161,16 +243,28 @@ func benchFunc(io benchIO, options *benchOptions) error {
 	dbOptions := *witchbolt.DefaultOptions
 	dbOptions.PageSize = options.pageSize
 	dbOptions.InitialMmapSize = options.initialMmapSize
+	dbOptions.NoSync = options.noSync
 	db, err := witchbolt.Open(options.path, 0600, &dbOptions)
 	if err != nil {
 		return err
 	}
-	db.NoSync = options.noSync
 	defer db.Close()
 
 	r := rand.New(rand.NewSource(time.Now().UnixNano()))
 
-	var writeResults benchResults
+	var writeResults, readResults benchResults
+	if options.latency {
+		writeResults.latency = &latencyHistogram{}
+		readResults.latency = &latencyHistogram{}
+	}
+
+	if options.profileMode == "rw-mixed" {
+		fmt.Fprintf(io.stderr, "starting mixed read-write benchmark.\n")
+		if err := runMixed(io, db, options, &writeResults, &readResults, r); err != nil {
+			return fmt.Errorf("bench: mixed: %s", err)
+		}
+		return printBenchResults(io, options, &writeResults, &readResults)
+	}
 
 	fmt.Fprintf(io.stderr, "starting write benchmark.\n")
 	keys, err := runWrites(io, db, options, &writeResults, r)
@@ -184,27 +278,55 @@ func benchFunc(io benchIO, options *benchOptions) error {
 		})
 	}
 
-	var readResults benchResults
+	// Reads of a duration-based run check against the keys actually written.
+	if options.duration > 0 {
+		options.iterations = writeResults.getCompletedOps()
+	}
+
 	fmt.Fprintf(io.stderr, "starting read benchmark.\n")
 	// Read from the database.
 	if err := runReads(io, db, options, &readResults, keys); err != nil {
 		return fmt.Errorf("bench: read: %s", err)
 	}
 
-	// Print results.
+	return printBenchResults(io, options, &writeResults, &readResults)
+}
+
+func printBenchResults(io benchIO, options *benchOptions, writeResults, readResults *benchResults) error {
+	switch options.outputFormat {
+	case "json":
+		out, err := json.MarshalIndent(benchReport{
+			Write: writeResults.phaseResult("write"),
+			Read:  readResults.phaseResult("read"),
+		}, "", "    ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(io.stdout, string(out))
+		return nil
+	case "csv":
+		w := csv.NewWriter(io.stdout)
+		_ = w.Write(benchCSVHeader)
+		_ = w.Write(writeResults.phaseResult("write").csvRecord())
+		_ = w.Write(readResults.phaseResult("read").csvRecord())
+		w.Flush()
+		return w.Error()
+	}
+
 	if options.goBenchOutput {
 		// below replicates the output of testing.B benchmarks, e.g. for external tooling
 		benchWriteName := "BenchmarkWrite"
 		benchReadName := "BenchmarkRead"
 		maxLen := max(len(benchReadName), len(benchWriteName))
-		printGoBenchResult(io.stdout, writeResults, maxLen, benchWriteName)
-		printGoBenchResult(io.stdout, readResults, maxLen, benchReadName)
+		printGoBenchResult(io.stdout, *writeResults, maxLen, benchWriteName)
+		printGoBenchResult(io.stdout, *readResults, maxLen, benchReadName)
 	} else {
 		fmt.Fprintf(io.stdout, "# Write\t%v(ops)\t%v\t(%v/op)\t(%v op/sec)\n", writeResults.getCompletedOps(), writeResults.getDuration(), writeResults.opDuration(), writeResults.opsPerSecond())
+		printLatencies(io.stdout, "Write", writeResults.latency)
 		fmt.Fprintf(io.stdout, "# Read\t%v(ops)\t%v\t(%v/op)\t(%v op/sec)\n", readResults.getCompletedOps(), readResults.getDuration(), readResults.opDuration(), readResults.opsPerSecond())
+		printLatencies(io.stdout, "Read", readResults.latency)
 	}
 	fmt.Fprintln(io.stdout, "")
-
 	return nil
 }
 
@@ -221,20 +343,24 @@ func runWrites(io benchIO, db *witchbolt.DB, options *benchOptions, results *ben
 	defer close(finishChan)
 
 	t := time.Now()
+	if options.duration > 0 {
+		options.deadline = t.Add(options.duration)
+	}
 
 	var keys []nestedKey
 	var err error
-	switch options.writeMode {
-	case "seq":
+	switch {
+	case options.workers > 1:
+		keys, err = runWritesConcurrent(io, db, options, results)
+	case options.writeMode == "seq":
 		keys, err = runWritesSequential(io, db, options, results)
-	case "rnd":
+	case options.writeMode == "rnd":
 		keys, err = runWritesRandom(io, db, options, results, r)
-	case "seq-nest":
+	case options.writeMode == "seq-nest":
 		keys, err = runWritesSequentialNested(io, db, options, results)
-	case "rnd-nest":
+	case options.writeMode == "rnd-nest":
 		keys, err = runWritesRandomNested(io, db, options, results, r)
-	case "seq-del":
-		options.deleteFraction = 0.1
+	case options.writeMode == "seq-del":
 		keys, err = runWritesSequentialAndDelete(io, db, options, results)
 	default:
 		return nil, fmt.Errorf("invalid write mode: %s", options.writeMode)
@@ -276,13 +402,58 @@ func runWritesRandomNested(io benchIO, db *witchbolt.DB, options *benchOptions,
 	return runWritesNestedWithSource(io, db, options, results, func() uint32 { return r.Uint32() })
 }
 
+// runWritesConcurrent splits the writes between options.workers goroutines.
+// Each commits its share of the iterations in transactions of
+// options.batchSize keys drawn from its own range of the key space, so
+// workers never write the same key.
+func runWritesConcurrent(io benchIO, db *witchbolt.DB, options *benchOptions, results *benchResults) ([]nestedKey, error) {
+	var write func(benchIO, *witchbolt.DB, *benchOptions, *benchResults, func() uint32) ([]nestedKey, error)
+	switch options.writeMode {
+	case "seq", "rnd":
+		write = runWritesWithSource
+	case "seq-nest", "rnd-nest":
+		write = runWritesNestedWithSource
+	case "seq-del":
+		write = runWritesDeletesWithSource
+	default:
+		return nil, fmt.Errorf("invalid write mode: %s", options.writeMode)
+	}
+	random := options.writeMode == "rnd" || options.writeMode == "rnd-nest"
+
+	workerOptions := *options
+	workerOptions.iterations = options.iterations / int64(options.workers)
+	span := uint32(math.MaxUint32 / uint64(options.workers))
+	workerKeys := make([][]nestedKey, options.workers)
+	var g errgroup.Group
+	for w := range options.workers {
+		base := uint32(w) * span
+		var keySource func() uint32
+		if random {
+			r := rand.New(rand.NewSource(time.Now().UnixNano() + int64(w)))
+			keySource = func() uint32 { return base + uint32(r.Int63n(int64(span))) }
+		} else {
+			i := base
+			keySource = func() uint32 { i++; return i }
+		}
+		g.Go(func() error {
+			keys, err := write(io, db, &workerOptions, results, keySource)
+			workerKeys[w] = keys
+			return err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return slices.Concat(workerKeys...), nil
+}
+
 func runWritesWithSource(io benchIO, db *witchbolt.DB, options *benchOptions, results *benchResults, keySource func() uint32) ([]nestedKey, error) {
 	var keys []nestedKey
 	if options.readMode == "rnd" {
 		keys = make([]nestedKey, 0, options.iterations)
 	}
 
-	for i := int64(0); i < options.iterations; i += options.batchSize {
+	for i := int64(0); options.moreWrites(i); i += options.batchSize {
 		if err := db.Update(func(tx *witchbolt.Tx) error {
 			b, _ := tx.CreateBucketIfNotExists(benchBucketName)
 			b.FillPercent = options.fillPercent
@@ -299,9 +470,11 @@ func runWritesWithSource(io benchIO, db *witchbolt.DB, options *benchOptions, re
 				binary.BigEndian.PutUint32(key, keySource())
 
 				// Insert key/value.
+				start := results.startOp()
 				if err := b.Put(key, value); err != nil {
 					return err
 				}
+				results.finishOp(start)
 				if keys != nil {
 					keyCopy := append([]byte(nil), key...)
 					keys = append(keys, nestedKey{nil, keyCopy})
@@ -323,7 +496,7 @@ func runWritesDeletesWithSource(io benchIO, db *witchbolt.DB, options *benchOpti
 	deleteSize := int64(math.Ceil(float64(options.batchSize) * options.deleteFraction))
 	var InsertedKeys [][]byte
 
-	for i := int64(0); i < options.iterations; i += options.batchSize {
+	for i := int64(0); options.moreWrites(i); i += options.batchSize {
 		if err := db.Update(func(tx *witchbolt.Tx) error {
 			b, _ := tx.CreateBucketIfNotExists(benchBucketName)
 			b.FillPercent = options.fillPercent
@@ -350,9 +523,11 @@ func runWritesDeletesWithSource(io benchIO, db *witchbolt.DB, options *benchOpti
 				InsertedKeys = append(InsertedKeys, append([]byte(nil), key...))
 
 				// Insert key/value.
+				start := results.startOp()
 				if err := b.Put(key, value); err != nil {
 					return err
 				}
+				results.finishOp(start)
 				if keys != nil {
 					keyCopy := append([]byte(nil), key...)
 					keys = append(keys, nestedKey{nil, keyCopy})
@@ -374,7 +549,7 @@ func runWritesNestedWithSource(io benchIO, db *witchbolt.DB, options *benchOptio
 		keys = make([]nestedKey, 0, options.iterations)
 	}
 
-	for i := int64(0); i < options.iterations; i += options.batchSize {
+	for i := int64(0); options.moreWrites(i); i += options.batchSize {
 		if err := db.Update(func(tx *witchbolt.Tx) error {
 			top, err := tx.CreateBucketIfNotExists(benchBucketName)
 			if err != nil {
@@ -410,9 +585,11 @@ func runWritesNestedWithSource(io benchIO, db *witchbolt.DB, options *benchOptio
 				binary.BigEndian.PutUint32(key, keySource())
 
 				// Insert value into subbucket.
+				start := results.startOp()
 				if err := b.Put(key, value); err != nil {
 					return err
 				}
+				results.finishOp(start)
 				if keys != nil {
 					keyCopy := append([]byte(nil), key...)
 					keys = append(keys, nestedKey{bucketCopy, keyCopy})
@@ -443,6 +620,34 @@ func runReads(io benchIO, db *witchbolt.DB, options *benchOptions, results *benc
 
 	t := time.Now()
 
+	var err error
+	if options.workers > 1 {
+		// Every worker reads the whole data set in its own transaction.
+		var g errgroup.Group
+		for range options.workers {
+			g.Go(func() error { return runReadsOnce(io, db, options, results, keys) })
+		}
+		err = g.Wait()
+	} else {
+		err = runReadsOnce(io, db, options, results, keys)
+	}
+
+	// Save read time.
+	results.setDuration(time.Since(t))
+
+	// Stop profiling for reads.
+	if options.profileMode == "rw" || options.profileMode == "r" {
+		if stopErr := stopProfiling(); stopErr != nil {
+			return stopErr
+		}
+	}
+
+	return err
+}
+
+// runReadsOnce runs the read benchmark selected by options on the calling
+// goroutine.
+func runReadsOnce(io benchIO, db *witchbolt.DB, options *benchOptions, results *benchResults, keys []nestedKey) error {
 	var err error
 	switch options.readMode {
 	case "seq":
@@ -462,21 +667,95 @@ func runReads(io benchIO, db *witchbolt.DB, options *benchOptions, results *benc
 	default:
 		return fmt.Errorf("invalid read mode: %s", options.readMode)
 	}
+	return err
+}
 
-	// Save read time.
-	results.setDuration(time.Since(t))
+type nestedKey struct{ bucket, key []byte }
 
-	// Stop profiling for reads.
-	if options.profileMode == "rw" || options.profileMode == "r" {
-		if stopErr := stopProfiling(); stopErr != nil {
-			return stopErr
-		}
+// mixedReadBatch is the most lookups a mixed-mode reader does in one read
+// transaction, so readers keep seeing new writes and do not pin old pages.
+const mixedReadBatch = 100
+
+// runMixed runs the write benchmark while options.workers readers look up
+// random keys, pacing the readers so reads make up options.readRatio of the
+// operations. Reads and writes are profiled together.
+func runMixed(io benchIO, db *witchbolt.DB, options *benchOptions, writeResults, readResults *benchResults, r *rand.Rand) error {
+	if err := startProfiling(options); err != nil {
+		return err
 	}
 
+	var done atomic.Bool
+	var g errgroup.Group
+	t := time.Now()
+	for range options.workers {
+		rr := rand.New(rand.NewSource(r.Int63()))
+		g.Go(func() error { return runMixedReads(db, options, writeResults, readResults, rr, &done) })
+	}
+	_, err := runWrites(io, db, options, writeResults, r)
+	done.Store(true)
+	if readErr := g.Wait(); err == nil {
+		err = readErr
+	}
+	readResults.setDuration(time.Since(t))
+
+	if stopErr := stopProfiling(); err == nil {
+		err = stopErr
+	}
 	return err
 }
 
-type nestedKey struct{ bucket, key []byte }
+// runMixedReads seeks to random keys up to the largest one written so far
+// until done is set, waiting whenever reads would exceed options.readRatio of
+// the operations. In nested write modes a lookup reads the first value of the
+// sub-bucket it lands on.
+func runMixedReads(db *witchbolt.DB, options *benchOptions, writeResults, readResults *benchResults, r *rand.Rand, done *atomic.Bool) error {
+	// The writers return their buffers to the pool before committing, while
+	// the pages still reference them, so readers must not draw from it.
+	key := make([]byte, options.keySize)
+
+	readsPerWrite := options.readRatio / (1 - options.readRatio)
+	mayRead := func() bool {
+		return float64(readResults.getCompletedOps()) < readsPerWrite*float64(writeResults.getCompletedOps())
+	}
+	for !done.Load() {
+		if !mayRead() {
+			time.Sleep(100 * time.Microsecond)
+			continue
+		}
+		if err := db.View(func(tx *witchbolt.Tx) error {
+			b := tx.Bucket(benchBucketName)
+			if b == nil {
+				return nil
+			}
+			c := b.Cursor()
+			last, _ := c.Last()
+			if last == nil {
+				return nil
+			}
+			maxKey := int64(binary.BigEndian.Uint32(last))
+			for i := 0; i < mixedReadBatch && mayRead(); i++ {
+				binary.BigEndian.PutUint32(key, uint32(r.Int63n(maxKey+1)))
+
+				start := readResults.startOp()
+				k, v := c.Seek(key)
+				if v == nil {
+					if nested := b.Bucket(k); nested != nil {
+						_, v = nested.Cursor().First()
+					}
+				}
+				readResults.finishOp(start)
+				if v == nil {
+					return ErrInvalidValue
+				}
+				readResults.addCompletedOps(1)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
 func runReadsSequential(io benchIO, db *witchbolt.DB, options *benchOptions, results *benchResults) error {
 	return db.View(func(tx *witchbolt.Tx) error {
@@ -488,11 +767,14 @@ func runReadsSequential(io benchIO, db *witchbolt.DB, options *benchOptions, res
 				defer func() { results.addCompletedOps(numReads) }()
 
 				c := tx.Bucket(benchBucketName).Cursor()
+				start := results.startOp()
 				for k, v := c.First(); k != nil; k, v = c.Next() {
+					results.finishOp(start)
 					numReads++
 					if v == nil {
 						return ErrInvalidValue
 					}
+					start = results.startOp()
 				}
 
 				return nil
@@ -506,8 +788,9 @@ func runReadsSequential(io benchIO, db *witchbolt.DB, options *benchOptions, res
 				return fmt.Errorf("read seq: iter mismatch: expected %d, got %d", options.iterations, numReads)
 			}
 
-			// Make sure we do this for at least a second.
-			if time.Since(t) >= time.Second {
+			// Make sure we do this for at least a second, or for the
+			// duration of the run if one is set.
+			if time.Since(t) >= options.readDuration() {
 				break
 			}
 		}
@@ -527,7 +810,9 @@ func runReadsRandom(io benchIO, db *witchbolt.DB, options *benchOptions, keys []
 
 				b := tx.Bucket(benchBucketName)
 				for _, key := range keys {
+					start := results.startOp()
 					v := b.Get(key.key)
+					results.finishOp(start)
 					numReads++
 					if v == nil {
 						return ErrInvalidValue
@@ -545,8 +830,9 @@ func runReadsRandom(io benchIO, db *witchbolt.DB, options *benchOptions, keys []
 				return fmt.Errorf("read seq: iter mismatch: expected %d, got %d", options.iterations, numReads)
 			}
 
-			// Make sure we do this for at least a second.
-			if time.Since(t) >= time.Second {
+			// Make sure we do this for at least a second, or for the
+			// duration of the run if one is set.
+			if time.Since(t) >= options.readDuration() {
 				break
 			}
 		}
@@ -566,11 +852,14 @@ func runReadsSequentialNested(io benchIO, db *witchbolt.DB, options *benchOption
 				defer func() { results.addCompletedOps(numReads) }()
 				if b := top.Bucket(name); b != nil {
 					c := b.Cursor()
+					start := results.startOp()
 					for k, v := c.First(); k != nil; k, v = c.Next() {
+						results.finishOp(start)
 						numReads++
 						if v == nil {
 							return ErrInvalidValue
 						}
+						start = results.startOp()
 					}
 				}
 				return nil
@@ -582,8 +871,9 @@ func runReadsSequentialNested(io benchIO, db *witchbolt.DB, options *benchOption
 				return fmt.Errorf("read seq-nest: iter mismatch: expected %d, got %d", options.iterations, numReads)
 			}
 
-			// Make sure we do this for at least a second.
-			if time.Since(t) >= time.Second {
+			// Make sure we do this for at least a second, or for the
+			// duration of the run if one is set.
+			if time.Since(t) >= options.readDuration() {
 				break
 			}
 		}
@@ -604,7 +894,9 @@ func runReadsRandomNested(io benchIO, db *witchbolt.DB, options *benchOptions, n
 				var top = tx.Bucket(benchBucketName)
 				for _, nestedKey := range nestedKeys {
 					if b := top.Bucket(nestedKey.bucket); b != nil {
+						start := results.startOp()
 						v := b.Get(nestedKey.key)
+						results.finishOp(start)
 						numReads++
 						if v == nil {
 							return ErrInvalidValue
@@ -623,8 +915,9 @@ func runReadsRandomNested(io benchIO, db *witchbolt.DB, options *benchOptions, n
 				return fmt.Errorf("read seq-nest: iter mismatch: expected %d, got %d", options.iterations, numReads)
 			}
 
-			// Make sure we do this for at least a second.
-			if time.Since(t) >= time.Second {
+			// Make sure we do this for at least a second, or for the
+			// duration of the run if one is set.
+			if time.Since(t) >= options.readDuration() {
 				break
 			}
 		}
@@ -731,6 +1024,25 @@ func stopProfiling() error {
 type benchResults struct {
 	completedOps int64
 	duration     int64
+
+	// latency, if set, records the duration of every operation.
+	latency *latencyHistogram
+}
+
+// startOp returns the start time of an operation, or the zero time when
+// latencies are not recorded, so the clock is only read with --latency.
+func (r *benchResults) startOp() time.Time {
+	if r.latency == nil {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+// finishOp records the latency of the operation started at start.
+func (r *benchResults) finishOp(start time.Time) {
+	if r.latency != nil {
+		r.latency.record(time.Since(start))
+	}
 }
 
 func (r *benchResults) addCompletedOps(amount int64) {
@@ -766,9 +1078,147 @@ func (r *benchResults) opsPerSecond() int {
 	return int(time.Second) / int(op)
 }
 
+// benchReport is the JSON form of the results of a benchmark.
+type benchReport struct {
+	Write benchPhaseResult `json:"write"`
+	Read  benchPhaseResult `json:"read"`
+}
+
+// benchPhaseResult holds the results of the write or read phase. Latency is
+// only set with --latency.
+type benchPhaseResult struct {
+	Phase      string        `json:"phase"`
+	Ops        int64         `json:"ops"`
+	DurationNs int64         `json:"durationNs"`
+	NsPerOp    int64         `json:"nsPerOp"`
+	OpsPerSec  int           `json:"opsPerSec"`
+	Latency    *benchLatency `json:"latency,omitempty"`
+}
+
+type benchLatency struct {
+	P50Ns int64 `json:"p50Ns"`
+	P90Ns int64 `json:"p90Ns"`
+	P99Ns int64 `json:"p99Ns"`
+	MaxNs int64 `json:"maxNs"`
+}
+
+var benchCSVHeader = []string{"phase", "ops", "duration_ns", "ns_per_op", "ops_per_sec", "p50_ns", "p90_ns", "p99_ns", "max_ns"}
+
+func (r *benchResults) phaseResult(phase string) benchPhaseResult {
+	result := benchPhaseResult{
+		Phase:      phase,
+		Ops:        r.getCompletedOps(),
+		DurationNs: int64(r.getDuration()),
+		NsPerOp:    int64(r.opDuration()),
+		OpsPerSec:  r.opsPerSecond(),
+	}
+	if h := r.latency; h != nil && h.count > 0 {
+		result.Latency = &benchLatency{
+			P50Ns: int64(h.percentile(50)),
+			P90Ns: int64(h.percentile(90)),
+			P99Ns: int64(h.percentile(99)),
+			MaxNs: int64(h.max),
+		}
+	}
+	return result
+}
+
+// csvRecord returns the row of r under benchCSVHeader, leaving the latency
+// columns empty when they were not recorded.
+func (r benchPhaseResult) csvRecord() []string {
+	record := []string{
+		r.Phase,
+		strconv.FormatInt(r.Ops, 10),
+		strconv.FormatInt(r.DurationNs, 10),
+		strconv.FormatInt(r.NsPerOp, 10),
+		strconv.Itoa(r.OpsPerSec),
+		"", "", "", "",
+	}
+	if l := r.Latency; l != nil {
+		for i, v := range []int64{l.P50Ns, l.P90Ns, l.P99Ns, l.MaxNs} {
+			record[5+i] = strconv.FormatInt(v, 10)
+		}
+	}
+	return record
+}
+
 func printGoBenchResult(w io.Writer, r benchResults, maxLen int, benchName string) {
 	gobenchResult := testing.BenchmarkResult{}
 	gobenchResult.T = r.getDuration()
 	gobenchResult.N = int(r.getCompletedOps())
+	if h := r.latency; h != nil && h.count > 0 {
+		gobenchResult.Extra = map[string]float64{
+			"p50-ns": float64(h.percentile(50)),
+			"p90-ns": float64(h.percentile(90)),
+			"p99-ns": float64(h.percentile(99)),
+			"max-ns": float64(h.max),
+		}
+	}
 	fmt.Fprintf(w, "%-*s\t%s\n", maxLen, benchName, gobenchResult.String())
 }
+
+// printLatencies prints the latency percentiles recorded in h, if any.
+func printLatencies(w io.Writer, name string, h *latencyHistogram) {
+	if h == nil || h.count == 0 {
+		return
+	}
+	fmt.Fprintf(w, "# %s latency\tp50 %v\tp90 %v\tp99 %v\tmax %v\n", name, h.percentile(50), h.percentile(90), h.percentile(99), h.max)
+}
+
+// latencySubBits sets the precision of latencyHistogram: each power of two
+// is split into 2^latencySubBits linear buckets, bounding the error of a
+// reported percentile to about 3%.
+const latencySubBits = 5
+
+// latencyHistogram is an HDR-style log-linear histogram of durations. It
+// uses a fixed 16KiB regardless of the number of operations recorded.
+type latencyHistogram struct {
+	mu     sync.Mutex // Serializes records from concurrent workers.
+	counts [(64 - latencySubBits + 1) << latencySubBits]int64
+	count  int64
+	max    time.Duration
+}
+
+func (h *latencyHistogram) record(d time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.counts[latencyBucket(uint64(max(d, 0)))]++
+	h.count++
+	h.max = max(h.max, d)
+}
+
+// percentile returns the smallest recorded bucket value that at least p
+// percent of the operations did not exceed, capped at the maximum.
+func (h *latencyHistogram) percentile(p float64) time.Duration {
+	rank := int64(math.Ceil(float64(h.count) * p / 100))
+	var seen int64
+	for i, n := range h.counts {
+		seen += n
+		if n > 0 && seen >= rank {
+			return min(time.Duration(latencyBucketMax(i)), h.max)
+		}
+	}
+	return h.max
+}
+
+// latencyBucket returns the bucket of v nanoseconds. Values below
+// 2^latencySubBits have a bucket each; larger ones share a bucket with the
+// values equal to them in their top latencySubBits+1 bits.
+func latencyBucket(v uint64) int {
+	if v < 1<<latencySubBits {
+		return int(v)
+	}
+	shift := bits.Len64(v) - 1 - latencySubBits
+	sub := v>>shift - 1<<latencySubBits
+	return (shift+1)<<latencySubBits + int(sub)
+}
+
+// latencyBucketMax returns the largest value in bucket i.
+func latencyBucketMax(i int) uint64 {
+	if i < 1<<latencySubBits {
+		return uint64(i)
+	}
+	shift := i>>latencySubBits - 1
+	sub := uint64(i & (1<<latencySubBits - 1))
+	return (1<<latencySubBits+sub+1)<<shift - 1
+}
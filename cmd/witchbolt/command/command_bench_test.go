@@ -1,9 +1,14 @@
 package command_test
 
 import (
+	"encoding/csv"
+	"encoding/json"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/delaneyj/witchbolt/cmd/witchbolt/command"
 )
 
 // Ensure the "bench" command runs and exits without errors
@@ -26,3 +31,125 @@ func TestBenchCommand_Run(t *testing.T) {
 		})
 	}
 }
+
+func TestBenchCommand_SeqDel(t *testing.T) {
+	res := runCLI(t, "bench", "--write-mode", "seq-del", "--count", "1000", "--batch-size", "100", "--delete-fraction", "0.5")
+	require.NoError(t, res.err)
+	require.Contains(t, res.stderr, "Starting delete iteration 100, deleteSize: 50")
+	require.Contains(t, res.stdout, "# Write")
+	require.Contains(t, res.stdout, "# Read")
+
+	t.Log("Rejecting a fraction outside [0, 1]")
+	res = runCLI(t, "bench", "--write-mode", "seq-del", "--delete-fraction", "1.5")
+	require.ErrorIs(t, res.err, command.ErrBatchInvalidDeleteFraction)
+}
+
+func TestBenchCommand_Latency(t *testing.T) {
+	res := runCLI(t, "bench", "--count", "1000", "--latency")
+	require.NoError(t, res.err)
+	require.Regexp(t, `# Write latency\tp50 \S+\tp90 \S+\tp99 \S+\tmax \S+\n`, res.stdout)
+	require.Regexp(t, `# Read latency\tp50 \S+\tp90 \S+\tp99 \S+\tmax \S+\n`, res.stdout)
+
+	t.Log("Appending the percentiles as custom metrics in gobench output")
+	res = runCLI(t, "bench", "--count", "1000", "--latency", "--gobench-output")
+	require.NoError(t, res.err)
+	require.Regexp(t, `BenchmarkWrite\s+1000\s+[\d.]+ ns/op\s+[\d.]+ max-ns\s+[\d.]+ p50-ns\s+[\d.]+ p90-ns\s+[\d.]+ p99-ns`, res.stdout)
+	require.Regexp(t, `BenchmarkRead\s+\d+\s+[\d.]+ ns/op\s+[\d.]+ max-ns`, res.stdout)
+
+	t.Log("Leaving the output unchanged without --latency")
+	res = runCLI(t, "bench", "--count", "1000")
+	require.NoError(t, res.err)
+	require.NotContains(t, res.stdout, "latency")
+}
+
+func TestBenchCommand_Workers(t *testing.T) {
+	for _, mode := range []string{"seq", "rnd", "seq-nest", "rnd-nest", "seq-del"} {
+		t.Run(mode, func(t *testing.T) {
+			res := runCLI(t, "bench", "--workers", "4", "--write-mode", mode, "--read-mode", "rnd", "--count", "1000", "--batch-size", "50", "--latency")
+			require.NoError(t, res.err)
+			require.NotContains(t, res.stderr, "iter mismatch")
+			require.Contains(t, res.stdout, "# Write\t1000(ops)")
+			require.Contains(t, res.stdout, "# Write latency")
+		})
+	}
+
+	t.Log("Sequential reads see every worker's keys")
+	res := runCLI(t, "bench", "--workers", "4", "--count", "1000", "--batch-size", "25")
+	require.NoError(t, res.err)
+	require.NotContains(t, res.stderr, "iter mismatch")
+
+	t.Log("Rejecting counts that do not split between the workers")
+	res = runCLI(t, "bench", "--workers", "3", "--count", "1000")
+	require.ErrorIs(t, res.err, command.ErrBatchNonDivisibleWorkers)
+	res = runCLI(t, "bench", "--workers", "4", "--count", "1000", "--batch-size", "500")
+	require.ErrorIs(t, res.err, command.ErrBatchNonDivisibleBatchSize)
+	res = runCLI(t, "bench", "--workers", "0")
+	require.ErrorIs(t, res.err, command.ErrBatchInvalidWorkers)
+}
+
+func TestBenchCommand_Duration(t *testing.T) {
+	for _, mode := range []string{"seq", "seq-nest"} {
+		t.Run(mode, func(t *testing.T) {
+			res := runCLI(t, "bench", "--duration", "200ms", "--write-mode", mode, "--batch-size", "100")
+			require.NoError(t, res.err)
+			require.NotContains(t, res.stderr, "iter mismatch")
+			require.Regexp(t, `# Write\t[1-9]\d*00\(ops\)\t`, res.stdout)
+			require.Regexp(t, `# Read\t[1-9]\d*\(ops\)\t`, res.stdout)
+		})
+	}
+
+	t.Log("Rejecting --count together with --duration")
+	res := runCLI(t, "bench", "--count", "1000", "--duration", "1s")
+	require.ErrorIs(t, res.err, command.ErrBatchCountAndDuration)
+}
+
+func TestBenchCommand_RWMixed(t *testing.T) {
+	for _, mode := range []string{"seq", "rnd", "seq-nest"} {
+		t.Run(mode, func(t *testing.T) {
+			res := runCLI(t, "bench", "--profile-mode", "rw-mixed", "--write-mode", mode, "--count", "20000", "--batch-size", "100", "--workers", "2", "--read-ratio", "0.8")
+			require.NoError(t, res.err)
+			require.Contains(t, res.stderr, "starting mixed read-write benchmark.")
+			require.NotContains(t, res.stderr, "starting read benchmark.")
+			require.Contains(t, res.stdout, "# Write\t20000(ops)")
+			require.Regexp(t, `# Read\t[1-9]\d*\(ops\)`, res.stdout)
+		})
+	}
+
+	t.Log("Rejecting a read ratio outside (0, 1)")
+	res := runCLI(t, "bench", "--profile-mode", "rw-mixed", "--read-ratio", "1")
+	require.ErrorIs(t, res.err, command.ErrBatchInvalidReadRatio)
+}
+
+func TestBenchCommand_OutputFormat(t *testing.T) {
+	t.Log("JSON reports both phases, with percentiles when --latency is set")
+	res := runCLI(t, "bench", "--count", "1000", "--output-format", "json", "--latency")
+	require.NoError(t, res.err)
+	var report struct {
+		Write, Read struct {
+			Phase     string
+			Ops       int64
+			OpsPerSec int
+			Latency   *struct{ P50Ns, MaxNs int64 }
+		}
+	}
+	require.NoError(t, json.Unmarshal([]byte(res.stdout), &report))
+	require.Equal(t, "write", report.Write.Phase)
+	require.EqualValues(t, 1000, report.Write.Ops)
+	require.Positive(t, report.Read.Ops)
+	require.NotNil(t, report.Read.Latency)
+	require.LessOrEqual(t, report.Read.Latency.P50Ns, report.Read.Latency.MaxNs)
+
+	t.Log("CSV has a header and a row per phase")
+	res = runCLI(t, "bench", "--count", "1000", "--output-format", "csv")
+	require.NoError(t, res.err)
+	records, err := csv.NewReader(strings.NewReader(res.stdout)).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	require.Equal(t, []string{"phase", "ops", "duration_ns", "ns_per_op", "ops_per_sec", "p50_ns", "p90_ns", "p99_ns", "max_ns"}, records[0])
+	require.Equal(t, []string{"write", "1000"}, records[1][:2])
+	require.Equal(t, "read", records[2][0])
+	require.Equal(t, []string{"", "", "", ""}, records[1][5:], "latency columns are empty without --latency")
+
+	res = runCLI(t, "bench", "--output-format", "json", "--gobench-output")
+	require.ErrorIs(t, res.err, command.ErrBatchConflictingOutput)
+}
@@ -0,0 +1,398 @@
+package command
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+
+	"github.com/delaneyj/witchbolt"
+)
+
+// BrowseCmd is an interactive database browser. On a terminal it reads
+// keystrokes in raw mode; otherwise it reads them from stdin as a stream, so
+// a session can be scripted.
+type BrowseCmd struct {
+	Path string `arg:"" help:"Path to witchbolt database file" type:"path"`
+}
+
+// browseValueWidth is how much of a value the item list shows.
+const browseValueWidth = 60
+
+func (c *BrowseCmd) Run() error {
+	if _, err := checkSourceDBPath(c.Path); err != nil {
+		return err
+	}
+
+	db, err := witchbolt.Open(c.Path, 0600, &witchbolt.Options{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	m := &browseModel{db: db, height: 20}
+	if err := m.load(); err != nil {
+		return err
+	}
+
+	fd := int(os.Stdin.Fd())
+	raw := term.IsTerminal(fd)
+	if raw {
+		state, err := term.MakeRaw(fd)
+		if err != nil {
+			return err
+		}
+		defer term.Restore(fd, state)
+		if _, rows, err := term.GetSize(fd); err == nil {
+			m.height = max(rows-4, 1)
+		}
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	buf := make([]byte, 256)
+	for {
+		screen := m.view()
+		if raw {
+			// Raw mode doesn't turn \n into \r\n.
+			screen = strings.ReplaceAll(screen, "\n", "\r\n")
+		}
+		fmt.Fprint(out, "\x1b[H\x1b[2J", screen)
+		if err := out.Flush(); err != nil {
+			return err
+		}
+
+		n, err := os.Stdin.Read(buf)
+		for _, key := range decodeBrowseKeys(buf[:n]) {
+			m.update(key)
+			if m.quit {
+				return nil
+			}
+		}
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// browseItem is an entry of the bucket being browsed.
+type browseItem struct {
+	key      []byte
+	value    []byte
+	isBucket bool
+}
+
+// browseModel is the state of the browser: the bucket being browsed, the
+// selected item and the export prompt. update applies a key to it and view
+// renders it, so the model can be driven without a terminal.
+type browseModel struct {
+	db *witchbolt.DB
+
+	path   [][]byte // the bucket being browsed, empty for the root
+	items  []browseItem
+	cursor int
+	offset int // first item shown
+	height int // number of items shown at once
+
+	prompting bool
+	input     []rune
+	status    string
+	quit      bool
+}
+
+// load reads the items of the bucket at m.path.
+func (m *browseModel) load() error {
+	m.items = m.items[:0]
+	return m.db.View(func(tx *witchbolt.Tx) error {
+		if len(m.path) == 0 {
+			return forEachUserBucket(tx, func(name []byte, _ *witchbolt.Bucket) error {
+				m.items = append(m.items, browseItem{key: bytes.Clone(name), isBucket: true})
+				return nil
+			})
+		}
+		b, err := m.bucket(tx)
+		if err != nil {
+			return err
+		}
+		return b.ForEach(func(k, v []byte) error {
+			m.items = append(m.items, browseItem{key: bytes.Clone(k), value: bytes.Clone(v), isBucket: v == nil})
+			return nil
+		})
+	})
+}
+
+// bucket returns the bucket at m.path in tx.
+func (m *browseModel) bucket(tx *witchbolt.Tx) (*witchbolt.Bucket, error) {
+	names := make([]string, len(m.path))
+	for i, name := range m.path {
+		names[i] = string(name)
+	}
+	return findLastBucket(tx, names)
+}
+
+// update applies a key decoded by decodeBrowseKeys.
+func (m *browseModel) update(key string) {
+	if m.prompting {
+		m.updatePrompt(key)
+		return
+	}
+	m.status = ""
+	switch key {
+	case "up", "k":
+		m.move(-1)
+	case "down", "j":
+		m.move(1)
+	case "enter", "right", "l":
+		if len(m.items) == 0 || !m.items[m.cursor].isBucket {
+			return
+		}
+		m.path = append(m.path, m.items[m.cursor].key)
+		m.cursor, m.offset = 0, 0
+		m.reload()
+	case "left", "backspace", "h":
+		if len(m.path) == 0 {
+			return
+		}
+		from := m.path[len(m.path)-1]
+		m.path = m.path[:len(m.path)-1]
+		m.cursor, m.offset = 0, 0
+		m.reload()
+		for i, item := range m.items {
+			if bytes.Equal(item.key, from) {
+				m.move(i)
+				break
+			}
+		}
+	case "e":
+		m.prompting, m.input = true, nil
+	case "q", "ctrl+c":
+		m.quit = true
+	}
+}
+
+func (m *browseModel) updatePrompt(key string) {
+	switch key {
+	case "enter":
+		m.prompting = false
+		if len(m.input) == 0 {
+			m.status = "export cancelled"
+			return
+		}
+		buckets, keys, err := m.export(string(m.input))
+		if err != nil {
+			m.status = fmt.Sprintf("export failed: %v", err)
+			return
+		}
+		m.status = fmt.Sprintf("exported %d buckets and %d keys to %s", buckets, keys, string(m.input))
+	case "esc", "ctrl+c":
+		m.prompting = false
+		m.status = "export cancelled"
+	case "backspace":
+		if len(m.input) > 0 {
+			m.input = m.input[:len(m.input)-1]
+		}
+	default:
+		if r, size := utf8.DecodeRuneInString(key); size == len(key) && unicode.IsPrint(r) {
+			m.input = append(m.input, r)
+		}
+	}
+}
+
+// reload is load, reporting a failure in the status line.
+func (m *browseModel) reload() {
+	if err := m.load(); err != nil {
+		m.status = err.Error()
+	}
+}
+
+// move moves the cursor by delta items, scrolling to keep it in view.
+func (m *browseModel) move(delta int) {
+	m.cursor = min(max(m.cursor+delta, 0), max(len(m.items)-1, 0))
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	} else if m.cursor >= m.offset+m.height {
+		m.offset = m.cursor - m.height + 1
+	}
+}
+
+func (m *browseModel) view() string {
+	var sb strings.Builder
+	names := make([]string, len(m.path))
+	for i, name := range m.path {
+		names[i] = bytesToAsciiOrHex(name)
+	}
+	fmt.Fprintf(&sb, "%s: /%s\n\n", m.db.Path(), strings.Join(names, "/"))
+
+	if len(m.items) == 0 {
+		sb.WriteString("  (empty)\n")
+	}
+	for i := m.offset; i < len(m.items) && i < m.offset+m.height; i++ {
+		item := m.items[i]
+		marker := "  "
+		if i == m.cursor {
+			marker = "> "
+		}
+		if item.isBucket {
+			fmt.Fprintf(&sb, "%s%s/\n", marker, bytesToAsciiOrHex(item.key))
+			continue
+		}
+		value := bytesToAsciiOrHex(item.value)
+		if len(value) > browseValueWidth {
+			value = value[:browseValueWidth] + "..."
+		}
+		fmt.Fprintf(&sb, "%s%s = %s\n", marker, bytesToAsciiOrHex(item.key), value)
+	}
+
+	sb.WriteString("\n")
+	switch {
+	case m.prompting:
+		fmt.Fprintf(&sb, "Export to (.json or .csv): %s", string(m.input))
+	case m.status != "":
+		sb.WriteString(m.status)
+	default:
+		sb.WriteString("up/down move  enter open  left back  e export  q quit")
+	}
+	return sb.String()
+}
+
+// export writes the bucket being browsed, or every bucket at the root, to a
+// new file at path. A .csv path gets one row per key; anything else gets a
+// json archive that import reads back.
+func (m *browseModel) export(path string) (buckets, keys int, err error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to create output file %q: %w", path, err)
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	w := bufio.NewWriter(f)
+
+	doc := newExportDocument()
+	err = m.db.View(func(tx *witchbolt.Tx) error {
+		if len(m.path) == 0 {
+			return forEachUserBucket(tx, func(name []byte, b *witchbolt.Bucket) error {
+				doc.Buckets = append(doc.Buckets, exportBucketTree(name, b, &buckets, &keys))
+				return nil
+			})
+		}
+		b, err := m.bucket(tx)
+		if err != nil {
+			return err
+		}
+		doc.Buckets = append(doc.Buckets, exportBucketTree(m.path[len(m.path)-1], b, &buckets, &keys))
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		var parent []string
+		for _, name := range m.path[:max(len(m.path)-1, 0)] {
+			parent = append(parent, csvField(newExportBytes(name)))
+		}
+		err = writeExportCSV(w, strings.Join(parent, "/"), doc.Buckets)
+	} else {
+		err = writeExportDocument(w, "json", doc)
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	return buckets, keys, w.Flush()
+}
+
+// writeExportCSV writes a bucket,key,value row for every key of buckets,
+// which are nested in the bucket at parent. The bucket column is the path
+// of the key's bucket, joined with slashes as in buckets --recursive. Fields
+// are text when printable and "base64:" followed by their base64 encoding
+// otherwise.
+func writeExportCSV(w io.Writer, parent string, buckets []*exportBucket) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"bucket", "key", "value"}); err != nil {
+		return err
+	}
+	var walk func(parent string, b *exportBucket) error
+	walk = func(parent string, b *exportBucket) error {
+		path := csvField(b.Name)
+		if parent != "" {
+			path = parent + "/" + path
+		}
+		for _, e := range b.Entries {
+			if err := cw.Write([]string{path, csvField(e.Key), csvField(e.Value)}); err != nil {
+				return err
+			}
+		}
+		for _, child := range b.Buckets {
+			if err := walk(path, child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, b := range buckets {
+		if err := walk(parent, b); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func csvField(e exportBytes) string {
+	if e.Encoding == "base64" {
+		return "base64:" + e.Data
+	}
+	return e.Data
+}
+
+// decodeBrowseKeys splits the bytes of a read from the terminal into keys:
+// "up", "down", "left", "right", "enter", "backspace", "esc", "ctrl+c" or a
+// single character.
+func decodeBrowseKeys(b []byte) []string {
+	var keys []string
+	for len(b) > 0 {
+		switch {
+		case bytes.HasPrefix(b, []byte("\x1b[")) && len(b) >= 3:
+			switch b[2] {
+			case 'A':
+				keys = append(keys, "up")
+			case 'B':
+				keys = append(keys, "down")
+			case 'C':
+				keys = append(keys, "right")
+			case 'D':
+				keys = append(keys, "left")
+			}
+			b = b[3:]
+			continue
+		case b[0] == 0x1b:
+			keys = append(keys, "esc")
+		case b[0] == '\r' || b[0] == '\n':
+			keys = append(keys, "enter")
+		case b[0] == 0x7f || b[0] == '\b':
+			keys = append(keys, "backspace")
+		case b[0] == 0x03:
+			keys = append(keys, "ctrl+c")
+		default:
+			r, size := utf8.DecodeRune(b)
+			keys = append(keys, string(r))
+			b = b[size:]
+			continue
+		}
+		b = b[1:]
+	}
+	return keys
+}
@@ -0,0 +1,94 @@
+package command_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// browse runs the browse command on path, typing keys on stdin.
+func browse(t *testing.T, path string, keys string) cliResult {
+	t.Helper()
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	_, err = w.WriteString(keys)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	stdin := os.Stdin
+	os.Stdin = r
+	defer func() {
+		os.Stdin = stdin
+		r.Close()
+	}()
+	return runCLI(t, "browse", path)
+}
+
+func TestBrowseCommand_Navigate(t *testing.T) {
+	path := exportTestDB(t)
+	defer requireDBNoChange(t, dbData(t, path), path)
+
+	t.Log("Opening foo lists its nested bucket and keys")
+	res := browse(t, path, "\r")
+	require.NoError(t, res.err)
+	require.Contains(t, res.stdout, path+": /foo\n\n> nested/\n  text = hello\n")
+
+	t.Log("Opening a key does nothing, backing out selects the bucket left")
+	res = browse(t, path, "\rj\r\x1b[Dh")
+	require.NoError(t, res.err)
+	require.Contains(t, res.stdout, path+": /\n\n> foo/\n")
+
+	t.Log("q quits before the rest of the input")
+	res = browse(t, path, "q\r")
+	require.NoError(t, res.err)
+	require.NotContains(t, res.stdout, "/foo")
+}
+
+func TestBrowseCommand_Export(t *testing.T) {
+	path := exportTestDB(t)
+	defer requireDBNoChange(t, dbData(t, path), path)
+	dir := t.TempDir()
+
+	t.Log("A json export of the root is an archive import reads back")
+	out := filepath.Join(dir, "browse.json")
+	res := browse(t, path, "e"+out+"\r")
+	require.NoError(t, res.err)
+	require.Contains(t, res.stdout, "exported 2 buckets and 2 keys to "+out)
+	want := filepath.Join(dir, "export.json")
+	require.NoError(t, runCLI(t, "export", path, "-o", want).err)
+	got, err := os.ReadFile(out)
+	require.NoError(t, err)
+	exported, err := os.ReadFile(want)
+	require.NoError(t, err)
+	require.Equal(t, string(exported), string(got), "the browser writes the same archive as export")
+	imported := filepath.Join(dir, "imported.db")
+	require.NoError(t, runCLI(t, "import", "-i", out, "-o", imported).err)
+	srcChk, err := chkdb(path)
+	require.NoError(t, err)
+	dstChk, err := chkdb(imported)
+	require.NoError(t, err)
+	require.Equal(t, srcChk, dstChk)
+
+	t.Log("A csv export of a nested bucket has a row per key")
+	out = filepath.Join(dir, "nested.csv")
+	res = browse(t, path, "\r\re"+out+"\r")
+	require.NoError(t, res.err)
+	require.Contains(t, res.stdout, "exported 1 buckets and 1 keys to "+out)
+	got, err = os.ReadFile(out)
+	require.NoError(t, err)
+	require.Equal(t, "bucket,key,value\nfoo/nested,binary,base64:AAH/\n", string(got))
+
+	t.Log("The prompt edits the path and refuses to overwrite a file")
+	res = browse(t, path, "e"+out+"x\x7f\r")
+	require.NoError(t, res.err)
+	require.Contains(t, res.stdout, "export failed: failed to create output file")
+	require.Contains(t, res.stdout, "file exists")
+
+	t.Log("Escape cancels the export")
+	res = browse(t, path, "e"+filepath.Join(dir, "never.json")+"\x1b")
+	require.NoError(t, res.err)
+	require.Contains(t, res.stdout, "export cancelled")
+	require.NoFileExists(t, filepath.Join(dir, "never.json"))
+}
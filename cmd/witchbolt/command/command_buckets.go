@@ -7,7 +7,9 @@ import (
 )
 
 type BucketsCmd struct {
-	Path string `arg:"" help:"Path to witchbolt database file" type:"path"`
+	Path      string `arg:"" help:"Path to witchbolt database file" type:"path"`
+	Recursive bool   `short:"r" help:"Also list nested buckets by their full path"`
+	Separator string `default:"/" help:"Separator between the bucket names of a path with --recursive"`
 }
 
 func (c *BucketsCmd) Run() error {
@@ -17,8 +19,9 @@ func (c *BucketsCmd) Run() error {
 
 	// Open database.
 	db, err := witchbolt.Open(c.Path, 0600, &witchbolt.Options{
-		ReadOnly:        true,
-		PreLoadFreelist: true,
+		ReadOnly:          true,
+		PreLoadFreelist:   true,
+		OnFreelistRebuild: freelistRebuildNotice(c.Path),
 	})
 	if err != nil {
 		return err
@@ -27,9 +30,21 @@ func (c *BucketsCmd) Run() error {
 
 	// Print buckets.
 	return db.View(func(tx *witchbolt.Tx) error {
-		return tx.ForEach(func(name []byte, _ *witchbolt.Bucket) error {
+		return forEachUserBucket(tx, func(name []byte, b *witchbolt.Bucket) error {
 			fmt.Println(string(name))
+			if c.Recursive {
+				return c.printNested(string(name), b)
+			}
 			return nil
 		})
 	})
 }
+
+// printNested prints the path of every bucket nested in b, parents first.
+func (c *BucketsCmd) printNested(path string, b *witchbolt.Bucket) error {
+	return b.ForEachBucket(func(name []byte) error {
+		childPath := path + c.Separator + string(name)
+		fmt.Println(childPath)
+		return c.printNested(childPath, b.Bucket(name))
+	})
+}
@@ -59,3 +59,50 @@ func TestBucketsCommand_Run(t *testing.T) {
 		})
 	}
 }
+
+func TestBucketsCommand_Recursive(t *testing.T) {
+	db := btesting.MustCreateDB(t)
+	require.NoError(t, db.Update(func(tx *witchbolt.Tx) error {
+		foo, err := tx.CreateBucket([]byte("foo"))
+		if err != nil {
+			return err
+		}
+		if err := foo.Put([]byte("key"), []byte("value")); err != nil {
+			return err
+		}
+		bar, err := foo.CreateBucket([]byte("bar"))
+		if err != nil {
+			return err
+		}
+		if _, err := bar.CreateBucket([]byte("baz")); err != nil {
+			return err
+		}
+		if _, err := foo.CreateBucket([]byte("qux")); err != nil {
+			return err
+		}
+		_, err = tx.CreateBucket([]byte("top"))
+		return err
+	}))
+	db.Close()
+	defer requireDBNoChange(t, dbData(t, db.Path()), db.Path())
+
+	res := runCLI(t, "buckets", db.Path())
+	require.NoError(t, res.err)
+	require.Equal(t, "foo\ntop\n", res.stdout, "the flat listing stays the default")
+
+	res = runCLI(t, "buckets", "--recursive", db.Path())
+	require.NoError(t, res.err)
+	require.Equal(t, "foo\nfoo/bar\nfoo/bar/baz\nfoo/qux\ntop\n", res.stdout)
+
+	res = runCLI(t, "buckets", "-r", "--separator", ".", db.Path())
+	require.NoError(t, res.err)
+	require.Equal(t, "foo\nfoo.bar\nfoo.bar.baz\nfoo.qux\ntop\n", res.stdout)
+}
+
+func TestBucketsCommand_SkipsStreamBucket(t *testing.T) {
+	dbPath, _ := replicateSampleDB(t, 1)
+
+	res := runCLI(t, "buckets", dbPath)
+	require.NoError(t, res.err)
+	require.Equal(t, "widgets\n", res.stdout, "the stream identity bucket is not listed")
+}
@@ -1,7 +1,12 @@
 package command
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
 
 	"github.com/delaneyj/witchbolt"
 	"github.com/delaneyj/witchbolt/internal/guts_cli"
@@ -10,6 +15,71 @@ import (
 type CheckCmd struct {
 	Path       string `arg:"" help:"Path to witchbolt database file" type:"path"`
 	FromPageID uint64 `help:"Check db integrity starting from the given page ID"`
+	JSON       bool   `name:"json" help:"Print the errors and a summary as JSON."`
+	Progress   bool   `help:"Print the pages checked out of the high water mark to stderr"`
+}
+
+// checkJSON is the JSON output of the check command.
+type checkJSON struct {
+	Errors  []checkErrorJSON `json:"errors"`
+	Summary checkSummaryJSON `json:"summary"`
+}
+
+// checkErrorJSON is a single consistency error. Page is the page the message
+// names, if any. Every problem tx.Check reports is an error, so Severity is
+// always "error" for now.
+type checkErrorJSON struct {
+	Page     *uint64 `json:"page,omitempty"`
+	Message  string  `json:"message"`
+	Severity string  `json:"severity"`
+}
+
+type checkSummaryJSON struct {
+	ErrorCount int  `json:"errorCount"`
+	OK         bool `json:"ok"`
+}
+
+// checkErrorPage matches the page ID in the messages of tx.Check.
+var checkErrorPage = regexp.MustCompile(`page (\d+):|page ID \((\d+)\)|page\((\d+)\)|pgId:(\d+)`)
+
+func newCheckErrorJSON(err error) checkErrorJSON {
+	e := checkErrorJSON{Message: err.Error(), Severity: "error"}
+	if m := checkErrorPage.FindStringSubmatch(e.Message); m != nil {
+		for _, id := range m[1:] {
+			if pgid, err := strconv.ParseUint(id, 10, 64); err == nil {
+				e.Page = &pgid
+				break
+			}
+		}
+	}
+	return e
+}
+
+// checkProgressPrinter renders check progress as a single line, rewritten in
+// place with a carriage return whenever the percentage changes.
+type checkProgressPrinter struct {
+	out     io.Writer
+	percent int
+}
+
+func newCheckProgressPrinter(out io.Writer) *checkProgressPrinter {
+	return &checkProgressPrinter{out: out, percent: -1}
+}
+
+func (p *checkProgressPrinter) update(done, total uint64) {
+	percent := int(done * 100 / max(total, 1))
+	if percent == p.percent {
+		return
+	}
+	p.percent = percent
+	fmt.Fprintf(p.out, "\rchecked %d/%d pages (%d%%)", done, total, percent)
+}
+
+// finish ends the progress line.
+func (p *checkProgressPrinter) finish() {
+	if p.percent >= 0 {
+		fmt.Fprintln(p.out)
+	}
 }
 
 func (c *CheckCmd) Run() error {
@@ -19,8 +89,9 @@ func (c *CheckCmd) Run() error {
 
 	// Open database.
 	db, err := witchbolt.Open(c.Path, 0600, &witchbolt.Options{
-		ReadOnly:        true,
-		PreLoadFreelist: true,
+		ReadOnly:          true,
+		PreLoadFreelist:   true,
+		OnFreelistRebuild: freelistRebuildNotice(c.Path),
 	})
 	if err != nil {
 		return err
@@ -31,8 +102,30 @@ func (c *CheckCmd) Run() error {
 	if c.FromPageID != 0 {
 		opts = append(opts, witchbolt.WithPageId(c.FromPageID))
 	}
+	if c.Progress {
+		progress := newCheckProgressPrinter(os.Stderr)
+		defer progress.finish()
+		opts = append(opts, witchbolt.WithProgress(progress.update))
+	}
 	// Perform consistency check.
 	return db.View(func(tx *witchbolt.Tx) error {
+		if c.JSON {
+			out := checkJSON{Errors: []checkErrorJSON{}}
+			for err := range tx.Check(opts...) {
+				out.Errors = append(out.Errors, newCheckErrorJSON(err))
+			}
+			out.Summary = checkSummaryJSON{ErrorCount: len(out.Errors), OK: len(out.Errors) == 0}
+			data, err := json.MarshalIndent(out, "", "    ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+			if !out.Summary.OK {
+				return guts_cli.ErrCorrupt
+			}
+			return nil
+		}
+
 		var count int
 		for err := range tx.Check(opts...) {
 			fmt.Println(err)
@@ -1,10 +1,12 @@
 package command_test
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 
+	"github.com/delaneyj/witchbolt"
 	"github.com/delaneyj/witchbolt/internal/btesting"
 	"github.com/delaneyj/witchbolt/internal/guts_cli"
 )
@@ -57,3 +59,71 @@ func TestCheckCommand_Run(t *testing.T) {
 		})
 	}
 }
+
+func TestCheckCommand_FreelistRebuildNotice(t *testing.T) {
+	t.Log("Creating sample DB without a synced freelist")
+	db := btesting.MustCreateDBWithOption(t, &witchbolt.Options{NoFreelistSync: true})
+	require.NoError(t, db.Update(func(tx *witchbolt.Tx) error {
+		_, err := tx.CreateBucket([]byte("bucket"))
+		return err
+	}))
+	db.Close()
+	defer requireDBNoChange(t, dbData(t, db.Path()), db.Path())
+
+	t.Log("Running check cmd")
+	res := runCLI(t, "check", db.Path())
+	require.NoError(t, res.err)
+	require.Contains(t, res.stdout, "OK\n")
+	require.Containsf(t, res.stderr, "rebuilding it by scanning the database", "unexpected stderr:\n\n%s", res.stderr)
+}
+
+func TestCheckCommand_JSON(t *testing.T) {
+	db := btesting.MustCreateDB(t)
+	db.Close()
+	defer requireDBNoChange(t, dbData(t, db.Path()), db.Path())
+
+	type output struct {
+		Errors []struct {
+			Page     *uint64
+			Message  string
+			Severity string
+		}
+		Summary struct {
+			ErrorCount int
+			OK         bool
+		}
+	}
+
+	res := runCLI(t, "check", db.Path(), "--json")
+	require.NoError(t, res.err)
+	var ok output
+	require.NoError(t, json.Unmarshal([]byte(res.stdout), &ok))
+	require.NotNil(t, ok.Errors)
+	require.Empty(t, ok.Errors)
+	require.True(t, ok.Summary.OK)
+	require.Contains(t, res.stdout, `"errors": []`)
+
+	t.Log("Errors carry the page they concern and still fail the command")
+	res = runCLI(t, "check", db.Path(), "--from-page-id", "1", "--json")
+	require.ErrorIs(t, res.err, guts_cli.ErrCorrupt)
+	var corrupt output
+	require.NoError(t, json.Unmarshal([]byte(res.stdout), &corrupt))
+	require.Len(t, corrupt.Errors, 1)
+	require.Equal(t, "page ID (1) out of range [2, 4)", corrupt.Errors[0].Message)
+	require.Equal(t, "error", corrupt.Errors[0].Severity)
+	require.NotNil(t, corrupt.Errors[0].Page)
+	require.EqualValues(t, 1, *corrupt.Errors[0].Page)
+	require.Equal(t, 1, corrupt.Summary.ErrorCount)
+	require.False(t, corrupt.Summary.OK)
+}
+
+func TestCheckCommand_Progress(t *testing.T) {
+	db := btesting.MustCreateDB(t)
+	db.Close()
+	defer requireDBNoChange(t, dbData(t, db.Path()), db.Path())
+
+	res := runCLI(t, "check", "--progress", db.Path())
+	require.NoError(t, res.err)
+	require.Equal(t, "OK\n", res.stdout, "progress stays off stdout")
+	require.Contains(t, res.stderr, "\rchecked 4/4 pages (100%)\n")
+}
@@ -1,9 +1,15 @@
 package command
 
 import (
+	"crypto/sha256"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"hash"
+	"maps"
 	"os"
+	"slices"
+	"strings"
 
 	"github.com/delaneyj/witchbolt"
 )
@@ -13,6 +19,8 @@ type CompactCmd struct {
 	Output    string `short:"o" required:"" help:"Destination database file" type:"path"`
 	TxMaxSize int64  `default:"65536" help:"Maximum transaction size"`
 	NoSync    bool   `help:"Disable fsync for destination database"`
+	Progress  bool   `help:"Print the keys and bytes copied so far to stderr"`
+	Verify    bool   `help:"Reopen the output read-only and compare the contents of each bucket with the source"`
 }
 
 func (c *CompactCmd) Run() error {
@@ -28,11 +36,19 @@ func (c *CompactCmd) Run() error {
 	initialSize := fi.Size()
 
 	// open source database.
-	src, err := witchbolt.Open(c.Src, 0400, &witchbolt.Options{ReadOnly: true})
+	src, err := witchbolt.Open(c.Src, 0400, &witchbolt.Options{
+		ReadOnly:          true,
+		PreLoadFreelist:   true,
+		OnFreelistRebuild: freelistRebuildNotice(c.Src),
+	})
 	if err != nil {
 		return err
 	}
 	defer src.Close()
+	before, err := readCompactStats(src)
+	if err != nil {
+		return err
+	}
 
 	// open destination database.
 	dst, err := witchbolt.Open(c.Output, fi.Mode(), &witchbolt.Options{NoSync: c.NoSync})
@@ -42,7 +58,17 @@ func (c *CompactCmd) Run() error {
 	defer dst.Close()
 
 	// run compaction.
-	if err := witchbolt.Compact(dst, src, c.TxMaxSize); err != nil {
+	var opts []witchbolt.CompactOption
+	if c.Progress {
+		opts = append(opts, witchbolt.WithCompactProgress(func(keys, bytes int64) {
+			fmt.Fprintf(os.Stderr, "\rcopied %d keys, %d bytes", keys, bytes)
+		}))
+	}
+	err = witchbolt.Compact(dst, src, c.TxMaxSize, opts...)
+	if c.Progress {
+		fmt.Fprintln(os.Stderr)
+	}
+	if err != nil {
 		return err
 	}
 
@@ -55,5 +81,139 @@ func (c *CompactCmd) Run() error {
 	}
 	fmt.Printf("%d -> %d bytes (gain=%.2fx)\n", initialSize, fi.Size(), float64(initialSize)/float64(fi.Size()))
 
+	after, err := readCompactStats(dst)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("free pages: %d -> %d (reclaimed %d)\n", before.freePages, after.freePages, before.freePages-after.freePages)
+	fmt.Printf("max bucket depth: %d -> %d\n", before.buckets.Depth, after.buckets.Depth)
+	fmt.Printf("inline buckets: %d -> %d\n", before.buckets.InlineBucketN, after.buckets.InlineBucketN)
+	fmt.Printf("fill factor: %.1f%% -> %.1f%%\n", before.fillFactor(), after.fillFactor())
+
+	if c.Verify {
+		// The output must be closed before it can be reopened read-only.
+		if err := dst.Close(); err != nil {
+			return err
+		}
+		return verifyCompaction(src, c.Output)
+	}
 	return nil
 }
+
+// verifyCompaction compares the digest of every top-level bucket in src with
+// the database compacted to path, and prints a summary if they all match.
+func verifyCompaction(src *witchbolt.DB, path string) error {
+	want, err := readBucketDigests(src)
+	if err != nil {
+		return err
+	}
+	out, err := witchbolt.Open(path, 0400, &witchbolt.Options{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	got, err := readBucketDigests(out)
+	if err != nil {
+		return err
+	}
+
+	var problems []string
+	var keys int
+	for _, name := range slices.Sorted(maps.Keys(want)) {
+		w, g := want[name], got[name]
+		switch _, ok := got[name]; {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("bucket %q missing", name))
+		case w.keys != g.keys:
+			problems = append(problems, fmt.Sprintf("bucket %q has %d keys, want %d", name, g.keys, w.keys))
+		case w.sum != g.sum:
+			problems = append(problems, fmt.Sprintf("bucket %q contents differ", name))
+		}
+		keys += w.keys
+	}
+	for _, name := range slices.Sorted(maps.Keys(got)) {
+		if _, ok := want[name]; !ok {
+			problems = append(problems, fmt.Sprintf("unexpected bucket %q", name))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrCompactVerifyMismatch, strings.Join(problems, "; "))
+	}
+	fmt.Printf("verified %d buckets, %d keys: output matches source\n", len(want), keys)
+	return nil
+}
+
+// bucketDigest summarises a top-level bucket, nested buckets included.
+type bucketDigest struct {
+	keys int
+	sum  [sha256.Size]byte
+}
+
+func readBucketDigests(db *witchbolt.DB) (map[string]bucketDigest, error) {
+	digests := make(map[string]bucketDigest)
+	err := db.View(func(tx *witchbolt.Tx) error {
+		return forEachUserBucket(tx, func(name []byte, b *witchbolt.Bucket) error {
+			h := sha256.New()
+			keys, err := hashBucket(h, b)
+			if err != nil {
+				return err
+			}
+			digests[string(name)] = bucketDigest{keys: keys, sum: [sha256.Size]byte(h.Sum(nil))}
+			return nil
+		})
+	})
+	return digests, err
+}
+
+// hashBucket writes the sequence and every key and value of b to h, recursing
+// into nested buckets, and returns the number of keys seen. Lengths are
+// written ahead of each key and value so that different layouts can't collide.
+func hashBucket(h hash.Hash, b *witchbolt.Bucket) (int, error) {
+	var keys int
+	h.Write(binary.BigEndian.AppendUint64(nil, b.Sequence()))
+	err := b.ForEach(func(k, v []byte) error {
+		keys++
+		h.Write(binary.BigEndian.AppendUint32(nil, uint32(len(k))))
+		h.Write(k)
+		if v != nil {
+			h.Write(binary.BigEndian.AppendUint32(nil, uint32(len(v))))
+			h.Write(v)
+			return nil
+		}
+		// A nil value marks a nested bucket.
+		h.Write([]byte{0xff, 0xff, 0xff, 0xff})
+		n, err := hashBucket(h, b.Bucket(k))
+		keys += n
+		return err
+	})
+	return keys, err
+}
+
+// compactStats captures the fragmentation figures compact reports for the
+// source and destination databases.
+type compactStats struct {
+	buckets   witchbolt.BucketStats
+	freePages int
+}
+
+func readCompactStats(db *witchbolt.DB) (compactStats, error) {
+	var s compactStats
+	err := db.View(func(tx *witchbolt.Tx) error {
+		return forEachUserBucket(tx, func(_ []byte, b *witchbolt.Bucket) error {
+			s.buckets.Add(b.Stats())
+			return nil
+		})
+	})
+	stats := db.Stats()
+	s.freePages = stats.FreePageN + stats.PendingPageN
+	return s, err
+}
+
+// fillFactor is the percentage of allocated branch and leaf bytes in use.
+func (s compactStats) fillFactor() float64 {
+	alloc := s.buckets.BranchAlloc + s.buckets.LeafAlloc
+	if alloc == 0 {
+		return 0
+	}
+	return float64(s.buckets.BranchInuse+s.buckets.LeafInuse) * 100 / float64(alloc)
+}
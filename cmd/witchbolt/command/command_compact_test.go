@@ -4,11 +4,14 @@ import (
 	crypto "crypto/rand"
 	"fmt"
 	"math/rand"
+	"regexp"
+	"strconv"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 
 	"github.com/delaneyj/witchbolt"
+	"github.com/delaneyj/witchbolt/cmd/witchbolt/command"
 	"github.com/delaneyj/witchbolt/internal/btesting"
 )
 
@@ -88,6 +91,140 @@ func TestCompactCommand_Run(t *testing.T) {
 	require.Equal(t, dbChk, dstdbChk, "the compacted db data isn't the same than the original db")
 }
 
+func TestCompactCommand_ReportsReclaimedPages(t *testing.T) {
+	t.Log("Creating a DB and deleting most of its keys")
+	db := btesting.MustCreateDB(t)
+	require.NoError(t, db.Update(func(tx *witchbolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("widgets"))
+		if err != nil {
+			return err
+		}
+		for i := 0; i < 20000; i++ {
+			if err := b.Put([]byte(fmt.Sprintf("key-%06d", i)), make([]byte, 100)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	require.NoError(t, db.Update(func(tx *witchbolt.Tx) error {
+		b := tx.Bucket([]byte("widgets"))
+		for i := 0; i < 20000; i++ {
+			if i%10 == 0 {
+				continue
+			}
+			if err := b.Delete([]byte(fmt.Sprintf("key-%06d", i))); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	db.Close()
+	dstPath := db.Path() + ".compacted"
+
+	t.Log("Running compact cmd")
+	res := runCLI(t, "compact", "-o", dstPath, db.Path())
+	require.NoError(t, res.err)
+
+	m := regexp.MustCompile(`free pages: (\d+) -> (\d+) \(reclaimed (-?\d+)\)`).FindStringSubmatch(res.stdout)
+	require.NotNil(t, m, res.stdout)
+	reclaimed, err := strconv.Atoi(m[3])
+	require.NoError(t, err)
+	require.Positive(t, reclaimed)
+	require.Contains(t, res.stdout, "max bucket depth: ")
+	require.Contains(t, res.stdout, "inline buckets: 0 -> 0\n")
+
+	fill := regexp.MustCompile(`fill factor: ([\d.]+)% -> ([\d.]+)%`).FindStringSubmatch(res.stdout)
+	require.NotNil(t, fill, res.stdout)
+	before, err := strconv.ParseFloat(fill[1], 64)
+	require.NoError(t, err)
+	after, err := strconv.ParseFloat(fill[2], 64)
+	require.NoError(t, err)
+	require.Greater(t, after, before, "compaction packs the sparse leaves")
+}
+
+func TestCompactCommand_Verify(t *testing.T) {
+	t.Log("Creating a DB with nested buckets")
+	db := btesting.MustCreateDB(t)
+	require.NoError(t, db.Update(func(tx *witchbolt.Tx) error {
+		for _, name := range []string{"a", "b"} {
+			b, err := tx.CreateBucket([]byte(name))
+			if err != nil {
+				return err
+			}
+			if err := b.SetSequence(7); err != nil {
+				return err
+			}
+			nested, err := b.CreateBucket([]byte("nested"))
+			if err != nil {
+				return err
+			}
+			for i := 0; i < 100; i++ {
+				if err := nested.Put([]byte(fmt.Sprintf("key-%03d", i)), []byte("value")); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}))
+	db.Close()
+	defer requireDBNoChange(t, dbData(t, db.Path()), db.Path())
+
+	res := runCLI(t, "compact", "--verify", "-o", db.Path()+".compacted", db.Path())
+	require.NoError(t, res.err)
+	require.Contains(t, res.stdout, "verified 2 buckets, 202 keys: output matches source\n")
+}
+
+func TestCompactCommand_VerifyMismatch(t *testing.T) {
+	db := btesting.MustCreateDB(t)
+	require.NoError(t, db.Update(func(tx *witchbolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("widgets"))
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte("key"), []byte("value"))
+	}))
+	db.Close()
+	defer requireDBNoChange(t, dbData(t, db.Path()), db.Path())
+
+	t.Log("Compacting into an output that already holds a bucket")
+	output := db.Path() + ".compacted"
+	stale, err := witchbolt.Open(output, 0600, nil)
+	require.NoError(t, err)
+	require.NoError(t, stale.Update(func(tx *witchbolt.Tx) error {
+		_, err := tx.CreateBucket([]byte("stale"))
+		return err
+	}))
+	require.NoError(t, stale.Close())
+
+	res := runCLI(t, "compact", "--verify", "-o", output, db.Path())
+	require.ErrorIs(t, res.err, command.ErrCompactVerifyMismatch)
+	require.ErrorContains(t, res.err, `unexpected bucket "stale"`)
+	require.NotContains(t, res.stdout, "output matches source")
+}
+
+func TestCompactCommand_Progress(t *testing.T) {
+	db := btesting.MustCreateDB(t)
+	require.NoError(t, db.Update(func(tx *witchbolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("widgets"))
+		if err != nil {
+			return err
+		}
+		for i := 0; i < 2000; i++ {
+			if err := b.Put([]byte(fmt.Sprintf("key-%04d", i)), make([]byte, 10)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	db.Close()
+
+	res := runCLI(t, "compact", "--progress", "-o", db.Path()+".compacted", db.Path())
+	require.NoError(t, res.err)
+	require.Contains(t, res.stderr, "\rcopied 1024 keys, 18421 bytes")
+	require.Contains(t, res.stderr, "\rcopied 2001 keys, 36007 bytes\n", "the totals count the bucket and every key and value byte")
+	require.NotContains(t, res.stdout, "copied")
+}
+
 func TestCompactCommand_NoArgs(t *testing.T) {
 	res := runCLI(t, "compact")
 	require.Error(t, res.err)
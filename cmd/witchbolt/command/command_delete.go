@@ -0,0 +1,107 @@
+package command
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/delaneyj/witchbolt"
+	berrors "github.com/delaneyj/witchbolt/errors"
+)
+
+type DeleteCmd struct {
+	Path        string   `arg:"" help:"Path to witchbolt database file" type:"path"`
+	BucketKey   []string `arg:"" help:"Bucket path (one or more bucket names) followed by the key to delete" placeholder:"bucket [subbucket ...] key"`
+	ParseFormat string   `default:"ascii-encoded" help:"Input format of the key: ascii-encoded|hex|uint64|int64"`
+	Bucket      bool     `help:"Delete the last bucket on the path, with everything in it, instead of a key"`
+}
+
+func (c *DeleteCmd) Run() error {
+	if c.Path == "" {
+		return ErrPathRequired
+	}
+
+	minArgs := 2
+	if c.Bucket {
+		minArgs = 1
+	}
+	if len(c.BucketKey) < minArgs {
+		return fmt.Errorf("bucket is required: %w", ErrBucketRequired)
+	}
+
+	buckets := c.BucketKey[:len(c.BucketKey)-1]
+	key, err := parseBytes(c.BucketKey[len(c.BucketKey)-1], c.ParseFormat)
+	if err != nil {
+		return err
+	}
+	if len(key) == 0 {
+		return fmt.Errorf("key is required: %w", berrors.ErrKeyRequired)
+	}
+
+	// check if the source DB path is valid and writable
+	fi, err := checkSourceDBPath(c.Path)
+	if err != nil {
+		return err
+	}
+	if fi.Mode().Perm()&0200 == 0 {
+		return fmt.Errorf("%w: %q", ErrReadOnlyFile, c.Path)
+	}
+
+	db, err := witchbolt.Open(c.Path, 0600, nil)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.Update(func(tx *witchbolt.Tx) error {
+		if c.Bucket {
+			return deleteBucket(tx, buckets, key)
+		}
+
+		lastBucket, err := findLastBucket(tx, buckets)
+		if err != nil {
+			return err
+		}
+		// Get returns nil for a nested bucket too, so look at the key with a
+		// cursor, which tells the two apart by the nil value of a bucket.
+		k, v := lastBucket.Cursor().Seek(key)
+		if !bytes.Equal(k, key) {
+			fmt.Printf("key %q not found\n", key)
+			return nil
+		}
+		if v == nil {
+			return fmt.Errorf("%q is a bucket, use --bucket to delete it: %w", key, berrors.ErrIncompatibleValue)
+		}
+		if err := lastBucket.Delete(key); err != nil {
+			return err
+		}
+		fmt.Printf("deleted key %q\n", key)
+		return nil
+	})
+}
+
+// deleteBucket deletes the bucket name from the bucket at the given path,
+// or from the root when the path is empty.
+func deleteBucket(tx *witchbolt.Tx, parents []string, name []byte) error {
+	deleteFn := tx.DeleteBucket
+	if len(parents) == 0 && isReservedBucket(name) {
+		return fmt.Errorf("%w: %q", ErrReservedBucket, name)
+	}
+	if len(parents) > 0 {
+		parent, err := findLastBucket(tx, parents)
+		if err != nil {
+			return err
+		}
+		deleteFn = parent.DeleteBucket
+	}
+
+	err := deleteFn(name)
+	if errors.Is(err, berrors.ErrBucketNotFound) {
+		fmt.Printf("bucket %q not found\n", name)
+		return nil
+	} else if err != nil {
+		return err
+	}
+	fmt.Printf("deleted bucket %q\n", name)
+	return nil
+}
@@ -0,0 +1,77 @@
+package command_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/delaneyj/witchbolt"
+	"github.com/delaneyj/witchbolt/cmd/witchbolt/command"
+	"github.com/delaneyj/witchbolt/errors"
+	"github.com/delaneyj/witchbolt/internal/btesting"
+)
+
+func TestDeleteCommand_Run(t *testing.T) {
+	db := btesting.MustCreateDB(t)
+	require.NoError(t, db.Update(func(tx *witchbolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("foo"))
+		if err != nil {
+			return err
+		}
+		if err := b.Put([]byte("key"), []byte("value")); err != nil {
+			return err
+		}
+		nested, err := b.CreateBucket([]byte("nested"))
+		if err != nil {
+			return err
+		}
+		return nested.Put([]byte("key"), []byte("value"))
+	}))
+	db.Close()
+
+	t.Log("Deleting a key reports whether it existed")
+	res := runCLI(t, "delete", db.Path(), "foo", "key")
+	require.NoError(t, res.err)
+	require.Equal(t, "deleted key \"key\"\n", res.stdout)
+	res = runCLI(t, "delete", "--parse-format", "hex", db.Path(), "foo", "6b6579")
+	require.NoError(t, res.err)
+	require.Equal(t, "key \"key\" not found\n", res.stdout)
+
+	t.Log("Deleting a nested bucket as a key points to --bucket")
+	res = runCLI(t, "delete", db.Path(), "foo", "nested")
+	require.ErrorIs(t, res.err, errors.ErrIncompatibleValue)
+	require.ErrorContains(t, res.err, `"nested" is a bucket, use --bucket to delete it`)
+	require.Empty(t, res.stdout)
+
+	t.Log("Deleting a nested bucket")
+	res = runCLI(t, "delete", "--bucket", db.Path(), "foo", "nested")
+	require.NoError(t, res.err)
+	require.Equal(t, "deleted bucket \"nested\"\n", res.stdout)
+	res = runCLI(t, "get", db.Path(), "foo", "nested", "key")
+	require.ErrorIs(t, res.err, errors.ErrBucketNotFound)
+
+	t.Log("Deleting a top-level bucket")
+	res = runCLI(t, "delete", "--bucket", db.Path(), "foo")
+	require.NoError(t, res.err)
+	require.Equal(t, "deleted bucket \"foo\"\n", res.stdout)
+	res = runCLI(t, "delete", "--bucket", db.Path(), "foo")
+	require.NoError(t, res.err)
+	require.Equal(t, "bucket \"foo\" not found\n", res.stdout)
+}
+
+func TestDeleteCommand_ReadOnlyFile(t *testing.T) {
+	db := btesting.MustCreateDB(t)
+	db.Close()
+	require.NoError(t, os.Chmod(db.Path(), 0400))
+	defer requireDBNoChange(t, dbData(t, db.Path()), db.Path())
+
+	res := runCLI(t, "delete", "--bucket", db.Path(), "foo")
+	require.ErrorIs(t, res.err, command.ErrReadOnlyFile)
+}
+
+func TestDeleteCommand_NoArgs(t *testing.T) {
+	res := runCLI(t, "delete")
+	require.Error(t, res.err)
+	require.Contains(t, res.err.Error(), "expected \"<path> <bucket-key> ...\"")
+}
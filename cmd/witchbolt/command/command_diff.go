@@ -0,0 +1,333 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/delaneyj/witchbolt"
+	"github.com/delaneyj/witchbolt/internal/common"
+	"github.com/delaneyj/witchbolt/stream"
+)
+
+// DiffCmd compares two databases key by key. With --only-changed it compares
+// the snapshot of a replica's generation with the end of its segment chain
+// instead, naming the TxID that last wrote each key that differs.
+type DiffCmd struct {
+	Src         string   `arg:"" optional:"" help:"Path to the witchbolt database to compare from" type:"path"`
+	Dst         string   `arg:"" optional:"" help:"Path to the witchbolt database to compare to" type:"path"`
+	OnlyChanged bool     `help:"Compare the replica's snapshot with the end of its segment chain and report the TxID that last wrote each key that changed"`
+	Config      []string `help:"Path to the stream configuration file for --only-changed (YAML or JSON); repeat to merge overrides, later files winning" type:"path" sep:"none"`
+}
+
+func (c *DiffCmd) Run() error {
+	if c.OnlyChanged || len(c.Config) > 0 {
+		if !c.OnlyChanged || len(c.Config) == 0 || c.Src != "" {
+			return ErrDiffOnlyChanged
+		}
+		return c.runOnlyChanged()
+	}
+	if c.Dst == "" {
+		return ErrPathRequired
+	}
+	for _, path := range []string{c.Src, c.Dst} {
+		if _, err := checkSourceDBPath(path); err != nil {
+			return err
+		}
+	}
+
+	src, err := witchbolt.Open(c.Src, 0600, &witchbolt.Options{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := witchbolt.Open(c.Dst, 0600, &witchbolt.Options{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	var sum diffSummary
+	err = diffDBs(src, dst, nil, func(d diffEntry) error {
+		sum.add(d)
+		fmt.Println(d)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Println(sum)
+	return nil
+}
+
+// runOnlyChanged replays the first replica's segments on top of its snapshot.
+// After each segment it diffs the database with its state before the
+// segment, descending only into buckets whose root page the segment wrote,
+// and records the segment's TxID against every key that differs. The keys
+// that differ between the snapshot and the end of the chain are then
+// reported with the last TxID recorded for them, so a key that was changed
+// and changed back is left out.
+func (c *DiffCmd) runOnlyChanged() error {
+	cfg, err := loadStreamConfig(c.Config)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	replicas, err := stream.BuildReplicas(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for _, replica := range replicas {
+			_ = replica.Close(ctx)
+		}
+	}()
+	if len(replicas) == 0 {
+		return fmt.Errorf("stream config has no replicas")
+	}
+
+	dir, err := os.MkdirTemp("", "witchbolt-diff-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	basePath := filepath.Join(dir, "base.db")
+	prevPath := filepath.Join(dir, "prev.db")
+	path := filepath.Join(dir, "replay.db")
+
+	var baseTxID, lastTxID uint64
+	lastWriter := make(map[string]uint64)
+	err = stream.ReplayReplica(ctx, replicas[0], path, func(txid uint64, pages []uint64) error {
+		lastTxID = txid
+		if pages == nil {
+			baseTxID = txid
+			if err := copyFile(path, basePath); err != nil {
+				return err
+			}
+			return copyFile(path, prevPath)
+		}
+
+		written := make(map[common.Pgid]bool, len(pages))
+		for _, id := range pages {
+			written[common.Pgid(id)] = true
+		}
+		// A page the segment didn't write holds the same bytes before and
+		// after it, so neither can anything in the bucket it roots differ.
+		unchanged := func(a, b *witchbolt.Bucket) bool {
+			return a.Root() != 0 && a.Root() == b.Root() && !written[a.Root()]
+		}
+		err := diffFiles(prevPath, path, unchanged, func(d diffEntry) error {
+			lastWriter[d.id()] = txid
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return copyFile(path, prevPath)
+	})
+	if err != nil {
+		return err
+	}
+
+	var sum diffSummary
+	err = diffFiles(basePath, path, nil, func(d diffEntry) error {
+		sum.add(d)
+		fmt.Printf("%s (txid %d)\n", d, lastWriter[d.id()])
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s between txid %d and %d\n", sum, baseTxID, lastTxID)
+	return nil
+}
+
+// diffEntry is a key or bucket that differs between two databases.
+type diffEntry struct {
+	op       byte     // '+' added, '-' removed or '~' changed
+	path     [][]byte // the names of the enclosing buckets, then the key
+	isBucket bool
+}
+
+// String renders the entry as its op and its path joined with slashes; a
+// bucket has a trailing slash.
+func (d diffEntry) String() string {
+	names := make([]string, len(d.path))
+	for i, name := range d.path {
+		names[i] = bytesToAsciiOrHex(name)
+	}
+	s := string(d.op) + " " + strings.Join(names, "/")
+	if d.isBucket {
+		s += "/"
+	}
+	return s
+}
+
+// id identifies the entry's path unambiguously, unlike String.
+func (d diffEntry) id() string {
+	var sb strings.Builder
+	for _, name := range d.path {
+		fmt.Fprintf(&sb, "%d:%s", len(name), name)
+	}
+	return sb.String()
+}
+
+// diffSummary counts the entries reported by a diff.
+type diffSummary struct {
+	added, removed, changed int
+}
+
+func (s *diffSummary) add(d diffEntry) {
+	switch d.op {
+	case '+':
+		s.added++
+	case '-':
+		s.removed++
+	default:
+		s.changed++
+	}
+}
+
+func (s diffSummary) String() string {
+	return fmt.Sprintf("%d added, %d removed, %d changed", s.added, s.removed, s.changed)
+}
+
+// diffFiles is diffDBs for the databases at two paths.
+func diffFiles(srcPath, dstPath string, unchanged func(a, b *witchbolt.Bucket) bool, fn func(diffEntry) error) error {
+	src, err := witchbolt.Open(srcPath, 0600, &witchbolt.Options{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := witchbolt.Open(dstPath, 0600, &witchbolt.Options{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+	return diffDBs(src, dst, unchanged, fn)
+}
+
+// diffDBs calls fn, in key order, for every bucket and key added, removed or
+// changed from src to dst. Every key of an added or removed bucket follows
+// the bucket. A key that turns into a bucket, or back, is removed and added.
+// unchanged, if set, reports buckets present in both databases that can be
+// skipped without comparing them.
+func diffDBs(src, dst *witchbolt.DB, unchanged func(a, b *witchbolt.Bucket) bool, fn func(diffEntry) error) error {
+	return src.View(func(srcTx *witchbolt.Tx) error {
+		return dst.View(func(dstTx *witchbolt.Tx) error {
+			return diffBuckets(nil, srcTx.Cursor().Bucket(), dstTx.Cursor().Bucket(), unchanged, fn)
+		})
+	})
+}
+
+// diffBuckets diffs the buckets a and b at path, either of which is nil if
+// the bucket is missing on that side.
+func diffBuckets(path [][]byte, a, b *witchbolt.Bucket, unchanged func(a, b *witchbolt.Bucket) bool, fn func(diffEntry) error) error {
+	if a != nil && b != nil && unchanged != nil && unchanged(a, b) {
+		return nil
+	}
+	next := func(c *witchbolt.Cursor, first bool) ([]byte, []byte) {
+		if c == nil {
+			return nil, nil
+		}
+		var k, v []byte
+		if first {
+			k, v = c.First()
+		} else {
+			k, v = c.Next()
+		}
+		// The root holds the bucket stream keeps the database identity in.
+		for k != nil && len(path) == 0 && isReservedBucket(k) {
+			k, v = c.Next()
+		}
+		return k, v
+	}
+	cursor := func(b *witchbolt.Bucket) *witchbolt.Cursor {
+		if b == nil {
+			return nil
+		}
+		return b.Cursor()
+	}
+	ac, bc := cursor(a), cursor(b)
+	ak, av := next(ac, true)
+	bk, bv := next(bc, true)
+
+	// report reports k of bucket parent as op, with every key of k first
+	// removed or then added if it is a bucket.
+	report := func(op byte, parent *witchbolt.Bucket, k, v []byte) error {
+		keyPath := append(path[:len(path):len(path)], k)
+		if err := fn(diffEntry{op: op, path: keyPath, isBucket: v == nil}); err != nil {
+			return err
+		}
+		if v != nil {
+			return nil
+		}
+		if op == '-' {
+			return diffBuckets(keyPath, parent.Bucket(k), nil, unchanged, fn)
+		}
+		return diffBuckets(keyPath, nil, parent.Bucket(k), unchanged, fn)
+	}
+
+	for ak != nil || bk != nil {
+		cmp := 1
+		if ak != nil && bk != nil {
+			cmp = bytes.Compare(ak, bk)
+		} else if ak != nil {
+			cmp = -1
+		}
+		switch {
+		case cmp < 0:
+			if err := report('-', a, ak, av); err != nil {
+				return err
+			}
+			ak, av = next(ac, false)
+		case cmp > 0:
+			if err := report('+', b, bk, bv); err != nil {
+				return err
+			}
+			bk, bv = next(bc, false)
+		default:
+			var err error
+			switch {
+			case av == nil && bv == nil:
+				keyPath := append(path[:len(path):len(path)], ak)
+				err = diffBuckets(keyPath, a.Bucket(ak), b.Bucket(bk), unchanged, fn)
+			case av == nil || bv == nil:
+				if err = report('-', a, ak, av); err == nil {
+					err = report('+', b, bk, bv)
+				}
+			case !bytes.Equal(av, bv):
+				err = fn(diffEntry{op: '~', path: append(path[:len(path):len(path)], ak)})
+			}
+			if err != nil {
+				return err
+			}
+			ak, av = next(ac, false)
+			bk, bv = next(bc, false)
+		}
+	}
+	return nil
+}
+
+// copyFile copies the file at src to dst, replacing it.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,129 @@
+package command_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/delaneyj/witchbolt"
+	"github.com/delaneyj/witchbolt/cmd/witchbolt/command"
+	"github.com/delaneyj/witchbolt/stream"
+)
+
+func TestDiffCommand(t *testing.T) {
+	src := exportTestDB(t)
+	defer requireDBNoChange(t, dbData(t, src), src)
+
+	t.Log("A database has no differences from its copy")
+	dst := filepath.Join(t.TempDir(), "dst.db")
+	data, err := os.ReadFile(src)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(dst, data, 0600))
+	res := runCLI(t, "diff", src, dst)
+	require.NoError(t, res.err)
+	require.Equal(t, "0 added, 0 removed, 0 changed\n", res.stdout)
+
+	t.Log("Changing, adding and removing keys and buckets")
+	db, err := witchbolt.Open(dst, 0600, nil)
+	require.NoError(t, err)
+	require.NoError(t, db.Update(func(tx *witchbolt.Tx) error {
+		foo := tx.Bucket([]byte("foo"))
+		if err := foo.Put([]byte("text"), []byte("world")); err != nil {
+			return err
+		}
+		if err := foo.DeleteBucket([]byte("nested")); err != nil {
+			return err
+		}
+		if err := foo.Put([]byte("nested"), []byte("now a key")); err != nil {
+			return err
+		}
+		bar, err := tx.CreateBucket([]byte("bar"))
+		if err != nil {
+			return err
+		}
+		return bar.Put([]byte("new"), []byte("value"))
+	}))
+	require.NoError(t, db.Close())
+
+	res = runCLI(t, "diff", src, dst)
+	require.NoError(t, res.err)
+	require.Equal(t, "+ bar/\n"+
+		"+ bar/new\n"+
+		"- foo/nested/\n"+
+		"- foo/nested/binary\n"+
+		"+ foo/nested\n"+
+		"~ foo/text\n"+
+		"3 added, 2 removed, 1 changed\n", res.stdout)
+
+	t.Log("A stream config replaces the database paths")
+	res = runCLI(t, "diff", src)
+	require.ErrorIs(t, res.err, command.ErrPathRequired)
+	res = runCLI(t, "diff", "--only-changed", src, dst)
+	require.ErrorIs(t, res.err, command.ErrDiffOnlyChanged)
+}
+
+func TestDiffCommand_OnlyChanged(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "db")
+	replicaPath := filepath.Join(dir, "replica")
+
+	db, err := witchbolt.Open(dbPath, 0600, nil)
+	require.NoError(t, err)
+	put := func(bucket, key, value string) uint64 {
+		var txid int
+		require.NoError(t, db.Update(func(tx *witchbolt.Tx) error {
+			txid = tx.ID()
+			b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+			if err != nil {
+				return err
+			}
+			if value == "" {
+				return b.Delete([]byte(key))
+			}
+			return b.Put([]byte(key), []byte(value))
+		}))
+		return uint64(txid)
+	}
+	put("widgets", "a", "1")
+	put("widgets", "b", "1")
+	// Too big for widgets to stay inline, so it gets a root page of its own.
+	put("widgets", "blob", strings.Repeat("x", 4096))
+
+	ctrl, err := stream.Enable(context.Background(), db, stream.Config{
+		ShadowDir: filepath.Join(dir, "shadow"),
+		Replicas:  []stream.ReplicaConfig{&stream.FileReplicaConfig{Path: replicaPath}},
+	})
+	require.NoError(t, err)
+	// The generation's snapshot is taken after its first segment.
+	baseTxID := put("widgets", "a", "1")
+	bTxID := put("widgets", "b", "2")
+	put("widgets", "a", "2")
+	cTxID := put("gadgets", "c", "1")
+	put("widgets", "tmp", "1")
+	put("widgets", "tmp", "")
+	aTxID := put("widgets", "a", "3")
+	for i := 0; i < 50; i++ {
+		// Commits that leave widgets' pages alone, so replaying them skips it.
+		put("gadgets", fmt.Sprintf("filler-%02d", i), "x")
+	}
+	require.NoError(t, ctrl.Stop(context.Background()))
+	require.NoError(t, db.Close())
+	defer requireDBNoChange(t, dbData(t, dbPath), dbPath)
+
+	res := runCLI(t, "diff", "--only-changed", "--config", writeStreamConfig(t, replicaPath, ""))
+	require.NoError(t, res.err)
+	want := fmt.Sprintf("+ gadgets/ (txid %d)\n", cTxID) +
+		fmt.Sprintf("+ gadgets/c (txid %d)\n", cTxID)
+	for i := 0; i < 50; i++ {
+		want += fmt.Sprintf("+ gadgets/filler-%02d (txid %d)\n", i, cTxID+4+uint64(i))
+	}
+	want += fmt.Sprintf("~ widgets/a (txid %d)\n", aTxID) +
+		fmt.Sprintf("~ widgets/b (txid %d)\n", bTxID)
+	require.Contains(t, res.stdout, want, "each key is attributed to the last transaction that changed it, and tmp, which was put and deleted again, is left out")
+	require.Contains(t, res.stdout, fmt.Sprintf("52 added, 0 removed, 2 changed between txid %d and %d\n", baseTxID, aTxID+50))
+}
@@ -13,6 +13,8 @@ import (
 type DumpCmd struct {
 	Path    string   `arg:"" help:"Path to witchbolt database file" type:"path"`
 	PageIDs []string `arg:"" help:"Page IDs to dump (one or more)"`
+	Raw     bool     `name:"raw" help:"Write the raw bytes of the pages, including their overflow pages, to --output instead of hexdumping them."`
+	Output  string   `name:"output" help:"New file the raw pages are written to, in the order given." type:"path"`
 }
 
 func (c *DumpCmd) Run() error {
@@ -23,10 +25,19 @@ func (c *DumpCmd) Run() error {
 		return ErrPageIDRequired
 	}
 
-	if _, err := checkSourceDBPath(c.Path); err != nil {
+	if c.Raw != (c.Output != "") {
+		return ErrDumpRawOutput
+	}
+
+	fi, err := checkSourceDBPath(c.Path)
+	if err != nil {
 		return err
 	}
 
+	if c.Raw {
+		return dumpRawPages(c.Path, fi, pageIDs, c.Output)
+	}
+
 	// open database to retrieve page size.
 	pageSize, _, err := guts_cli.ReadPageAndHWMSize(c.Path)
 	if err != nil {
@@ -105,3 +116,37 @@ func dumpPage(w io.Writer, r io.ReaderAt, pageID uint64, pageSize uint64) error
 
 	return nil
 }
+
+// dumpRawPages writes the pages, each with its overflow pages, to a new file
+// at output. It refuses to replace an existing file, the source included.
+func dumpRawPages(path string, fi os.FileInfo, pageIDs []uint64, output string) (err error) {
+	if ofi, statErr := os.Stat(output); statErr == nil {
+		if os.SameFile(fi, ofi) {
+			return fmt.Errorf("refusing to overwrite the source database %q", path)
+		}
+		return fmt.Errorf("output file %q already exists", output)
+	} else if !os.IsNotExist(statErr) {
+		return statErr
+	}
+
+	f, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create output file %q: %w", output, err)
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	for _, pageID := range pageIDs {
+		_, buf, err := guts_cli.ReadPage(path, pageID)
+		if err != nil {
+			return fmt.Errorf("read page %d: %w", pageID, err)
+		}
+		if _, err := f.Write(buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
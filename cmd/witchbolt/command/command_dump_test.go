@@ -1,12 +1,16 @@
 package command_test
 
 import (
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 
 	"github.com/delaneyj/witchbolt"
+	"github.com/delaneyj/witchbolt/cmd/witchbolt/command"
 	"github.com/delaneyj/witchbolt/internal/btesting"
 )
 
@@ -30,3 +34,51 @@ func TestDumpCommand_NoArgs(t *testing.T) {
 	require.Error(t, res.err)
 	require.Contains(t, res.err.Error(), "expected \"<path> <page-i-ds> ...\"")
 }
+
+func TestDumpCommand_Raw(t *testing.T) {
+	db := btesting.MustCreateDBWithOption(t, &witchbolt.Options{PageSize: 4096})
+	require.NoError(t, db.Update(func(tx *witchbolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("widgets"))
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte("big"), make([]byte, 10000))
+	}))
+	var overflowID, overflowN int
+	require.NoError(t, db.View(func(tx *witchbolt.Tx) error {
+		for id := 0; ; id++ {
+			p, err := tx.Page(id)
+			if err != nil || p == nil {
+				return err
+			}
+			if p.Type == "leaf" && p.OverflowCount > 0 {
+				overflowID, overflowN = p.ID, p.OverflowCount
+				return nil
+			}
+		}
+	}))
+	require.NotZero(t, overflowN)
+	require.NoError(t, db.Close())
+	defer requireDBNoChange(t, dbData(t, db.Path()), db.Path())
+
+	output := filepath.Join(t.TempDir(), "pages.bin")
+	res := runCLI(t, "dump", db.Path(), "0", strconv.Itoa(overflowID), "--raw", "--output", output)
+	require.NoError(t, res.err)
+	require.Empty(t, res.stdout)
+
+	t.Log("The file holds the meta page followed by the leaf and its overflow pages")
+	data := dbData(t, db.Path())
+	got, err := os.ReadFile(output)
+	require.NoError(t, err)
+	leaf := data[overflowID*4096 : (overflowID+overflowN+1)*4096]
+	require.Equal(t, append(append([]byte(nil), data[:4096]...), leaf...), got)
+
+	t.Log("Refusing to overwrite existing files")
+	res = runCLI(t, "dump", db.Path(), "0", "--raw", "--output", db.Path())
+	require.ErrorContains(t, res.err, "refusing to overwrite the source database")
+	res = runCLI(t, "dump", db.Path(), "0", "--raw", "--output", output)
+	require.ErrorContains(t, res.err, "already exists")
+
+	res = runCLI(t, "dump", db.Path(), "0", "--raw")
+	require.ErrorIs(t, res.err, command.ErrDumpRawOutput)
+}
@@ -0,0 +1,191 @@
+package command
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/fxamacker/cbor/v2"
+
+	"github.com/delaneyj/witchbolt"
+)
+
+const (
+	exportMagic   = "witchbolt-export"
+	exportVersion = 1
+)
+
+type ExportCmd struct {
+	Path   string `arg:"" help:"Path to witchbolt database file" type:"path"`
+	Output string `short:"o" required:"" help:"Archive file to create" type:"path"`
+	Format string `default:"json" enum:"json,jsonl,cbor" help:"Archive format: json|jsonl|cbor"`
+}
+
+// exportHeader identifies an archive. jsonl archives start with it on their
+// own line; json and cbor archives embed it in the document.
+type exportHeader struct {
+	Magic   string `json:"magic" cbor:"magic"`
+	Version int    `json:"version" cbor:"version"`
+}
+
+// exportDocument is a json or cbor archive: the header and the tree of
+// top-level buckets.
+type exportDocument struct {
+	exportHeader
+	Buckets []*exportBucket `json:"buckets" cbor:"buckets"`
+}
+
+// exportBucket is a bucket with its key/value pairs and nested buckets.
+type exportBucket struct {
+	Name     exportBytes     `json:"name" cbor:"name"`
+	Sequence uint64          `json:"sequence,omitempty" cbor:"sequence,omitempty"`
+	Entries  []exportEntry   `json:"entries,omitempty" cbor:"entries,omitempty"`
+	Buckets  []*exportBucket `json:"buckets,omitempty" cbor:"buckets,omitempty"`
+}
+
+type exportEntry struct {
+	Key   exportBytes `json:"key" cbor:"key"`
+	Value exportBytes `json:"value" cbor:"value"`
+}
+
+// exportRecord is a line of a jsonl archive after the header. A "bucket"
+// record creates the bucket at Path, which ends with its own name; a "kv"
+// record puts Key and Value into the bucket at Path. Parents always come
+// before their contents.
+type exportRecord struct {
+	Type     string        `json:"type"`
+	Path     []exportBytes `json:"path"`
+	Sequence uint64        `json:"sequence,omitempty"`
+	Key      *exportBytes  `json:"key,omitempty"`
+	Value    *exportBytes  `json:"value,omitempty"`
+}
+
+// exportBytes is a key, value or bucket name with the encoding of Data:
+// "utf8" for printable text, "base64" for anything else.
+type exportBytes struct {
+	Encoding string `json:"encoding" cbor:"encoding"`
+	Data     string `json:"data" cbor:"data"`
+}
+
+func newExportBytes(b []byte) exportBytes {
+	if isPrintable(string(b)) {
+		return exportBytes{Encoding: "utf8", Data: string(b)}
+	}
+	return exportBytes{Encoding: "base64", Data: base64.StdEncoding.EncodeToString(b)}
+}
+
+func (e exportBytes) bytes() ([]byte, error) {
+	switch e.Encoding {
+	case "utf8":
+		return []byte(e.Data), nil
+	case "base64":
+		return base64.StdEncoding.DecodeString(e.Data)
+	default:
+		return nil, fmt.Errorf("unsupported encoding %q", e.Encoding)
+	}
+}
+
+func (c *ExportCmd) Run() (err error) {
+	if _, err := checkSourceDBPath(c.Path); err != nil {
+		return err
+	}
+
+	db, err := witchbolt.Open(c.Path, 0600, &witchbolt.Options{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	f, err := os.OpenFile(c.Output, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create output file %q: %w", c.Output, err)
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	w := bufio.NewWriter(f)
+
+	var buckets, keys int
+	err = db.View(func(tx *witchbolt.Tx) error {
+		if c.Format == "jsonl" {
+			enc := json.NewEncoder(w)
+			if err := enc.Encode(exportHeader{Magic: exportMagic, Version: exportVersion}); err != nil {
+				return err
+			}
+			return forEachUserBucket(tx, func(name []byte, b *witchbolt.Bucket) error {
+				return exportBucketRecords(enc, nil, name, b, &buckets, &keys)
+			})
+		}
+
+		doc := newExportDocument()
+		if err := forEachUserBucket(tx, func(name []byte, b *witchbolt.Bucket) error {
+			doc.Buckets = append(doc.Buckets, exportBucketTree(name, b, &buckets, &keys))
+			return nil
+		}); err != nil {
+			return err
+		}
+		return writeExportDocument(w, c.Format, doc)
+	})
+	if err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Printf("exported %d buckets and %d keys to %s\n", buckets, keys, c.Output)
+	return nil
+}
+
+// newExportDocument returns a json or cbor archive without any buckets.
+func newExportDocument() *exportDocument {
+	return &exportDocument{exportHeader: exportHeader{Magic: exportMagic, Version: exportVersion}, Buckets: []*exportBucket{}}
+}
+
+func writeExportDocument(w io.Writer, format string, doc *exportDocument) error {
+	if format == "cbor" {
+		return cbor.NewEncoder(w).Encode(doc)
+	}
+	data, err := json.MarshalIndent(doc, "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+func exportBucketTree(name []byte, b *witchbolt.Bucket, buckets, keys *int) *exportBucket {
+	*buckets++
+	out := &exportBucket{Name: newExportBytes(name), Sequence: b.Sequence()}
+	_ = b.ForEach(func(k, v []byte) error {
+		if v == nil {
+			out.Buckets = append(out.Buckets, exportBucketTree(k, b.Bucket(k), buckets, keys))
+			return nil
+		}
+		*keys++
+		out.Entries = append(out.Entries, exportEntry{Key: newExportBytes(k), Value: newExportBytes(v)})
+		return nil
+	})
+	return out
+}
+
+func exportBucketRecords(enc *json.Encoder, parent []exportBytes, name []byte, b *witchbolt.Bucket, buckets, keys *int) error {
+	*buckets++
+	path := append(parent[:len(parent):len(parent)], newExportBytes(name))
+	if err := enc.Encode(exportRecord{Type: "bucket", Path: path, Sequence: b.Sequence()}); err != nil {
+		return err
+	}
+	return b.ForEach(func(k, v []byte) error {
+		if v == nil {
+			return exportBucketRecords(enc, path, k, b.Bucket(k), buckets, keys)
+		}
+		*keys++
+		key, value := newExportBytes(k), newExportBytes(v)
+		return enc.Encode(exportRecord{Type: "kv", Path: path, Key: &key, Value: &value})
+	})
+}
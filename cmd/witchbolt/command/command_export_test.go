@@ -0,0 +1,110 @@
+package command_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/stretchr/testify/require"
+
+	"github.com/delaneyj/witchbolt"
+	"github.com/delaneyj/witchbolt/internal/btesting"
+	"github.com/delaneyj/witchbolt/stream"
+)
+
+// exportTestDB creates a database with a nested bucket, a sequence and a
+// binary value for the export and import tests.
+func exportTestDB(t *testing.T) string {
+	db := btesting.MustCreateDB(t)
+	require.NoError(t, db.Update(func(tx *witchbolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("foo"))
+		if err != nil {
+			return err
+		}
+		if err := b.SetSequence(42); err != nil {
+			return err
+		}
+		if err := b.Put([]byte("text"), []byte("hello")); err != nil {
+			return err
+		}
+		nested, err := b.CreateBucket([]byte("nested"))
+		if err != nil {
+			return err
+		}
+		return nested.Put([]byte("binary"), []byte{0, 1, 0xff})
+	}))
+	db.Close()
+	return db.Path()
+}
+
+func TestExportCommand_Run(t *testing.T) {
+	path := exportTestDB(t)
+	defer requireDBNoChange(t, dbData(t, path), path)
+	dir := t.TempDir()
+
+	t.Log("json nests the buckets and notes each encoding")
+	out := filepath.Join(dir, "export.json")
+	res := runCLI(t, "export", path, "-o", out)
+	require.NoError(t, res.err)
+	require.Equal(t, "exported 2 buckets and 2 keys to "+out+"\n", res.stdout)
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	var doc struct {
+		Magic   string
+		Version int
+		Buckets []map[string]any
+	}
+	require.NoError(t, json.Unmarshal(data, &doc))
+	require.Equal(t, "witchbolt-export", doc.Magic)
+	require.Equal(t, 1, doc.Version)
+	require.Len(t, doc.Buckets, 1)
+	require.EqualValues(t, 42, doc.Buckets[0]["sequence"])
+	nested := doc.Buckets[0]["buckets"].([]any)[0].(map[string]any)
+	require.Equal(t, map[string]any{"encoding": "base64", "data": "AAH/"}, nested["entries"].([]any)[0].(map[string]any)["value"])
+
+	t.Log("jsonl writes the header, then a record per bucket and key")
+	out = filepath.Join(dir, "export.jsonl")
+	res = runCLI(t, "export", path, "-o", out, "--format", "jsonl")
+	require.NoError(t, res.err)
+	data, err = os.ReadFile(out)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 5)
+	require.JSONEq(t, `{"magic":"witchbolt-export","version":1}`, lines[0])
+	require.JSONEq(t, `{"type":"kv","path":[{"encoding":"utf8","data":"foo"},{"encoding":"utf8","data":"nested"}],"key":{"encoding":"utf8","data":"binary"},"value":{"encoding":"base64","data":"AAH/"}}`, lines[3])
+
+	t.Log("cbor holds the same document as json")
+	out = filepath.Join(dir, "export.cbor")
+	res = runCLI(t, "export", path, "-o", out, "--format", "cbor")
+	require.NoError(t, res.err)
+	data, err = os.ReadFile(out)
+	require.NoError(t, err)
+	var cdoc struct {
+		Magic   string `cbor:"magic"`
+		Buckets []struct {
+			Sequence uint64 `cbor:"sequence"`
+		} `cbor:"buckets"`
+	}
+	require.NoError(t, cbor.Unmarshal(data, &cdoc))
+	require.Equal(t, "witchbolt-export", cdoc.Magic)
+	require.EqualValues(t, 42, cdoc.Buckets[0].Sequence)
+
+	t.Log("Refusing to replace an existing file")
+	res = runCLI(t, "export", path, "-o", out)
+	require.ErrorContains(t, res.err, "file exists")
+}
+
+func TestExportCommand_SkipsStreamBucket(t *testing.T) {
+	dbPath, _ := replicateSampleDB(t, 3)
+	out := filepath.Join(t.TempDir(), "export.jsonl")
+
+	res := runCLI(t, "export", dbPath, "-o", out, "--format", "jsonl")
+	require.NoError(t, res.err)
+	require.Equal(t, "exported 1 buckets and 3 keys to "+out+"\n", res.stdout)
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	require.NotContains(t, string(data), stream.IdentityBucket, "an import must not share the source's identity")
+}
@@ -3,6 +3,8 @@ package command
 import (
 	"fmt"
 	"os"
+	"slices"
+	"strings"
 
 	"github.com/delaneyj/witchbolt"
 	"github.com/delaneyj/witchbolt/errors"
@@ -11,8 +13,12 @@ import (
 type GetCmd struct {
 	Path        string   `arg:"" help:"Path to witchbolt database file" type:"path"`
 	BucketKey   []string `arg:"" help:"Bucket path (one or more bucket names) followed by the key to retrieve" placeholder:"bucket [subbucket ...] key"`
-	ParseFormat string   `default:"ascii-encoded" help:"Input format: ascii-encoded|hex"`
-	Format      string   `default:"auto" help:"Output format: auto|ascii-encoded|hex|bytes"`
+	ParseFormat string   `default:"ascii-encoded" help:"Input format: ascii-encoded|hex|uint64|int64"`
+	Format      string   `default:"auto" help:"Output format: auto|ascii-encoded|hex|bytes|uint64|int64"`
+	Key         []string `help:"Key to retrieve, may be repeated. All positional arguments are then bucket names"`
+	KeysFile    string   `help:"File with a key to retrieve on each line. All positional arguments are then bucket names" type:"existingfile"`
+	Delimiter   string   `help:"Line printed between the values of several keys"`
+	SkipMissing bool     `help:"Skip keys that are not found instead of failing"`
 }
 
 func (c *GetCmd) Run() error {
@@ -20,20 +26,21 @@ func (c *GetCmd) Run() error {
 		return ErrPathRequired
 	}
 
-	if len(c.BucketKey) < 2 {
-		return fmt.Errorf("bucket is required: %w", ErrBucketRequired)
-	}
-
-	buckets := c.BucketKey[:len(c.BucketKey)-1]
-	keyArg := c.BucketKey[len(c.BucketKey)-1]
-
-	key, err := parseBytes(keyArg, c.ParseFormat)
+	buckets, keyArgs, err := c.bucketsAndKeys()
 	if err != nil {
 		return err
 	}
 
-	if len(key) == 0 {
-		return fmt.Errorf("key is required: %w", errors.ErrKeyRequired)
+	keys := make([][]byte, 0, len(keyArgs))
+	for _, keyArg := range keyArgs {
+		key, err := parseBytes(keyArg, c.ParseFormat)
+		if err != nil {
+			return err
+		}
+		if len(key) == 0 {
+			return fmt.Errorf("key is required: %w", errors.ErrKeyRequired)
+		}
+		keys = append(keys, key)
 	}
 
 	// check if the source DB path is valid
@@ -54,10 +61,51 @@ func (c *GetCmd) Run() error {
 		if err != nil {
 			return err
 		}
-		val := lastBucket.Get(key)
-		if val == nil {
-			return fmt.Errorf("Error %w for key: %q hex: \"%x\"", ErrKeyNotFound, key, string(key))
+		var printed int
+		for _, key := range keys {
+			val := lastBucket.Get(key)
+			if val == nil {
+				if c.SkipMissing {
+					continue
+				}
+				return fmt.Errorf("Error %w for key: %q hex: \"%x\"", ErrKeyNotFound, key, string(key))
+			}
+			if printed > 0 && c.Delimiter != "" {
+				fmt.Println(c.Delimiter)
+			}
+			if err := writelnBytes(os.Stdout, val, c.Format); err != nil {
+				return err
+			}
+			printed++
 		}
-		return writelnBytes(os.Stdout, val, c.Format)
+		return nil
 	})
 }
+
+// bucketsAndKeys splits the positional arguments into the bucket path and the
+// keys to retrieve. Without --key or --keys-file the last argument is the key.
+func (c *GetCmd) bucketsAndKeys() ([]string, []string, error) {
+	if len(c.Key) == 0 && c.KeysFile == "" {
+		if len(c.BucketKey) < 2 {
+			return nil, nil, fmt.Errorf("bucket is required: %w", ErrBucketRequired)
+		}
+		return c.BucketKey[:len(c.BucketKey)-1], c.BucketKey[len(c.BucketKey)-1:], nil
+	}
+
+	keys := slices.Clone(c.Key)
+	if c.KeysFile != "" {
+		data, err := os.ReadFile(c.KeysFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimSuffix(line, "\r"); line != "" {
+				keys = append(keys, line)
+			}
+		}
+	}
+	if len(keys) == 0 {
+		return nil, nil, fmt.Errorf("key is required: %w", errors.ErrKeyRequired)
+	}
+	return c.BucketKey, keys, nil
+}
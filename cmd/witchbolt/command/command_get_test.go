@@ -1,13 +1,17 @@
 package command_test
 
 import (
+	"encoding/binary"
 	"encoding/hex"
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 
 	"github.com/delaneyj/witchbolt"
+	"github.com/delaneyj/witchbolt/cmd/witchbolt/command"
 	"github.com/delaneyj/witchbolt/internal/btesting"
 )
 
@@ -67,6 +71,68 @@ func TestGetCommand_Run(t *testing.T) {
 	}
 }
 
+func TestGetCommand_IntegerFormats(t *testing.T) {
+	db := btesting.MustCreateDB(t)
+	require.NoError(t, db.Update(func(tx *witchbolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("counters"))
+		if err != nil {
+			return err
+		}
+		return b.Put(binary.BigEndian.AppendUint64(nil, 42), binary.BigEndian.AppendUint64(nil, uint64(1<<64-5)))
+	}))
+	db.Close()
+	defer requireDBNoChange(t, dbData(t, db.Path()), db.Path())
+
+	res := runCLI(t, "get", "--parse-format", "uint64", "--format", "int64", db.Path(), "counters", "42")
+	require.NoError(t, res.err)
+	require.Equal(t, "-5\n", res.stdout)
+
+	res = runCLI(t, "get", "--parse-format", "int64", "--format", "uint64", db.Path(), "counters", "42")
+	require.NoError(t, res.err)
+	require.Equal(t, "18446744073709551611\n", res.stdout)
+}
+
+func TestGetCommand_MultipleKeys(t *testing.T) {
+	db := btesting.MustCreateDB(t)
+	require.NoError(t, db.Update(func(tx *witchbolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("foo"))
+		if err != nil {
+			return err
+		}
+		nested, err := b.CreateBucket([]byte("nested"))
+		if err != nil {
+			return err
+		}
+		for i := 0; i < 3; i++ {
+			if err := nested.Put([]byte(fmt.Sprintf("k%d", i)), []byte(fmt.Sprintf("v%d", i))); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	db.Close()
+	defer requireDBNoChange(t, dbData(t, db.Path()), db.Path())
+
+	t.Log("Repeating --key makes every positional argument a bucket")
+	res := runCLI(t, "get", db.Path(), "foo", "nested", "--key", "k2", "--key", "k0")
+	require.NoError(t, res.err)
+	require.Equal(t, "v2\nv0\n", res.stdout)
+
+	t.Log("Reading keys from a file, with a delimiter between the values")
+	keysFile := filepath.Join(t.TempDir(), "keys")
+	require.NoError(t, os.WriteFile(keysFile, []byte("k0\nk1\n\nk2\n"), 0600))
+	res = runCLI(t, "get", db.Path(), "foo", "nested", "--keys-file", keysFile, "--key", "k1", "--delimiter=---")
+	require.NoError(t, res.err)
+	require.Equal(t, "v1\n---\nv0\n---\nv1\n---\nv2\n", res.stdout)
+
+	t.Log("Missing keys fail unless skipped")
+	res = runCLI(t, "get", db.Path(), "foo", "nested", "--key", "k0", "--key", "missing")
+	require.ErrorIs(t, res.err, command.ErrKeyNotFound)
+	res = runCLI(t, "get", db.Path(), "foo", "nested", "--key", "missing", "--key", "k1", "--skip-missing", "--delimiter=---")
+	require.NoError(t, res.err)
+	require.Equal(t, "v1\n", res.stdout)
+}
+
 func TestGetCommand_NoArgs(t *testing.T) {
 	res := runCLI(t, "get")
 	require.Error(t, res.err)
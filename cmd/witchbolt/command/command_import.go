@@ -0,0 +1,250 @@
+package command
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/fxamacker/cbor/v2"
+
+	"github.com/delaneyj/witchbolt"
+)
+
+type ImportCmd struct {
+	Input     string `short:"i" required:"" help:"Archive written by export" type:"existingfile"`
+	Output    string `short:"o" required:"" help:"Database file to create" type:"path"`
+	PageSize  int    `help:"Page size of the new database (default: the OS page size)"`
+	BatchSize int    `default:"1000" help:"Number of buckets and keys written per transaction"`
+}
+
+func (c *ImportCmd) Run() (err error) {
+	if c.BatchSize <= 0 {
+		return ErrImportInvalidBatchSize
+	}
+	if _, err := os.Stat(c.Output); err == nil {
+		return fmt.Errorf("output file %q already exists", c.Output)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	f, err := os.Open(c.Input)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+
+	db, err := witchbolt.Open(c.Output, 0600, &witchbolt.Options{PageSize: c.PageSize})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := db.Close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	imp := &importer{db: db, batchSize: c.BatchSize}
+	if err := imp.readArchive(r); err != nil {
+		// Don't leave a partial database behind.
+		if imp.tx != nil {
+			_ = imp.tx.Rollback()
+		}
+		_ = db.Close()
+		_ = os.Remove(c.Output)
+		return fmt.Errorf("import %q: %w", c.Input, err)
+	}
+	if err := imp.commit(); err != nil {
+		return err
+	}
+
+	fmt.Printf("imported %d buckets and %d keys into %s\n", imp.buckets, imp.keys, c.Output)
+	return nil
+}
+
+// importer writes the contents of an archive to db, committing every
+// batchSize buckets and keys.
+type importer struct {
+	db        *witchbolt.DB
+	tx        *witchbolt.Tx
+	batchSize int
+	pending   int
+
+	buckets, keys int
+}
+
+// readArchive detects the format of the archive from its first value: a
+// cbor archive is not a json object, and a jsonl archive has more values
+// after the header. A json or cbor archive is a single document and is
+// decoded whole; only jsonl is read a record at a time.
+func (imp *importer) readArchive(r *bufio.Reader) error {
+	first, err := r.Peek(1)
+	for err == nil && (first[0] == ' ' || first[0] == '\t' || first[0] == '\r' || first[0] == '\n') {
+		_, _ = r.ReadByte()
+		first, err = r.Peek(1)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrImportInvalidArchive, err)
+	}
+
+	var doc exportDocument
+	if first[0] != '{' {
+		if err := cbor.NewDecoder(r).Decode(&doc); err != nil {
+			return fmt.Errorf("%w: %v", ErrImportInvalidArchive, err)
+		}
+		return imp.readDocument(&doc)
+	}
+
+	dec := json.NewDecoder(r)
+	if err := dec.Decode(&doc); err != nil {
+		return fmt.Errorf("%w: %v", ErrImportInvalidArchive, err)
+	}
+	if !dec.More() {
+		return imp.readDocument(&doc)
+	}
+	if err := checkExportHeader(doc.exportHeader); err != nil {
+		return err
+	}
+	for line := 2; ; line++ {
+		var rec exportRecord
+		if err := dec.Decode(&rec); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("%w: line %d: %v", ErrImportInvalidArchive, line, err)
+		}
+		if err := imp.readRecord(&rec); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+}
+
+func checkExportHeader(h exportHeader) error {
+	if h.Magic != exportMagic {
+		return fmt.Errorf("%w: magic %q, want %q", ErrImportInvalidArchive, h.Magic, exportMagic)
+	}
+	if h.Version != exportVersion {
+		return fmt.Errorf("%w: unsupported version %d, want %d", ErrImportInvalidArchive, h.Version, exportVersion)
+	}
+	return nil
+}
+
+func (imp *importer) readDocument(doc *exportDocument) error {
+	if err := checkExportHeader(doc.exportHeader); err != nil {
+		return err
+	}
+	for _, b := range doc.Buckets {
+		if err := imp.readBucket(nil, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (imp *importer) readBucket(parent []exportBytes, b *exportBucket) error {
+	path := append(parent[:len(parent):len(parent)], b.Name)
+	if err := imp.createBucket(path, b.Sequence); err != nil {
+		return err
+	}
+	for _, e := range b.Entries {
+		if err := imp.put(path, e.Key, e.Value); err != nil {
+			return err
+		}
+	}
+	for _, child := range b.Buckets {
+		if err := imp.readBucket(path, child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (imp *importer) readRecord(rec *exportRecord) error {
+	switch rec.Type {
+	case "bucket":
+		return imp.createBucket(rec.Path, rec.Sequence)
+	case "kv":
+		if rec.Key == nil || rec.Value == nil {
+			return fmt.Errorf("%w: kv record without a key or value", ErrImportInvalidArchive)
+		}
+		return imp.put(rec.Path, *rec.Key, *rec.Value)
+	default:
+		return fmt.Errorf("%w: unknown record type %q", ErrImportInvalidArchive, rec.Type)
+	}
+}
+
+func (imp *importer) createBucket(path []exportBytes, sequence uint64) error {
+	b, err := imp.bucket(path)
+	if err != nil {
+		return err
+	}
+	imp.buckets++
+	return b.SetSequence(sequence)
+}
+
+func (imp *importer) put(path []exportBytes, key, value exportBytes) error {
+	k, err := key.bytes()
+	if err != nil {
+		return fmt.Errorf("%w: key: %v", ErrImportInvalidArchive, err)
+	}
+	v, err := value.bytes()
+	if err != nil {
+		return fmt.Errorf("%w: value: %v", ErrImportInvalidArchive, err)
+	}
+	b, err := imp.bucket(path)
+	if err != nil {
+		return err
+	}
+	imp.keys++
+	return b.Put(k, v)
+}
+
+// bucket returns the bucket at path, creating it and its parents as needed,
+// in the current transaction. It starts a new transaction once the previous
+// one holds batchSize writes.
+func (imp *importer) bucket(path []exportBytes) (*witchbolt.Bucket, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("%w: empty bucket path", ErrImportInvalidArchive)
+	}
+	if imp.pending == imp.batchSize {
+		if err := imp.commit(); err != nil {
+			return nil, err
+		}
+	}
+	if imp.tx == nil {
+		tx, err := imp.db.Begin(true)
+		if err != nil {
+			return nil, err
+		}
+		imp.tx = tx
+	}
+	imp.pending++
+
+	var b *witchbolt.Bucket
+	for i, name := range path {
+		n, err := name.bytes()
+		if err != nil {
+			return nil, fmt.Errorf("%w: bucket name: %v", ErrImportInvalidArchive, err)
+		}
+		if i == 0 {
+			b, err = imp.tx.CreateBucketIfNotExists(n)
+		} else {
+			b, err = b.CreateBucketIfNotExists(n)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+func (imp *importer) commit() error {
+	if imp.tx == nil {
+		return nil
+	}
+	tx := imp.tx
+	imp.tx, imp.pending = nil, 0
+	return tx.Commit()
+}
@@ -0,0 +1,67 @@
+package command_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/delaneyj/witchbolt"
+	"github.com/delaneyj/witchbolt/cmd/witchbolt/command"
+)
+
+func TestImportCommand_RoundTrip(t *testing.T) {
+	src := exportTestDB(t)
+
+	for _, format := range []string{"json", "jsonl", "cbor"} {
+		t.Run(format, func(t *testing.T) {
+			dir := t.TempDir()
+			archive := filepath.Join(dir, "export."+format)
+			res := runCLI(t, "export", src, "-o", archive, "--format", format)
+			require.NoError(t, res.err)
+
+			dst := filepath.Join(dir, "db.new")
+			res = runCLI(t, "import", "-i", archive, "-o", dst, "--page-size", "8192", "--batch-size", "1")
+			require.NoError(t, res.err)
+			require.Equal(t, "imported 2 buckets and 2 keys into "+dst+"\n", res.stdout)
+
+			srcChk, err := chkdb(src)
+			require.NoError(t, err)
+			dstChk, err := chkdb(dst)
+			require.NoError(t, err)
+			require.Equal(t, srcChk, dstChk)
+
+			db, err := witchbolt.Open(dst, 0600, &witchbolt.Options{ReadOnly: true})
+			require.NoError(t, err)
+			defer db.Close()
+			require.Equal(t, 8192, db.Info().PageSize)
+			require.NoError(t, db.View(func(tx *witchbolt.Tx) error {
+				b := tx.Bucket([]byte("foo"))
+				require.EqualValues(t, 42, b.Sequence())
+				require.Equal(t, []byte{0, 1, 0xff}, b.Bucket([]byte("nested")).Get([]byte("binary")))
+				return nil
+			}))
+		})
+	}
+}
+
+func TestImportCommand_InvalidArchive(t *testing.T) {
+	dir := t.TempDir()
+	testCases := map[string]string{
+		"foreign magic":        `{"magic":"something-else","version":1,"buckets":[]}`,
+		"newer version":        `{"magic":"witchbolt-export","version":2,"buckets":[]}`,
+		"unknown jsonl record": "{\"magic\":\"witchbolt-export\",\"version\":1}\n{\"type\":\"nope\"}\n",
+	}
+	for name, archive := range testCases {
+		t.Run(name, func(t *testing.T) {
+			input := filepath.Join(dir, name)
+			require.NoError(t, os.WriteFile(input, []byte(archive), 0600))
+			dst := filepath.Join(dir, name+".db")
+
+			res := runCLI(t, "import", "-i", input, "-o", dst)
+			require.ErrorIs(t, res.err, command.ErrImportInvalidArchive)
+			require.NoFileExists(t, dst, "a failed import leaves no database behind")
+		})
+	}
+}
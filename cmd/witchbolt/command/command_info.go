@@ -1,19 +1,44 @@
 package command
 
 import (
+	"encoding/json"
 	"fmt"
 
 	"github.com/delaneyj/witchbolt"
+	"github.com/delaneyj/witchbolt/internal/guts_cli"
 )
 
 type InfoCmd struct {
 	Path string `arg:"" help:"Path to witchbolt database file" type:"path"`
+	JSON bool   `name:"json" help:"Print the page size, version, high water mark, meta pages, freelist page and file size as JSON, reading the file without opening the database."`
+}
+
+// infoJSON is the JSON output of the info command. HWM and Freelist come
+// from the active meta page, the valid one with the higher txid.
+type infoJSON struct {
+	PageSize   uint64         `json:"pageSize"`
+	Version    uint32         `json:"version"`
+	HWM        uint64         `json:"hwm"`
+	Freelist   uint64         `json:"freelist"`
+	FileSize   int64          `json:"fileSize"`
+	ActiveMeta int            `json:"activeMeta"`
+	Metas      []infoMetaJSON `json:"metas"`
+}
+
+type infoMetaJSON struct {
+	Txid     uint64 `json:"txid"`
+	Checksum string `json:"checksum"`
+	Valid    bool   `json:"valid"`
 }
 
 func (c *InfoCmd) Run() error {
-	if _, err := checkSourceDBPath(c.Path); err != nil {
+	fi, err := checkSourceDBPath(c.Path)
+	if err != nil {
 		return err
 	}
+	if c.JSON {
+		return printInfoJSON(c.Path, fi.Size())
+	}
 
 	// Open database.
 	db, err := witchbolt.Open(c.Path, 0600, &witchbolt.Options{
@@ -30,3 +55,38 @@ func (c *InfoCmd) Run() error {
 
 	return nil
 }
+
+// printInfoJSON reads both meta pages straight from the file, so it does not
+// take the database lock and works while another process has the DB open.
+func printInfoJSON(path string, fileSize int64) error {
+	pageSize, _, err := guts_cli.ReadPageAndHWMSize(path)
+	if err != nil {
+		return fmt.Errorf("read Page size failed: %w", err)
+	}
+	metas, active, err := readMetaPages(path)
+	if err != nil {
+		return err
+	}
+
+	out := infoJSON{PageSize: pageSize, FileSize: fileSize, ActiveMeta: -1}
+	for _, m := range metas {
+		out.Metas = append(out.Metas, infoMetaJSON{
+			Txid:     uint64(m.Txid()),
+			Checksum: fmt.Sprintf("%016x", m.Checksum()),
+			Valid:    m.Validate() == nil,
+		})
+	}
+	if m := metas[active]; out.Metas[active].Valid {
+		out.ActiveMeta = active
+		out.Version = m.Version()
+		out.HWM = uint64(m.Pgid())
+		out.Freelist = uint64(m.Freelist())
+	}
+
+	data, err := json.MarshalIndent(out, "", "    ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
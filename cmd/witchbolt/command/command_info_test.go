@@ -1,10 +1,13 @@
 package command_test
 
 import (
+	"encoding/json"
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 
+	"github.com/delaneyj/witchbolt"
 	"github.com/delaneyj/witchbolt/internal/btesting"
 )
 
@@ -20,6 +23,78 @@ func TestInfoCommand_Run(t *testing.T) {
 	require.NoError(t, res.err)
 }
 
+func TestInfoCommand_JSON(t *testing.T) {
+	db := btesting.MustCreateDBWithOption(t, &witchbolt.Options{PageSize: 4096})
+	db.Close()
+	defer requireDBNoChange(t, dbData(t, db.Path()), db.Path())
+	fi, err := os.Stat(db.Path())
+	require.NoError(t, err)
+
+	res := runCLI(t, "info", "--json", db.Path())
+	require.NoError(t, res.err)
+	var info struct {
+		PageSize, HWM, Freelist uint64
+		Version                 uint32
+		FileSize                int64
+		ActiveMeta              int
+		Metas                   []struct {
+			Txid     uint64
+			Checksum string
+			Valid    bool
+		}
+	}
+	require.NoError(t, json.Unmarshal([]byte(res.stdout), &info))
+	require.EqualValues(t, 4096, info.PageSize)
+	require.EqualValues(t, 2, info.Version)
+	require.EqualValues(t, 4, info.HWM)
+	require.EqualValues(t, 2, info.Freelist)
+	require.Equal(t, fi.Size(), info.FileSize)
+	require.Equal(t, 1, info.ActiveMeta)
+	require.Len(t, info.Metas, 2)
+	require.EqualValues(t, 0, info.Metas[0].Txid)
+	require.EqualValues(t, 1, info.Metas[1].Txid)
+	require.Len(t, info.Metas[1].Checksum, 16)
+	require.True(t, info.Metas[0].Valid && info.Metas[1].Valid)
+
+	t.Log("Working while another process holds the database open")
+	rw, err := witchbolt.Open(db.Path(), 0600, nil)
+	require.NoError(t, err)
+	defer rw.Close()
+	res = runCLI(t, "info", "--json", db.Path())
+	require.NoError(t, res.err)
+}
+
+func TestInfoCommand_JSONInvalidMeta(t *testing.T) {
+	db := btesting.MustCreateDBWithOption(t, &witchbolt.Options{PageSize: 4096})
+	db.Close()
+
+	t.Log("Corrupting the meta page with the higher txid")
+	f, err := os.OpenFile(db.Path(), os.O_RDWR, 0600)
+	require.NoError(t, err)
+	_, err = f.WriteAt([]byte{0xff}, 4096+32) // the root bucket's page id
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	res := runCLI(t, "info", "--json", db.Path())
+	require.NoError(t, res.err)
+	var info struct {
+		ActiveMeta int
+		Metas      []struct {
+			Txid  uint64
+			Valid bool
+		}
+	}
+	require.NoError(t, json.Unmarshal([]byte(res.stdout), &info))
+	require.Equal(t, 0, info.ActiveMeta, "the valid meta page is active")
+	require.True(t, info.Metas[0].Valid)
+	require.False(t, info.Metas[1].Valid)
+	require.EqualValues(t, 1, info.Metas[1].Txid)
+
+	res = runCLI(t, "txid", db.Path())
+	require.NoError(t, res.err)
+	require.Contains(t, res.stdout, "TxID: 0\n", "txid agrees on the active meta page")
+}
+
 func TestInfoCommand_NoArgs(t *testing.T) {
 	res := runCLI(t, "info")
 	require.Error(t, res.err)
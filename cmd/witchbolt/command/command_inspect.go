@@ -8,13 +8,29 @@ import (
 )
 
 type InspectCmd struct {
-	Path string `arg:"" help:"Path to witchbolt database file" type:"path"`
+	Path     string   `arg:"" help:"Path to witchbolt database file" type:"path"`
+	MaxDepth int      `help:"Only descend this many levels of nested buckets (0 means no limit)"`
+	Bucket   []string `help:"Only inspect this bucket; repeat to name a nested bucket path"`
+}
+
+// inspectNode is witchbolt.BucketStructure with a record of the truncation
+// done by --max-depth: a node whose nested buckets were cut off has
+// Truncated set and their number in BucketN.
+type inspectNode struct {
+	Name      string         `json:"name"`
+	KeyN      int            `json:"keyN"`
+	Children  []*inspectNode `json:"buckets,omitempty"`
+	Truncated bool           `json:"truncated,omitempty"`
+	BucketN   int            `json:"bucketN,omitempty"`
 }
 
 func (c *InspectCmd) Run() error {
 	if _, err := checkSourceDBPath(c.Path); err != nil {
 		return err
 	}
+	if c.MaxDepth < 0 {
+		return fmt.Errorf("the max depth must not be negative, but got %d", c.MaxDepth)
+	}
 
 	db, err := witchbolt.Open(c.Path, 0600, &witchbolt.Options{ReadOnly: true})
 	if err != nil {
@@ -23,7 +39,16 @@ func (c *InspectCmd) Run() error {
 	defer db.Close()
 
 	return db.View(func(tx *witchbolt.Tx) error {
-		bs := tx.Inspect()
+		var bs any
+		if len(c.Bucket) == 0 {
+			bs = c.inspectRoot(tx)
+		} else {
+			b, err := findLastBucket(tx, c.Bucket)
+			if err != nil {
+				return err
+			}
+			bs = c.inspectBucket(c.Bucket[len(c.Bucket)-1], b, 0)
+		}
 		out, err := json.MarshalIndent(bs, "", "    ")
 		if err != nil {
 			return err
@@ -32,3 +57,38 @@ func (c *InspectCmd) Run() error {
 		return nil
 	})
 }
+
+// inspectRoot mirrors tx.Inspect, which has no Bucket for the root and
+// includes the reserved bucket.
+func (c *InspectCmd) inspectRoot(tx *witchbolt.Tx) *inspectNode {
+	node := &inspectNode{Name: "root"}
+	_ = forEachUserBucket(tx, func(name []byte, b *witchbolt.Bucket) error {
+		c.addChild(node, string(name), b, 0)
+		return nil
+	})
+	return node
+}
+
+func (c *InspectCmd) inspectBucket(name string, b *witchbolt.Bucket, depth int) *inspectNode {
+	node := &inspectNode{Name: name}
+	_ = b.ForEach(func(k, v []byte) error {
+		if v == nil {
+			c.addChild(node, string(k), b.Bucket(k), depth)
+		} else {
+			node.KeyN++
+		}
+		return nil
+	})
+	return node
+}
+
+// addChild adds the nested bucket b to node at depth, or counts it as cut
+// off once node is at the maximum depth.
+func (c *InspectCmd) addChild(node *inspectNode, name string, b *witchbolt.Bucket, depth int) {
+	if c.MaxDepth > 0 && depth == c.MaxDepth {
+		node.Truncated = true
+		node.BucketN++
+		return
+	}
+	node.Children = append(node.Children, c.inspectBucket(name, b, depth+1))
+}
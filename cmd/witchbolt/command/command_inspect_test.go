@@ -20,3 +20,52 @@ func TestInspect(t *testing.T) {
 	res := runCLI(t, "inspect", srcPath)
 	require.NoError(t, res.err)
 }
+
+func TestInspect_DepthAndBucket(t *testing.T) {
+	db := btesting.MustCreateDB(t)
+	require.NoError(t, db.Update(func(tx *witchbolt.Tx) error {
+		foo, err := tx.CreateBucket([]byte("foo"))
+		if err != nil {
+			return err
+		}
+		if err := foo.Put([]byte("key"), []byte("value")); err != nil {
+			return err
+		}
+		bar, err := foo.CreateBucket([]byte("bar"))
+		if err != nil {
+			return err
+		}
+		if _, err := bar.CreateBucket([]byte("baz")); err != nil {
+			return err
+		}
+		_, err = tx.CreateBucket([]byte("top"))
+		return err
+	}))
+	db.Close()
+	defer requireDBNoChange(t, dbData(t, db.Path()), db.Path())
+
+	t.Log("Cutting the tree below the top-level buckets")
+	res := runCLI(t, "inspect", db.Path(), "--max-depth", "1")
+	require.NoError(t, res.err)
+	require.JSONEq(t, `{"name": "root", "keyN": 0, "buckets": [
+		{"name": "foo", "keyN": 1, "truncated": true, "bucketN": 1},
+		{"name": "top", "keyN": 0}
+	]}`, res.stdout)
+
+	t.Log("Inspecting a nested bucket only")
+	res = runCLI(t, "inspect", db.Path(), "--bucket", "foo", "--bucket", "bar")
+	require.NoError(t, res.err)
+	require.JSONEq(t, `{"name": "bar", "keyN": 0, "buckets": [{"name": "baz", "keyN": 0}]}`, res.stdout)
+
+	res = runCLI(t, "inspect", db.Path(), "--bucket", "foo", "--max-depth", "1")
+	require.NoError(t, res.err)
+	require.JSONEq(t, `{"name": "foo", "keyN": 1, "buckets": [{"name": "bar", "keyN": 0, "truncated": true, "bucketN": 1}]}`, res.stdout)
+
+	t.Log("Without filters the output matches tx.Inspect")
+	res = runCLI(t, "inspect", db.Path())
+	require.NoError(t, res.err)
+	require.JSONEq(t, `{"name": "root", "keyN": 0, "buckets": [
+		{"name": "foo", "keyN": 1, "buckets": [{"name": "bar", "keyN": 0, "buckets": [{"name": "baz", "keyN": 0}]}]},
+		{"name": "top", "keyN": 0}
+	]}`, res.stdout)
+}
@@ -1,6 +1,7 @@
 package command
 
 import (
+	"bytes"
 	"os"
 
 	"github.com/delaneyj/witchbolt"
@@ -9,10 +10,34 @@ import (
 type KeysCmd struct {
 	Path    string   `arg:"" help:"Path to witchbolt database file" type:"path"`
 	Buckets []string `arg:"" help:"Bucket path (one or more bucket names)"`
-	Format  string   `short:"f" default:"auto" help:"Output format: auto|ascii-encoded|hex|bytes"`
+	Format  string   `short:"f" default:"auto" help:"Output format: auto|ascii-encoded|hex|bytes|uint64|int64"`
+
+	ParseFormat string `default:"ascii-encoded" help:"Input format of --prefix, --start and --end: ascii-encoded|hex|uint64|int64"`
+	Prefix      string `help:"Only print keys with this prefix"`
+	Start       string `help:"Only print keys at or after this key"`
+	End         string `help:"Only print keys before this key"`
+	Limit       int    `help:"Stop after printing this many keys (0 means no limit)"`
 }
 
 func (c *KeysCmd) Run() error {
+	var prefix, start, end []byte
+	var err error
+	if c.Prefix != "" {
+		if prefix, err = parseBytes(c.Prefix, c.ParseFormat); err != nil {
+			return err
+		}
+	}
+	if c.Start != "" {
+		if start, err = parseBytes(c.Start, c.ParseFormat); err != nil {
+			return err
+		}
+	}
+	if c.End != "" {
+		if end, err = parseBytes(c.End, c.ParseFormat); err != nil {
+			return err
+		}
+	}
+
 	if _, err := checkSourceDBPath(c.Path); err != nil {
 		return err
 	}
@@ -33,9 +58,25 @@ func (c *KeysCmd) Run() error {
 			return err
 		}
 
-		// Iterate over each key.
-		return lastBucket.ForEach(func(key, _ []byte) error {
-			return writelnBytes(os.Stdout, key, c.Format)
-		})
+		// Seek to the first key in range and stop as soon as one falls outside it.
+		seek := start
+		if bytes.Compare(prefix, seek) > 0 {
+			seek = prefix
+		}
+		var printed int
+		cur := lastBucket.Cursor()
+		for key, _ := cur.Seek(seek); key != nil; key, _ = cur.Next() {
+			if !bytes.HasPrefix(key, prefix) || (end != nil && bytes.Compare(key, end) >= 0) {
+				break
+			}
+			if c.Limit > 0 && printed == c.Limit {
+				break
+			}
+			if err := writelnBytes(os.Stdout, key, c.Format); err != nil {
+				return err
+			}
+			printed++
+		}
+		return nil
 	})
 }
@@ -1,6 +1,7 @@
 package command_test
 
 import (
+	"encoding/binary"
 	"fmt"
 	"testing"
 
@@ -73,6 +74,126 @@ func TestKeysCommand_Run(t *testing.T) {
 	}
 }
 
+func TestKeysCommand_IntegerFormats(t *testing.T) {
+	db := btesting.MustCreateDB(t)
+	require.NoError(t, db.Update(func(tx *witchbolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("ids"))
+		if err != nil {
+			return err
+		}
+		for _, id := range []uint64{7, 1234567890123, 1 << 63} {
+			if err := b.Put(binary.BigEndian.AppendUint64(nil, id), []byte{0}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	db.Close()
+	defer requireDBNoChange(t, dbData(t, db.Path()), db.Path())
+
+	res := runCLI(t, "keys", "--format", "uint64", db.Path(), "ids")
+	require.NoError(t, res.err)
+	require.Equal(t, "7\n1234567890123\n9223372036854775808\n", res.stdout)
+
+	res = runCLI(t, "keys", "--format", "int64", db.Path(), "ids")
+	require.NoError(t, res.err)
+	require.Equal(t, "7\n1234567890123\n-9223372036854775808\n", res.stdout)
+}
+
+func TestKeysCommand_IntegerFormatRequiresEightBytes(t *testing.T) {
+	db := btesting.MustCreateDB(t)
+	require.NoError(t, db.Update(func(tx *witchbolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("widgets"))
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte("short"), []byte{0})
+	}))
+	db.Close()
+
+	res := runCLI(t, "keys", "--format", "uint64", db.Path(), "widgets")
+	require.ErrorContains(t, res.err, "uint64 format requires 8 bytes, got 5")
+}
+
+func TestKeysCommand_Filters(t *testing.T) {
+	db := btesting.MustCreateDB(t)
+	require.NoError(t, db.Update(func(tx *witchbolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("widgets"))
+		if err != nil {
+			return err
+		}
+		for _, k := range []string{"a1", "a2", "a3", "b1", "b2", "c1"} {
+			if err := b.Put([]byte(k), []byte{0}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	db.Close()
+	defer requireDBNoChange(t, dbData(t, db.Path()), db.Path())
+
+	testCases := []struct {
+		name      string
+		args      []string
+		expOutput string
+	}{
+		{name: "prefix", args: []string{"--prefix", "b"}, expOutput: "b1\nb2\n"},
+		{name: "start", args: []string{"--start", "b2"}, expOutput: "b2\nc1\n"},
+		{name: "end", args: []string{"--end", "a3"}, expOutput: "a1\na2\n"},
+		{name: "range", args: []string{"--start", "a2", "--end", "b2"}, expOutput: "a2\na3\nb1\n"},
+		{name: "limit", args: []string{"--limit", "2"}, expOutput: "a1\na2\n"},
+		{name: "prefix after start", args: []string{"--prefix", "b", "--start", "a2"}, expOutput: "b1\nb2\n"},
+		{name: "hex bounds", args: []string{"--parse-format", "hex", "--start", "6132", "--limit", "1"}, expOutput: "a2\n"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res := runCLI(t, append([]string{"keys", db.Path(), "widgets"}, tc.args...)...)
+			require.NoError(t, res.err)
+			require.Equal(t, tc.expOutput, res.stdout)
+		})
+	}
+}
+
+func TestKeysCommand_IntegerFilters(t *testing.T) {
+	db := btesting.MustCreateDB(t)
+	require.NoError(t, db.Update(func(tx *witchbolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("ids"))
+		if err != nil {
+			return err
+		}
+		for _, id := range []int64{7, 300, 1234567890123, -5} {
+			if err := b.Put(binary.BigEndian.AppendUint64(nil, uint64(id)), []byte{0}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	db.Close()
+	defer requireDBNoChange(t, dbData(t, db.Path()), db.Path())
+
+	testCases := []struct {
+		name      string
+		args      []string
+		expOutput string
+	}{
+		{name: "uint64 without filters", args: []string{"--parse-format", "uint64", "--format", "uint64"}, expOutput: "7\n300\n1234567890123\n18446744073709551611\n"},
+		{name: "uint64 prefix", args: []string{"--parse-format", "uint64", "--format", "uint64", "--prefix", "300"}, expOutput: "300\n"},
+		{name: "uint64 start", args: []string{"--parse-format", "uint64", "--format", "uint64", "--start", "300"}, expOutput: "300\n1234567890123\n18446744073709551611\n"},
+		{name: "uint64 end", args: []string{"--parse-format", "uint64", "--format", "uint64", "--end", "300"}, expOutput: "7\n"},
+		{name: "int64 without filters", args: []string{"--parse-format", "int64", "--format", "int64"}, expOutput: "7\n300\n1234567890123\n-5\n"},
+		{name: "int64 prefix", args: []string{"--parse-format", "int64", "--format", "int64", "--prefix=-5"}, expOutput: "-5\n"},
+		{name: "int64 start", args: []string{"--parse-format", "int64", "--format", "int64", "--start", "8"}, expOutput: "300\n1234567890123\n-5\n"},
+		{name: "int64 end", args: []string{"--parse-format", "int64", "--format", "int64", "--end=-5"}, expOutput: "7\n300\n1234567890123\n"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res := runCLI(t, append([]string{"keys", db.Path(), "ids"}, tc.args...)...)
+			require.NoError(t, res.err)
+			require.Equal(t, tc.expOutput, res.stdout)
+		})
+	}
+}
+
 func TestKeyCommand_NoArgs(t *testing.T) {
 	res := runCLI(t, "keys")
 	require.Error(t, res.err)
@@ -1,6 +1,7 @@
 package command
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -10,10 +11,11 @@ import (
 )
 
 type PageCmd struct {
-	Path        string   `arg:"" help:"Path to witchbolt database file" type:"path"`
-	PageIDs     []string `arg:"" optional:"" help:"Page IDs to print"`
-	All         bool     `help:"List all pages"`
-	FormatValue string   `default:"auto" help:"Output format: auto|ascii-encoded|hex|bytes (applies to leaf page values)"`
+	Path         string   `arg:"" help:"Path to witchbolt database file" type:"path"`
+	PageIDs      []string `arg:"" optional:"" help:"Page IDs to print"`
+	All          bool     `help:"List all pages"`
+	FormatValue  string   `default:"auto" help:"Output format: auto|ascii-encoded|hex|bytes|uint64|int64 (applies to leaf page values)"`
+	FormatOutput string   `default:"text" enum:"text,json" help:"Output format: text|json"`
 }
 
 func (c *PageCmd) Run() error {
@@ -33,6 +35,10 @@ func (c *PageCmd) Run() error {
 		return err
 	}
 
+	if c.FormatOutput == "json" {
+		return printPagesJSON(os.Stdout, c.Path, pageIDs, c.All, c.FormatValue)
+	}
+
 	if c.All {
 		printAllPages(c.Path, c.FormatValue)
 	} else {
@@ -207,3 +213,161 @@ func pagePrintFreelist(w io.Writer, buf []byte) error {
 	fmt.Fprintf(w, "\n")
 	return nil
 }
+
+// pageJSON is the JSON form of a page. Exactly one of the type-specific
+// fields is set, matching Type; a page that cannot be read only has Error.
+type pageJSON struct {
+	ID       uint64            `json:"id"`
+	Type     string            `json:"type,omitempty"`
+	Size     int               `json:"size,omitempty"`
+	Overflow uint32            `json:"overflow"`
+	Error    string            `json:"error,omitempty"`
+	Meta     *pageMetaJSON     `json:"meta,omitempty"`
+	Leaf     *pageLeafJSON     `json:"leaf,omitempty"`
+	Branch   *pageBranchJSON   `json:"branch,omitempty"`
+	Freelist *pageFreelistJSON `json:"freelist,omitempty"`
+}
+
+type pageMetaJSON struct {
+	Version  uint32 `json:"version"`
+	PageSize uint32 `json:"pageSize"`
+	Flags    uint32 `json:"flags"`
+	Root     uint64 `json:"root"`
+	Freelist uint64 `json:"freelist"`
+	HWM      uint64 `json:"hwm"`
+	TxID     uint64 `json:"txid"`
+	Checksum string `json:"checksum"`
+}
+
+type pageLeafJSON struct {
+	Count int                `json:"count"`
+	Items []pageLeafItemJSON `json:"items"`
+}
+
+// pageLeafItemJSON is a leaf element: a value formatted with --format-value,
+// or the header of a nested bucket.
+type pageLeafItemJSON struct {
+	Key    string          `json:"key"`
+	Value  *string         `json:"value,omitempty"`
+	Bucket *pageBucketJSON `json:"bucket,omitempty"`
+}
+
+type pageBucketJSON struct {
+	Root     uint64 `json:"root"`
+	Sequence uint64 `json:"sequence"`
+}
+
+type pageBranchJSON struct {
+	Count int                  `json:"count"`
+	Items []pageBranchItemJSON `json:"items"`
+}
+
+type pageBranchItemJSON struct {
+	Key  string `json:"key"`
+	Pgid uint64 `json:"pgid"`
+}
+
+type pageFreelistJSON struct {
+	Count int      `json:"count"`
+	IDs   []uint64 `json:"ids"`
+}
+
+// printPagesJSON writes the given pages, or every page with all, as a JSON
+// array. Pages that fail to read are reported in their error field.
+func printPagesJSON(w io.Writer, path string, pageIDs []uint64, all bool, formatValue string) error {
+	pages := []pageJSON{}
+	if all {
+		_, hwm, err := guts_cli.ReadPageAndHWMSize(path)
+		if err != nil {
+			return fmt.Errorf("cannot read number of pages: %w", err)
+		}
+		for pageID := uint64(0); pageID < uint64(hwm); {
+			page := readPageJSON(path, pageID, formatValue)
+			pages = append(pages, page)
+			pageID += uint64(page.Overflow) + 1
+		}
+	} else {
+		for _, pageID := range pageIDs {
+			pages = append(pages, readPageJSON(path, pageID, formatValue))
+		}
+	}
+
+	out, err := json.MarshalIndent(pages, "", "    ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, string(out))
+	return nil
+}
+
+// readPageJSON reads and decodes a page like printPage does.
+func readPageJSON(path string, pageID uint64, formatValue string) (page pageJSON) {
+	defer func() {
+		if err := recover(); err != nil {
+			page = pageJSON{ID: pageID, Error: fmt.Sprintf("%s", err)}
+		}
+	}()
+
+	p, buf, err := guts_cli.ReadPage(path, pageID)
+	if err != nil {
+		return pageJSON{ID: pageID, Error: err.Error()}
+	}
+	page = pageJSON{ID: uint64(p.Id()), Type: p.Typ(), Size: len(buf), Overflow: p.Overflow()}
+
+	switch p.Typ() {
+	case "meta":
+		m := common.LoadPageMeta(buf)
+		page.Meta = &pageMetaJSON{
+			Version:  m.Version(),
+			PageSize: m.PageSize(),
+			Flags:    m.Flags(),
+			Root:     uint64(m.RootBucket().RootPage()),
+			Freelist: uint64(m.Freelist()),
+			HWM:      uint64(m.Pgid()),
+			TxID:     uint64(m.Txid()),
+			Checksum: fmt.Sprintf("%016x", m.Checksum()),
+		}
+	case "leaf":
+		leaf := &pageLeafJSON{Count: int(p.Count()), Items: []pageLeafItemJSON{}}
+		for i := uint16(0); i < p.Count(); i++ {
+			e := p.LeafPageElement(i)
+			item := pageLeafItemJSON{Key: pageKeyString(e.Key())}
+			if e.IsBucketEntry() {
+				b := e.Bucket()
+				item.Bucket = &pageBucketJSON{Root: uint64(b.RootPage()), Sequence: b.InSequence()}
+			} else {
+				v, err := formatBytes(e.Value(), formatValue)
+				if err != nil {
+					return pageJSON{ID: pageID, Error: err.Error()}
+				}
+				item.Value = &v
+			}
+			leaf.Items = append(leaf.Items, item)
+		}
+		page.Leaf = leaf
+	case "branch":
+		branch := &pageBranchJSON{Count: int(p.Count()), Items: []pageBranchItemJSON{}}
+		for i := uint16(0); i < p.Count(); i++ {
+			e := p.BranchPageElement(i)
+			branch.Items = append(branch.Items, pageBranchItemJSON{Key: pageKeyString(e.Key()), Pgid: uint64(e.Pgid())})
+		}
+		page.Branch = branch
+	case "freelist":
+		_, cnt := p.FreelistPageCount()
+		freelist := &pageFreelistJSON{Count: cnt, IDs: []uint64{}}
+		for _, id := range p.FreelistPageIds() {
+			freelist.IDs = append(freelist.IDs, uint64(id))
+		}
+		page.Freelist = freelist
+	}
+	return page
+}
+
+// pageKeyString returns a printable key as is and any other key hex encoded,
+// as the text output does without the quoting.
+func pageKeyString(key []byte) string {
+	if isPrintable(string(key)) {
+		return string(key)
+	}
+	return fmt.Sprintf("%x", key)
+}
@@ -16,7 +16,7 @@ type PageItemCmd struct {
 	ItemID    uint64 `arg:"" help:"Item ID"`
 	KeyOnly   bool   `help:"Print only the key"`
 	ValueOnly bool   `help:"Print only the value"`
-	Format    string `default:"auto" help:"Output format: auto|ascii-encoded|hex|bytes"`
+	Format    string `default:"auto" help:"Output format: auto|ascii-encoded|hex|bytes|uint64|int64"`
 }
 
 func (c *PageItemCmd) Run() error {
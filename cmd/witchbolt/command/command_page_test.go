@@ -1,6 +1,9 @@
 package command_test
 
 import (
+	"encoding/json"
+	"fmt"
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -95,3 +98,104 @@ func TestPageCommand_NoArgs(t *testing.T) {
 	require.Error(t, res.err)
 	require.Contains(t, res.err.Error(), "expected \"<path>\"")
 }
+
+func TestPageCommand_BeyondEndOfFile(t *testing.T) {
+	db := btesting.MustCreateDBWithOption(t, &witchbolt.Options{PageSize: 4096})
+	db.Close()
+	defer requireDBNoChange(t, dbData(t, db.Path()), db.Path())
+
+	res := runCLI(t, "page", db.Path(), "100")
+	require.NoError(t, res.err)
+	require.Equal(t, "Prining page 100 failed: page 100 beyond end of file (size=16384). Continuing...\n", res.stdout)
+}
+
+func TestPageCommand_TruncatedMeta(t *testing.T) {
+	db := btesting.MustCreateDBWithOption(t, &witchbolt.Options{PageSize: 4096})
+	db.Close()
+	require.NoError(t, os.Truncate(db.Path(), 100))
+
+	res := runCLI(t, "page", db.Path(), "0")
+	require.NoError(t, res.err)
+	require.Contains(t, res.stdout, "meta page beyond end of file (size=100)")
+}
+
+func TestPageCommand_JSON(t *testing.T) {
+	db := btesting.MustCreateDBWithOption(t, &witchbolt.Options{PageSize: 4096})
+	require.NoError(t, db.Update(func(tx *witchbolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("widgets"))
+		if err != nil {
+			return err
+		}
+		for i := 0; i < 300; i++ {
+			if err := b.Put([]byte(fmt.Sprintf("key-%04d", i)), []byte("value")); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	db.Close()
+	defer requireDBNoChange(t, dbData(t, db.Path()), db.Path())
+
+	res := runCLI(t, "page", db.Path(), "--all", "--format-output", "json")
+	require.NoError(t, res.err)
+	var pages []struct {
+		ID       uint64
+		Type     string
+		Overflow uint32
+		Meta     *struct{ PageSize, Root uint64 }
+		Leaf     *struct {
+			Count int
+			Items []struct {
+				Key    string
+				Value  *string
+				Bucket *struct{ Root uint64 }
+			}
+		}
+		Branch *struct {
+			Count int
+			Items []struct {
+				Key  string
+				Pgid uint64
+			}
+		}
+		Freelist *struct{ Count int }
+	}
+	require.NoError(t, json.Unmarshal([]byte(res.stdout), &pages))
+
+	types := make(map[string]int)
+	var bucketRoot uint64
+	for _, p := range pages {
+		types[p.Type]++
+		switch p.Type {
+		case "meta":
+			require.EqualValues(t, 4096, p.Meta.PageSize)
+		case "leaf":
+			require.Equal(t, p.Leaf.Count, len(p.Leaf.Items))
+			for _, item := range p.Leaf.Items {
+				if item.Key == "widgets" {
+					require.NotNil(t, item.Bucket)
+					require.Nil(t, item.Value)
+					bucketRoot = item.Bucket.Root
+				} else {
+					require.Equal(t, "value", *item.Value)
+				}
+			}
+		case "branch":
+			require.Equal(t, p.Branch.Count, len(p.Branch.Items))
+			require.Equal(t, "key-0000", p.Branch.Items[0].Key)
+		}
+		require.Equal(t, p.Type == "freelist", p.Freelist != nil, "only freelist pages have a freelist")
+	}
+	require.Equal(t, 2, types["meta"])
+	require.Equal(t, 1, types["branch"])
+	require.NotZero(t, types["freelist"])
+	require.NotZero(t, bucketRoot, "the bucket was spilled to its own pages")
+
+	t.Log("Unreadable pages carry an error")
+	res = runCLI(t, "page", db.Path(), "0", "100", "--format-output", "json")
+	require.NoError(t, res.err)
+	require.NoError(t, json.Unmarshal([]byte(res.stdout), &pages))
+	require.Len(t, pages, 2)
+	require.Equal(t, "meta", pages[0].Type)
+	require.Contains(t, res.stdout, `"error": "page 100 beyond end of file (size=`)
+}
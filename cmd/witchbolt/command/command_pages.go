@@ -2,6 +2,7 @@ package command
 
 import (
 	"fmt"
+	"slices"
 	"strconv"
 
 	"github.com/delaneyj/witchbolt"
@@ -17,29 +18,45 @@ func (e *PageError) Error() string {
 }
 
 type PagesCmd struct {
-	Path string `arg:"" help:"Path to witchbolt database file" type:"path"`
+	Path       string   `arg:"" help:"Path to witchbolt database file" type:"path"`
+	Type       []string `name:"type" enum:"leaf,branch,meta,freelist,free" help:"Only list pages of this type: leaf|branch|meta|freelist|free. Repeatable."`
+	CountOnly  bool     `name:"count-only" help:"Print the number of pages of each type instead of the table."`
+	NoFreelist bool     `name:"no-freelist" help:"Don't load the freelist, so the database opens without rebuilding an unsynced one. Free pages are listed by the type in their header."`
 }
 
+// pageTypes lists the page types in the order --count-only prints them.
+var pageTypes = []string{"meta", "leaf", "branch", "freelist", "free"}
+
 func (c *PagesCmd) Run() error {
 	if _, err := checkSourceDBPath(c.Path); err != nil {
 		return err
 	}
+	if c.NoFreelist && slices.Contains(c.Type, "free") {
+		return ErrPagesFreeWithoutFreelist
+	}
 
 	// Open database.
-	db, err := witchbolt.Open(c.Path, 0600, &witchbolt.Options{
-		ReadOnly:        true,
-		PreLoadFreelist: true,
-	})
+	db, err := witchbolt.Open(c.Path, 0600, inspectOptions(c.Path, c.NoFreelist))
 	if err != nil {
 		return err
 	}
 	defer db.Close()
 
+	types := pageTypes
+	if len(c.Type) > 0 {
+		types = slices.DeleteFunc(slices.Clone(pageTypes), func(typ string) bool {
+			return !slices.Contains(c.Type, typ)
+		})
+	}
+	counts := make(map[string]int)
+
 	// Write header.
-	fmt.Println("ID       TYPE       ITEMS  OVRFLW")
-	fmt.Println("======== ========== ====== ======")
+	if !c.CountOnly {
+		fmt.Println("ID       TYPE       ITEMS  OVRFLW")
+		fmt.Println("======== ========== ====== ======")
+	}
 
-	return db.View(func(tx *witchbolt.Tx) error {
+	err = db.View(func(tx *witchbolt.Tx) error {
 		var id int
 		for {
 			p, err := tx.Page(id)
@@ -59,7 +76,12 @@ func (c *PagesCmd) Run() error {
 			}
 
 			// Print table row.
-			fmt.Printf("%-8d %-10s %-6s %-6s\n", p.ID, p.Type, count, overflow)
+			if slices.Contains(types, p.Type) {
+				counts[p.Type]++
+				if !c.CountOnly {
+					fmt.Printf("%-8d %-10s %-6s %-6s\n", p.ID, p.Type, count, overflow)
+				}
+			}
 
 			// Move to the next non-overflow page.
 			id += 1
@@ -69,4 +91,14 @@ func (c *PagesCmd) Run() error {
 		}
 		return nil
 	})
+	if err != nil || !c.CountOnly {
+		return err
+	}
+
+	fmt.Println("TYPE       COUNT")
+	fmt.Println("========== ======")
+	for _, typ := range types {
+		fmt.Printf("%-10s %d\n", typ, counts[typ])
+	}
+	return nil
 }
@@ -2,11 +2,14 @@ package command_test
 
 import (
 	"fmt"
+	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 
 	"github.com/delaneyj/witchbolt"
+	"github.com/delaneyj/witchbolt/cmd/witchbolt/command"
 	"github.com/delaneyj/witchbolt/internal/btesting"
 )
 
@@ -44,3 +47,82 @@ func TestPagesCommand_NoArgs(t *testing.T) {
 	require.Error(t, res.err)
 	require.Contains(t, res.err.Error(), "expected \"<path>\"")
 }
+
+func TestPagesCommand_TypeFilter(t *testing.T) {
+	db := btesting.MustCreateDB(t)
+	require.NoError(t, db.Update(func(tx *witchbolt.Tx) error {
+		_, err := tx.CreateBucket([]byte("widgets"))
+		return err
+	}))
+	db.Close()
+	defer requireDBNoChange(t, dbData(t, db.Path()), db.Path())
+
+	rows := func(stdout string) []string {
+		lines := strings.Split(strings.TrimSuffix(stdout, "\n"), "\n")
+		for i := range lines {
+			lines[i] = strings.TrimRight(lines[i], " ")
+		}
+		return lines[2:]
+	}
+
+	res := runCLI(t, "pages", db.Path(), "--type", "meta")
+	require.NoError(t, res.err)
+	require.Equal(t, []string{
+		"0        meta       0",
+		"1        meta       0",
+	}, rows(res.stdout))
+
+	res = runCLI(t, "pages", db.Path(), "--type", "leaf", "--type", "freelist")
+	require.NoError(t, res.err)
+	for _, row := range rows(res.stdout) {
+		require.Regexp(t, `^\d+ +(leaf|freelist) `, row)
+	}
+
+	t.Log("Tallying pages by type")
+	res = runCLI(t, "pages", db.Path(), "--count-only")
+	require.NoError(t, res.err)
+	require.Regexp(t, `^TYPE       COUNT\n========== ======\nmeta       2\nleaf       \d+\nbranch     0\nfreelist   1\nfree       \d+\n$`, res.stdout)
+
+	res = runCLI(t, "pages", db.Path(), "--count-only", "--type", "meta")
+	require.NoError(t, res.err)
+	require.Equal(t, "TYPE       COUNT\n========== ======\nmeta       2\n", res.stdout)
+
+	res = runCLI(t, "pages", db.Path(), "--type", "overflow")
+	require.Error(t, res.err)
+}
+
+func TestPagesCommand_NoFreelist(t *testing.T) {
+	db := btesting.MustCreateDBWithOption(t, &witchbolt.Options{NoFreelistSync: true})
+	require.NoError(t, db.Update(func(tx *witchbolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("widgets"))
+		if err != nil {
+			return err
+		}
+		for i := 0; i < 100; i++ {
+			if err := b.Put([]byte(fmt.Sprintf("%04d", i)), make([]byte, 500)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	require.NoError(t, db.Update(func(tx *witchbolt.Tx) error {
+		return tx.DeleteBucket([]byte("widgets"))
+	}))
+	db.Close()
+	defer requireDBNoChange(t, dbData(t, db.Path()), db.Path())
+
+	countRow := regexp.MustCompile(`(?m)^free +(\d+)$`)
+	res := runCLI(t, "pages", db.Path(), "--count-only")
+	require.NoError(t, res.err)
+	require.Contains(t, res.stderr, "rebuilding it by scanning the database")
+	require.NotEqual(t, "0", countRow.FindStringSubmatch(res.stdout)[1])
+
+	t.Log("Skipping the freelist")
+	res = runCLI(t, "pages", db.Path(), "--count-only", "--no-freelist")
+	require.NoError(t, res.err)
+	require.Empty(t, res.stderr)
+	require.Equal(t, "0", countRow.FindStringSubmatch(res.stdout)[1])
+
+	res = runCLI(t, "pages", db.Path(), "--no-freelist", "--type", "free")
+	require.ErrorIs(t, res.err, command.ErrPagesFreeWithoutFreelist)
+}
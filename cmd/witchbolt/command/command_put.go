@@ -0,0 +1,79 @@
+package command
+
+import (
+	"fmt"
+
+	"github.com/delaneyj/witchbolt"
+	"github.com/delaneyj/witchbolt/errors"
+)
+
+type PutCmd struct {
+	Path         string   `arg:"" help:"Path to witchbolt database file" type:"path"`
+	BucketKey    []string `arg:"" help:"Bucket path (one or more bucket names) followed by the key and the value to write" placeholder:"bucket [subbucket ...] key value"`
+	ParseFormat  string   `default:"ascii-encoded" help:"Input format of the key and value: ascii-encoded|hex|uint64|int64"`
+	CreateBucket bool     `help:"Create the buckets on the path that do not exist yet"`
+}
+
+func (c *PutCmd) Run() error {
+	if c.Path == "" {
+		return ErrPathRequired
+	}
+
+	if len(c.BucketKey) < 3 {
+		return fmt.Errorf("bucket is required: %w", ErrBucketRequired)
+	}
+
+	buckets := c.BucketKey[:len(c.BucketKey)-2]
+	key, err := parseBytes(c.BucketKey[len(c.BucketKey)-2], c.ParseFormat)
+	if err != nil {
+		return err
+	}
+	if len(key) == 0 {
+		return fmt.Errorf("key is required: %w", errors.ErrKeyRequired)
+	}
+	value, err := parseBytes(c.BucketKey[len(c.BucketKey)-1], c.ParseFormat)
+	if err != nil {
+		return err
+	}
+
+	// check if the source DB path is valid
+	if _, err := checkSourceDBPath(c.Path); err != nil {
+		return err
+	}
+
+	db, err := witchbolt.Open(c.Path, 0600, nil)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.Update(func(tx *witchbolt.Tx) error {
+		var lastBucket *witchbolt.Bucket
+		if c.CreateBucket {
+			lastBucket, err = createLastBucket(tx, buckets)
+		} else {
+			lastBucket, err = findLastBucket(tx, buckets)
+		}
+		if err != nil {
+			return err
+		}
+		return lastBucket.Put(key, value)
+	})
+}
+
+// createLastBucket is findLastBucket, creating each missing bucket on the path.
+func createLastBucket(tx *witchbolt.Tx, bucketNames []string) (*witchbolt.Bucket, error) {
+	if isReservedBucket([]byte(bucketNames[0])) {
+		return nil, fmt.Errorf("%w: %q", ErrReservedBucket, bucketNames[0])
+	}
+	lastBucket, err := tx.CreateBucketIfNotExists([]byte(bucketNames[0]))
+	if err != nil {
+		return nil, err
+	}
+	for _, bucket := range bucketNames[1:] {
+		if lastBucket, err = lastBucket.CreateBucketIfNotExists([]byte(bucket)); err != nil {
+			return nil, err
+		}
+	}
+	return lastBucket, nil
+}
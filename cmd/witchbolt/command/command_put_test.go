@@ -0,0 +1,49 @@
+package command_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/delaneyj/witchbolt"
+	"github.com/delaneyj/witchbolt/errors"
+	"github.com/delaneyj/witchbolt/internal/btesting"
+)
+
+func TestPutCommand_Run(t *testing.T) {
+	db := btesting.MustCreateDB(t)
+	require.NoError(t, db.Update(func(tx *witchbolt.Tx) error {
+		_, err := tx.CreateBucket([]byte("foo"))
+		return err
+	}))
+	db.Close()
+
+	t.Log("Writing a key into an existing bucket")
+	res := runCLI(t, "put", db.Path(), "foo", "key", "value")
+	require.NoError(t, res.err)
+	res = runCLI(t, "get", db.Path(), "foo", "key")
+	require.NoError(t, res.err)
+	require.Equal(t, "value\n", res.stdout)
+
+	t.Log("Parsing the key and value as hex")
+	res = runCLI(t, "put", "--parse-format", "hex", db.Path(), "foo", "6b6579", "00ff")
+	require.NoError(t, res.err)
+	res = runCLI(t, "get", "--format", "hex", db.Path(), "foo", "key")
+	require.NoError(t, res.err)
+	require.Equal(t, "00ff\n", res.stdout)
+
+	t.Log("Missing buckets are only created on request")
+	res = runCLI(t, "put", db.Path(), "bar", "nested", "key", "value")
+	require.ErrorIs(t, res.err, errors.ErrBucketNotFound)
+	res = runCLI(t, "put", "--create-bucket", db.Path(), "bar", "nested", "key", "value")
+	require.NoError(t, res.err)
+	res = runCLI(t, "get", db.Path(), "bar", "nested", "key")
+	require.NoError(t, res.err)
+	require.Equal(t, "value\n", res.stdout)
+}
+
+func TestPutCommand_NoArgs(t *testing.T) {
+	res := runCLI(t, "put")
+	require.Error(t, res.err)
+	require.Contains(t, res.err.Error(), "expected \"<path> <bucket-key> ...\"")
+}
@@ -2,14 +2,51 @@ package command
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
+	"os"
 
 	"github.com/delaneyj/witchbolt"
 )
 
 type StatsCmd struct {
-	Path   string `arg:"" help:"Path to witchbolt database file" type:"path"`
-	Prefix string `arg:"" optional:"" help:"Bucket name prefix filter"`
+	Path       string `arg:"" help:"Path to witchbolt database file" type:"path"`
+	Prefix     string `arg:"" optional:"" help:"Bucket name prefix filter"`
+	JSON       bool   `name:"json" help:"Print the statistics as JSON."`
+	PerBucket  bool   `name:"per-bucket" help:"Also print the statistics of each matching bucket."`
+	NoFreelist bool   `name:"no-freelist" help:"Don't load the freelist, so the database opens without rebuilding an unsynced one. The statistics don't depend on it."`
+}
+
+// statsJSON is the JSON output of the stats command. Buckets is only set with
+// --per-bucket.
+type statsJSON struct {
+	BucketCount int              `json:"bucketCount"`
+	Aggregate   bucketStatsJSON  `json:"aggregate"`
+	Buckets     []namedStatsJSON `json:"buckets,omitempty"`
+}
+
+type namedStatsJSON struct {
+	Name  string          `json:"name"`
+	Stats bucketStatsJSON `json:"stats"`
+}
+
+// bucketStatsJSON mirrors witchbolt.BucketStats with camelCase keys. The
+// fields match, so a BucketStats converts to it directly.
+type bucketStatsJSON struct {
+	BranchPageN       int `json:"branchPageN"`
+	BranchOverflowN   int `json:"branchOverflowN"`
+	LeafPageN         int `json:"leafPageN"`
+	LeafOverflowN     int `json:"leafOverflowN"`
+	KeyN              int `json:"keyN"`
+	Depth             int `json:"depth"`
+	BranchAlloc       int `json:"branchAlloc"`
+	BranchInuse       int `json:"branchInuse"`
+	LeafAlloc         int `json:"leafAlloc"`
+	LeafInuse         int `json:"leafInuse"`
+	BucketN           int `json:"bucketN"`
+	InlineBucketN     int `json:"inlineBucketN"`
+	InlineBucketInuse int `json:"inlineBucketInuse"`
 }
 
 func (c *StatsCmd) Run() error {
@@ -18,10 +55,7 @@ func (c *StatsCmd) Run() error {
 	}
 
 	// open database.
-	db, err := witchbolt.Open(c.Path, 0600, &witchbolt.Options{
-		ReadOnly:        true,
-		PreLoadFreelist: true,
-	})
+	db, err := witchbolt.Open(c.Path, 0600, inspectOptions(c.Path, c.NoFreelist))
 	if err != nil {
 		return err
 	}
@@ -30,55 +64,79 @@ func (c *StatsCmd) Run() error {
 	return db.View(func(tx *witchbolt.Tx) error {
 		var s witchbolt.BucketStats
 		var count int
-		if err := tx.ForEach(func(name []byte, b *witchbolt.Bucket) error {
+		var buckets []namedStatsJSON
+		if err := forEachUserBucket(tx, func(name []byte, b *witchbolt.Bucket) error {
 			if bytes.HasPrefix(name, []byte(c.Prefix)) {
-				s.Add(b.Stats())
+				bs := b.Stats()
+				s.Add(bs)
 				count += 1
+				if c.PerBucket {
+					buckets = append(buckets, namedStatsJSON{Name: string(name), Stats: bucketStatsJSON(bs)})
+				}
 			}
 			return nil
 		}); err != nil {
 			return err
 		}
 
-		fmt.Printf("Aggregate statistics for %d buckets\n\n", count)
-
-		fmt.Println("Page count statistics")
-		fmt.Printf("\tNumber of logical branch pages: %d\n", s.BranchPageN)
-		fmt.Printf("\tNumber of physical branch overflow pages: %d\n", s.BranchOverflowN)
-		fmt.Printf("\tNumber of logical leaf pages: %d\n", s.LeafPageN)
-		fmt.Printf("\tNumber of physical leaf overflow pages: %d\n", s.LeafOverflowN)
-
-		fmt.Println("Tree statistics")
-		fmt.Printf("\tNumber of keys/value pairs: %d\n", s.KeyN)
-		fmt.Printf("\tNumber of levels in B+tree: %d\n", s.Depth)
-
-		fmt.Println("Page size utilization")
-		fmt.Printf("\tBytes allocated for physical branch pages: %d\n", s.BranchAlloc)
-		var percentage int
-		if s.BranchAlloc != 0 {
-			percentage = int(float32(s.BranchInuse) * 100.0 / float32(s.BranchAlloc))
-		}
-		fmt.Printf("\tBytes actually used for branch data: %d (%d%%)\n", s.BranchInuse, percentage)
-		fmt.Printf("\tBytes allocated for physical leaf pages: %d\n", s.LeafAlloc)
-		percentage = 0
-		if s.LeafAlloc != 0 {
-			percentage = int(float32(s.LeafInuse) * 100.0 / float32(s.LeafAlloc))
+		if c.JSON {
+			out := statsJSON{BucketCount: count, Aggregate: bucketStatsJSON(s), Buckets: buckets}
+			data, err := json.MarshalIndent(out, "", "    ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+			return nil
 		}
-		fmt.Printf("\tBytes actually used for leaf data: %d (%d%%)\n", s.LeafInuse, percentage)
 
-		fmt.Println("Bucket statistics")
-		fmt.Printf("\tTotal number of buckets: %d\n", s.BucketN)
-		percentage = 0
-		if s.BucketN != 0 {
-			percentage = int(float32(s.InlineBucketN) * 100.0 / float32(s.BucketN))
+		for _, b := range buckets {
+			fmt.Printf("Statistics for bucket %q\n\n", b.Name)
+			printBucketStats(os.Stdout, witchbolt.BucketStats(b.Stats))
+			fmt.Println()
 		}
-		fmt.Printf("\tTotal number on inlined buckets: %d (%d%%)\n", s.InlineBucketN, percentage)
-		percentage = 0
-		if s.LeafInuse != 0 {
-			percentage = int(float32(s.InlineBucketInuse) * 100.0 / float32(s.LeafInuse))
-		}
-		fmt.Printf("\tBytes used for inlined buckets: %d (%d%%)\n", s.InlineBucketInuse, percentage)
 
+		fmt.Printf("Aggregate statistics for %d buckets\n\n", count)
+		printBucketStats(os.Stdout, s)
 		return nil
 	})
 }
+
+// printBucketStats prints s as a text block.
+func printBucketStats(w io.Writer, s witchbolt.BucketStats) {
+	fmt.Fprintln(w, "Page count statistics")
+	fmt.Fprintf(w, "\tNumber of logical branch pages: %d\n", s.BranchPageN)
+	fmt.Fprintf(w, "\tNumber of physical branch overflow pages: %d\n", s.BranchOverflowN)
+	fmt.Fprintf(w, "\tNumber of logical leaf pages: %d\n", s.LeafPageN)
+	fmt.Fprintf(w, "\tNumber of physical leaf overflow pages: %d\n", s.LeafOverflowN)
+
+	fmt.Fprintln(w, "Tree statistics")
+	fmt.Fprintf(w, "\tNumber of keys/value pairs: %d\n", s.KeyN)
+	fmt.Fprintf(w, "\tNumber of levels in B+tree: %d\n", s.Depth)
+
+	fmt.Fprintln(w, "Page size utilization")
+	fmt.Fprintf(w, "\tBytes allocated for physical branch pages: %d\n", s.BranchAlloc)
+	var percentage int
+	if s.BranchAlloc != 0 {
+		percentage = int(float32(s.BranchInuse) * 100.0 / float32(s.BranchAlloc))
+	}
+	fmt.Fprintf(w, "\tBytes actually used for branch data: %d (%d%%)\n", s.BranchInuse, percentage)
+	fmt.Fprintf(w, "\tBytes allocated for physical leaf pages: %d\n", s.LeafAlloc)
+	percentage = 0
+	if s.LeafAlloc != 0 {
+		percentage = int(float32(s.LeafInuse) * 100.0 / float32(s.LeafAlloc))
+	}
+	fmt.Fprintf(w, "\tBytes actually used for leaf data: %d (%d%%)\n", s.LeafInuse, percentage)
+
+	fmt.Fprintln(w, "Bucket statistics")
+	fmt.Fprintf(w, "\tTotal number of buckets: %d\n", s.BucketN)
+	percentage = 0
+	if s.BucketN != 0 {
+		percentage = int(float32(s.InlineBucketN) * 100.0 / float32(s.BucketN))
+	}
+	fmt.Fprintf(w, "\tTotal number on inlined buckets: %d (%d%%)\n", s.InlineBucketN, percentage)
+	percentage = 0
+	if s.LeafInuse != 0 {
+		percentage = int(float32(s.InlineBucketInuse) * 100.0 / float32(s.LeafInuse))
+	}
+	fmt.Fprintf(w, "\tBytes used for inlined buckets: %d (%d%%)\n", s.InlineBucketInuse, percentage)
+}
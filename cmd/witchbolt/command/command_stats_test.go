@@ -1,6 +1,7 @@
 package command_test
 
 import (
+	"encoding/json"
 	"os"
 	"strconv"
 	"testing"
@@ -128,3 +129,88 @@ func TestStatsCommand_NoArgs(t *testing.T) {
 	require.Error(t, res.err)
 	require.Contains(t, res.err.Error(), "expected \"<path>\"")
 }
+
+func TestStatsCommand_PerBucketAndJSON(t *testing.T) {
+	db := btesting.MustCreateDB(t)
+	require.NoError(t, db.Update(func(tx *witchbolt.Tx) error {
+		for name, n := range map[string]int{"small": 1, "bloated": 500, "other": 2} {
+			b, err := tx.CreateBucket([]byte(name))
+			if err != nil {
+				return err
+			}
+			for i := 0; i < n; i++ {
+				if err := b.Put([]byte(strconv.Itoa(i)), make([]byte, 100)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}))
+	db.Close()
+	defer requireDBNoChange(t, dbData(t, db.Path()), db.Path())
+
+	t.Log("Printing a block per matching bucket before the aggregate")
+	res := runCLI(t, "stats", db.Path(), "--per-bucket")
+	require.NoError(t, res.err)
+	require.Regexp(t, `(?s)^Statistics for bucket "bloated"\n\n.*\tNumber of keys/value pairs: 500\n.*`+
+		`Statistics for bucket "other"\n\n.*Statistics for bucket "small"\n\n.*`+
+		`Aggregate statistics for 3 buckets\n\n.*\tNumber of keys/value pairs: 503\n`, res.stdout)
+
+	t.Log("Emitting JSON, filtered by prefix")
+	res = runCLI(t, "stats", db.Path(), "s", "--json", "--per-bucket")
+	require.NoError(t, res.err)
+	var out struct {
+		BucketCount int            `json:"bucketCount"`
+		Aggregate   map[string]int `json:"aggregate"`
+		Buckets     []struct {
+			Name  string         `json:"name"`
+			Stats map[string]int `json:"stats"`
+		} `json:"buckets"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(res.stdout), &out))
+	require.Equal(t, 1, out.BucketCount)
+	require.Equal(t, map[string]int{
+		"branchPageN": 0, "branchOverflowN": 0, "leafPageN": 0, "leafOverflowN": 0,
+		"keyN": 1, "depth": 1,
+		"branchAlloc": 0, "branchInuse": 0, "leafAlloc": 0, "leafInuse": out.Aggregate["leafInuse"],
+		"bucketN": 1, "inlineBucketN": 1, "inlineBucketInuse": out.Aggregate["inlineBucketInuse"],
+	}, out.Aggregate, "every statistic has a camelCase key")
+	require.Len(t, out.Buckets, 1)
+	require.Equal(t, "small", out.Buckets[0].Name)
+	require.Equal(t, out.Aggregate, out.Buckets[0].Stats)
+
+	res = runCLI(t, "stats", db.Path(), "--json")
+	require.NoError(t, res.err)
+	require.NotContains(t, res.stdout, `"buckets"`, "per-bucket stats are opt-in")
+}
+
+// Ensure "stats --no-freelist" prints the same statistics without rebuilding
+// an unsynced freelist.
+func TestStatsCommand_NoFreelist(t *testing.T) {
+	db := btesting.MustCreateDBWithOption(t, &witchbolt.Options{NoFreelistSync: true})
+	require.NoError(t, db.Update(func(tx *witchbolt.Tx) error {
+		for _, name := range []string{"foo", "bar"} {
+			b, err := tx.CreateBucket([]byte(name))
+			if err != nil {
+				return err
+			}
+			for i := 0; i < 100; i++ {
+				if err := b.Put([]byte(strconv.Itoa(i)), make([]byte, 100)); err != nil {
+					return err
+				}
+			}
+		}
+		return tx.DeleteBucket([]byte("bar"))
+	}))
+	db.Close()
+	defer requireDBNoChange(t, dbData(t, db.Path()), db.Path())
+
+	res := runCLI(t, "stats", db.Path())
+	require.NoError(t, res.err)
+	require.Contains(t, res.stderr, "rebuilding it by scanning the database")
+
+	skipped := runCLI(t, "stats", "--no-freelist", db.Path())
+	require.NoError(t, skipped.err)
+	require.Empty(t, skipped.stderr)
+	require.Equal(t, res.stdout, skipped.stdout)
+}
@@ -0,0 +1,306 @@
+package command
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/delaneyj/witchbolt/stream"
+)
+
+// StreamCmd groups commands operating on stream replication artefacts.
+type StreamCmd struct {
+	Check            StreamCheckCmd            `cmd:"" help:"Verify that every configured replica is reachable"`
+	List             StreamListCmd             `cmd:"" help:"List the generations stored on every configured replica"`
+	Scrub            StreamScrubCmd            `cmd:"" help:"Verify the checksum of every artefact referenced by replica state"`
+	Verify           StreamVerifyCmd           `cmd:"" help:"Verify that each replica's head snapshot and segments form a restorable chain"`
+	Compact          StreamCompactCmd          `cmd:"" help:"Merge runs of small segments into one segment per run"`
+	Snapshot         StreamSnapshotCmd         `cmd:"" help:"Take and replicate a snapshot of a database immediately"`
+	Restore          StreamRestoreCmd          `cmd:"" help:"Restore a database from the first replica with a snapshot"`
+	DeleteGeneration StreamDeleteGenerationCmd `cmd:"" name:"delete-generation" help:"Delete every snapshot and segment of a generation from all replicas"`
+	ExportWAL        StreamExportWALCmd        `cmd:"" name:"export-wal" help:"Export the first replica's segments as a length-prefixed page frame stream"`
+	UpgradeArtefacts StreamUpgradeArtefactsCmd `cmd:"" name:"upgrade-artefacts" help:"Rewrite each replica's head snapshot and segments in the current artefact version"`
+}
+
+// loadStreamConfig reads a stream controller configuration from one or more
+// YAML or JSON files. Files ending in .yaml or .yml are parsed as YAML,
+// anything else as JSON. Gzip-compressed files (a .gz suffix or gzip magic
+// bytes) are decompressed first; the extension before .gz selects the format.
+// Several files are merged in order by mergeStreamConfigDocs before the
+// result is decoded.
+func loadStreamConfig(paths []string) (stream.Config, error) {
+	var cfg stream.Config
+	var merged map[string]any
+	for _, path := range paths {
+		doc, err := readStreamConfigDoc(path)
+		if err != nil {
+			return cfg, err
+		}
+		if merged == nil {
+			merged = doc
+		} else {
+			mergeStreamConfigDocs(merged, doc)
+		}
+	}
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return cfg, fmt.Errorf("merge stream config: %w", err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		if len(paths) == 1 {
+			return cfg, fmt.Errorf("parse stream config %q: %w", paths[0], err)
+		}
+		return cfg, fmt.Errorf("parse merged stream config: %w", err)
+	}
+	if err := expandStreamConfigEnv(&cfg); err != nil {
+		return cfg, fmt.Errorf("expand stream config: %w", err)
+	}
+	return cfg, nil
+}
+
+// readStreamConfigDoc decodes the config file at path into its generic JSON
+// form.
+func readStreamConfigDoc(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read stream config: %w", err)
+	}
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == ".gz" {
+		ext = strings.ToLower(filepath.Ext(strings.TrimSuffix(path, filepath.Ext(path))))
+	}
+	if isGzip(data) {
+		if data, err = gunzip(data); err != nil {
+			return nil, fmt.Errorf("decompress stream config %q: %w", path, err)
+		}
+	}
+	switch ext {
+	case ".yaml", ".yml":
+		var doc any
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("parse stream config %q: %w", path, err)
+		}
+		if data, err = json.Marshal(doc); err != nil {
+			return nil, fmt.Errorf("parse stream config %q: %w", path, err)
+		}
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parse stream config %q: %w", path, err)
+	}
+	if doc == nil {
+		doc = map[string]any{}
+	}
+	if err := checkReplicaNames(doc); err != nil {
+		return nil, fmt.Errorf("parse stream config %q: %w", path, err)
+	}
+	return doc, nil
+}
+
+// mergeStreamConfigDocs merges the config document override into base:
+//
+//   - objects are merged key by key, recursively
+//   - any other value in override, lists included, replaces the one in base
+//   - a null in override removes the key from base, restoring its default
+//   - the replicas list is the exception: an entry whose name matches a
+//     replica already in base is merged into it as an object, and any other
+//     entry is appended
+//
+// The name key of a replica entry is only used for merging; the replicas
+// themselves ignore it.
+func mergeStreamConfigDocs(base, override map[string]any) {
+	for k, v := range override {
+		if k == "replicas" {
+			baseList, ok1 := base[k].([]any)
+			overrideList, ok2 := v.([]any)
+			if ok1 && ok2 {
+				base[k] = mergeReplicaDocs(baseList, overrideList)
+				continue
+			}
+		}
+		mergeDocValue(base, k, v)
+	}
+}
+
+func mergeDocValue(base map[string]any, k string, v any) {
+	if v == nil {
+		delete(base, k)
+		return
+	}
+	baseMap, ok1 := base[k].(map[string]any)
+	overrideMap, ok2 := v.(map[string]any)
+	if !ok1 || !ok2 {
+		base[k] = v
+		return
+	}
+	for field, value := range overrideMap {
+		mergeDocValue(baseMap, field, value)
+	}
+}
+
+func mergeReplicaDocs(base, override []any) []any {
+	byName := make(map[string]map[string]any)
+	for _, entry := range base {
+		if name := replicaDocName(entry); name != "" {
+			byName[name] = entry.(map[string]any)
+		}
+	}
+	for _, entry := range override {
+		target, ok := byName[replicaDocName(entry)]
+		if !ok {
+			base = append(base, entry)
+			continue
+		}
+		for k, v := range entry.(map[string]any) {
+			mergeDocValue(target, k, v)
+		}
+	}
+	return base
+}
+
+// replicaDocName returns the name of a replica entry, or "" if it has none.
+func replicaDocName(entry any) string {
+	m, ok := entry.(map[string]any)
+	if !ok {
+		return ""
+	}
+	name, _ := m["name"].(string)
+	return name
+}
+
+// checkReplicaNames rejects a file naming two replicas alike, which would
+// make merging by name ambiguous.
+func checkReplicaNames(doc map[string]any) error {
+	replicas, _ := doc["replicas"].([]any)
+	seen := make(map[string]bool)
+	for i, entry := range replicas {
+		name := replicaDocName(entry)
+		if name == "" {
+			continue
+		}
+		if seen[name] {
+			return fmt.Errorf("replicas[%d]: duplicate replica name %q", i, name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+// expandStreamConfigEnv replaces ${VAR} references with the value of the
+// environment variable VAR in the replica fields that hold endpoints and
+// credentials, so secrets needn't be stored in the config file:
+//
+//   - s3: endpoint, accessKey, secretKey, sessionToken, sse.kmsKeyId and
+//     sse.customerKey
+//   - sftp: host, user, password and keyPath
+//   - nats: url, creds and nkey
+//   - webdav: url, user and password
+//
+// $$ stands for a literal $, and a $ not followed by { or $ is kept as is.
+// Referencing an unset variable is an error, rather than a silently empty
+// credential.
+func expandStreamConfigEnv(cfg *stream.Config) error {
+	for i, rc := range cfg.Replicas {
+		var fields []envField
+		switch rc := rc.(type) {
+		case *stream.S3CompatibleConfig:
+			fields = []envField{
+				{"endpoint", &rc.Endpoint},
+				{"accessKey", &rc.AccessKey},
+				{"secretKey", &rc.SecretKey},
+				{"sessionToken", &rc.SessionToken},
+				{"sse.kmsKeyId", &rc.SSE.KMSKeyID},
+				{"sse.customerKey", &rc.SSE.CustomerKey},
+			}
+		case *stream.SFTPReplicaConfig:
+			fields = []envField{
+				{"host", &rc.Host},
+				{"user", &rc.User},
+				{"password", &rc.Password},
+				{"keyPath", &rc.KeyPath},
+			}
+		case *stream.NATSReplicaConfig:
+			fields = []envField{
+				{"url", &rc.URL},
+				{"creds", &rc.Creds},
+				{"nkey", &rc.NKey},
+			}
+		case *stream.WebDAVReplicaConfig:
+			fields = []envField{
+				{"url", &rc.URL},
+				{"user", &rc.User},
+				{"password", &rc.Password},
+			}
+		}
+		for _, f := range fields {
+			expanded, err := expandEnvReferences(*f.value)
+			if err != nil {
+				return fmt.Errorf("replicas[%d].%s: %w", i, f.name, err)
+			}
+			*f.value = expanded
+		}
+	}
+	return nil
+}
+
+// envField is a config string that expandStreamConfigEnv expands, named as in
+// the config file.
+type envField struct {
+	name  string
+	value *string
+}
+
+// expandEnvReferences expands the ${VAR} references and $$ escapes of s. Its
+// errors don't quote s, which may hold a secret.
+func expandEnvReferences(s string) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		switch s[i+1] {
+		case '$':
+			b.WriteByte('$')
+			i++
+		case '{':
+			end := strings.IndexByte(s[i+2:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated ${ reference")
+			}
+			name := s[i+2 : i+2+end]
+			value, ok := os.LookupEnv(name)
+			if !ok {
+				return "", fmt.Errorf("environment variable %q is not set", name)
+			}
+			b.WriteString(value)
+			i += 2 + end
+		default:
+			b.WriteByte('$')
+		}
+	}
+	return b.String(), nil
+}
+
+func isGzip(data []byte) bool {
+	return len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b
+}
+
+func gunzip(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
@@ -0,0 +1,44 @@
+package command
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/delaneyj/witchbolt/stream"
+)
+
+type StreamCheckCmd struct {
+	Config []string `required:"" help:"Path to the stream configuration file (YAML or JSON); repeat to merge overrides, later files winning" type:"path" sep:"none"`
+}
+
+func (c *StreamCheckCmd) Run() error {
+	cfg, err := loadStreamConfig(c.Config)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	replicas, err := stream.BuildReplicas(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for _, replica := range replicas {
+			_ = replica.Close(ctx)
+		}
+	}()
+
+	var failed int
+	for _, replica := range replicas {
+		if err := replica.HealthCheck(ctx); err != nil {
+			failed++
+			fmt.Printf("%s: FAIL: %v\n", replica.Name(), err)
+			continue
+		}
+		fmt.Printf("%s: OK\n", replica.Name())
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d replicas failed the health check", failed, len(replicas))
+	}
+	return nil
+}
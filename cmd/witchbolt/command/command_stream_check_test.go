@@ -0,0 +1,91 @@
+package command_test
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamCheckCommand_Run(t *testing.T) {
+	healthy := t.TempDir()
+	cfgPath := writeStreamConfig(t, healthy, "")
+
+	res := runCLI(t, "stream", "check", "--config", cfgPath)
+	require.NoError(t, res.err)
+	require.Equal(t, healthy+": OK\n", res.stdout)
+
+	t.Log("Adding a WebDAV replica with nothing listening")
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	unreachable := "http://" + listener.Addr().String()
+	require.NoError(t, listener.Close())
+	cfgPath = writeStreamConfig(t, healthy, fmt.Sprintf("  - type: webdav\n    url: %s\n", unreachable))
+
+	res = runCLI(t, "stream", "check", "--config", cfgPath)
+	require.ErrorContains(t, res.err, "1 of 2 replicas failed the health check")
+	require.Contains(t, res.stdout, healthy+": OK\n")
+	require.Contains(t, res.stdout, unreachable+": FAIL: ")
+}
+
+func TestStreamCheckCommand_ExpandsEnv(t *testing.T) {
+	healthy := t.TempDir()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	unreachable := "http://" + listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	t.Setenv("WITCHBOLT_TEST_WEBDAV", unreachable)
+	cfgPath := writeStreamConfig(t, healthy, "  - type: webdav\n    url: ${WITCHBOLT_TEST_WEBDAV}/dav$$1\n")
+
+	res := runCLI(t, "stream", "check", "--config", cfgPath)
+	require.ErrorContains(t, res.err, "1 of 2 replicas failed the health check")
+	require.Contains(t, res.stdout, unreachable+"/dav$1: FAIL: ")
+
+	t.Log("Referencing an unset variable")
+	cfgPath = writeStreamConfig(t, healthy, "  - type: webdav\n    url: ${WITCHBOLT_TEST_UNSET}\n")
+	res = runCLI(t, "stream", "check", "--config", cfgPath)
+	require.ErrorContains(t, res.err, `replicas[1].url: environment variable "WITCHBOLT_TEST_UNSET" is not set`)
+}
+
+func TestStreamCheckCommand_MergedConfigs(t *testing.T) {
+	base, override, extra := t.TempDir(), t.TempDir(), t.TempDir()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	unreachable := "http://" + listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	dir := t.TempDir()
+	basePath := filepath.Join(dir, "base.yaml")
+	require.NoError(t, os.WriteFile(basePath, []byte(fmt.Sprintf(`snapshotInterval: 5m
+replicas:
+  - name: primary
+    type: file
+    path: %s
+  - type: webdav
+    url: %s
+`, base, unreachable)), 0600))
+	overridePath := filepath.Join(dir, "override.json")
+	require.NoError(t, os.WriteFile(overridePath, []byte(fmt.Sprintf(`{
+  "replicas": [
+    {"name": "primary", "path": %q},
+    {"name": "extra", "type": "file", "path": %q}
+  ]
+}`, override, extra)), 0600))
+
+	t.Log("Merging the override into the base config")
+	res := runCLI(t, "stream", "check", "--config", basePath, "--config", overridePath)
+	require.ErrorContains(t, res.err, "1 of 3 replicas failed the health check")
+	require.True(t, strings.HasPrefix(res.stdout, override+": OK\n"+unreachable+": FAIL: "), res.stdout)
+	require.Contains(t, res.stdout, extra+": OK\n")
+	require.NotContains(t, res.stdout, base)
+
+	t.Log("Rejecting a file that names two replicas alike")
+	require.NoError(t, os.WriteFile(overridePath, []byte(`{"replicas": [{"name": "a", "type": "file", "path": "x"}, {"name": "a", "type": "file", "path": "y"}]}`), 0600))
+	res = runCLI(t, "stream", "check", "--config", basePath, "--config", overridePath)
+	require.ErrorContains(t, res.err, `replicas[1]: duplicate replica name "a"`)
+}
@@ -0,0 +1,44 @@
+package command
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/delaneyj/witchbolt/stream"
+)
+
+type StreamCompactCmd struct {
+	Config     []string `required:"" help:"Path to the stream configuration file (YAML or JSON); repeat to merge overrides, later files winning" type:"path" sep:"none"`
+	Generation string   `help:"Generation to compact. Defaults to the generation each replica's state references."`
+}
+
+func (c *StreamCompactCmd) Run() error {
+	cfg, err := loadStreamConfig(c.Config)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	replicas, err := stream.BuildReplicas(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for _, replica := range replicas {
+			_ = replica.Close(ctx)
+		}
+	}()
+
+	compacted, err := stream.CompactReplicaSegments(ctx, replicas, c.Generation)
+	for _, run := range compacted {
+		fmt.Printf("%s: merged %d segments (txid %d-%d, %d pages) of generation %s\n",
+			run.Replica, run.Segments, run.FirstTxID, run.LastTxID, run.Pages, run.Generation)
+	}
+	if err != nil {
+		return err
+	}
+	if len(compacted) == 0 {
+		fmt.Println("nothing to compact")
+	}
+	return nil
+}
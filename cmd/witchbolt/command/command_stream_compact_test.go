@@ -0,0 +1,38 @@
+package command_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/delaneyj/witchbolt/stream"
+)
+
+func TestStreamCompactCommand_Run(t *testing.T) {
+	_, replicaPath := replicateSampleDB(t, 4)
+	cfgPath := writeStreamConfig(t, replicaPath, "")
+
+	replica, err := stream.NewFileReplica(&stream.FileReplicaConfig{Path: replicaPath})
+	require.NoError(t, err)
+	before, err := replica.LatestState(context.Background())
+	require.NoError(t, err)
+	require.Greater(t, len(before.Segments), 1)
+
+	t.Log("Compacting the replica")
+	res := runCLI(t, "stream", "compact", "--config", cfgPath)
+	require.NoError(t, res.err)
+	require.Contains(t, res.stdout, "merged")
+	require.Contains(t, res.stdout, "of generation "+before.Generation)
+
+	after, err := replica.LatestState(context.Background())
+	require.NoError(t, err)
+	require.Len(t, after.Segments, 1)
+	require.Equal(t, before.Segments[0].FirstTxID, after.Segments[0].FirstTxID)
+	require.Equal(t, before.Segments[len(before.Segments)-1].LastTxID, after.Segments[0].LastTxID)
+
+	t.Log("Compacting again finds nothing to merge")
+	res = runCLI(t, "stream", "compact", "--config", cfgPath)
+	require.NoError(t, res.err)
+	require.Equal(t, "nothing to compact\n", res.stdout)
+}
@@ -0,0 +1,42 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/delaneyj/witchbolt/stream"
+)
+
+type StreamDeleteGenerationCmd struct {
+	Config     []string `required:"" help:"Path to the stream configuration file (YAML or JSON); repeat to merge overrides, later files winning" type:"path" sep:"none"`
+	Generation string   `arg:"" help:"Generation to delete"`
+	Force      bool     `help:"Delete the generation even if a replica's state references it, leaving that replica with nothing to restore"`
+}
+
+func (c *StreamDeleteGenerationCmd) Run() error {
+	cfg, err := loadStreamConfig(c.Config)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	replicas, err := stream.BuildReplicas(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for _, replica := range replicas {
+			_ = replica.Close(ctx)
+		}
+	}()
+
+	if err := stream.DeleteReplicaGeneration(ctx, replicas, c.Generation, c.Force); err != nil {
+		if errors.Is(err, stream.ErrGenerationInUse) {
+			return fmt.Errorf("%w; pass --force to delete it anyway", err)
+		}
+		return err
+	}
+	fmt.Printf("generation %s deleted from %d replicas\n", c.Generation, len(replicas))
+	return nil
+}
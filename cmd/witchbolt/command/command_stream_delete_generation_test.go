@@ -0,0 +1,61 @@
+package command_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/delaneyj/witchbolt/stream"
+)
+
+func TestStreamDeleteGenerationCommand_Run(t *testing.T) {
+	_, replicaPath := replicateSampleDB(t, 2)
+	cfgPath := writeStreamConfig(t, replicaPath, "")
+
+	ctx := context.Background()
+	replica, err := stream.NewFileReplica(&stream.FileReplicaConfig{Path: replicaPath})
+	require.NoError(t, err)
+	current, err := replica.LatestState(ctx)
+	require.NoError(t, err)
+
+	t.Log("Leaving a stale generation behind without updating the state manifest")
+	statePath := filepath.Join(replicaPath, "_state.json")
+	manifest, err := os.ReadFile(statePath)
+	require.NoError(t, err)
+	require.NoError(t, replica.PutSnapshot(ctx, "stale", &stream.Snapshot{
+		Header: stream.SnapshotHeader{TxID: 1, CreatedAt: time.Now().UTC()},
+		Data:   []byte("snapshot"),
+	}))
+	require.NoError(t, os.WriteFile(statePath, manifest, 0600))
+	generations, err := replica.ListGenerations(ctx)
+	require.NoError(t, err)
+	require.Len(t, generations, 2)
+
+	t.Log("Refusing to delete the generation the state references")
+	res := runCLI(t, "stream", "delete-generation", "--config", cfgPath, current.Generation)
+	require.ErrorIs(t, res.err, stream.ErrGenerationInUse)
+	require.ErrorContains(t, res.err, "--force")
+
+	t.Log("Deleting the stale generation")
+	res = runCLI(t, "stream", "delete-generation", "--config", cfgPath, "stale")
+	require.NoError(t, res.err)
+	require.Equal(t, "generation stale deleted from 1 replicas\n", res.stdout)
+	generations, err = replica.ListGenerations(ctx)
+	require.NoError(t, err)
+	require.Equal(t, current.Generation, generations[0].ID)
+	require.Len(t, generations, 1)
+
+	t.Log("Forcing deletion of the current generation clears the state")
+	res = runCLI(t, "stream", "delete-generation", "--config", cfgPath, "--force", current.Generation)
+	require.NoError(t, res.err)
+	generations, err = replica.ListGenerations(ctx)
+	require.NoError(t, err)
+	require.Empty(t, generations)
+	state, err := replica.LatestState(ctx)
+	require.NoError(t, err)
+	require.Empty(t, state.Generation)
+}
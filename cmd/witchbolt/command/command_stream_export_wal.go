@@ -0,0 +1,53 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/delaneyj/witchbolt/stream"
+)
+
+type StreamExportWALCmd struct {
+	Config []string `required:"" help:"Path to the stream configuration file (YAML or JSON); repeat to merge overrides, later files winning" type:"path" sep:"none"`
+	Out    string   `required:"" help:"File to write the framed page stream to" type:"path"`
+}
+
+func (c *StreamExportWALCmd) Run() error {
+	cfg, err := loadStreamConfig(c.Config)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	replicas, err := stream.BuildReplicas(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for _, replica := range replicas {
+			_ = replica.Close(ctx)
+		}
+	}()
+	if len(replicas) == 0 {
+		return fmt.Errorf("stream config has no replicas")
+	}
+
+	f, err := os.Create(c.Out)
+	if err != nil {
+		return err
+	}
+	stats, err := stream.ExportWAL(ctx, replicas[0], f)
+	if err != nil {
+		_ = f.Close()
+		_ = os.Remove(c.Out)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	fmt.Printf("exported %d frames from %d segments (txid %d-%d) of generation %s\n",
+		stats.Frames, stats.Segments, stats.FirstTxID, stats.LastTxID, stats.Generation)
+	return nil
+}
@@ -0,0 +1,44 @@
+package command_test
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamExportWALCommand_Run(t *testing.T) {
+	_, replicaPath := replicateSampleDB(t, 3)
+	cfgPath := writeStreamConfig(t, replicaPath, "")
+	outPath := filepath.Join(t.TempDir(), "export.wal")
+
+	res := runCLI(t, "stream", "export-wal", "--config", cfgPath, "--out", outPath)
+	require.NoError(t, res.err)
+
+	data, err := os.ReadFile(outPath)
+	require.NoError(t, err)
+	require.Equal(t, "WBWL", string(data[:4]))
+	pageSize := int(binary.BigEndian.Uint32(data[8:12]))
+	require.Positive(t, pageSize)
+
+	t.Log("Walking the frames back")
+	var txids []uint64
+	for off := 12; off < len(data); {
+		length := int(binary.BigEndian.Uint32(data[off:]))
+		frame := data[off+4 : off+4+length]
+		txid := binary.BigEndian.Uint64(frame[0:])
+		require.Zero(t, (len(frame)-16)%pageSize, "page data must be whole pages")
+		if len(txids) == 0 || txids[len(txids)-1] != txid {
+			txids = append(txids, txid)
+		}
+		off += 4 + length
+	}
+	require.GreaterOrEqual(t, len(txids), 2)
+	for i := 1; i < len(txids); i++ {
+		require.Less(t, txids[i-1], txids[i], "frames must be in txid order")
+	}
+	require.Contains(t, res.stdout, fmt.Sprintf("from %d segments", len(txids)))
+}
@@ -0,0 +1,63 @@
+package command
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/delaneyj/witchbolt/stream"
+)
+
+type StreamListCmd struct {
+	Config []string `required:"" help:"Path to the stream configuration file (YAML or JSON); repeat to merge overrides, later files winning" type:"path" sep:"none"`
+}
+
+func (c *StreamListCmd) Run() error {
+	cfg, err := loadStreamConfig(c.Config)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	replicas, err := stream.BuildReplicas(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for _, replica := range replicas {
+			_ = replica.Close(ctx)
+		}
+	}()
+
+	var failed int
+	for _, replica := range replicas {
+		generations, err := replica.ListGenerations(ctx)
+		if err != nil {
+			failed++
+			fmt.Printf("%s: FAIL: %v\n", replica.Name(), err)
+			continue
+		}
+		state, err := replica.LatestState(ctx)
+		if err != nil {
+			failed++
+			fmt.Printf("%s: FAIL: %v\n", replica.Name(), err)
+			continue
+		}
+		fmt.Printf("%s:\n", replica.Name())
+		if len(generations) == 0 {
+			fmt.Printf("  no generations\n")
+			continue
+		}
+		fmt.Printf("  %-18s %9s %9s %s\n", "GENERATION", "SNAPSHOTS", "SEGMENTS", "TXIDS")
+		for _, gen := range generations {
+			id := gen.ID
+			if state != nil && gen.ID == state.Generation {
+				id += "*"
+			}
+			fmt.Printf("  %-18s %9d %9d %d-%d\n", id, gen.Snapshots, gen.Segments, gen.MinTxID, gen.MaxTxID)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d replicas could not be listed", failed, len(replicas))
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package command_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/delaneyj/witchbolt/stream"
+)
+
+func TestStreamListCommand_Run(t *testing.T) {
+	_, replicaPath := replicateSampleDB(t, 3)
+	cfgPath := writeStreamConfig(t, replicaPath, "")
+
+	// The first snapshot supersedes the segment written with it. Prune it, as
+	// the controller's retention loop may not have got to it before Stop.
+	replica, err := stream.NewFileReplica(&stream.FileReplicaConfig{Path: replicaPath})
+	require.NoError(t, err)
+	require.NoError(t, replica.Prune(context.Background(), "", stream.RetentionConfig{SnapshotRetention: time.Hour}))
+
+	res := runCLI(t, "stream", "list", "--config", cfgPath)
+	require.NoError(t, res.err)
+	require.Contains(t, res.stdout, replicaPath+":\n")
+	require.Regexp(t, regexp.MustCompile(`(?m)^  GENERATION\s+SNAPSHOTS\s+SEGMENTS\s+TXIDS$`), res.stdout)
+	require.Regexp(t, regexp.MustCompile(`(?m)^  [0-9a-f]{16}\*\s+1\s+2\s+3-5$`), res.stdout)
+}
+
+func TestStreamListCommand_Empty(t *testing.T) {
+	replicaPath := t.TempDir()
+	cfgPath := writeStreamConfig(t, replicaPath, "")
+
+	res := runCLI(t, "stream", "list", "--config", cfgPath)
+	require.NoError(t, res.err)
+	require.Equal(t, replicaPath+":\n  no generations\n", res.stdout)
+}
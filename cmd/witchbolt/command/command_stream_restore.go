@@ -0,0 +1,122 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/delaneyj/witchbolt/stream"
+)
+
+type StreamRestoreCmd struct {
+	Config   []string `required:"" help:"Path to the stream configuration file (YAML or JSON); repeat to merge overrides, later files winning" type:"path" sep:"none"`
+	Output   string   `help:"Path to restore the database to, overriding restore.targetPath from the config" type:"path"`
+	Progress bool     `help:"Print a progress line with the phase, percentage and ETA to stderr"`
+}
+
+func (c *StreamRestoreCmd) Run() error {
+	cfg, err := loadStreamConfig(c.Config)
+	if err != nil {
+		return err
+	}
+	if c.Output != "" {
+		cfg.Restore.TargetPath = c.Output
+	}
+	if cfg.Restore.TargetPath == "" {
+		return fmt.Errorf("no restore target: pass --output or set restore.targetPath")
+	}
+	if _, err := os.Stat(cfg.Restore.TargetPath); err == nil {
+		return fmt.Errorf("restore target %q already exists", cfg.Restore.TargetPath)
+	}
+
+	var progress *restoreProgressPrinter
+	if c.Progress {
+		progress = newRestoreProgressPrinter(os.Stderr)
+		cfg.Restore.Plan = progress.plan
+		cfg.Restore.SnapshotProgress = progress.snapshot
+		cfg.Restore.Progress = progress.segments
+	}
+	events := &restoreEvents{}
+	cfg.Events = events
+
+	err = stream.RestoreStandalone(context.Background(), cfg)
+	if progress != nil {
+		progress.finish()
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("restored txid %d to %s\n", events.txid, cfg.Restore.TargetPath)
+	return nil
+}
+
+// restoreEvents records the TxID a restore reached.
+type restoreEvents struct {
+	stream.NopEvents
+	txid uint64
+}
+
+func (e *restoreEvents) OnRestore(_ string, txid uint64) {
+	e.txid = txid
+}
+
+// restoreProgressPrinter renders restore progress as a single line per phase,
+// rewritten in place with a carriage return. The percentage and ETA cover the
+// whole restore, counting each snapshot page and each segment as one unit.
+type restoreProgressPrinter struct {
+	out      io.Writer
+	pages    uint64
+	segCount int
+	start    time.Time
+	phase    string
+	percent  int
+}
+
+func newRestoreProgressPrinter(out io.Writer) *restoreProgressPrinter {
+	return &restoreProgressPrinter{out: out, start: time.Now(), percent: -1}
+}
+
+func (p *restoreProgressPrinter) plan(pages uint64, segments int) {
+	p.pages, p.segCount = pages, segments
+	p.start = time.Now()
+}
+
+func (p *restoreProgressPrinter) snapshot(read, total int64) {
+	done := float64(p.pages) * float64(read) / float64(max(total, 1))
+	p.update("snapshot", done, fmt.Sprintf(" %d pages", p.pages))
+}
+
+func (p *restoreProgressPrinter) segments(applied, total int, txid uint64) {
+	p.update("segments", float64(p.pages)+float64(applied), fmt.Sprintf(" %d/%d, txid %d", applied, total, txid))
+}
+
+func (p *restoreProgressPrinter) update(phase string, done float64, detail string) {
+	if phase != p.phase {
+		p.finish()
+		p.phase = phase
+	}
+	fraction := min(done/float64(max(p.pages+uint64(p.segCount), 1)), 1)
+	percent := int(fraction * 100)
+	if percent == p.percent {
+		return
+	}
+	p.percent = percent
+	eta := "?"
+	if fraction > 0 {
+		elapsed := time.Since(p.start)
+		eta = (time.Duration(float64(elapsed)/fraction) - elapsed).Round(time.Second).String()
+	}
+	fmt.Fprintf(p.out, "\rrestoring %s: %3d%%%s, ETA %s", phase, percent, detail, eta)
+}
+
+// finish ends the current progress line.
+func (p *restoreProgressPrinter) finish() {
+	if p.phase != "" {
+		fmt.Fprintln(p.out)
+	}
+	p.phase = ""
+	p.percent = -1
+}
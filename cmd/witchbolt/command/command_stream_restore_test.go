@@ -0,0 +1,42 @@
+package command_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamRestoreCommand(t *testing.T) {
+	dbPath, replicaPath := replicateSampleDB(t, 3)
+	configPath := writeStreamConfig(t, replicaPath, "")
+	output := filepath.Join(t.TempDir(), "restored.db")
+
+	res := runCLI(t, "stream", "restore", "--config", configPath, "--output", output)
+	require.NoError(t, res.err)
+	require.Equal(t, fmt.Sprintf("restored txid %d to %s\n", readMetaPage(t, dbPath).Txid(), output), res.stdout)
+	require.Empty(t, res.stderr, "progress is only printed with --progress")
+	requireRestoredMatches(t, dbPath, output)
+
+	t.Log("Refusing to overwrite an existing database")
+	res = runCLI(t, "stream", "restore", "--config", configPath, "--output", output)
+	require.ErrorContains(t, res.err, "already exists")
+	_, err := os.Stat(output)
+	require.NoError(t, err)
+}
+
+func TestStreamRestoreCommand_Progress(t *testing.T) {
+	dbPath, replicaPath := replicateSampleDB(t, 3)
+	configPath := writeStreamConfig(t, replicaPath, "")
+	output := filepath.Join(t.TempDir(), "restored.db")
+
+	res := runCLI(t, "stream", "restore", "--config", configPath, "--output", output, "--progress")
+	require.NoError(t, res.err)
+	require.Regexp(t, regexp.MustCompile(`\rrestoring snapshot: +\d+% \d+ pages, ETA `), res.stderr)
+	require.Regexp(t, regexp.MustCompile(`\rrestoring segments: 100% \d+/\d+, txid \d+, ETA 0s\n$`), res.stderr)
+	require.NotContains(t, res.stdout, "restoring", "progress goes to stderr")
+	requireRestoredMatches(t, dbPath, output)
+}
@@ -0,0 +1,46 @@
+package command
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/delaneyj/witchbolt/internal/guts_cli"
+	"github.com/delaneyj/witchbolt/stream"
+)
+
+type StreamScrubCmd struct {
+	Config []string `required:"" help:"Path to the stream configuration file (YAML or JSON); repeat to merge overrides, later files winning" type:"path" sep:"none"`
+}
+
+func (c *StreamScrubCmd) Run() error {
+	cfg, err := loadStreamConfig(c.Config)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	replicas, err := stream.BuildReplicas(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for _, replica := range replicas {
+			_ = replica.Close(ctx)
+		}
+	}()
+
+	scrubErrs, err := stream.ScrubReplicas(ctx, replicas)
+	if err != nil {
+		return err
+	}
+	for _, scrubErr := range scrubErrs {
+		fmt.Println(scrubErr.Error())
+	}
+
+	if len(scrubErrs) > 0 {
+		fmt.Printf("%d corrupt artefacts found\n", len(scrubErrs))
+		return guts_cli.ErrCorrupt
+	}
+	fmt.Println("OK")
+	return nil
+}
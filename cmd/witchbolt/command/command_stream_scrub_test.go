@@ -0,0 +1,64 @@
+package command_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/delaneyj/witchbolt/internal/guts_cli"
+	"github.com/delaneyj/witchbolt/stream"
+)
+
+func TestStreamScrubCommand_Run(t *testing.T) {
+	_, replicaPath := replicateSampleDB(t, 3)
+	cfgPath := writeStreamConfig(t, replicaPath, "")
+
+	t.Log("Scrubbing healthy replica")
+	res := runCLI(t, "stream", "scrub", "--config", cfgPath)
+	require.NoError(t, res.err)
+	require.Equal(t, "OK\n", res.stdout)
+
+	t.Log("Truncating the snapshot artefact")
+	replica, err := stream.NewFileReplica(&stream.FileReplicaConfig{Path: replicaPath})
+	require.NoError(t, err)
+	state, err := replica.LatestState(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, state.Snapshot)
+	require.NoError(t, os.Truncate(filepath.Join(replicaPath, state.Snapshot.Name), 16))
+
+	res = runCLI(t, "stream", "scrub", "--config", cfgPath)
+	require.ErrorIs(t, res.err, guts_cli.ErrCorrupt)
+	require.Contains(t, res.stdout, state.Snapshot.Name)
+	require.Contains(t, res.stdout, "1 corrupt artefacts found")
+}
+
+func TestStreamScrubCommand_GzipConfig(t *testing.T) {
+	_, replicaPath := replicateSampleDB(t, 2)
+	plain, err := os.ReadFile(writeStreamConfig(t, replicaPath, ""))
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err = zw.Write(plain)
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	cfgPath := filepath.Join(t.TempDir(), "stream.yaml.gz")
+	require.NoError(t, os.WriteFile(cfgPath, buf.Bytes(), 0600))
+
+	t.Log("Corrupting the snapshot so the report names the parsed replica path")
+	replica, err := stream.NewFileReplica(&stream.FileReplicaConfig{Path: replicaPath})
+	require.NoError(t, err)
+	state, err := replica.LatestState(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, state.Snapshot)
+	require.NoError(t, os.Truncate(filepath.Join(replicaPath, state.Snapshot.Name), 16))
+
+	res := runCLI(t, "stream", "scrub", "--config", cfgPath)
+	require.ErrorIs(t, res.err, guts_cli.ErrCorrupt)
+	require.Contains(t, res.stdout, replicaPath+": "+state.Snapshot.Name)
+}
@@ -0,0 +1,57 @@
+package command
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/delaneyj/witchbolt"
+	"github.com/delaneyj/witchbolt/stream"
+)
+
+type StreamSnapshotCmd struct {
+	Config []string `required:"" help:"Path to the stream configuration file (YAML or JSON); repeat to merge overrides, later files winning" type:"path" sep:"none"`
+	DB     string   `required:"" name:"db" help:"Path to the witchbolt database to snapshot" type:"path"`
+}
+
+func (c *StreamSnapshotCmd) Run() error {
+	if _, err := checkSourceDBPath(c.DB); err != nil {
+		return err
+	}
+	cfg, err := loadStreamConfig(c.Config)
+	if err != nil {
+		return err
+	}
+
+	db, err := witchbolt.Open(c.DB, 0600, &witchbolt.Options{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	replicas, err := stream.BuildReplicas(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	if len(replicas) == 0 {
+		return fmt.Errorf("stream config has no replicas")
+	}
+	ctrl, err := stream.NewController(db, cfg, replicas)
+	if err != nil {
+		for _, replica := range replicas {
+			_ = replica.Close(ctx)
+		}
+		return err
+	}
+	header, err := ctrl.Snapshot(ctx)
+	if stopErr := ctrl.Stop(ctx); err == nil {
+		err = stopErr
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("snapshot of txid %d written to %d replicas as generation %s\n",
+		header.TxID, len(replicas), ctrl.Status().CurrentGeneration)
+	return nil
+}
@@ -0,0 +1,78 @@
+package command_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/delaneyj/witchbolt"
+	"github.com/delaneyj/witchbolt/internal/btesting"
+	"github.com/delaneyj/witchbolt/stream"
+)
+
+func TestStreamSnapshotCommand_Run(t *testing.T) {
+	dbPath, replicaPath := replicateSampleDB(t, 3)
+	cfgPath := writeStreamConfig(t, replicaPath, "")
+	defer requireDBNoChange(t, dbData(t, dbPath), dbPath)
+
+	db, err := witchbolt.Open(dbPath, 0600, &witchbolt.Options{ReadOnly: true})
+	require.NoError(t, err)
+	var txid uint64
+	require.NoError(t, db.View(func(tx *witchbolt.Tx) error {
+		txid = uint64(tx.ID())
+		return nil
+	}))
+	require.NoError(t, db.Close())
+
+	t.Log("Taking a snapshot")
+	res := runCLI(t, "stream", "snapshot", "--config", cfgPath, "--db", dbPath)
+	require.NoError(t, res.err)
+	require.Contains(t, res.stdout, fmt.Sprintf("snapshot of txid %d written to 1 replicas", txid))
+
+	replica, err := stream.NewFileReplica(&stream.FileReplicaConfig{Path: replicaPath})
+	require.NoError(t, err)
+	state, err := replica.LatestState(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, state.Snapshot)
+	require.Empty(t, state.Segments)
+	require.Contains(t, res.stdout, "as generation "+state.Generation)
+	require.Contains(t, state.Snapshot.Name, fmt.Sprintf("%016x", txid))
+}
+
+func TestStreamSnapshotCommand_NeverReplicated(t *testing.T) {
+	db := btesting.MustCreateDB(t)
+	require.NoError(t, db.Update(func(tx *witchbolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("widgets"))
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte("key"), []byte("value"))
+	}))
+	db.Close()
+	defer requireDBNoChange(t, dbData(t, db.Path()), db.Path())
+	replicaPath := filepath.Join(t.TempDir(), "replica")
+	cfgPath := writeStreamConfig(t, replicaPath, "")
+
+	t.Log("Snapshotting a database without an identity")
+	res := runCLI(t, "stream", "snapshot", "--config", cfgPath, "--db", db.Path())
+	require.NoError(t, res.err)
+	require.Contains(t, res.stdout, "written to 1 replicas")
+
+	target := filepath.Join(t.TempDir(), "restored.db")
+	res = runCLI(t, "stream", "restore", "--config", cfgPath, "--output", target)
+	require.NoError(t, res.err)
+	requireRestoredMatches(t, db.Path(), target)
+}
+
+func TestStreamSnapshotCommand_NoReplicas(t *testing.T) {
+	dbPath, _ := replicateSampleDB(t, 1)
+	cfgPath := filepath.Join(t.TempDir(), "stream.yaml")
+	require.NoError(t, os.WriteFile(cfgPath, []byte("replicas: []\n"), 0600))
+
+	res := runCLI(t, "stream", "snapshot", "--config", cfgPath, "--db", dbPath)
+	require.ErrorContains(t, res.err, "no replicas")
+}
@@ -0,0 +1,65 @@
+package command_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/delaneyj/witchbolt"
+	"github.com/delaneyj/witchbolt/stream"
+)
+
+// replicateSampleDB creates a database replicating into a file replica, writes
+// a few transactions and returns the replica directory.
+func replicateSampleDB(t *testing.T, txCount int) (dbPath string, replicaPath string) {
+	t.Helper()
+	dir := t.TempDir()
+	dbPath = filepath.Join(dir, "db")
+	replicaPath = filepath.Join(dir, "replica")
+
+	db, err := witchbolt.Open(dbPath, 0600, nil)
+	require.NoError(t, err)
+	ctrl, err := stream.Enable(context.Background(), db, stream.Config{
+		ShadowDir: filepath.Join(dir, "shadow"),
+		Replicas:  []stream.ReplicaConfig{&stream.FileReplicaConfig{Path: replicaPath}},
+	})
+	require.NoError(t, err)
+	for i := 0; i < txCount; i++ {
+		err := db.Update(func(tx *witchbolt.Tx) error {
+			b, err := tx.CreateBucketIfNotExists([]byte("widgets"))
+			if err != nil {
+				return err
+			}
+			return b.Put([]byte(fmt.Sprintf("key-%d", i)), []byte(fmt.Sprintf("value-%d", i)))
+		})
+		require.NoError(t, err)
+	}
+	require.NoError(t, ctrl.Stop(context.Background()))
+	require.NoError(t, db.Close())
+	return dbPath, replicaPath
+}
+
+// writeStreamConfig writes a YAML stream config with a single file replica.
+func writeStreamConfig(t *testing.T, replicaPath string, extra string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "stream.yaml")
+	content := fmt.Sprintf("replicas:\n  - type: file\n    path: %s\n%s", replicaPath, extra)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+// requireRestoredMatches asserts that the database restored at path reached
+// the TxID of the source database and holds the same buckets, keys and values.
+func requireRestoredMatches(t *testing.T, sourcePath, path string) {
+	t.Helper()
+	require.Equal(t, readMetaPage(t, sourcePath).Txid(), readMetaPage(t, path).Txid(), "the restore reaches the source's TxID")
+	want, err := chkdb(sourcePath)
+	require.NoError(t, err)
+	got, err := chkdb(path)
+	require.NoError(t, err)
+	require.Equal(t, string(want), string(got))
+}
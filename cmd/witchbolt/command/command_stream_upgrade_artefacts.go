@@ -0,0 +1,41 @@
+package command
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/delaneyj/witchbolt/stream"
+)
+
+type StreamUpgradeArtefactsCmd struct {
+	Config []string `required:"" help:"Path to the stream configuration file (YAML or JSON); repeat to merge overrides, later files winning" type:"path" sep:"none"`
+}
+
+func (c *StreamUpgradeArtefactsCmd) Run() error {
+	cfg, err := loadStreamConfig(c.Config)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	replicas, err := stream.BuildReplicas(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for _, replica := range replicas {
+			_ = replica.Close(ctx)
+		}
+	}()
+
+	upgrades, err := stream.UpgradeReplicaArtefacts(ctx, replicas)
+	for _, upgrade := range upgrades {
+		if upgrade.Snapshots == 0 && upgrade.Segments == 0 {
+			fmt.Printf("%s: up to date\n", upgrade.Replica)
+			continue
+		}
+		fmt.Printf("%s: upgraded %d snapshots and %d segments in generation %s\n",
+			upgrade.Replica, upgrade.Snapshots, upgrade.Segments, upgrade.Generation)
+	}
+	return err
+}
@@ -0,0 +1,17 @@
+package command_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamUpgradeArtefactsCommand_Run(t *testing.T) {
+	_, replicaPath := replicateSampleDB(t, 3)
+	cfgPath := writeStreamConfig(t, replicaPath, "")
+
+	t.Log("Artefacts written by this build are already current")
+	res := runCLI(t, "stream", "upgrade-artefacts", "--config", cfgPath)
+	require.NoError(t, res.err)
+	require.Contains(t, res.stdout, ": up to date\n")
+}
@@ -0,0 +1,52 @@
+package command
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/delaneyj/witchbolt/stream"
+)
+
+type StreamVerifyCmd struct {
+	Config []string `required:"" help:"Path to the stream configuration file (YAML or JSON); repeat to merge overrides, later files winning" type:"path" sep:"none"`
+}
+
+func (c *StreamVerifyCmd) Run() error {
+	cfg, err := loadStreamConfig(c.Config)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	replicas, err := stream.BuildReplicas(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for _, replica := range replicas {
+			_ = replica.Close(ctx)
+		}
+	}()
+
+	var failed int
+	for _, replica := range replicas {
+		result, err := stream.VerifyReplica(ctx, replica)
+		if err != nil {
+			return err
+		}
+		if !result.OK() {
+			failed++
+			fmt.Printf("%s: FAIL: head txid %d\n", result.Replica, result.HeadTxID)
+			for _, err := range result.Errors {
+				fmt.Printf("  %v\n", err)
+			}
+			continue
+		}
+		fmt.Printf("%s: OK: generation %s, snapshot txid %d, %d segments, head txid %d\n",
+			result.Replica, result.Generation, result.SnapshotTxID, result.Segments, result.HeadTxID)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d replicas failed verification", failed, len(replicas))
+	}
+	return nil
+}
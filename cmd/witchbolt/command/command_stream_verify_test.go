@@ -0,0 +1,49 @@
+package command_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/delaneyj/witchbolt/stream"
+)
+
+func TestStreamVerifyCommand_Run(t *testing.T) {
+	_, replicaPath := replicateSampleDB(t, 3)
+	cfgPath := writeStreamConfig(t, replicaPath, "")
+
+	replica, err := stream.NewFileReplica(&stream.FileReplicaConfig{Path: replicaPath})
+	require.NoError(t, err)
+	state, err := replica.LatestState(context.Background())
+	require.NoError(t, err)
+	require.NotEmpty(t, state.Segments)
+	head := state.Segments[len(state.Segments)-1].LastTxID
+
+	t.Log("Verifying a healthy replica")
+	res := runCLI(t, "stream", "verify", "--config", cfgPath)
+	require.NoError(t, res.err)
+	require.Equal(t, fmt.Sprintf("%s: OK: generation %s, snapshot txid %d, %d segments, head txid %d\n",
+		replicaPath, state.Generation, state.Segments[0].FirstTxID-1, len(state.Segments), head), res.stdout)
+
+	t.Log("Removing the last segment")
+	last := state.Segments[len(state.Segments)-1]
+	require.NoError(t, os.Remove(filepath.Join(replicaPath, filepath.FromSlash(last.Name))))
+
+	res = runCLI(t, "stream", "verify", "--config", cfgPath)
+	require.ErrorContains(t, res.err, "1 of 1 replicas failed verification")
+	require.Contains(t, res.stdout, fmt.Sprintf("%s: FAIL: head txid %d\n", replicaPath, last.FirstTxID-1))
+	require.Contains(t, res.stdout, last.Name)
+}
+
+func TestStreamVerifyCommand_EmptyReplica(t *testing.T) {
+	replicaPath := t.TempDir()
+	cfgPath := writeStreamConfig(t, replicaPath, "")
+
+	res := runCLI(t, "stream", "verify", "--config", cfgPath)
+	require.Error(t, res.err)
+	require.Contains(t, res.stdout, "no snapshot to restore from")
+}
@@ -14,6 +14,7 @@ type SurgeryCmd struct {
 	RevertMetaPage    SurgeryRevertMetaPageCmd    `cmd:"" help:"Revert the meta page to undo the latest transaction."`
 	CopyPage          SurgeryCopyPageCmd          `cmd:"" help:"Copy a page to another page."`
 	ClearPage         SurgeryClearPageCmd         `cmd:"" help:"Clear all elements from a page."`
+	ClearPages        SurgeryClearPagesCmd        `cmd:"" help:"Overwrite a range of pages with empty leaf pages."`
 	ClearPageElements SurgeryClearPageElementsCmd `cmd:"" help:"Clear a range of elements from a page."`
 	Freelist          SurgeryFreelistCmd          `cmd:"" help:"Freelist related surgery commands."`
 	Meta              SurgeryMetaCmd              `cmd:"" help:"Meta page related surgery commands."`
@@ -68,6 +69,25 @@ func (c *SurgeryClearPageCmd) Run() error {
 	return surgeryClearPageFunc(c.Src, cfg)
 }
 
+type SurgeryClearPagesCmd struct {
+	Src    string `arg:"" help:"Path to witchbolt database file" type:"path"`
+	Output string `name:"output" required:"" help:"Path to the output database file" type:"path"`
+	From   uint64 `name:"from" required:"" help:"First page ID to clear."`
+	To     uint64 `name:"to" required:"" help:"Last page ID to clear (inclusive)."`
+}
+
+func (c *SurgeryClearPagesCmd) Run() error {
+	cfg := surgeryClearPagesOptions{
+		surgeryBaseOptions: surgeryBaseOptions{outputDBFilePath: c.Output},
+		fromPageId:         c.From,
+		toPageId:           c.To,
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	return surgeryClearPagesFunc(c.Src, cfg)
+}
+
 type SurgeryClearPageElementsCmd struct {
 	Src       string `arg:"" help:"Path to witchbolt database file" type:"path"`
 	Output    string `name:"output" required:"" help:"Path to the output database file" type:"path"`
@@ -198,6 +218,55 @@ func surgeryClearPageFunc(srcDBPath string, cfg surgeryClearPageOptions) error {
 	return nil
 }
 
+type surgeryClearPagesOptions struct {
+	surgeryBaseOptions
+	fromPageId uint64
+	toPageId   uint64
+}
+
+func (o *surgeryClearPagesOptions) Validate() error {
+	if err := o.surgeryBaseOptions.Validate(); err != nil {
+		return err
+	}
+	if o.fromPageId < 2 {
+		return fmt.Errorf("the from page must be at least 2, but got %d", o.fromPageId)
+	}
+	if o.toPageId < o.fromPageId {
+		return fmt.Errorf("the to page (%d) must not be smaller than the from page (%d)", o.toPageId, o.fromPageId)
+	}
+	return nil
+}
+
+func surgeryClearPagesFunc(srcDBPath string, cfg surgeryClearPagesOptions) error {
+	if _, err := checkSourceDBPath(srcDBPath); err != nil {
+		return err
+	}
+	_, hwm, err := guts_cli.ReadPageAndHWMSize(srcDBPath)
+	if err != nil {
+		return fmt.Errorf("read the high water mark failed: %w", err)
+	}
+	if cfg.toPageId >= uint64(hwm) {
+		return fmt.Errorf("the to page (%d) is beyond the high water mark (%d)", cfg.toPageId, hwm)
+	}
+
+	if err := common.CopyFile(srcDBPath, cfg.outputDBFilePath); err != nil {
+		return fmt.Errorf("[clear-pages] copy file failed: %w", err)
+	}
+
+	needAbandonFreelist, err := surgeon.ClearPages(cfg.outputDBFilePath, common.Pgid(cfg.fromPageId), common.Pgid(cfg.toPageId))
+	if err != nil {
+		return fmt.Errorf("clear-pages command failed: %w", err)
+	}
+
+	if needAbandonFreelist {
+		fmt.Fprintf(os.Stdout, "WARNING: The clearing has abandoned some pages that are not yet referenced from free list.\n")
+		fmt.Fprintf(os.Stdout, "Please consider executing `./witchbolt surgery freelist abandon ...`\n")
+	}
+
+	fmt.Fprintf(os.Stdout, "The pages [%d, %d] were cleared\n", cfg.fromPageId, cfg.toPageId)
+	return nil
+}
+
 type surgeryClearPageElementsOptions struct {
 	surgeryBaseOptions
 	pageId          uint64
@@ -212,6 +281,32 @@ func (o *surgeryClearPageElementsOptions) Validate() error {
 	if o.pageId < 2 {
 		return fmt.Errorf("the pageId must be at least 2, but got %d", o.pageId)
 	}
+	if o.startElementIdx < 0 {
+		return fmt.Errorf("the from-index must not be negative, but got %d", o.startElementIdx)
+	}
+	if o.endElementIdx != -1 && o.endElementIdx <= o.startElementIdx {
+		return fmt.Errorf("the to-index must be -1 or bigger than the from-index (%d), but got %d", o.startElementIdx, o.endElementIdx)
+	}
+	return nil
+}
+
+// validatePageRange checks that the page holds elements and that the range
+// fits its element count, before anything is copied.
+func (o *surgeryClearPageElementsOptions) validatePageRange(srcDBPath string) error {
+	p, _, err := guts_cli.ReadPage(srcDBPath, o.pageId)
+	if err != nil {
+		return fmt.Errorf("read page %d failed: %w", o.pageId, err)
+	}
+	if !p.IsLeafPage() && !p.IsBranchPage() {
+		return fmt.Errorf("can't clear elements in %q page %d", p.Typ(), o.pageId)
+	}
+	count := int(p.Count())
+	if o.startElementIdx >= count {
+		return fmt.Errorf("the from-index (%d) is out of range [0, %d) of page %d", o.startElementIdx, count, o.pageId)
+	}
+	if o.endElementIdx > count {
+		return fmt.Errorf("the to-index (%d) is out of range [1, %d] of page %d", o.endElementIdx, count, o.pageId)
+	}
 	return nil
 }
 
@@ -219,6 +314,9 @@ func surgeryClearPageElementFunc(srcDBPath string, cfg surgeryClearPageElementsO
 	if _, err := checkSourceDBPath(srcDBPath); err != nil {
 		return err
 	}
+	if err := cfg.validatePageRange(srcDBPath); err != nil {
+		return err
+	}
 
 	if err := common.CopyFile(srcDBPath, cfg.outputDBFilePath); err != nil {
 		return fmt.Errorf("[clear-page-element] copy file failed: %w", err)
@@ -239,21 +337,39 @@ func surgeryClearPageElementFunc(srcDBPath string, cfg surgeryClearPageElementsO
 }
 
 func readMetaPage(path string) (*common.Meta, error) {
+	metas, active, err := readMetaPages(path)
+	if err != nil {
+		return nil, err
+	}
+	return metas[active], nil
+}
+
+// readMetaPages reads both meta pages straight from the file and returns
+// them with the index of the active one: the valid meta with the higher
+// txid, or the higher txid if neither is valid.
+func readMetaPages(path string) ([2]*common.Meta, int, error) {
+	var m [2]*common.Meta
 	pageSize, _, err := guts_cli.ReadPageAndHWMSize(path)
 	if err != nil {
-		return nil, fmt.Errorf("read Page size failed: %w", err)
+		return m, 0, fmt.Errorf("read Page size failed: %w", err)
 	}
 
-	m := make([]*common.Meta, 2)
 	for i := 0; i < 2; i++ {
 		m[i], _, err = ReadMetaPageAt(path, uint32(i), uint32(pageSize))
 		if err != nil {
-			return nil, fmt.Errorf("read meta page %d failed: %w", i, err)
+			return m, 0, fmt.Errorf("read meta page %d failed: %w", i, err)
 		}
 	}
 
+	valid0, valid1 := m[0].Validate() == nil, m[1].Validate() == nil
+	if valid0 != valid1 {
+		if valid0 {
+			return m, 0, nil
+		}
+		return m, 1, nil
+	}
 	if m[0].Txid() > m[1].Txid() {
-		return m[0], nil
+		return m, 0, nil
 	}
-	return m[1], nil
+	return m, 1, nil
 }
@@ -8,6 +8,8 @@ import (
 	"strings"
 
 	"github.com/delaneyj/witchbolt/internal/common"
+	"github.com/delaneyj/witchbolt/internal/guts_cli"
+	"github.com/delaneyj/witchbolt/internal/surgeon"
 	"github.com/valyala/bytebufferpool"
 )
 
@@ -21,6 +23,9 @@ const (
 type SurgeryMetaCmd struct {
 	Validate SurgeryMetaValidateCmd `cmd:"" help:"Validate both meta pages."`
 	Update   SurgeryMetaUpdateCmd   `cmd:"" help:"Update fields in meta pages."`
+	SetTxid  SurgeryMetaSetTxidCmd  `cmd:"" help:"Force the transaction ID of a meta page."`
+
+	RecomputeChecksum SurgeryMetaRecomputeChecksumCmd `cmd:"" help:"Recompute the checksum of a hand-edited meta page."`
 }
 
 type SurgeryMetaValidateCmd struct {
@@ -161,6 +166,122 @@ func surgeryMetaUpdateFunc(srcDBPath string, cfg surgeryMetaUpdateOptions) error
 	return nil
 }
 
+// surgeryMetaPageOptions are the options of the commands that operate on a
+// single meta page.
+type surgeryMetaPageOptions struct {
+	surgeryBaseOptions
+	metaPageId uint32
+}
+
+func (o *surgeryMetaPageOptions) Validate() error {
+	if err := o.surgeryBaseOptions.Validate(); err != nil {
+		return err
+	}
+	if o.metaPageId > 1 {
+		return fmt.Errorf("invalid meta page id: %d", o.metaPageId)
+	}
+	return nil
+}
+
+type surgeryMetaSetTxidOptions struct {
+	surgeryMetaPageOptions
+	txid uint64
+}
+
+type SurgeryMetaSetTxidCmd struct {
+	Src        string `arg:"" help:"Path to witchbolt database file" type:"path"`
+	Output     string `name:"output" required:"" help:"Path to the output database file" type:"path"`
+	MetaPageID uint32 `name:"meta" required:"" help:"Meta page ID to operate on (0 or 1)."`
+	Txid       uint64 `name:"txid" required:"" help:"Transaction ID to write to the meta page."`
+}
+
+func (c *SurgeryMetaSetTxidCmd) Run() error {
+	cfg := surgeryMetaSetTxidOptions{
+		surgeryMetaPageOptions: surgeryMetaPageOptions{
+			surgeryBaseOptions: surgeryBaseOptions{outputDBFilePath: c.Output},
+			metaPageId:         c.MetaPageID,
+		},
+		txid: c.Txid,
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	return surgeryMetaSetTxidFunc(c.Src, cfg)
+}
+
+func surgeryMetaSetTxidFunc(srcDBPath string, cfg surgeryMetaSetTxidOptions) error {
+	if _, err := checkSourceDBPath(srcDBPath); err != nil {
+		return err
+	}
+
+	if err := common.CopyFile(srcDBPath, cfg.outputDBFilePath); err != nil {
+		return fmt.Errorf("[meta set-txid] copy file failed: %w", err)
+	}
+
+	oldTxid, err := surgeon.SetMetaTxid(cfg.outputDBFilePath, uint64(cfg.metaPageId), common.Txid(cfg.txid))
+	if err != nil {
+		return fmt.Errorf("[meta set-txid] %w", err)
+	}
+
+	fmt.Fprintf(os.Stdout, "WARNING: Forcing a transaction ID is dangerous. The database opens whichever valid meta page has the higher txid, and the two meta pages may now diverge.\n")
+	if other, _, err := guts_cli.ReadPage(cfg.outputDBFilePath, uint64(1-cfg.metaPageId)); err == nil {
+		otherTxid := other.Meta().Txid()
+		fmt.Fprintf(os.Stdout, "WARNING: The other meta page %d has txid %d.\n", 1-cfg.metaPageId, otherTxid)
+		if otherTxid == common.Txid(cfg.txid) {
+			fmt.Fprintf(os.Stdout, "WARNING: Both meta pages now have the same txid.\n")
+		}
+	}
+	fmt.Fprintf(os.Stdout, "The txid of meta page %d has been changed from %d to %d\n", cfg.metaPageId, oldTxid, cfg.txid)
+	return nil
+}
+
+type SurgeryMetaRecomputeChecksumCmd struct {
+	Src        string `arg:"" help:"Path to witchbolt database file" type:"path"`
+	Output     string `name:"output" required:"" help:"Path to the output database file" type:"path"`
+	MetaPageID uint32 `name:"meta" required:"" help:"Meta page ID to operate on (0 or 1)."`
+}
+
+func (c *SurgeryMetaRecomputeChecksumCmd) Run() error {
+	cfg := surgeryMetaPageOptions{
+		surgeryBaseOptions: surgeryBaseOptions{outputDBFilePath: c.Output},
+		metaPageId:         c.MetaPageID,
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	return surgeryMetaRecomputeChecksumFunc(c.Src, cfg)
+}
+
+func surgeryMetaRecomputeChecksumFunc(srcDBPath string, cfg surgeryMetaPageOptions) error {
+	if _, err := checkSourceDBPath(srcDBPath); err != nil {
+		return err
+	}
+
+	if err := common.CopyFile(srcDBPath, cfg.outputDBFilePath); err != nil {
+		return fmt.Errorf("[meta recompute-checksum] copy file failed: %w", err)
+	}
+
+	oldChecksum, newChecksum, err := surgeon.RecomputeMetaChecksum(cfg.outputDBFilePath, uint64(cfg.metaPageId))
+	if err != nil {
+		return fmt.Errorf("[meta recompute-checksum] %w", err)
+	}
+
+	if oldChecksum == newChecksum {
+		fmt.Fprintf(os.Stdout, "The checksum of meta page %d is already correct: %016x\n", cfg.metaPageId, oldChecksum)
+	} else {
+		fmt.Fprintf(os.Stdout, "The checksum of meta page %d has been changed from %016x to %016x\n", cfg.metaPageId, oldChecksum, newChecksum)
+	}
+
+	m, _, err := guts_cli.ReadPage(cfg.outputDBFilePath, uint64(cfg.metaPageId))
+	if err != nil {
+		return fmt.Errorf("[meta recompute-checksum] read meta page %d failed: %w", cfg.metaPageId, err)
+	}
+	if err := m.Meta().Validate(); err != nil {
+		fmt.Fprintf(os.Stdout, "WARNING: The meta page %d still isn't valid: %v!\n", cfg.metaPageId, err)
+	}
+	return nil
+}
+
 func parseFields(fields []string) map[string]uint64 {
 	fieldsMap := make(map[string]uint64)
 	for _, field := range fields {
@@ -2,6 +2,7 @@ package command_test
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
 	"testing"
@@ -118,3 +119,64 @@ func TestSurgery_Meta_Update(t *testing.T) {
 		}
 	}
 }
+
+func TestSurgery_Meta_SetTxid(t *testing.T) {
+	db := btesting.MustCreateDBWithOption(t, &witchbolt.Options{PageSize: 4096})
+	srcPath := db.Path()
+	db.Close()
+	defer requireDBNoChange(t, dbData(t, srcPath), srcPath)
+
+	output := filepath.Join(t.TempDir(), "db")
+	res := runCLI(t, "surgery", "meta", "set-txid", srcPath, "--output", output, "--meta", "0", "--txid", "100")
+	require.NoError(t, res.err)
+	require.Contains(t, res.stdout, "WARNING: Forcing a transaction ID is dangerous.")
+	require.Contains(t, res.stdout, "The txid of meta page 0 has been changed from 0 to 100\n")
+
+	m, _, err := command.ReadMetaPageAt(output, 0, 4096)
+	require.NoError(t, err)
+	require.NoError(t, m.Validate(), "the checksum is recomputed")
+	require.Equal(t, common.Txid(100), m.Txid())
+
+	t.Log("The database opens on the forced meta page")
+	out, err := witchbolt.Open(output, 0600, &witchbolt.Options{ReadOnly: true})
+	require.NoError(t, err)
+	tx, err := out.Begin(false)
+	require.NoError(t, err)
+	require.Equal(t, 100, tx.ID())
+	require.NoError(t, tx.Rollback())
+	require.NoError(t, out.Close())
+
+	res = runCLI(t, "surgery", "meta", "set-txid", srcPath, "--output", output, "--meta", "2", "--txid", "1")
+	require.ErrorContains(t, res.err, "invalid meta page id: 2")
+}
+
+func TestSurgery_Meta_RecomputeChecksum(t *testing.T) {
+	db := btesting.MustCreateDBWithOption(t, &witchbolt.Options{PageSize: 4096})
+	srcPath := db.Path()
+	db.Close()
+
+	t.Log("Hand-editing the txid of meta page 1 without fixing its checksum")
+	m, buf, err := command.ReadMetaPageAt(srcPath, 1, 4096)
+	require.NoError(t, err)
+	oldChecksum := m.Checksum()
+	m.SetTxid(5)
+	f, err := os.OpenFile(srcPath, os.O_WRONLY, 0600)
+	require.NoError(t, err)
+	_, err = f.WriteAt(buf, 4096)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	defer requireDBNoChange(t, dbData(t, srcPath), srcPath)
+
+	output := filepath.Join(t.TempDir(), "db")
+	res := runCLI(t, "surgery", "meta", "recompute-checksum", srcPath, "--output", output, "--meta", "1")
+	require.NoError(t, res.err)
+	m, _, err = command.ReadMetaPageAt(output, 1, 4096)
+	require.NoError(t, err)
+	require.NoError(t, m.Validate())
+	require.Equal(t, fmt.Sprintf("The checksum of meta page 1 has been changed from %016x to %016x\n", oldChecksum, m.Checksum()), res.stdout)
+
+	t.Log("Running it again leaves the checksum alone")
+	res = runCLI(t, "surgery", "meta", "recompute-checksum", output, "--output", output+".again", "--meta", "1")
+	require.NoError(t, res.err)
+	require.Equal(t, fmt.Sprintf("The checksum of meta page 1 is already correct: %016x\n", m.Checksum()), res.stdout)
+}
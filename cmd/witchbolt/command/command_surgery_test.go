@@ -1,6 +1,7 @@
 package command_test
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -117,6 +118,66 @@ func TestSurgery_ClearPage(t *testing.T) {
 	assert.Equal(t, uint32(0), p.Overflow())
 }
 
+func TestSurgery_ClearPages(t *testing.T) {
+	pageSize := 4096
+	db := btesting.MustCreateDBWithOption(t, &witchbolt.Options{PageSize: pageSize})
+	srcPath := db.Path()
+	require.NoError(t, db.Fill([]byte("data"), 1, 200,
+		func(tx int, k int) []byte { return []byte(fmt.Sprintf("%04d", k)) },
+		func(tx int, k int) []byte { return make([]byte, 100) },
+	))
+	var leafIds []common.Pgid
+	require.NoError(t, db.View(func(tx *witchbolt.Tx) error {
+		root := tx.Bucket([]byte("data")).RootPage()
+		p, _, err := guts_cli.ReadPage(srcPath, uint64(root))
+		require.NoError(t, err)
+		require.True(t, p.IsBranchPage())
+		for _, elem := range p.BranchPageElements() {
+			leafIds = append(leafIds, elem.Pgid())
+		}
+		return nil
+	}))
+	db.Close()
+
+	t.Log("Blanking garbage over a leaf page")
+	victim := leafIds[1]
+	f, err := os.OpenFile(srcPath, os.O_WRONLY, 0600)
+	require.NoError(t, err)
+	_, err = f.WriteAt(bytes.Repeat([]byte{0xab}, pageSize), int64(victim)*int64(pageSize))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	defer requireDBNoChange(t, dbData(t, srcPath), srcPath)
+
+	output := filepath.Join(t.TempDir(), "dstdb")
+	res := runCLI(t, "surgery", "clear-pages", srcPath, "--output", output, "--from", fmt.Sprint(victim), "--to", fmt.Sprint(victim))
+	require.NoError(t, res.err)
+	require.Contains(t, res.stdout, "WARNING: The clearing has abandoned some pages")
+	require.Contains(t, res.stdout, fmt.Sprintf("The pages [%d, %d] were cleared\n", victim, victim))
+
+	p := common.LoadPage(readPage(t, output, int(victim), pageSize))
+	require.Equal(t, victim, p.Id())
+	require.True(t, p.IsLeafPage())
+	require.Equal(t, uint16(0), p.Count())
+
+	t.Log("The cleared page reads as an empty leaf")
+	res = runCLI(t, "check", output)
+	require.NoError(t, res.err)
+
+	t.Log("Rejecting ranges outside [2, hwm)")
+	_, hwm, err := guts_cli.ReadPageAndHWMSize(srcPath)
+	require.NoError(t, err)
+	for _, args := range [][]string{
+		{"--from", "1", "--to", "3"},
+		{"--from", "4", "--to", "3"},
+		{"--from", "3", "--to", fmt.Sprint(hwm)},
+	} {
+		output := filepath.Join(t.TempDir(), "dstdb")
+		res := runCLI(t, append([]string{"surgery", "clear-pages", srcPath, "--output", output}, args...)...)
+		require.Error(t, res.err, args)
+		require.NoFileExists(t, output)
+	}
+}
+
 func TestSurgery_ClearPageElements_Without_Overflow(t *testing.T) {
 	testCases := []struct {
 		name                 string
@@ -209,7 +270,7 @@ func TestSurgery_ClearPageElements_Without_Overflow(t *testing.T) {
 		},
 		{
 			name:        "abnormal range: [-2, 5)",
-			from:        -1,
+			from:        -2,
 			to:          5,
 			expectError: true,
 		},
@@ -233,8 +294,14 @@ func TestSurgery_ClearPageElements_Without_Overflow(t *testing.T) {
 		},
 		{
 			name:        "abnormal range: [3, 1000000)",
-			from:        -1,
-			to:          4,
+			from:        3,
+			to:          1000000,
+			expectError: true,
+		},
+		{
+			name:        "abnormal range: [1000000, -1)",
+			from:        1000000,
+			to:          -1,
 			expectError: true,
 		},
 	}
@@ -305,6 +372,7 @@ func testSurgeryClearPageElementsWithoutOverflow(t *testing.T, startIdx, endIdx
 	)
 	if expectError {
 		require.Error(t, res.err)
+		require.NoFileExists(t, output, "invalid ranges are rejected before the file is copied")
 		return
 	}
 
@@ -357,6 +425,43 @@ func compareDataAfterClearingElement(t *testing.T, srcPath, dstPath string, page
 	}
 }
 
+func TestSurgery_ClearPageElements_InvalidRange(t *testing.T) {
+	db := btesting.MustCreateDBWithOption(t, &witchbolt.Options{PageSize: 4096})
+	srcPath := db.Path()
+	require.NoError(t, db.Fill([]byte("data"), 1, 5,
+		func(tx int, k int) []byte { return []byte(fmt.Sprintf("%04d", k)) },
+		func(tx int, k int) []byte { return make([]byte, 300) },
+	))
+	var pageId common.Pgid
+	require.NoError(t, db.View(func(tx *witchbolt.Tx) error {
+		pageId = tx.Bucket([]byte("data")).RootPage()
+		return nil
+	}))
+	db.Close()
+	defer requireDBNoChange(t, dbData(t, srcPath), srcPath)
+
+	testCases := []struct {
+		name     string
+		from, to int
+		errMsg   string
+	}{
+		{name: "negative from-index", from: -1, to: 2, errMsg: "the from-index must not be negative, but got -1"},
+		{name: "empty range", from: 2, to: 2, errMsg: "the to-index must be -1 or bigger than the from-index (2), but got 2"},
+		{name: "reversed range", from: 3, to: 1, errMsg: "the to-index must be -1 or bigger than the from-index (3), but got 1"},
+		{name: "from-index past the count", from: 5, to: -1, errMsg: fmt.Sprintf("the from-index (5) is out of range [0, 5) of page %d", pageId)},
+		{name: "to-index past the count", from: 1, to: 6, errMsg: fmt.Sprintf("the to-index (6) is out of range [1, 5] of page %d", pageId)},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			output := filepath.Join(t.TempDir(), "db")
+			res := runCLI(t, "surgery", "clear-page-elements", srcPath, "--output", output,
+				fmt.Sprintf("--pageId=%d", pageId), fmt.Sprintf("--from-index=%d", tc.from), fmt.Sprintf("--to-index=%d", tc.to))
+			require.ErrorContains(t, res.err, tc.errMsg)
+			require.NoFileExists(t, output)
+		})
+	}
+}
+
 func TestSurgery_ClearPageElements_With_Overflow(t *testing.T) {
 	testCases := []struct {
 		name             string
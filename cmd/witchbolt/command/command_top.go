@@ -0,0 +1,91 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/delaneyj/witchbolt"
+)
+
+type TopCmd struct {
+	Path   string `arg:"" help:"Path to witchbolt database file" type:"path"`
+	By     string `default:"keys" enum:"keys,bytes,depth" help:"Rank buckets by keys, bytes or depth."`
+	Limit  int    `short:"n" default:"10" help:"Number of buckets to print. Zero prints every bucket."`
+	Format string `default:"text" enum:"text,json" help:"Output format: text|json"`
+}
+
+type topBucket struct {
+	Name  string `json:"name"`
+	Keys  int    `json:"keys"`
+	Bytes int    `json:"bytes"`
+	Depth int    `json:"depth"`
+}
+
+func (c *TopCmd) Run() error {
+	if _, err := checkSourceDBPath(c.Path); err != nil {
+		return err
+	}
+
+	db, err := witchbolt.Open(c.Path, 0600, &witchbolt.Options{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var buckets []topBucket
+	if err := db.View(func(tx *witchbolt.Tx) error {
+		return forEachUserBucket(tx, func(name []byte, b *witchbolt.Bucket) error {
+			s := b.Stats()
+			buckets = append(buckets, topBucket{
+				Name:  string(name),
+				Keys:  s.KeyN,
+				Bytes: s.BranchInuse + s.LeafInuse + s.InlineBucketInuse,
+				Depth: s.Depth,
+			})
+			return nil
+		})
+	}); err != nil {
+		return err
+	}
+
+	metric := func(b topBucket) int {
+		switch c.By {
+		case "bytes":
+			return b.Bytes
+		case "depth":
+			return b.Depth
+		default:
+			return b.Keys
+		}
+	}
+	// Buckets are visited in name order, so a stable sort breaks ties by name.
+	sort.SliceStable(buckets, func(i, j int) bool {
+		return metric(buckets[i]) > metric(buckets[j])
+	})
+	if c.Limit > 0 && len(buckets) > c.Limit {
+		buckets = buckets[:c.Limit]
+	}
+
+	if c.Format == "json" {
+		if buckets == nil {
+			buckets = []topBucket{}
+		}
+		out, err := json.MarshalIndent(buckets, "", "    ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+		return nil
+	}
+
+	width := len("BUCKET")
+	for _, b := range buckets {
+		width = max(width, len(b.Name))
+	}
+	fmt.Printf("%-*s %12s %12s %6s\n", width, "BUCKET", "KEYS", "BYTES", "DEPTH")
+	for _, b := range buckets {
+		fmt.Printf("%-*s %12d %12d %6d\n", width, b.Name, b.Keys, b.Bytes, b.Depth)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package command_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/delaneyj/witchbolt"
+	"github.com/delaneyj/witchbolt/internal/btesting"
+)
+
+type topBucket struct {
+	Name  string `json:"name"`
+	Keys  int    `json:"keys"`
+	Bytes int    `json:"bytes"`
+	Depth int    `json:"depth"`
+}
+
+func TestTopCommand_Run(t *testing.T) {
+	t.Log("Creating sample DB")
+	db := btesting.MustCreateDB(t)
+	sizes := map[string]int{"alpha": 5, "bravo": 500, "charlie": 50, "delta": 1}
+	require.NoError(t, db.Update(func(tx *witchbolt.Tx) error {
+		for name, n := range sizes {
+			b, err := tx.CreateBucket([]byte(name))
+			if err != nil {
+				return err
+			}
+			for i := 0; i < n; i++ {
+				if err := b.Put([]byte(fmt.Sprintf("key-%04d", i)), []byte(strings.Repeat("v", 64))); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}))
+	db.Close()
+	defer requireDBNoChange(t, dbData(t, db.Path()), db.Path())
+
+	t.Log("Ranking by keys with a limit")
+	res := runCLI(t, "top", db.Path(), "--limit", "2")
+	require.NoError(t, res.err)
+	lines := strings.Split(strings.TrimSpace(res.stdout), "\n")
+	require.Len(t, lines, 3)
+	require.True(t, strings.HasPrefix(lines[0], "BUCKET"))
+	require.True(t, strings.HasPrefix(lines[1], "bravo"))
+	require.True(t, strings.HasPrefix(lines[2], "charlie"))
+
+	t.Log("Ranking by bytes as JSON")
+	res = runCLI(t, "top", db.Path(), "--by", "bytes", "--format", "json")
+	require.NoError(t, res.err)
+	var buckets []topBucket
+	require.NoError(t, json.Unmarshal([]byte(res.stdout), &buckets))
+	require.Len(t, buckets, 4)
+	var names []string
+	for i, b := range buckets {
+		names = append(names, b.Name)
+		require.Equal(t, sizes[b.Name], b.Keys)
+		if i > 0 {
+			require.GreaterOrEqual(t, buckets[i-1].Bytes, b.Bytes)
+		}
+	}
+	require.Equal(t, []string{"bravo", "charlie", "alpha", "delta"}, names)
+
+	t.Log("Ranking by depth")
+	res = runCLI(t, "top", db.Path(), "--by", "depth", "--format", "json", "-n", "1")
+	require.NoError(t, res.err)
+	require.NoError(t, json.Unmarshal([]byte(res.stdout), &buckets))
+	require.Len(t, buckets, 1)
+	require.Equal(t, "bravo", buckets[0].Name)
+	require.Greater(t, buckets[0].Depth, 1)
+}
+
+func TestTopCommand_SkipsStreamBucket(t *testing.T) {
+	dbPath, _ := replicateSampleDB(t, 3)
+
+	res := runCLI(t, "top", dbPath, "--format", "json")
+	require.NoError(t, res.err)
+	var buckets []topBucket
+	require.NoError(t, json.Unmarshal([]byte(res.stdout), &buckets))
+	require.Len(t, buckets, 1)
+	require.Equal(t, "widgets", buckets[0].Name)
+}
@@ -0,0 +1,55 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+type TxidCmd struct {
+	Path   string `arg:"" help:"Path to witchbolt database file" type:"path"`
+	Format string `default:"text" enum:"text,json" help:"Output format: text|json"`
+}
+
+type txidInfo struct {
+	TxID     uint64 `json:"txid"`
+	Root     uint64 `json:"root"`
+	Freelist uint64 `json:"freelist"`
+	HWM      uint64 `json:"hwm"`
+	PageSize uint32 `json:"pageSize"`
+}
+
+// Run reads the active meta page straight from the file, so it does not take
+// the database lock and works while another process has the DB open.
+func (c *TxidCmd) Run() error {
+	if _, err := checkSourceDBPath(c.Path); err != nil {
+		return err
+	}
+
+	m, err := readMetaPage(c.Path)
+	if err != nil {
+		return err
+	}
+	info := txidInfo{
+		TxID:     uint64(m.Txid()),
+		Root:     uint64(m.RootBucket().RootPage()),
+		Freelist: uint64(m.Freelist()),
+		HWM:      uint64(m.Pgid()),
+		PageSize: m.PageSize(),
+	}
+
+	if c.Format == "json" {
+		out, err := json.MarshalIndent(info, "", "    ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+		return nil
+	}
+
+	fmt.Printf("TxID: %d\n", info.TxID)
+	fmt.Printf("Root Page: %d\n", info.Root)
+	fmt.Printf("Freelist Page: %d\n", info.Freelist)
+	fmt.Printf("High Water Mark: %d\n", info.HWM)
+	fmt.Printf("Page Size: %d\n", info.PageSize)
+	return nil
+}
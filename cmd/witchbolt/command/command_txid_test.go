@@ -0,0 +1,65 @@
+package command_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/delaneyj/witchbolt"
+	"github.com/delaneyj/witchbolt/internal/btesting"
+)
+
+func TestTxidCommand_Run(t *testing.T) {
+	t.Log("Creating sample DB")
+	db := btesting.MustCreateDB(t)
+	pageSize := db.Info().PageSize
+	var start int
+	require.NoError(t, db.View(func(tx *witchbolt.Tx) error {
+		start = tx.ID()
+		return nil
+	}))
+	const txCount = 5
+	for i := 0; i < txCount; i++ {
+		require.NoError(t, db.Update(func(tx *witchbolt.Tx) error {
+			b, err := tx.CreateBucketIfNotExists([]byte("widgets"))
+			if err != nil {
+				return err
+			}
+			return b.Put([]byte(fmt.Sprintf("key-%d", i)), []byte("value"))
+		}))
+	}
+	db.Close()
+	defer requireDBNoChange(t, dbData(t, db.Path()), db.Path())
+
+	t.Log("Running txid cmd")
+	res := runCLI(t, "txid", db.Path())
+	require.NoError(t, res.err)
+	require.Contains(t, res.stdout, fmt.Sprintf("TxID: %d\n", start+txCount))
+	require.Contains(t, res.stdout, "Root Page: ")
+	require.Contains(t, res.stdout, "Freelist Page: ")
+	require.Contains(t, res.stdout, "High Water Mark: ")
+
+	t.Log("Running txid cmd with json output")
+	res = runCLI(t, "txid", "--format", "json", db.Path())
+	require.NoError(t, res.err)
+	var info struct {
+		TxID     uint64 `json:"txid"`
+		Root     uint64 `json:"root"`
+		Freelist uint64 `json:"freelist"`
+		HWM      uint64 `json:"hwm"`
+		PageSize uint32 `json:"pageSize"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(res.stdout), &info))
+	require.Equal(t, uint64(start+txCount), info.TxID)
+	require.Greater(t, info.HWM, info.Root)
+	require.Greater(t, info.HWM, info.Freelist)
+	require.Equal(t, uint32(pageSize), info.PageSize)
+}
+
+func TestTxidCommand_NoArgs(t *testing.T) {
+	res := runCLI(t, "txid")
+	require.Error(t, res.err)
+	require.Contains(t, res.err.Error(), "expected \"<path>\"")
+}
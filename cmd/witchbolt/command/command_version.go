@@ -1,17 +1,72 @@
 package command
 
 import (
+	"encoding/json"
 	"fmt"
 	"runtime"
+	"runtime/debug"
 
 	"github.com/delaneyj/witchbolt/version"
 )
 
-type VersionCmd struct{}
+type VersionCmd struct {
+	JSON bool `name:"json" help:"Print build information as JSON."`
+}
+
+type versionInfo struct {
+	Version   string `json:"version"`
+	GoVersion string `json:"goVersion"`
+	OS        string `json:"os"`
+	Arch      string `json:"arch"`
+	Commit    string `json:"commit,omitempty"`
+	BuildDate string `json:"buildDate,omitempty"`
+	Modified  bool   `json:"modified,omitempty"`
+}
 
 func (v *VersionCmd) Run() error {
-	fmt.Printf("witchbolt Version: %s\n", version.Version)
-	fmt.Printf("Go Version: %s\n", runtime.Version())
-	fmt.Printf("Go OS/Arch: %s/%s\n", runtime.GOOS, runtime.GOARCH)
+	info := buildVersionInfo()
+	if v.JSON {
+		out, err := json.MarshalIndent(info, "", "    ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+		return nil
+	}
+	fmt.Printf("witchbolt Version: %s\n", info.Version)
+	fmt.Printf("Go Version: %s\n", info.GoVersion)
+	fmt.Printf("Go OS/Arch: %s/%s\n", info.OS, info.Arch)
+	if info.Commit != "" {
+		fmt.Printf("Commit: %s\n", info.Commit)
+	}
+	if info.BuildDate != "" {
+		fmt.Printf("Build Date: %s\n", info.BuildDate)
+	}
 	return nil
 }
+
+// buildVersionInfo combines the release version with the VCS stamp the Go
+// toolchain embeds in binaries built from a checkout.
+func buildVersionInfo() versionInfo {
+	info := versionInfo{
+		Version:   version.Version,
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+	}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	for _, setting := range bi.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			info.Commit = setting.Value
+		case "vcs.time":
+			info.BuildDate = setting.Value
+		case "vcs.modified":
+			info.Modified = setting.Value == "true"
+		}
+	}
+	return info
+}
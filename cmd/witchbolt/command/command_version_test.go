@@ -0,0 +1,28 @@
+package command_test
+
+import (
+	"encoding/json"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/delaneyj/witchbolt/version"
+)
+
+func TestVersionCommand_Run(t *testing.T) {
+	res := runCLI(t, "version")
+	require.NoError(t, res.err)
+	require.Contains(t, res.stdout, "witchbolt Version: "+version.Version)
+	require.Contains(t, res.stdout, "Go Version: "+runtime.Version())
+}
+
+func TestVersionCommand_JSON(t *testing.T) {
+	res := runCLI(t, "version", "--json")
+	require.NoError(t, res.err)
+
+	var info map[string]any
+	require.NoError(t, json.Unmarshal([]byte(res.stdout), &info))
+	require.Equal(t, version.Version, info["version"])
+	require.Equal(t, runtime.Version(), info["goVersion"])
+}
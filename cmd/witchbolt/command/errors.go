@@ -3,8 +3,30 @@ package command
 import "errors"
 
 var (
-	// ErrBatchInvalidWriteMode is returned when the write mode is other than seq, rnd, seq-nest, or rnd-nest.
-	ErrBatchInvalidWriteMode = errors.New("the write mode should be one of seq, rnd, seq-nest, or rnd-nest")
+	// ErrBatchInvalidWriteMode is returned when the write mode is other than seq, rnd, seq-nest, rnd-nest, or seq-del.
+	ErrBatchInvalidWriteMode = errors.New("the write mode should be one of seq, rnd, seq-nest, rnd-nest, or seq-del")
+
+	// ErrBatchInvalidDeleteFraction is returned when the delete fraction is outside [0, 1].
+	ErrBatchInvalidDeleteFraction = errors.New("the delete fraction must be between 0 and 1")
+
+	// ErrBatchConflictingOutput is returned when --gobench-output is combined with a json or csv output format.
+	ErrBatchConflictingOutput = errors.New("--gobench-output cannot be combined with --output-format json or csv")
+
+	// ErrBatchCountAndDuration is returned when both an iteration count and a duration are given.
+	ErrBatchCountAndDuration = errors.New("--count and --duration are mutually exclusive")
+
+	// ErrBatchInvalidDuration is returned when the duration is negative.
+	ErrBatchInvalidDuration = errors.New("the duration must be positive")
+
+	// ErrBatchInvalidReadRatio is returned when the read ratio of a mixed workload is outside (0, 1).
+	ErrBatchInvalidReadRatio = errors.New("the read ratio must be between 0 and 1")
+
+	// ErrBatchInvalidWorkers is returned when the number of workers is less than one.
+	ErrBatchInvalidWorkers = errors.New("the number of workers must be at least 1")
+
+	// ErrBatchNonDivisibleWorkers is returned when the iteration count can't be
+	// evenly divided between the workers.
+	ErrBatchNonDivisibleWorkers = errors.New("the number of iterations must be divisible by the number of workers")
 
 	// ErrBatchNonDivisibleBatchSize is returned when the batch size can't be evenly
 	// divided by the iteration count.
@@ -13,6 +35,21 @@ var (
 	// ErrBucketRequired is returned when a bucket is not specified.
 	ErrBucketRequired = errors.New("bucket required")
 
+	// ErrCompactVerifyMismatch is returned when the compacted database's contents differ from the source.
+	ErrCompactVerifyMismatch = errors.New("the compacted database does not match the source")
+
+	// ErrDiffOnlyChanged is returned when --only-changed and --config are not given together, or are given with database paths.
+	ErrDiffOnlyChanged = errors.New("--only-changed needs --config and compares a replica instead of database paths")
+
+	// ErrDumpRawOutput is returned when only one of --raw and --output is given to dump.
+	ErrDumpRawOutput = errors.New("--raw and --output must be used together")
+
+	// ErrImportInvalidArchive is returned when an import archive is malformed or not written by export.
+	ErrImportInvalidArchive = errors.New("not a valid export archive")
+
+	// ErrImportInvalidBatchSize is returned when the import batch size is less than one.
+	ErrImportInvalidBatchSize = errors.New("the batch size must be at least 1")
+
 	// ErrInvalidPageArgs is returned when Page cmd receives pageIds and all option is true.
 	ErrInvalidPageArgs = errors.New("invalid args: either use '--all' or 'pageid...'")
 
@@ -22,12 +59,21 @@ var (
 	// ErrKeyNotFound is returned when a key is not found.
 	ErrKeyNotFound = errors.New("key not found")
 
+	// ErrPagesFreeWithoutFreelist is returned when pages is asked to list free pages without loading the freelist.
+	ErrPagesFreeWithoutFreelist = errors.New("--type free needs the freelist and cannot be combined with --no-freelist")
+
 	// ErrPageIDRequired is returned when a required page id is not specified.
 	ErrPageIDRequired = errors.New("page id required")
 
 	// ErrPathRequired is returned when the path to a witchbolt database is not specified.
 	ErrPathRequired = errors.New("path required")
 
+	// ErrReadOnlyFile is returned when a command that modifies the database is run against a read-only file.
+	ErrReadOnlyFile = errors.New("the database file is read-only")
+
+	// ErrReservedBucket is returned when a command would write to or delete the bucket stream keeps the database identity in.
+	ErrReservedBucket = errors.New("the bucket is reserved for stream replication")
+
 	// ErrSurgeryFreelistAlreadyExist is returned when a witchbolt database file already has a freelist.
 	ErrSurgeryFreelistAlreadyExist = errors.New("the file already has freelist, please consider to abandon the freelist to forcibly rebuild it")
 )
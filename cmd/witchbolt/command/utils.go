@@ -2,6 +2,7 @@ package command
 
 import (
 	"crypto/sha256"
+	"encoding/binary"
 	"encoding/hex"
 	"fmt"
 	"io"
@@ -12,6 +13,7 @@ import (
 
 	"github.com/delaneyj/witchbolt"
 	berrors "github.com/delaneyj/witchbolt/errors"
+	"github.com/delaneyj/witchbolt/stream"
 )
 
 func checkSourceDBPath(srcPath string) (os.FileInfo, error) {
@@ -24,10 +26,38 @@ func checkSourceDBPath(srcPath string) (os.FileInfo, error) {
 	return fi, nil
 }
 
-const FORMAT_MODES = "auto|ascii-encoded|hex|bytes|redacted"
+// freelistRebuildNotice returns an Options.OnFreelistRebuild hook that tells
+// the user on stderr why opening path is slow when its freelist has to be
+// reconstructed.
+func freelistRebuildNotice(path string) func(uint64) {
+	var noticed bool
+	return func(uint64) {
+		if noticed {
+			return
+		}
+		noticed = true
+		fmt.Fprintf(os.Stderr, "The freelist of %q is not synced; rebuilding it by scanning the database, this may take a while...\n", path)
+	}
+}
+
+// inspectOptions returns the options to open path with for an inspection
+// command. The freelist is preloaded, with a notice if it has to be rebuilt,
+// unless noFreelist is set: the database then opens without reading the
+// freelist at all, and free pages can't be told apart.
+func inspectOptions(path string, noFreelist bool) *witchbolt.Options {
+	return &witchbolt.Options{
+		ReadOnly:          true,
+		PreLoadFreelist:   true,
+		SkipFreelistLoad:  noFreelist,
+		OnFreelistRebuild: freelistRebuildNotice(path),
+	}
+}
+
+const FORMAT_MODES = "auto|ascii-encoded|hex|bytes|redacted|uint64|int64"
 
 // formatBytes converts bytes into string according to format.
-// Supported formats: ascii-encoded, hex, bytes.
+// Supported formats: ascii-encoded, hex, bytes, auto, redacted, uint64, int64.
+// uint64 and int64 decode exactly 8 big-endian bytes.
 func formatBytes(b []byte, format string) (string, error) {
 	switch format {
 	case "ascii-encoded":
@@ -42,6 +72,15 @@ func formatBytes(b []byte, format string) (string, error) {
 		hash := sha256.New()
 		hash.Write(b)
 		return fmt.Sprintf("<redacted len:%d sha256:%x>", len(b), hash.Sum(nil)), nil
+	case "uint64", "int64":
+		if len(b) != 8 {
+			return "", fmt.Errorf("formatBytes: %s format requires 8 bytes, got %d", format, len(b))
+		}
+		n := binary.BigEndian.Uint64(b)
+		if format == "int64" {
+			return strconv.FormatInt(int64(n), 10), nil
+		}
+		return strconv.FormatUint(n, 10), nil
 	default:
 		return "", fmt.Errorf("formatBytes: unsupported format: %s", format)
 	}
@@ -53,12 +92,24 @@ func parseBytes(str string, format string) ([]byte, error) {
 		return []byte(str), nil
 	case "hex":
 		return hex.DecodeString(str)
+	case "uint64":
+		n, err := strconv.ParseUint(str, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		return binary.BigEndian.AppendUint64(nil, n), nil
+	case "int64":
+		n, err := strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		return binary.BigEndian.AppendUint64(nil, uint64(n)), nil
 	default:
 		return nil, fmt.Errorf("parseBytes: unsupported format: %s", format)
 	}
 }
 
-// writelnBytes writes the byte to the writer. Supported formats: ascii-encoded, hex, bytes, auto, redacted.
+// writelnBytes writes the byte to the writer. Supported formats: ascii-encoded, hex, bytes, auto, redacted, uint64, int64.
 // Terminates the write with a new line symbol;
 func writelnBytes(w io.Writer, b []byte, format string) error {
 	str, err := formatBytes(b, format)
@@ -126,6 +177,9 @@ func CmdKvStringer() witchbolt.KVStringer {
 }
 
 func findLastBucket(tx *witchbolt.Tx, bucketNames []string) (*witchbolt.Bucket, error) {
+	if isReservedBucket([]byte(bucketNames[0])) {
+		return nil, berrors.ErrBucketNotFound
+	}
 	lastbucket := tx.Bucket([]byte(bucketNames[0]))
 	if lastbucket == nil {
 		return nil, berrors.ErrBucketNotFound
@@ -138,3 +192,21 @@ func findLastBucket(tx *witchbolt.Tx, bucketNames []string) (*witchbolt.Bucket,
 	}
 	return lastbucket, nil
 }
+
+// isReservedBucket reports whether name is the root bucket stream keeps the
+// database identity in. Every command that lists, reads or writes buckets
+// treats it as absent.
+func isReservedBucket(name []byte) bool {
+	return string(name) == stream.IdentityBucket
+}
+
+// forEachUserBucket calls fn for every root bucket of tx except the reserved
+// one.
+func forEachUserBucket(tx *witchbolt.Tx, fn func(name []byte, b *witchbolt.Bucket) error) error {
+	return tx.ForEach(func(name []byte, b *witchbolt.Bucket) error {
+		if isReservedBucket(name) {
+			return nil
+		}
+		return fn(name, b)
+	})
+}
@@ -9,6 +9,7 @@ import (
 	"io"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"testing"
@@ -16,8 +17,12 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/delaneyj/witchbolt"
+	"github.com/delaneyj/witchbolt/cmd/witchbolt/command"
+	"github.com/delaneyj/witchbolt/errors"
+	"github.com/delaneyj/witchbolt/internal/btesting"
 	"github.com/delaneyj/witchbolt/internal/common"
 	"github.com/delaneyj/witchbolt/internal/guts_cli"
+	"github.com/delaneyj/witchbolt/stream"
 )
 
 func loadMetaPage(t *testing.T, dbPath string, pageID uint64) *common.Meta {
@@ -175,3 +180,55 @@ func convertInt64KeysIntoHexString(nums ...int64) string {
 	}
 	return strings.Join(res, "\n") + "\n" // last newline char
 }
+
+func TestCommands_HideStreamBucket(t *testing.T) {
+	db := btesting.MustCreateDB(t)
+	require.NoError(t, db.Update(func(tx *witchbolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("widgets"))
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte("key"), []byte("value"))
+	}))
+	_, err := stream.NewController(db.DB, stream.Config{ShadowDir: t.TempDir()}, nil)
+	require.NoError(t, err)
+	require.NoError(t, db.View(func(tx *witchbolt.Tx) error {
+		require.NotNil(t, tx.Bucket([]byte(stream.IdentityBucket)), "the controller stores the identity")
+		return nil
+	}))
+	db.Close()
+	path := db.Path()
+	reserved := stream.IdentityBucket
+
+	t.Log("Listing buckets")
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"buckets", path}, "widgets\n"},
+		{[]string{"top", path}, "widgets"},
+		{[]string{"inspect", path}, `"name": "widgets"`},
+		{[]string{"stats", path, "--per-bucket"}, "Aggregate statistics for 1 buckets"},
+		{[]string{"check", path}, "OK\n"},
+		{[]string{"compact", "--verify", "-o", filepath.Join(t.TempDir(), "compacted.db"), path}, "verified 1 buckets, 1 keys"},
+	} {
+		res := runCLI(t, tc.args...)
+		require.NoError(t, res.err, tc.args[0])
+		require.Contains(t, res.stdout, tc.want, tc.args[0])
+		require.NotContains(t, res.stdout, reserved, tc.args[0])
+	}
+
+	t.Log("Reading the bucket")
+	res := runCLI(t, "keys", path, reserved)
+	require.ErrorIs(t, res.err, errors.ErrBucketNotFound)
+	res = runCLI(t, "get", path, reserved, "databaseId")
+	require.ErrorIs(t, res.err, errors.ErrBucketNotFound)
+
+	t.Log("Writing the bucket")
+	res = runCLI(t, "put", path, reserved, "databaseId", "forged")
+	require.ErrorIs(t, res.err, errors.ErrBucketNotFound)
+	res = runCLI(t, "put", "--create-bucket", path, reserved, "databaseId", "forged")
+	require.ErrorIs(t, res.err, command.ErrReservedBucket)
+	res = runCLI(t, "delete", "--bucket", path, reserved)
+	require.ErrorIs(t, res.err, command.ErrReservedBucket)
+}
@@ -1,13 +1,67 @@
 package witchbolt
 
+import (
+	"context"
+
+	"github.com/delaneyj/witchbolt/errors"
+)
+
 // Compact will create a copy of the source DB and in the destination DB. This may
 // reclaim space that the source database no longer has use for. txMaxSize can be
 // used to limit the transactions size of this process and may trigger intermittent
 // commits. A value of zero will ignore transaction sizes.
 // TODO: merge with: https://github.com/etcd-io/etcd/blob/b7f0f52a16dbf83f18ca1d803f7892d750366a94/mvcc/backend/backend.go#L349
-func Compact(dst, src *DB, txMaxSize int64) error {
+func Compact(dst, src *DB, txMaxSize int64, options ...CompactOption) error {
+	cfg := newCompactConfig(options)
+	return copyWalked(dst, txMaxSize, cfg, nil, func(fn walkFunc) error {
+		return walk(cfg.ctx, src, fn)
+	})
+}
+
+// CopyBucket copies the bucket at bucketPath in src, a top-level bucket name
+// followed by the names of nested buckets, to the same path in dst, with its
+// keys, nested buckets and sequences, as Compact does for a whole database.
+// The parents of the bucket are created in dst if they don't exist, without
+// copying their keys or sequences, but the bucket itself must not exist in
+// dst. txMaxSize batches the copy as for Compact, so a failed copy may leave
+// a part of the bucket in dst. It returns errors.ErrBucketNotFound if there
+// is no bucket at bucketPath in src.
+func CopyBucket(dst, src *DB, bucketPath [][]byte, txMaxSize int64, options ...CompactOption) error {
+	if len(bucketPath) == 0 {
+		return errors.ErrBucketNameRequired
+	}
+	cfg := newCompactConfig(options)
+	parents, name := bucketPath[:len(bucketPath)-1], bucketPath[len(bucketPath)-1]
+	return copyWalked(dst, txMaxSize, cfg, parents, func(fn walkFunc) error {
+		return src.ViewContext(cfg.ctx, func(tx *Tx) error {
+			b := tx.Bucket(bucketPath[0])
+			for _, k := range bucketPath[1:] {
+				if b == nil {
+					break
+				}
+				b = b.Bucket(k)
+			}
+			if b == nil {
+				return errors.ErrBucketNotFound
+			}
+			return walkBucket(b, parents, name, nil, b.Sequence(), fn)
+		})
+	})
+}
+
+func newCompactConfig(options []CompactOption) compactConfig {
+	cfg := compactConfig{ctx: context.Background()}
+	for _, op := range options {
+		op(&cfg)
+	}
+	return cfg
+}
+
+// copyWalked copies the keys visited by walkSrc to dst. The paths of the keys
+// start with parents, whose buckets are created first where they don't exist.
+func copyWalked(dst *DB, txMaxSize int64, cfg compactConfig, parents [][]byte, walkSrc func(walkFunc) error) error {
 	// commit regularly, or we'll run out of memory for large datasets if using one transaction.
-	var size int64
+	var size, copiedKeys, copiedBytes int64
 	tx, err := dst.Begin(true)
 	if err != nil {
 		return err
@@ -18,7 +72,20 @@ func Compact(dst, src *DB, txMaxSize int64) error {
 		}
 	}()
 
-	if err := walk(src, func(keys [][]byte, k, v []byte, seq uint64) error {
+	if len(parents) > 0 {
+		b, err := tx.CreateBucketIfNotExists(parents[0])
+		for _, k := range parents[1:] {
+			if err != nil {
+				break
+			}
+			b, err = b.CreateBucketIfNotExists(k)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := walkSrc(func(keys [][]byte, k, v []byte, seq uint64) error {
 		// On each key/value, check if we have exceeded tx size.
 		sz := int64(len(k) + len(v))
 		if size+sz > txMaxSize && txMaxSize != 0 {
@@ -36,6 +103,12 @@ func Compact(dst, src *DB, txMaxSize int64) error {
 		}
 		size += sz
 
+		copiedKeys++
+		copiedBytes += sz
+		if cfg.progress != nil && copiedKeys%compactProgressInterval == 0 {
+			cfg.progress(copiedKeys, copiedBytes)
+		}
+
 		// Create bucket on the root transaction if this is the first level.
 		nk := len(keys)
 		if nk == 0 {
@@ -78,18 +151,51 @@ func Compact(dst, src *DB, txMaxSize int64) error {
 		return err
 	}
 	err = tx.Commit()
+	if err == nil && cfg.progress != nil {
+		cfg.progress(copiedKeys, copiedBytes)
+	}
 
 	return err
 }
 
+// compactProgressInterval is how many copied keys pass between calls to the
+// WithCompactProgress callback.
+const compactProgressInterval = 1024
+
+type compactConfig struct {
+	ctx      context.Context
+	progress func(copiedKeys, copiedBytes int64)
+}
+
+type CompactOption func(options *compactConfig)
+
+// WithCompactProgress sets a callback that is periodically given the number
+// of keys, buckets included, and the key and value bytes copied so far. It is
+// called once more with the totals when the compaction completes.
+func WithCompactProgress(fn func(copiedKeys, copiedBytes int64)) CompactOption {
+	return func(c *compactConfig) {
+		c.progress = fn
+	}
+}
+
+// WithCompactContext binds the compaction to ctx: the source is read with
+// DB.ViewContext, so the copy stops with ctx.Err() once ctx is done. The
+// transactions already committed to dst by a txMaxSize split are kept, and
+// the last one is rolled back.
+func WithCompactContext(ctx context.Context) CompactOption {
+	return func(c *compactConfig) {
+		c.ctx = ctx
+	}
+}
+
 // walkFunc is the type of the function called for keys (buckets and "normal"
 // values) discovered by Walk. keys is the list of keys to descend to the bucket
 // owning the discovered key/value pair k/v.
 type walkFunc func(keys [][]byte, k, v []byte, seq uint64) error
 
 // walk walks recursively the bolt database db, calling walkFn for each key it finds.
-func walk(db *DB, walkFn walkFunc) error {
-	return db.View(func(tx *Tx) error {
+func walk(ctx context.Context, db *DB, walkFn walkFunc) error {
+	return db.ViewContext(ctx, func(tx *Tx) error {
 		return tx.ForEach(func(name []byte, b *Bucket) error {
 			return walkBucket(b, nil, name, nil, b.Sequence(), walkFn)
 		})
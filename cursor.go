@@ -165,6 +165,22 @@ func (c *Cursor) seek(seek []byte) (key []byte, value []byte, flags uint32) {
 	return c.keyValue()
 }
 
+// seekNear is seek for a key likely to be near the previous one. A key
+// between the first and the last key of the leaf the cursor is on can only be
+// in that leaf, so only the leaf is searched; any other key descends the tree
+// from the root again.
+func (c *Cursor) seekNear(key []byte) ([]byte, []byte, uint32) {
+	if len(c.stack) > 0 {
+		ref := &c.stack[len(c.stack)-1]
+		if n := ref.count(); ref.isLeaf() && n > 0 &&
+			bytes.Compare(ref.key(0), key) <= 0 && bytes.Compare(key, ref.key(n-1)) <= 0 {
+			c.nsearch(key)
+			return c.keyValue()
+		}
+	}
+	return c.seek(key)
+}
+
 // first moves the cursor to the first leaf element under the last page in the stack.
 func (c *Cursor) goToFirstElementOnTheStack() {
 	for {
@@ -430,3 +446,11 @@ func (r *elemRef) count() int {
 	}
 	return int(r.page.Count())
 }
+
+// key returns the key of the i-th inode or leaf page element.
+func (r *elemRef) key(i int) []byte {
+	if r.node != nil {
+		return r.node.inodes[i].Key()
+	}
+	return r.page.LeafPageElement(uint16(i)).Key()
+}
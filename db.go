@@ -1,11 +1,13 @@
 package witchbolt
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"runtime"
+	"slices"
 	"sync"
 	"time"
 	"unsafe"
@@ -52,6 +54,11 @@ type DB struct {
 	// If the package global IgnoreNoSync constant is true, this value is
 	// ignored.  See the comment on that constant for more details.
 	//
+	// Prefer Options.NoSync, which is applied before any transaction can
+	// start. Changing this field after Open is deprecated because it races
+	// with in-flight commits; the first commit that observes a changed value
+	// logs a warning.
+	//
 	// THIS IS UNSAFE. PLEASE USE WITH CAUTION.
 	NoSync bool
 
@@ -93,7 +100,8 @@ type DB struct {
 	MaxBatchSize int
 
 	// MaxBatchDelay is the maximum delay before a batch starts.
-	// Default value is copied from DefaultMaxBatchDelay in Open.
+	// Default value is copied from Options.MaxBatchDelay, or
+	// DefaultMaxBatchDelay, in Open.
 	//
 	// If <=0, effectively disables batching.
 	//
@@ -117,6 +125,11 @@ type DB struct {
 	// Supported only on Unix via mlock/munlock syscalls.
 	Mlock bool
 
+	// StopPageFlushOnError skips the remaining page flush observers of a
+	// commit once one returns an error. By default every observer is
+	// notified and their errors are logged together.
+	StopPageFlushOnError bool
+
 	logger Logger
 
 	path     string
@@ -138,6 +151,15 @@ type DB struct {
 	freelist     fl.Interface
 	freelistLoad sync.Once
 
+	// skipFreelistLoad is Options.SkipFreelistLoad for a read-only database.
+	skipFreelistLoad bool
+
+	// onFreelistRebuild reports progress while the freelist is reconstructed.
+	onFreelistRebuild func(pagesScanned uint64)
+	// freelistRebuilt is closed once an asynchronous reconstruction
+	// started by Open completes. It is nil otherwise.
+	freelistRebuilt chan struct{}
+
 	pagePool sync.Pool
 
 	batchMu sync.Mutex
@@ -148,13 +170,20 @@ type DB struct {
 	mmaplock             sync.RWMutex // Protects mmap access during remapping.
 	statlock             sync.RWMutex // Protects stats access.
 	flushMu              sync.RWMutex
-	flushObservers       []PageFlushObserver
-	flushObserverClosers []func() error
+	flushObservers       []pageFlushObserver
+	flushObserverClosers []*pageFlushCloser
+	commitObservers      []TxCommitObserver
 
 	ops struct {
-		writeAt func(b []byte, off int64) (n int, err error)
+		writeAt   func(b []byte, off int64) (n int, err error)
+		fdatasync func() error
 	}
 
+	// openNoSync is the NoSync value applied by Open. Commits compare it with
+	// NoSync to detect deprecated post-open mutation.
+	openNoSync    bool
+	noSyncWarning sync.Once
+
 	// Read only mode.
 	// When true, Update() and Begin(true) return ErrDatabaseReadOnly immediately.
 	readOnly bool
@@ -189,6 +218,7 @@ func Open(path string, mode os.FileMode, options *Options) (db *DB, err error) {
 		options = DefaultOptions
 	}
 	db.NoSync = options.NoSync
+	db.openNoSync = options.NoSync
 	db.NoGrowSync = options.NoGrowSync
 	db.MmapFlags = options.MmapFlags
 	db.NoFreelistSync = options.NoFreelistSync
@@ -196,10 +226,18 @@ func Open(path string, mode os.FileMode, options *Options) (db *DB, err error) {
 	db.FreelistType = options.FreelistType
 	db.Mlock = options.Mlock
 	db.MaxSize = options.MaxSize
+	db.StopPageFlushOnError = options.StopPageFlushOnError
+	db.onFreelistRebuild = options.OnFreelistRebuild
 
 	// Set default values for later DB operations.
 	db.MaxBatchSize = common.DefaultMaxBatchSize
+	if options.MaxBatchSize != 0 {
+		db.MaxBatchSize = options.MaxBatchSize
+	}
 	db.MaxBatchDelay = common.DefaultMaxBatchDelay
+	if options.MaxBatchDelay != 0 {
+		db.MaxBatchDelay = options.MaxBatchDelay
+	}
 	db.AllocSize = common.DefaultAllocSize
 
 	if !options.NoStatistics {
@@ -228,6 +266,10 @@ func Open(path string, mode os.FileMode, options *Options) (db *DB, err error) {
 	if options.ReadOnly {
 		flag = os.O_RDONLY
 		db.readOnly = true
+		if options.SkipFreelistLoad {
+			db.PreLoadFreelist = false
+			db.skipFreelistLoad = true
+		}
 	} else {
 		// always load free pages in write mode
 		db.PreLoadFreelist = true
@@ -262,6 +304,7 @@ func Open(path string, mode os.FileMode, options *Options) (db *DB, err error) {
 
 	// Default values for test hooks
 	db.ops.writeAt = db.file.WriteAt
+	db.ops.fdatasync = func() error { return fdatasync(db) }
 
 	if db.pageSize = options.PageSize; db.pageSize == 0 {
 		// Set the default page size to the OS page size.
@@ -304,7 +347,14 @@ func Open(path string, mode os.FileMode, options *Options) (db *DB, err error) {
 		return nil, err
 	}
 
-	if db.PreLoadFreelist {
+	rebuildAsync := options.RebuildFreelistAsync && db.PreLoadFreelist && !db.hasSyncedFreelist()
+	if rebuildAsync {
+		if err = db.loadFreelistAsync(); err != nil {
+			_ = db.close()
+			lg.Errorf("failed to start freelist reconstruction (%s): %v", path, err)
+			return nil, err
+		}
+	} else if db.PreLoadFreelist {
 		db.loadFreelist()
 	}
 
@@ -313,47 +363,25 @@ func Open(path string, mode os.FileMode, options *Options) (db *DB, err error) {
 	}
 
 	// Flush freelist when transitioning from no sync to sync so
-	// NoFreelistSync unaware boltdb can open the db later.
-	if !db.NoFreelistSync && !db.hasSyncedFreelist() {
-		tx, txErr := db.Begin(true)
-		if tx != nil {
-			txErr = tx.Commit()
-		}
-		if txErr != nil {
-			lg.Errorf("starting readwrite transaction failed: %v", txErr)
+	// NoFreelistSync unaware boltdb can open the db later. An asynchronous
+	// reconstruction flushes it once it completes instead.
+	if !rebuildAsync && !db.NoFreelistSync && !db.hasSyncedFreelist() {
+		if err = db.syncFreelist(); err != nil {
+			lg.Errorf("starting readwrite transaction failed: %v", err)
 			_ = db.close()
-			return nil, txErr
+			return nil, err
 		}
 	}
 
-	if len(options.PageFlushObservers) > 0 {
-		for _, registration := range options.PageFlushObservers {
-			var observer PageFlushObserver
-			var err error
-			if registration.Start != nil {
-				observer, err = registration.Start(db)
-				if err != nil {
-					_ = db.close()
-					lg.Errorf("starting page flush observer failed: %v", err)
-					return nil, err
-				}
-			}
-			if observer == nil {
-				observer = registration.Observer
-			}
-			closeFn := registration.Close
-			if observer != nil {
-				db.RegisterPageFlushObserver(observer)
-				db.flushObserverClosers = append(db.flushObserverClosers, func() error {
-					db.UnregisterPageFlushObserver(observer)
-					if closeFn != nil {
-						return closeFn()
-					}
-					return nil
-				})
-			} else if closeFn != nil {
-				db.flushObserverClosers = append(db.flushObserverClosers, closeFn)
-			}
+	for _, registration := range options.PageFlushObservers {
+		closer, err := db.startPageFlushObserver(registration)
+		if err != nil {
+			_ = db.close()
+			lg.Errorf("starting page flush observer failed: %v", err)
+			return nil, err
+		}
+		if closer != nil {
+			db.flushObserverClosers = append(db.flushObserverClosers, closer)
 		}
 	}
 
@@ -470,6 +498,59 @@ func (db *DB) loadFreelist() {
 	})
 }
 
+// loadFreelistAsync reconstructs an unsynced freelist in the background.
+// Read transactions register with an empty freelist meanwhile, and writers
+// wait on freelistRebuilt until the scan completes. The scan's read
+// transaction is opened here, so Close waits for it rather than racing it.
+func (db *DB) loadFreelistAsync() error {
+	tx, err := db.beginTx()
+	if err != nil {
+		return err
+	}
+	db.freelist = newFreelist(db.FreelistType)
+	db.freelistRebuilt = make(chan struct{})
+	started := make(chan struct{})
+	go func() {
+		db.freelistLoad.Do(func() {
+			defer close(db.freelistRebuilt)
+			close(started)
+			ids := db.freepagesTx(tx)
+			if err := tx.Rollback(); err != nil {
+				panic("freepages: failed to rollback tx")
+			}
+
+			db.metalock.Lock()
+			defer db.metalock.Unlock()
+			if !db.opened {
+				return
+			}
+			db.freelist.Init(ids)
+			if db.stats != nil {
+				db.stats.FreePageN = db.freelist.FreeCount()
+			}
+		})
+		if !db.readOnly && !db.NoFreelistSync {
+			if err := db.syncFreelist(); err != nil && !errors.Is(err, berrors.ErrDatabaseNotOpen) {
+				db.Logger().Errorf("flushing reconstructed freelist failed: %v", err)
+			}
+		}
+	}()
+	// Hold Open until the goroutine owns freelistLoad, so no other caller
+	// can start a second, synchronous scan.
+	<-started
+	return nil
+}
+
+// syncFreelist commits an empty read-write transaction, which persists the
+// freelist.
+func (db *DB) syncFreelist() error {
+	tx, err := db.Begin(true)
+	if tx != nil {
+		err = tx.Commit()
+	}
+	return err
+}
+
 func (db *DB) hasSyncedFreelist() bool {
 	return db.meta().Freelist() != common.PgidNoFreelist
 }
@@ -737,18 +818,22 @@ func (db *DB) close() error {
 
 	// Clear ops.
 	db.ops.writeAt = nil
+	db.ops.fdatasync = nil
 
 	var errs []error
 
-	for _, closeFn := range db.flushObserverClosers {
-		if closeFn != nil {
-			if err := closeFn(); err != nil {
-				errs = append(errs, err)
-			}
+	db.flushMu.Lock()
+	closers := db.flushObserverClosers
+	db.flushObserverClosers = nil
+	db.flushMu.Unlock()
+	for _, closer := range closers {
+		if err := closer.close(); err != nil {
+			errs = append(errs, err)
 		}
 	}
-	db.flushObserverClosers = nil
+	db.flushMu.Lock()
 	db.flushObservers = nil
+	db.flushMu.Unlock()
 	// Close the mmap.
 	if err := db.munmap(); err != nil {
 		errs = append(errs, err)
@@ -821,30 +906,131 @@ func (db *DB) Logger() Logger {
 	return db.logger
 }
 
+// syncEnabled reports whether a commit must fdatasync the file. It warns once
+// when DB.NoSync no longer matches the value Open applied from Options.NoSync.
+func (db *DB) syncEnabled() bool {
+	if db.NoSync != db.openNoSync {
+		db.noSyncWarning.Do(func() {
+			db.Logger().Warningf("DB.NoSync was changed after Open; set Options.NoSync instead, mutating the field races with in-flight commits")
+		})
+	}
+	return !db.NoSync || common.IgnoreNoSync
+}
+
 // RegisterPageFlushObserver registers an observer that is notified when dirty pages are flushed.
+// Observers are notified in registration order; registering one twice notifies it twice.
 // Passing nil clears all observers.
 func (db *DB) RegisterPageFlushObserver(observer PageFlushObserver) {
-	db.flushMu.Lock()
-	defer db.flushMu.Unlock()
 	if observer == nil {
+		db.flushMu.Lock()
 		db.flushObservers = nil
+		db.flushMu.Unlock()
 		return
 	}
-	db.flushObservers = append(db.flushObservers, observer)
+	db.registerPageFlushObserver(observer, nil)
+}
+
+// AttachObserver starts and registers the observer of reg on an open
+// database, as Options.PageFlushObservers does during Open. The returned
+// detach unregisters the observer and runs reg.Close; it is safe to call more
+// than once, and observers still attached when the database closes are
+// detached by Close. If the database closes while reg.Start runs, the
+// observer is detached again and ErrDatabaseNotOpen is returned.
+func (db *DB) AttachObserver(reg PageFlushObserverRegistration) (detach func() error, err error) {
+	db.mmaplock.RLock()
+	opened := db.opened
+	db.mmaplock.RUnlock()
+	if !opened {
+		return nil, berrors.ErrDatabaseNotOpen
+	}
+	closer, err := db.startPageFlushObserver(reg)
+	if err != nil {
+		return nil, err
+	}
+	if closer == nil {
+		return func() error { return nil }, nil
+	}
+	// Close may have run while the observer started. Check and register under
+	// mmaplock, which Close holds, so that Close either sees the closer or
+	// has already happened.
+	db.mmaplock.RLock()
+	if !db.opened {
+		db.mmaplock.RUnlock()
+		if err := closer.close(); err != nil {
+			return nil, errors.Join(berrors.ErrDatabaseNotOpen, err)
+		}
+		return nil, berrors.ErrDatabaseNotOpen
+	}
+	db.flushMu.Lock()
+	db.flushObserverClosers = append(db.flushObserverClosers, closer)
+	db.flushMu.Unlock()
+	db.mmaplock.RUnlock()
+	return func() error {
+		db.flushMu.Lock()
+		i := slices.Index(db.flushObserverClosers, closer)
+		if i >= 0 {
+			db.flushObserverClosers = slices.Delete(db.flushObserverClosers, i, i+1)
+		}
+		db.flushMu.Unlock()
+		if i < 0 {
+			return nil
+		}
+		return closer.close()
+	}, nil
+}
+
+// startPageFlushObserver runs the Start callback of registration and
+// registers the resulting observer. The returned closer, nil if there is
+// nothing to undo, unregisters the observer and runs registration.Close.
+func (db *DB) startPageFlushObserver(registration PageFlushObserverRegistration) (*pageFlushCloser, error) {
+	observer := registration.Observer
+	if registration.Start != nil {
+		started, err := registration.Start(db)
+		if err != nil {
+			return nil, err
+		}
+		if started != nil {
+			observer = started
+		}
+	}
+	closeFn := registration.Close
+	if observer == nil {
+		if closeFn == nil {
+			return nil, nil
+		}
+		return &pageFlushCloser{close: closeFn}, nil
+	}
+	db.registerPageFlushObserver(observer, registration.Filter)
+	return &pageFlushCloser{close: func() error {
+		db.UnregisterPageFlushObserver(observer)
+		if closeFn != nil {
+			return closeFn()
+		}
+		return nil
+	}}, nil
 }
 
-func (db *DB) getPageFlushObservers() []PageFlushObserver {
+func (db *DB) registerPageFlushObserver(observer PageFlushObserver, filter func(PageFlushInfo) bool) {
+	db.flushMu.Lock()
+	defer db.flushMu.Unlock()
+	db.flushObservers = append(db.flushObservers, pageFlushObserver{observer: observer, filter: filter})
+}
+
+func (db *DB) getPageFlushObservers() []pageFlushObserver {
 	db.flushMu.RLock()
 	defer db.flushMu.RUnlock()
 	if len(db.flushObservers) == 0 {
 		return nil
 	}
-	observers := make([]PageFlushObserver, len(db.flushObservers))
+	observers := make([]pageFlushObserver, len(db.flushObservers))
 	copy(observers, db.flushObservers)
 	return observers
 }
 
-// UnregisterPageFlushObserver removes a previously registered observer.
+// UnregisterPageFlushObserver removes a previously registered observer, leaving
+// the others in order. An observer registered more than once loses its earliest
+// registration. Observers are compared with ==, so their dynamic type must be
+// comparable.
 func (db *DB) UnregisterPageFlushObserver(observer PageFlushObserver) {
 	if observer == nil {
 		return
@@ -852,7 +1038,7 @@ func (db *DB) UnregisterPageFlushObserver(observer PageFlushObserver) {
 	db.flushMu.Lock()
 	defer db.flushMu.Unlock()
 	for i, obs := range db.flushObservers {
-		if obs == observer {
+		if obs.observer == observer {
 			db.flushObservers = append(db.flushObservers[:i], db.flushObservers[i+1:]...)
 			break
 		}
@@ -912,6 +1098,11 @@ func (db *DB) beginRWTx() (*Tx, error) {
 		return nil, berrors.ErrDatabaseReadOnly
 	}
 
+	// Wait for an asynchronous freelist reconstruction to finish.
+	if db.freelistRebuilt != nil {
+		<-db.freelistRebuilt
+	}
+
 	// Obtain writer lock. This is released by the transaction when it closes.
 	// This enforces only one writer transaction at a time.
 	db.rwlock.Lock()
@@ -1030,6 +1221,44 @@ func (db *DB) View(fn func(*Tx) error) error {
 	return t.Rollback()
 }
 
+// UpdateContext is like Update, but bound to ctx. It returns ctx.Err() without
+// starting the transaction if ctx is already done, and rolls the transaction
+// back and returns ctx.Err() if ctx is done by the time fn returns, so that
+// nothing is committed once ctx is cancelled. While fn runs, the bucket
+// iteration helpers (ForEach, ForEachReverse, ForEachPrefix, Range,
+// ForEachBucket, Tx.ForEach) and Tx.Check stop early with ctx.Err(), and fn
+// can use tx.Context() for its own checks. Waiting for the writer lock and a
+// commit already under way are not interrupted.
+func (db *DB) UpdateContext(ctx context.Context, fn func(*Tx) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return db.Update(func(tx *Tx) error {
+		tx.ctx = ctx
+		if err := fn(tx); err != nil {
+			return err
+		}
+		return ctx.Err()
+	})
+}
+
+// ViewContext is like View, but bound to ctx. It returns ctx.Err() without
+// starting the transaction if ctx is already done, or if ctx is done by the
+// time fn returns. While fn runs, the checkpoints are the same as for
+// UpdateContext.
+func (db *DB) ViewContext(ctx context.Context, fn func(*Tx) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return db.View(func(tx *Tx) error {
+		tx.ctx = ctx
+		if err := fn(tx); err != nil {
+			return err
+		}
+		return ctx.Err()
+	})
+}
+
 // Batch calls fn as part of a batch. It behaves similar to Update,
 // except:
 //
@@ -1189,6 +1418,39 @@ func (db *DB) Stats() Stats {
 	return s
 }
 
+// FreelistStats returns the size and fragmentation of the free pages,
+// computed from the in-memory freelist. It waits for the open read-write
+// transaction, if any, to finish, so it is safe to call concurrently with
+// writes but deadlocks when called from inside an Update or Batch function
+// of the same database; use Stats there instead. A read-only database only
+// has a freelist when opened with PreLoadFreelist; without one,
+// ErrFreePagesNotLoaded is returned.
+func (db *DB) FreelistStats() (FreelistStats, error) {
+	// Wait for an asynchronous freelist reconstruction to finish.
+	if db.freelistRebuilt != nil {
+		<-db.freelistRebuilt
+	}
+
+	db.rwlock.Lock()
+	defer db.rwlock.Unlock()
+	db.metalock.Lock()
+	defer db.metalock.Unlock()
+
+	if !db.opened {
+		return FreelistStats{}, berrors.ErrDatabaseNotOpen
+	}
+	if db.freelist == nil {
+		return FreelistStats{}, berrors.ErrFreePagesNotLoaded
+	}
+
+	s := FreelistStats{
+		FreePageN:    db.freelist.FreeCount(),
+		PendingPageN: db.freelist.PendingCount(),
+	}
+	s.RunN, s.LargestRunN = db.freelist.FreeRuns()
+	return s, nil
+}
+
 // This is for internal access to the raw data bytes from the C cursor, use
 // carefully, or not at all.
 func (db *DB) Info() *Info {
@@ -1337,6 +1599,30 @@ func (db *DB) freepages() []common.Pgid {
 	if err != nil {
 		panic("freepages: failed to open read only tx")
 	}
+	return db.freepagesTx(tx)
+}
+
+// freelistRebuildReportInterval is how many scanned pages pass between
+// OnFreelistRebuild calls.
+const freelistRebuildReportInterval = 1024
+
+// freepagesTx returns the pages below the high water mark that are not
+// reachable from the root bucket as of tx.
+func (db *DB) freepagesTx(tx *Tx) []common.Pgid {
+	var scanned uint64
+	if progress := db.onFreelistRebuild; progress != nil {
+		progress(0)
+		tx.pageVisited = func(*common.Page) {
+			scanned++
+			if scanned%freelistRebuildReportInterval == 0 {
+				progress(scanned)
+			}
+		}
+		defer func() {
+			tx.pageVisited = nil
+			progress(scanned)
+		}()
+	}
 
 	reachable := make(map[common.Pgid]*common.Page)
 	nofreed := make(map[common.Pgid]bool)
@@ -1385,6 +1671,30 @@ type Options struct {
 	// load the free pages.
 	PreLoadFreelist bool
 
+	// SkipFreelistLoad opens a read-only database without its freelist, so
+	// Open never reads or reconstructs it, however large the database and
+	// even when the freelist isn't synced. PreLoadFreelist is ignored. The
+	// trade-off is that free pages can't be told apart: Tx.Page reports
+	// each page by the type in its header instead of as "free", and
+	// DB.FreelistStats returns ErrFreePagesNotLoaded. Tx.Check still loads
+	// the freelist when it runs. It has no effect in write mode.
+	SkipFreelistLoad bool
+
+	// OnFreelistRebuild, if set, is called while an unsynced freelist is
+	// reconstructed by scanning every reachable page, as happens when
+	// opening a database whose freelist was abandoned and, with
+	// NoFreelistSync, after a writable transaction rolls back. It receives
+	// the number of pages scanned so far: 0 when the scan starts,
+	// periodically during it, and the total once it completes.
+	OnFreelistRebuild func(pagesScanned uint64)
+
+	// RebuildFreelistAsync makes Open return without waiting for an
+	// unsynced freelist to be reconstructed. The scan runs in the
+	// background; read transactions proceed meanwhile, while writable
+	// transactions and Tx.Check wait for it to finish. It has no effect
+	// when the freelist is synced or not preloaded.
+	RebuildFreelistAsync bool
+
 	// FreelistType sets the backend freelist type. There are two options. Array which is simple but endures
 	// dramatic performance degradation if database is large and fragmentation in freelist is common.
 	// The alternative one is using hashmap, it is faster in almost all circumstances
@@ -1421,11 +1731,19 @@ type Options struct {
 	// MaxSize sets the maximum size of the data file. <=0 means no maximum.
 	MaxSize int
 
-	// NoSync sets the initial value of DB.NoSync. Normally this can just be
-	// set directly on the DB itself when returned from Open(), but this option
-	// is useful in APIs which expose Options but not the underlying DB.
+	// NoSync sets the initial value of DB.NoSync. It is applied before Open
+	// returns, so unlike assigning DB.NoSync afterwards it cannot race with
+	// commits that are already running.
 	NoSync bool
 
+	// MaxBatchSize sets the initial value of DB.MaxBatchSize. Zero uses
+	// DefaultMaxBatchSize; a negative value disables batching.
+	MaxBatchSize int
+
+	// MaxBatchDelay sets the initial value of DB.MaxBatchDelay. Zero uses
+	// DefaultMaxBatchDelay; a negative value disables batching.
+	MaxBatchDelay time.Duration
+
 	// OpenFile is used to open files. It defaults to os.OpenFile. This option
 	// is useful for writing hermetic tests.
 	OpenFile func(string, int, os.FileMode) (*os.File, error)
@@ -1441,6 +1759,9 @@ type Options struct {
 	// PageFlushObservers registers observers that receive flushed page events.
 	PageFlushObservers []PageFlushObserverRegistration
 
+	// StopPageFlushOnError sets DB.StopPageFlushOnError.
+	StopPageFlushOnError bool
+
 	// NoStatistics turns off statistics collection, Stats method will
 	// return empty structure in this case. This can be beneficial for
 	// performance under high-concurrency read-only transactions.
@@ -1452,8 +1773,8 @@ func (o *Options) String() string {
 		return "{}"
 	}
 
-	return fmt.Sprintf("{Timeout: %s, NoGrowSync: %t, NoFreelistSync: %t, PreLoadFreelist: %t, FreelistType: %s, ReadOnly: %t, MmapFlags: %x, InitialMmapSize: %d, PageSize: %d, MaxSize: %d, NoSync: %t, OpenFile: %p, Mlock: %t, Logger: %p, PageFlushObservers: %d, NoStatistics: %t}",
-		o.Timeout, o.NoGrowSync, o.NoFreelistSync, o.PreLoadFreelist, o.FreelistType, o.ReadOnly, o.MmapFlags, o.InitialMmapSize, o.PageSize, o.MaxSize, o.NoSync, o.OpenFile, o.Mlock, o.Logger, len(o.PageFlushObservers), o.NoStatistics)
+	return fmt.Sprintf("{Timeout: %s, NoGrowSync: %t, NoFreelistSync: %t, PreLoadFreelist: %t, SkipFreelistLoad: %t, FreelistType: %s, ReadOnly: %t, MmapFlags: %x, InitialMmapSize: %d, PageSize: %d, MaxSize: %d, NoSync: %t, MaxBatchSize: %d, MaxBatchDelay: %s, OpenFile: %p, Mlock: %t, Logger: %p, PageFlushObservers: %d, StopPageFlushOnError: %t, NoStatistics: %t, RebuildFreelistAsync: %t}",
+		o.Timeout, o.NoGrowSync, o.NoFreelistSync, o.PreLoadFreelist, o.SkipFreelistLoad, o.FreelistType, o.ReadOnly, o.MmapFlags, o.InitialMmapSize, o.PageSize, o.MaxSize, o.NoSync, o.MaxBatchSize, o.MaxBatchDelay, o.OpenFile, o.Mlock, o.Logger, len(o.PageFlushObservers), o.StopPageFlushOnError, o.NoStatistics, o.RebuildFreelistAsync)
 
 }
 
@@ -1501,6 +1822,16 @@ func (s *Stats) Sub(other *Stats) Stats {
 	return diff
 }
 
+// FreelistStats describes the free pages of the database. Pages freed by
+// transactions that are still visible to open readers are pending and are not
+// part of any run.
+type FreelistStats struct {
+	FreePageN    int // number of free pages
+	PendingPageN int // number of pending pages
+	RunN         int // number of runs of contiguous free pages
+	LargestRunN  int // length in pages of the longest run
+}
+
 type Info struct {
 	Data     uintptr
 	PageSize int
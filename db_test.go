@@ -2,6 +2,7 @@ package witchbolt_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
@@ -648,6 +649,79 @@ func TestOpen_RecoverFreeList(t *testing.T) {
 	}
 }
 
+// Ensure that reconstructing an abandoned freelist reports progress, both
+// during Open and in the background.
+func TestOpen_FreelistRebuildProgress(t *testing.T) {
+	db := btesting.MustCreateDBWithOption(t, &witchbolt.Options{NoFreelistSync: true})
+	path := db.Path()
+	err := db.Update(func(tx *witchbolt.Tx) error {
+		for i := 0; i < 100; i++ {
+			b, err := tx.CreateBucket([]byte(fmt.Sprintf("%d", i)))
+			if err != nil {
+				return err
+			}
+			if err := b.Put([]byte("k"), make([]byte, 8192)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	for _, async := range []bool{false, true} {
+		t.Run(fmt.Sprintf("async=%t", async), func(t *testing.T) {
+			dbPath := filepath.Join(t.TempDir(), "db")
+			require.NoError(t, os.WriteFile(dbPath, data, 0600))
+
+			var mu sync.Mutex
+			var calls []uint64
+			progress := func(pagesScanned uint64) {
+				mu.Lock()
+				defer mu.Unlock()
+				calls = append(calls, pagesScanned)
+			}
+			db, err := witchbolt.Open(dbPath, 0600, &witchbolt.Options{
+				OnFreelistRebuild:    progress,
+				RebuildFreelistAsync: async,
+			})
+			require.NoError(t, err)
+
+			// Writers wait for the reconstruction to finish.
+			require.NoError(t, db.Update(func(tx *witchbolt.Tx) error {
+				return tx.Bucket([]byte("0")).Put([]byte("k2"), []byte("v"))
+			}))
+			require.NoError(t, db.Close())
+
+			mu.Lock()
+			scanned := calls
+			calls = nil
+			mu.Unlock()
+			require.GreaterOrEqual(t, len(scanned), 2)
+			assert.Equal(t, uint64(0), scanned[0])
+			assert.Greater(t, scanned[len(scanned)-1], uint64(100))
+			for i := 1; i < len(scanned); i++ {
+				assert.GreaterOrEqual(t, scanned[i], scanned[i-1])
+			}
+
+			// The reconstructed freelist was synced, so reopening skips the scan.
+			db, err = witchbolt.Open(dbPath, 0600, &witchbolt.Options{
+				ReadOnly:          true,
+				PreLoadFreelist:   true,
+				OnFreelistRebuild: progress,
+			})
+			require.NoError(t, err)
+			require.NoError(t, db.Close())
+			mu.Lock()
+			defer mu.Unlock()
+			assert.Empty(t, calls)
+		})
+	}
+}
+
 // Ensure that a database cannot open a transaction when it's not open.
 func TestDB_Begin_ErrDatabaseNotOpen(t *testing.T) {
 	var db witchbolt.DB
@@ -1177,6 +1251,259 @@ func TestDB_View_Panic(t *testing.T) {
 	}
 }
 
+// Ensure that UpdateContext commits like Update, and doesn't commit once its
+// context is cancelled.
+func TestDB_UpdateContext(t *testing.T) {
+	db := btesting.MustCreateDB(t)
+
+	err := db.UpdateContext(context.Background(), func(tx *witchbolt.Tx) error {
+		require.Equal(t, context.Background(), tx.Context())
+		b, err := tx.CreateBucket([]byte("widgets"))
+		require.NoError(t, err)
+		for i := 0; i < 1000; i++ {
+			require.NoError(t, b.Put([]byte(fmt.Sprintf("%04d", i)), []byte("value")))
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	t.Run("cancelled before starting", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := db.UpdateContext(ctx, func(tx *witchbolt.Tx) error {
+			t.Fatal("fn must not be called")
+			return nil
+		})
+		require.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("cancelled before committing", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		err := db.UpdateContext(ctx, func(tx *witchbolt.Tx) error {
+			require.Equal(t, ctx, tx.Context())
+			require.NoError(t, tx.Bucket([]byte("widgets")).Put([]byte("lost"), []byte("value")))
+			cancel()
+			return nil
+		})
+		require.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("cancelled while iterating", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		var visited int
+		err := db.UpdateContext(ctx, func(tx *witchbolt.Tx) error {
+			b := tx.Bucket([]byte("widgets"))
+			require.NoError(t, b.Put([]byte("lost"), []byte("value")))
+			return b.ForEach(func(k, v []byte) error {
+				if visited++; visited == 3 {
+					cancel()
+				}
+				return nil
+			})
+		})
+		require.ErrorIs(t, err, context.Canceled)
+		require.Less(t, visited, 1000)
+	})
+
+	err = db.View(func(tx *witchbolt.Tx) error {
+		require.Nil(t, tx.Bucket([]byte("widgets")).Get([]byte("lost")), "a cancelled transaction must not commit")
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+// Ensure that the iteration helpers and Check of a ViewContext transaction
+// stop once its context is done. The iteration helpers only check the
+// context every few hundred keys, so there are more keys than that.
+func TestDB_ViewContext(t *testing.T) {
+	const keyN = 1000
+	db := btesting.MustCreateDB(t)
+	err := db.Update(func(tx *witchbolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("widgets"))
+		require.NoError(t, err)
+		for i := 0; i < keyN; i++ {
+			require.NoError(t, b.Put([]byte(fmt.Sprintf("%04d", i)), []byte("value")))
+			_, err := b.CreateBucket([]byte(fmt.Sprintf("sub%04d", i)))
+			require.NoError(t, err)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	iterators := map[string]func(b *witchbolt.Bucket, fn func() error) error{
+		"ForEach": func(b *witchbolt.Bucket, fn func() error) error {
+			return b.ForEach(func(k, v []byte) error { return fn() })
+		},
+		"ForEachReverse": func(b *witchbolt.Bucket, fn func() error) error {
+			return b.ForEachReverse(func(k, v []byte) error { return fn() })
+		},
+		"ForEachPrefix": func(b *witchbolt.Bucket, fn func() error) error {
+			return b.ForEachPrefix([]byte("0"), func(k, v []byte) error { return fn() })
+		},
+		"Range": func(b *witchbolt.Bucket, fn func() error) error {
+			return b.Range(nil, nil, func(k, v []byte) error { return fn() })
+		},
+		"ForEachBucket": func(b *witchbolt.Bucket, fn func() error) error {
+			return b.ForEachBucket(func(k []byte) error { return fn() })
+		},
+	}
+	for name, iterate := range iterators {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			var visited int
+			err := db.ViewContext(ctx, func(tx *witchbolt.Tx) error {
+				return iterate(tx.Bucket([]byte("widgets")), func() error {
+					if visited++; visited == 2 {
+						cancel()
+					}
+					return nil
+				})
+			})
+			require.ErrorIs(t, err, context.Canceled)
+			require.Less(t, visited, keyN, "the iteration stops before the end")
+
+			// Without a context, the same iteration runs to the end.
+			visited = 0
+			err = db.View(func(tx *witchbolt.Tx) error {
+				return iterate(tx.Bucket([]byte("widgets")), func() error {
+					visited++
+					return nil
+				})
+			})
+			require.NoError(t, err)
+			require.GreaterOrEqual(t, visited, keyN)
+		})
+	}
+
+	t.Run("Check", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		err := db.ViewContext(ctx, func(tx *witchbolt.Tx) error {
+			cancel()
+			var errs []error
+			for cErr := range tx.Check() {
+				errs = append(errs, cErr)
+			}
+			require.Len(t, errs, 1, "a cancelled check must not report unvisited pages")
+			require.ErrorIs(t, errs[0], context.Canceled)
+			return nil
+		})
+		require.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+// Ensure that Compact stops once the context given by WithCompactContext is
+// done.
+func TestCompact_Context(t *testing.T) {
+	src := btesting.MustCreateDB(t)
+	err := src.Update(func(tx *witchbolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("widgets"))
+		require.NoError(t, err)
+		for i := 0; i < 5000; i++ {
+			require.NoError(t, b.Put([]byte(fmt.Sprintf("%04d", i)), []byte("value")))
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	dst := btesting.MustCreateDB(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var reports int
+	err = witchbolt.Compact(dst.DB, src.DB, 0, witchbolt.WithCompactContext(ctx), witchbolt.WithCompactProgress(func(copiedKeys, copiedBytes int64) {
+		if reports++; reports == 2 {
+			cancel()
+		}
+	}))
+	require.ErrorIs(t, err, context.Canceled)
+
+	err = dst.View(func(tx *witchbolt.Tx) error {
+		require.Nil(t, tx.Bucket([]byte("widgets")), "a cancelled compaction must not commit")
+		return nil
+	})
+	require.NoError(t, err)
+
+	err = witchbolt.Compact(dst.DB, src.DB, 0, witchbolt.WithCompactContext(context.Background()))
+	require.NoError(t, err)
+	dst.MustCheck()
+}
+
+// Ensure that CopyBucket copies one nested bucket tree, with its sequences,
+// into the same path of another database.
+func TestCopyBucket(t *testing.T) {
+	src := btesting.MustCreateDB(t)
+	err := src.Update(func(tx *witchbolt.Tx) error {
+		users, err := tx.CreateBucket([]byte("users"))
+		require.NoError(t, err)
+		require.NoError(t, users.Put([]byte("admin"), []byte("root")))
+		require.NoError(t, users.SetSequence(7))
+		shard, err := users.CreateBucket([]byte("shard-1"))
+		require.NoError(t, err)
+		require.NoError(t, shard.SetSequence(42))
+		for i := 0; i < 1000; i++ {
+			require.NoError(t, shard.Put([]byte(fmt.Sprintf("%04d", i)), make([]byte, 100)))
+		}
+		nested, err := shard.CreateBucket([]byte("index"))
+		require.NoError(t, err)
+		require.NoError(t, nested.SetSequence(3))
+		require.NoError(t, nested.Put([]byte("foo"), []byte("bar")))
+		_, err = users.CreateBucket([]byte("shard-2"))
+		require.NoError(t, err)
+		_, err = tx.CreateBucket([]byte("other"))
+		return err
+	})
+	require.NoError(t, err)
+
+	dst := btesting.MustCreateDB(t)
+	err = witchbolt.CopyBucket(dst.DB, src.DB, [][]byte{[]byte("users"), []byte("shard-1")}, 4096)
+	require.NoError(t, err)
+	dst.MustCheck()
+
+	err = dst.View(func(tx *witchbolt.Tx) error {
+		require.Nil(t, tx.Bucket([]byte("other")))
+		users := tx.Bucket([]byte("users"))
+		require.NotNil(t, users)
+		require.Nil(t, users.Get([]byte("admin")), "the keys of the parents are not copied")
+		require.Zero(t, users.Sequence())
+		require.Nil(t, users.Bucket([]byte("shard-2")))
+
+		shard := users.Bucket([]byte("shard-1"))
+		require.NotNil(t, shard)
+		require.Equal(t, uint64(42), shard.Sequence())
+		require.Equal(t, 1002, shard.Stats().KeyN, "1000 keys, the nested bucket and its key")
+		require.Equal(t, make([]byte, 100), shard.Get([]byte("0999")))
+		nested := shard.Bucket([]byte("index"))
+		require.Equal(t, uint64(3), nested.Sequence())
+		require.Equal(t, []byte("bar"), nested.Get([]byte("foo")))
+		return nil
+	})
+	require.NoError(t, err)
+
+	t.Log("A top-level bucket is copied next to the buckets already in dst")
+	err = witchbolt.CopyBucket(dst.DB, src.DB, [][]byte{[]byte("other")}, 0)
+	require.NoError(t, err)
+	err = dst.View(func(tx *witchbolt.Tx) error {
+		require.NotNil(t, tx.Bucket([]byte("other")))
+		require.NotNil(t, tx.Bucket([]byte("users")))
+		return nil
+	})
+	require.NoError(t, err)
+
+	t.Log("The bucket must exist in src and not in dst")
+	err = witchbolt.CopyBucket(dst.DB, src.DB, [][]byte{[]byte("users"), []byte("shard-1")}, 0)
+	require.ErrorIs(t, err, berrors.ErrBucketExists)
+	err = witchbolt.CopyBucket(dst.DB, src.DB, [][]byte{[]byte("users"), []byte("shard-3")}, 0)
+	require.ErrorIs(t, err, berrors.ErrBucketNotFound)
+	err = witchbolt.CopyBucket(dst.DB, src.DB, [][]byte{[]byte("missing"), []byte("shard-1")}, 0)
+	require.ErrorIs(t, err, berrors.ErrBucketNotFound)
+	err = witchbolt.CopyBucket(dst.DB, src.DB, nil, 0)
+	require.ErrorIs(t, err, berrors.ErrBucketNameRequired)
+	err = dst.View(func(tx *witchbolt.Tx) error {
+		require.Nil(t, tx.Bucket([]byte("missing")), "a failed copy doesn't commit the parents")
+		return nil
+	})
+	require.NoError(t, err)
+}
+
 // Ensure that DB stats can be returned.
 func TestDB_Stats(t *testing.T) {
 	db := btesting.MustCreateDB(t)
@@ -1197,6 +1524,185 @@ func TestDB_Stats(t *testing.T) {
 	}
 }
 
+// Ensure that freelist stats reflect the pages freed by deleting a bucket.
+func TestDB_FreelistStats(t *testing.T) {
+	db := btesting.MustCreateDB(t)
+
+	s, err := db.FreelistStats()
+	require.NoError(t, err)
+	require.Zero(t, s.FreePageN)
+	require.Zero(t, s.RunN)
+
+	err = db.Update(func(tx *witchbolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("widgets"))
+		require.NoError(t, err)
+		for i := 0; i < 1000; i++ {
+			require.NoError(t, b.Put([]byte(fmt.Sprintf("%04d", i)), make([]byte, 500)))
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	err = db.Update(func(tx *witchbolt.Tx) error {
+		return tx.DeleteBucket([]byte("widgets"))
+	})
+	require.NoError(t, err)
+
+	s, err = db.FreelistStats()
+	require.NoError(t, err)
+	require.Positive(t, s.PendingPageN)
+
+	// The next write transaction releases the pending pages.
+	require.NoError(t, db.Update(func(tx *witchbolt.Tx) error { return nil }))
+	s, err = db.FreelistStats()
+	require.NoError(t, err)
+	require.Equal(t, db.Stats().FreePageN, s.FreePageN)
+	require.Greater(t, s.FreePageN, 100)
+	require.Positive(t, s.RunN)
+	require.GreaterOrEqual(t, s.FreePageN, s.LargestRunN)
+	require.Greater(t, s.LargestRunN, 1)
+
+	closed := db.DB
+	db.MustClose()
+	_, err = closed.FreelistStats()
+	require.ErrorIs(t, err, berrors.ErrDatabaseNotOpen)
+}
+
+// Ensure that freelist stats can be read while other goroutines write.
+func TestDB_FreelistStats_Concurrent(t *testing.T) {
+	db := btesting.MustCreateDB(t)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			err := db.Update(func(tx *witchbolt.Tx) error {
+				b, err := tx.CreateBucketIfNotExists([]byte("widgets"))
+				if err != nil {
+					return err
+				}
+				if i%2 == 1 {
+					return tx.DeleteBucket([]byte("widgets"))
+				}
+				for j := 0; j < 50; j++ {
+					if err := b.Put([]byte(fmt.Sprintf("%04d", j)), make([]byte, 200)); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			require.NoError(t, err)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		s, err := db.FreelistStats()
+		require.NoError(t, err)
+		require.GreaterOrEqual(t, s.FreePageN, s.LargestRunN)
+	}
+	wg.Wait()
+}
+
+// Ensure that FreelistStats waits for an open read-write transaction, and
+// so must not be called from inside one.
+func TestDB_FreelistStats_WaitsForWriter(t *testing.T) {
+	db := btesting.MustCreateDB(t)
+
+	tx, err := db.Begin(true)
+	require.NoError(t, err)
+	_, err = tx.CreateBucket([]byte("widgets"))
+	require.NoError(t, err)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := db.FreelistStats()
+		done <- err
+	}()
+	select {
+	case <-done:
+		t.Fatal("FreelistStats returned while a write transaction was open")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	require.NoError(t, tx.Commit())
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("FreelistStats did not return after the commit")
+	}
+}
+
+// Ensure that a read-only database without a preloaded freelist has no
+// freelist stats.
+func TestDB_FreelistStats_ReadOnly(t *testing.T) {
+	db := btesting.MustCreateDB(t)
+	require.NoError(t, db.Update(func(tx *witchbolt.Tx) error { return nil }))
+	path := db.Path()
+	db.MustClose()
+
+	ro, err := witchbolt.Open(path, 0600, &witchbolt.Options{ReadOnly: true})
+	require.NoError(t, err)
+	_, err = ro.FreelistStats()
+	require.ErrorIs(t, err, berrors.ErrFreePagesNotLoaded)
+	require.NoError(t, ro.Close())
+
+	ro, err = witchbolt.Open(path, 0600, &witchbolt.Options{ReadOnly: true, PreLoadFreelist: true})
+	require.NoError(t, err)
+	_, err = ro.FreelistStats()
+	require.NoError(t, err)
+	require.NoError(t, ro.Close())
+}
+
+// Ensure that a read-only open with SkipFreelistLoad doesn't rebuild an
+// unsynced freelist, and that Tx.Page then reports free pages by their header.
+func TestOpen_SkipFreelistLoad(t *testing.T) {
+	db := btesting.MustCreateDBWithOption(t, &witchbolt.Options{NoFreelistSync: true})
+	err := db.Update(func(tx *witchbolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("widgets"))
+		require.NoError(t, err)
+		for i := 0; i < 100; i++ {
+			require.NoError(t, b.Put([]byte(fmt.Sprintf("%04d", i)), make([]byte, 500)))
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.NoError(t, db.Update(func(tx *witchbolt.Tx) error {
+		return tx.DeleteBucket([]byte("widgets"))
+	}))
+	path := db.Path()
+	db.MustClose()
+
+	pageTypes := func(options *witchbolt.Options) (types map[string]int, rebuilt bool) {
+		options.ReadOnly = true
+		options.OnFreelistRebuild = func(uint64) { rebuilt = true }
+		ro, err := witchbolt.Open(path, 0600, options)
+		require.NoError(t, err)
+		defer func() { require.NoError(t, ro.Close()) }()
+
+		types = make(map[string]int)
+		require.NoError(t, ro.View(func(tx *witchbolt.Tx) error {
+			for id := 0; ; id++ {
+				p, err := tx.Page(id)
+				require.NoError(t, err)
+				if p == nil {
+					return nil
+				}
+				types[p.Type]++
+			}
+		}))
+		return types, rebuilt
+	}
+
+	types, rebuilt := pageTypes(&witchbolt.Options{PreLoadFreelist: true})
+	require.True(t, rebuilt)
+	require.Positive(t, types["free"])
+
+	skipped, rebuilt := pageTypes(&witchbolt.Options{PreLoadFreelist: true, SkipFreelistLoad: true})
+	require.False(t, rebuilt, "the freelist shouldn't be rebuilt")
+	require.Zero(t, skipped["free"])
+	require.Greater(t, skipped["leaf"], types["leaf"])
+}
+
 // Ensure that database pages are in expected order and type.
 func TestDB_Consistency(t *testing.T) {
 	db := btesting.MustCreateDB(t)
@@ -1461,6 +1967,44 @@ func TestDB_BatchTime(t *testing.T) {
 	}
 }
 
+// Ensure batch tuning supplied through Options is applied at Open.
+func TestOpen_BatchOptions(t *testing.T) {
+	db := btesting.MustCreateDBWithOption(t, &witchbolt.Options{
+		MaxBatchSize:  2,
+		MaxBatchDelay: time.Hour,
+	})
+	require.Equal(t, 2, db.MaxBatchSize)
+	require.Equal(t, time.Hour, db.MaxBatchDelay)
+
+	require.NoError(t, db.Update(func(tx *witchbolt.Tx) error {
+		_, err := tx.CreateBucket([]byte("widgets"))
+		return err
+	}))
+
+	// With an hour-long delay the batch can only run once it is full.
+	const size = 2
+	ch := make(chan error, size)
+	for i := 0; i < size; i++ {
+		go func(i int) {
+			ch <- db.Batch(func(tx *witchbolt.Tx) error {
+				return tx.Bucket([]byte("widgets")).Put(u64tob(uint64(i)), []byte{})
+			})
+		}(i)
+	}
+	for i := 0; i < size; i++ {
+		select {
+		case err := <-ch:
+			require.NoError(t, err)
+		case <-time.After(10 * time.Second):
+			t.Fatal("batch did not trigger on size")
+		}
+	}
+
+	defaults := btesting.MustCreateDBWithOption(t, &witchbolt.Options{})
+	require.Equal(t, 1000, defaults.MaxBatchSize)
+	require.Equal(t, 10*time.Millisecond, defaults.MaxBatchDelay)
+}
+
 // TestDBUnmap verifes that `dataref`, `data` and `datasz` must be reset
 // to zero values respectively after unmapping the db.
 func TestDBUnmap(t *testing.T) {
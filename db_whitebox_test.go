@@ -1,13 +1,17 @@
 package witchbolt
 
 import (
+	"bytes"
+	"log"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/delaneyj/witchbolt/errors"
+	"github.com/delaneyj/witchbolt/internal/common"
 )
 
 func TestOpenWithPreLoadFreelist(t *testing.T) {
@@ -124,3 +128,60 @@ func prepareData(t *testing.T) (string, error) {
 
 	return fileName, nil
 }
+
+func TestOpenWithNoSync(t *testing.T) {
+	if common.IgnoreNoSync {
+		t.Skip("NoSync is ignored on this platform")
+	}
+
+	countSyncs := func(t *testing.T, db *DB) int {
+		syncs := 0
+		fdatasync := db.ops.fdatasync
+		db.ops.fdatasync = func() error {
+			syncs++
+			return fdatasync()
+		}
+		err := db.Update(func(tx *Tx) error {
+			b, err := tx.CreateBucketIfNotExists([]byte("widgets"))
+			if err != nil {
+				return err
+			}
+			return b.Put([]byte("foo"), []byte("bar"))
+		})
+		require.NoError(t, err)
+		return syncs
+	}
+
+	t.Run("synced", func(t *testing.T) {
+		db, err := Open(filepath.Join(t.TempDir(), "db"), 0600, &Options{})
+		require.NoError(t, err)
+		defer db.Close()
+		assert.Positive(t, countSyncs(t, db))
+	})
+
+	t.Run("options", func(t *testing.T) {
+		var logs bytes.Buffer
+		db, err := Open(filepath.Join(t.TempDir(), "db"), 0600, &Options{
+			NoSync: true,
+			Logger: &DefaultLogger{Logger: log.New(&logs, "", 0)},
+		})
+		require.NoError(t, err)
+		defer db.Close()
+		assert.True(t, db.NoSync)
+		assert.Zero(t, countSyncs(t, db))
+		assert.NotContains(t, logs.String(), "DB.NoSync was changed after Open")
+	})
+
+	t.Run("mutated after open", func(t *testing.T) {
+		var logs bytes.Buffer
+		db, err := Open(filepath.Join(t.TempDir(), "db"), 0600, &Options{
+			Logger: &DefaultLogger{Logger: log.New(&logs, "", 0)},
+		})
+		require.NoError(t, err)
+		defer db.Close()
+		db.NoSync = true
+		assert.Zero(t, countSyncs(t, db))
+		countSyncs(t, db)
+		assert.Equal(t, 1, strings.Count(logs.String(), "DB.NoSync was changed after Open"))
+	})
+}
@@ -84,4 +84,8 @@ var (
 	// ErrDifferentDB is returned when trying to move a sub-bucket between
 	// source and target buckets, while source and target buckets are in different database files.
 	ErrDifferentDB = errors.New("the source and target buckets are in different database files")
+
+	// ErrMoveToDescendant is returned when trying to move a sub-bucket into
+	// itself or into one of its own descendants.
+	ErrMoveToDescendant = errors.New("the target bucket is the moved bucket or one of its descendants")
 )
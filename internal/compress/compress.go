@@ -0,0 +1,115 @@
+// Package compress holds the compression codecs shared by
+// Tx.WriteToCompressed and the stream package, so that a compressed copy of
+// a database and a stream snapshot are encoded the same way.
+package compress
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/s2"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Codec names, as used by witchbolt.CompressionType.
+const (
+	None = "none"
+	ZSTD = "zstd"
+	S2   = "s2"
+)
+
+// ZSTDLevel maps the codec-agnostic 1-11 quality scale onto zstd levels.
+// Zero keeps the library default.
+func ZSTDLevel(level int) int {
+	if level <= 0 {
+		return 0
+	}
+	if level > 11 {
+		level = 11
+	}
+	table := []int{0, -5, -3, -1, 0, 3, 6, 9, 12, 15, 18, 22}
+	return table[level]
+}
+
+// ZSTDWindow rounds a window size in bytes up to the power of two zstd
+// requires, clamped to the sizes the encoder supports. Zero keeps the library
+// default.
+func ZSTDWindow(window int) int {
+	if window <= 0 {
+		return 0
+	}
+	size := zstd.MinWindowSize
+	for size < window && size < zstd.MaxWindowSize {
+		size <<= 1
+	}
+	return size
+}
+
+// S2Level maps the codec-agnostic 1-11 quality scale onto the three S2
+// encoder modes: 1 (default), 2 (better) and 3 (best).
+func S2Level(level int) int {
+	switch {
+	case level <= 0:
+		return 0
+	case level <= 4:
+		return 1
+	case level <= 8:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// ZSTDOptions returns the encoder options for a level and window already
+// normalized by ZSTDLevel and ZSTDWindow.
+func ZSTDOptions(level, window int) []zstd.EOption {
+	options := []zstd.EOption{}
+	if level != 0 {
+		options = append(options, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+	}
+	if window != 0 {
+		// The window bounds how far back matches may reach, so a large one
+		// finds repeats between distant pages.
+		options = append(options, zstd.WithWindowSize(window))
+	}
+	return options
+}
+
+// S2Options returns the writer options for a level already normalized by
+// S2Level.
+func S2Options(level int) []s2.WriterOption {
+	options := []s2.WriterOption{s2.WriterConcurrency(1)}
+	switch level {
+	case 2:
+		options = append(options, s2.WriterBetterCompression())
+	case 3:
+		options = append(options, s2.WriterBestCompression())
+	}
+	return options
+}
+
+// NewWriter returns a writer compressing everything written to it into w.
+// level is on the codec-agnostic 1-11 scale, and window is as for
+// ZSTDWindow. Close flushes the compressed stream but does not close w.
+func NewWriter(w io.Writer, codec string, level, window int) (io.WriteCloser, error) {
+	switch codec {
+	case None:
+		return nopCloser{w}, nil
+	case ZSTD:
+		encoder, err := zstd.NewWriter(w, ZSTDOptions(ZSTDLevel(level), ZSTDWindow(window))...)
+		if err != nil {
+			return nil, fmt.Errorf("create zstd writer: %w", err)
+		}
+		return encoder, nil
+	case S2:
+		return s2.NewWriter(w, S2Options(S2Level(level))...), nil
+	default:
+		return nil, fmt.Errorf("unknown compression codec: %s", codec)
+	}
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
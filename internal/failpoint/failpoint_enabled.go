@@ -4,27 +4,54 @@ package failpoint
 
 import (
 	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
 var (
-	mu         sync.RWMutex
+	mu         sync.Mutex
 	failpoints = make(map[string]*Failpoint)
 )
 
 type Failpoint struct {
 	action string
+
+	kind  string // return, sleep or panic
+	msg   string
+	sleep time.Duration
+
+	// percent is the chance, from 0 to 100, that an evaluation fires.
+	percent float64
+	// remaining is how many more times the failpoint may fire, or -1 for no
+	// limit.
+	remaining int
 }
 
 // Enable enables a failpoint with the given action.
 // Supported actions:
 //   - return("error message") - returns an error
 //   - sleep(milliseconds) - sleeps for the given duration
+//   - panic("message") - panics with the message
+//
+// An action may be followed by modifiers, each introduced by "->":
+//   - N% - fires on N percent of evaluations, chosen at random, e.g.
+//     return("flaky")->10%
+//   - count(N) - fires at most N times, after which the failpoint is inert,
+//     e.g. return("flaky")->count(3)
+//
+// Both may be combined, as in sleep(100)->50%->count(2). Enabling a
+// failpoint again resets its count.
 func Enable(name string, action string) error {
+	fp, err := parse(action)
+	if err != nil {
+		return err
+	}
 	mu.Lock()
 	defer mu.Unlock()
-	failpoints[name] = &Failpoint{action: action}
+	failpoints[name] = fp
 	return nil
 }
 
@@ -39,46 +66,120 @@ func Disable(name string) error {
 // Inject checks if a failpoint is enabled and executes its action.
 // Returns an error message if the failpoint should return an error.
 func Inject(name string) (string, bool) {
-	mu.RLock()
-	fp := failpoints[name]
-	mu.RUnlock()
-
+	fp := fire(name)
 	if fp == nil {
 		return "", false
 	}
 
-	// Parse action
-	if len(fp.action) > 8 && fp.action[:7] == "return(" && fp.action[len(fp.action)-1] == ')' {
-		msg := fp.action[8 : len(fp.action)-2] // remove return(" and ")
-		return msg, true
+	switch fp.kind {
+	case "return":
+		return fp.msg, true
+	case "sleep":
+		time.Sleep(fp.sleep)
+	case "panic":
+		panic(fp.msg)
 	}
-
-	if len(fp.action) > 6 && fp.action[:6] == "sleep(" && fp.action[len(fp.action)-1] == ')' {
-		// Parse sleep duration
-		var ms int
-		fmt.Sscanf(fp.action[6:len(fp.action)-1], "%d", &ms)
-		time.Sleep(time.Duration(ms) * time.Millisecond)
-		return "", false
-	}
-
 	return "", false
 }
 
 // InjectStruct checks if a struct-type failpoint is enabled and executes its action.
-// This is for failpoints that just need to trigger an action without returning an error.
+// This is for failpoints that just need to trigger an action without returning an error,
+// so return actions are ignored.
 func InjectStruct(name string) {
-	mu.RLock()
-	fp := failpoints[name]
-	mu.RUnlock()
-
+	fp := fire(name)
 	if fp == nil {
 		return
 	}
 
-	// Parse action (same as Inject but doesn't return anything)
-	if len(fp.action) > 6 && fp.action[:6] == "sleep(" && fp.action[len(fp.action)-1] == ')' {
-		var ms int
-		fmt.Sscanf(fp.action[6:len(fp.action)-1], "%d", &ms)
-		time.Sleep(time.Duration(ms) * time.Millisecond)
+	switch fp.kind {
+	case "sleep":
+		time.Sleep(fp.sleep)
+	case "panic":
+		panic(fp.msg)
+	}
+}
+
+// fire returns the named failpoint if it is enabled and this evaluation
+// should execute its action, counting the evaluation against its limit.
+func fire(name string) *Failpoint {
+	mu.Lock()
+	defer mu.Unlock()
+
+	fp := failpoints[name]
+	if fp == nil || fp.remaining == 0 {
+		return nil
+	}
+	if fp.percent < 100 && rand.Float64()*100 >= fp.percent {
+		return nil
+	}
+	if fp.remaining > 0 {
+		fp.remaining--
+	}
+	return fp
+}
+
+// parse parses an action of the form accepted by Enable. The modifiers are
+// peeled off from the end, so a return or panic message may contain "->".
+func parse(action string) (*Failpoint, error) {
+	fp := &Failpoint{action: action, percent: 100, remaining: -1}
+	term := action
+	var hasPercent, hasCount bool
+	for {
+		i := strings.LastIndex(term, "->")
+		if i < 0 {
+			break
+		}
+		mod := strings.TrimSpace(term[i+2:])
+		if n, ok := strings.CutSuffix(mod, "%"); ok {
+			p, err := strconv.ParseFloat(n, 64)
+			if err != nil || p < 0 || p > 100 || hasPercent {
+				return nil, fmt.Errorf("failpoint: invalid probability %q in %q", mod, action)
+			}
+			fp.percent, hasPercent = p, true
+		} else if n, ok := parseCall(mod, "count"); ok {
+			c, err := strconv.Atoi(n)
+			if err != nil || c < 0 || hasCount {
+				return nil, fmt.Errorf("failpoint: invalid count %q in %q", mod, action)
+			}
+			fp.remaining, hasCount = c, true
+		} else {
+			break
+		}
+		term = term[:i]
+	}
+
+	term = strings.TrimSpace(term)
+	if arg, ok := parseCall(term, "return"); ok {
+		fp.kind, fp.msg = "return", unquote(arg)
+		return fp, nil
+	}
+	if arg, ok := parseCall(term, "panic"); ok {
+		fp.kind, fp.msg = "panic", unquote(arg)
+		return fp, nil
+	}
+	if arg, ok := parseCall(term, "sleep"); ok {
+		ms, err := strconv.Atoi(arg)
+		if err != nil || ms < 0 {
+			return nil, fmt.Errorf("failpoint: invalid sleep duration %q in %q", arg, action)
+		}
+		fp.kind, fp.sleep = "sleep", time.Duration(ms)*time.Millisecond
+		return fp, nil
+	}
+	return nil, fmt.Errorf("failpoint: unsupported action %q", action)
+}
+
+// parseCall returns the argument of a term of the form fn(arg).
+func parseCall(term, fn string) (string, bool) {
+	arg, ok := strings.CutPrefix(term, fn+"(")
+	if !ok {
+		return "", false
+	}
+	return strings.CutSuffix(arg, ")")
+}
+
+func unquote(arg string) string {
+	if s, err := strconv.Unquote(arg); err == nil {
+		return s
 	}
+	return arg
 }
@@ -0,0 +1,100 @@
+//go:build failpoint
+
+package failpoint
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInject_Count(t *testing.T) {
+	require.NoError(t, Enable("count", `return("fail")->count(3)`))
+	t.Cleanup(func() { _ = Disable("count") })
+
+	for i := 0; i < 3; i++ {
+		msg, ok := Inject("count")
+		require.True(t, ok)
+		require.Equal(t, "fail", msg)
+	}
+	_, ok := Inject("count")
+	require.False(t, ok)
+
+	// Enabling the failpoint again resets its count.
+	require.NoError(t, Enable("count", `return("fail")->count(1)`))
+	_, ok = Inject("count")
+	require.True(t, ok)
+}
+
+func TestInject_Probability(t *testing.T) {
+	t.Cleanup(func() { _ = Disable("probability") })
+
+	require.NoError(t, Enable("probability", `return("fail")->0%`))
+	for i := 0; i < 100; i++ {
+		_, ok := Inject("probability")
+		require.False(t, ok)
+	}
+
+	require.NoError(t, Enable("probability", `return("fail")->10%`))
+	var fired int
+	for i := 0; i < 10000; i++ {
+		if _, ok := Inject("probability"); ok {
+			fired++
+		}
+	}
+	require.InDelta(t, 1000, fired, 250)
+
+	t.Log("Combining a probability with a count")
+	require.NoError(t, Enable("probability", `return("fail")->50%->count(2)`))
+	fired = 0
+	for i := 0; i < 1000; i++ {
+		if _, ok := Inject("probability"); ok {
+			fired++
+		}
+	}
+	require.Equal(t, 2, fired)
+}
+
+func TestInject_Panic(t *testing.T) {
+	require.NoError(t, Enable("panic", `panic("boom")`))
+	t.Cleanup(func() { _ = Disable("panic") })
+
+	require.PanicsWithValue(t, "boom", func() { Inject("panic") })
+	require.PanicsWithValue(t, "boom", func() { InjectStruct("panic") })
+}
+
+func TestInjectStruct_SleepCount(t *testing.T) {
+	require.NoError(t, Enable("sleep", `sleep(20)->count(1)`))
+	t.Cleanup(func() { _ = Disable("sleep") })
+
+	start := time.Now()
+	InjectStruct("sleep")
+	require.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+
+	start = time.Now()
+	InjectStruct("sleep")
+	require.Less(t, time.Since(start), 20*time.Millisecond)
+}
+
+func TestEnable_Invalid(t *testing.T) {
+	for _, action := range []string{
+		`return`,
+		`fail("x")`,
+		`sleep(soon)`,
+		`return("x")->101%`,
+		`return("x")->count(-1)`,
+		`return("x")->10%->20%`,
+	} {
+		require.Error(t, Enable("invalid", action), action)
+	}
+	_, ok := Inject("invalid")
+	require.False(t, ok)
+
+	t.Log("Messages may contain the modifier separator")
+	require.NoError(t, Enable("arrow", `return("a->b")->count(1)`))
+	t.Cleanup(func() { _ = Disable("arrow") })
+	msg, ok := Inject("arrow")
+	require.True(t, ok)
+	require.Equal(t, "a->b", msg)
+}
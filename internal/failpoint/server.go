@@ -0,0 +1,112 @@
+//go:build failpoint
+
+package failpoint
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+)
+
+// SocketEnv names the environment variable that, when set to a path, makes
+// processes built with the failpoint tag serve Handler on a Unix socket at
+// that path from startup.
+const SocketEnv = "WITCHBOLT_FAILPOINT_SOCKET"
+
+func init() {
+	path := os.Getenv(SocketEnv)
+	if path == "" {
+		return
+	}
+	if _, err := ListenAndServe(path); err != nil {
+		fmt.Fprintf(os.Stderr, "failpoint: %s: %v\n", SocketEnv, err)
+	}
+}
+
+// List returns the action of every enabled failpoint, keyed by name.
+func List() map[string]string {
+	mu.Lock()
+	defer mu.Unlock()
+	actions := make(map[string]string, len(failpoints))
+	for name, fp := range failpoints {
+		actions[name] = fp.action
+	}
+	return actions
+}
+
+// Handler returns an HTTP handler that controls the failpoints of the
+// process:
+//   - GET / lists the enabled failpoints, one name=action line each
+//   - GET /name returns the action of a failpoint, or 404 if it is disabled
+//   - PUT /name enables a failpoint with the action in the request body
+//   - DELETE /name disables a failpoint
+func Handler() http.Handler {
+	return http.HandlerFunc(serveHTTP)
+}
+
+func serveHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/")
+	switch {
+	case r.Method == http.MethodGet && name == "":
+		actions := List()
+		names := make([]string, 0, len(actions))
+		for name := range actions {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(w, "%s=%s\n", name, actions[name])
+		}
+	case name == "":
+		http.Error(w, "failpoint name required", http.StatusBadRequest)
+	case r.Method == http.MethodGet:
+		action, ok := List()[name]
+		if !ok {
+			http.Error(w, "failpoint not enabled", http.StatusNotFound)
+			return
+		}
+		fmt.Fprintln(w, action)
+	case r.Method == http.MethodPut:
+		action, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := Enable(name, strings.TrimSpace(string(action))); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodDelete:
+		if err := Disable(name); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// ListenAndServe serves Handler on a Unix socket at path, replacing a stale
+// socket file left by an earlier process. It returns once the socket is
+// listening; closing the returned listener stops the server and removes the
+// socket.
+func ListenAndServe(path string) (net.Listener, error) {
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	go http.Serve(l, Handler())
+	return l, nil
+}
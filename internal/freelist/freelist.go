@@ -36,6 +36,14 @@ type Interface interface {
 	// PendingCount returns the number of pending pages.
 	PendingCount() int
 
+	// FreeRuns returns the number of runs of contiguous free pages and the
+	// length in pages of the longest one. Pending pages are not counted.
+	FreeRuns() (runs int, longest int)
+
+	// PendingPageIDs returns the pages freed by the given transaction that
+	// are still pending.
+	PendingPageIDs(txid common.Txid) common.Pgids
+
 	// AddReadonlyTXID adds a given read-only transaction id for pending page tracking.
 	AddReadonlyTXID(txid common.Txid)
 
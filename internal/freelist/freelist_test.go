@@ -38,6 +38,39 @@ func TestFreelist_free_overflow(t *testing.T) {
 	}
 }
 
+// Ensure that the pages freed by a transaction are reported until released.
+func TestFreelist_PendingPageIDs(t *testing.T) {
+	f := newTestFreelist()
+	f.Free(100, common.NewPage(12, 0, 0, 1))
+	f.Free(101, common.NewPage(20, 0, 0, 0))
+	require.Equal(t, common.Pgids{12, 13}, f.PendingPageIDs(100))
+	require.Equal(t, common.Pgids{20}, f.PendingPageIDs(101))
+	require.Empty(t, f.PendingPageIDs(102))
+
+	f.release(100)
+	require.Empty(t, f.PendingPageIDs(100))
+}
+
+// Ensure that the runs of contiguous free pages are counted, ignoring
+// pending pages.
+func TestFreelist_FreeRuns(t *testing.T) {
+	f := newTestFreelist()
+	runs, longest := f.FreeRuns()
+	require.Zero(t, runs)
+	require.Zero(t, longest)
+
+	f.Init(common.Pgids{3, 5, 6, 7, 9, 10, 20})
+	f.Free(100, common.NewPage(11, 0, 0, 4))
+	runs, longest = f.FreeRuns()
+	require.Equal(t, 4, runs)
+	require.Equal(t, 3, longest)
+
+	f.release(100)
+	runs, longest = f.FreeRuns()
+	require.Equal(t, 4, runs)
+	require.Equal(t, 7, longest, "pages 9-15 once 11-15 are released")
+}
+
 // Ensure that double freeing a page is causing a panic
 func TestFreelist_free_double_free_panics(t *testing.T) {
 	f := newTestFreelist()
@@ -44,6 +44,29 @@ func (t *shared) PendingCount() int {
 	return count
 }
 
+func (t *shared) PendingPageIDs(txid common.Txid) common.Pgids {
+	txp := t.pending[txid]
+	if txp == nil {
+		return nil
+	}
+	return append(common.Pgids(nil), txp.ids...)
+}
+
+func (t *shared) FreeRuns() (runs int, longest int) {
+	var length int
+	var prev common.Pgid
+	for _, id := range t.freePageIds() {
+		if runs == 0 || id != prev+1 {
+			runs++
+			length = 0
+		}
+		length++
+		longest = max(longest, length)
+		prev = id
+	}
+	return runs, longest
+}
+
 func (t *shared) Count() int {
 	return t.FreeCount() + t.PendingCount()
 }
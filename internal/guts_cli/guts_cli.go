@@ -32,6 +32,17 @@ func ReadPage(path string, pageID uint64) (*common.Page, []byte, error) {
 	}
 	defer f.Close()
 
+	// Check the Page lies within the file before reading it.
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	size := fi.Size()
+	filePages := uint64(size) / pageSize
+	if pageID >= filePages {
+		return nil, nil, fmt.Errorf("page %d beyond end of file (size=%d)", pageID, size)
+	}
+
 	// Read one block into buffer.
 	buf := make([]byte, pageSize)
 	if n, err := f.ReadAt(buf, int64(pageID*pageSize)); err != nil {
@@ -53,6 +64,9 @@ func ReadPage(path string, pageID uint64) (*common.Page, []byte, error) {
 	if overflowN == 0 {
 		return p, buf, nil
 	}
+	if uint64(overflowN) >= filePages-pageID {
+		return nil, nil, fmt.Errorf("page %d with %d overflow pages extends beyond end of file (size=%d)", pageID, overflowN, size)
+	}
 
 	// Re-read entire Page (with overflow) into buffer.
 	buf = make([]byte, (uint64(overflowN)+1)*pageSize)
@@ -100,7 +114,10 @@ func ReadPageAndHWMSize(path string) (uint64, common.Pgid, error) {
 
 	// Read 4KB chunk.
 	buf := make([]byte, 4096)
-	if _, err := io.ReadFull(f, buf); err != nil {
+	if n, err := io.ReadFull(f, buf); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+			return 0, 0, fmt.Errorf("meta page beyond end of file (size=%d)", n)
+		}
 		return 0, 0, err
 	}
 
@@ -109,6 +126,9 @@ func ReadPageAndHWMSize(path string) (uint64, common.Pgid, error) {
 	if m.Magic() != common.Magic {
 		return 0, 0, fmt.Errorf("the Meta Page has wrong (unexpected) magic")
 	}
+	if m.PageSize() == 0 {
+		return 0, 0, fmt.Errorf("the Meta Page has a zero page size")
+	}
 	return uint64(m.PageSize()), common.Pgid(m.Pgid()), nil
 }
 
@@ -2,6 +2,7 @@ package surgeon
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/delaneyj/witchbolt/internal/common"
 	"github.com/delaneyj/witchbolt/internal/guts_cli"
@@ -112,6 +113,65 @@ func ClearPageElements(path string, pgId common.Pgid, start, end int, abandonFre
 	return false, nil
 }
 
+// ClearPages overwrites the pages in [from, to] with empty leaf pages, which
+// any reference to them can still read. The old contents are not read
+// through guts_cli.ReadPage, as the pages may be too damaged for it. The
+// first return parameter is true, as for ClearPageElements, when the old
+// contents may have referenced pages that are now abandoned: a branch, a
+// bucket entry, an overflow or freelist page, or a page that isn't what its
+// header claims.
+func ClearPages(path string, from, to common.Pgid) (bool, error) {
+	pageSize, hwm, err := guts_cli.ReadPageAndHWMSize(path)
+	if err != nil {
+		return false, fmt.Errorf("ReadPageAndHWMSize failed: %w", err)
+	}
+	if from < 2 || from > to || to >= hwm {
+		return false, fmt.Errorf("the page range [%d, %d] is out of range [2, %d)", from, to, hwm)
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	var abandoned bool
+	buf := make([]byte, pageSize)
+	for id := from; id <= to; id++ {
+		off := int64(id) * int64(pageSize)
+		if _, err := f.ReadAt(buf, off); err != nil {
+			return false, fmt.Errorf("read page %d failed: %w", id, err)
+		}
+		abandoned = abandoned || mayReferencePages(common.LoadPage(buf), id, pageSize)
+
+		clear(buf)
+		p := common.LoadPage(buf)
+		p.SetId(id)
+		p.SetFlags(common.LeafPageFlag)
+		if _, err := f.WriteAt(buf, off); err != nil {
+			return false, fmt.Errorf("write page %d failed: %w", id, err)
+		}
+	}
+	return abandoned, f.Sync()
+}
+
+// mayReferencePages reports whether clearing p, read as page id, may leave
+// pages it referenced unreachable.
+func mayReferencePages(p *common.Page, id common.Pgid, pageSize uint64) bool {
+	if p.Id() != id || !p.IsLeafPage() || p.Overflow() > 0 {
+		return true
+	}
+	if uint64(common.PageHeaderSize)+uint64(p.Count())*uint64(common.LeafPageElementSize) > pageSize {
+		return true
+	}
+	for i := range p.LeafPageElements() {
+		if p.LeafPageElement(uint16(i)).IsBucketEntry() {
+			return true
+		}
+	}
+	return false
+}
+
 func ClearFreelist(path string) error {
 	if err := clearFreelistInMetaPage(path, 0); err != nil {
 		return fmt.Errorf("clearFreelist on meta page 0 failed: %w", err)
@@ -154,3 +214,61 @@ func RevertMetaPage(path string) error {
 		return CopyPage(path, 0, 1)
 	}
 }
+
+// SetMetaTxid rewrites the txid of the given meta page and recomputes its
+// checksum. It refuses a meta page that is invalid to begin with, so that a
+// damaged page is not passed off as valid, and returns the previous txid.
+func SetMetaTxid(path string, metaPageId uint64, txid common.Txid) (common.Txid, error) {
+	if metaPageId > 1 {
+		return 0, fmt.Errorf("invalid meta page id: %d", metaPageId)
+	}
+
+	_, buf, err := guts_cli.ReadPage(path, metaPageId)
+	if err != nil {
+		return 0, fmt.Errorf("ReadPage %d failed: %w", metaPageId, err)
+	}
+
+	meta := common.LoadPageMeta(buf)
+	if err := meta.Validate(); err != nil {
+		return 0, fmt.Errorf("meta page %d is invalid: %w", metaPageId, err)
+	}
+	oldTxid := meta.Txid()
+	meta.SetTxid(txid)
+	meta.SetChecksum(meta.Sum64())
+	if err := meta.Validate(); err != nil {
+		return 0, fmt.Errorf("meta page %d is invalid after setting txid %d: %w", metaPageId, txid, err)
+	}
+
+	if err := guts_cli.WritePage(path, buf); err != nil {
+		return 0, fmt.Errorf("WritePage %d failed: %w", metaPageId, err)
+	}
+
+	return oldTxid, nil
+}
+
+// RecomputeMetaChecksum sets the checksum of the given meta page to the
+// checksum of its current fields, as after a hand edit, and returns the
+// previous and the new checksum.
+func RecomputeMetaChecksum(path string, metaPageId uint64) (uint64, uint64, error) {
+	if metaPageId > 1 {
+		return 0, 0, fmt.Errorf("invalid meta page id: %d", metaPageId)
+	}
+
+	_, buf, err := guts_cli.ReadPage(path, metaPageId)
+	if err != nil {
+		return 0, 0, fmt.Errorf("ReadPage %d failed: %w", metaPageId, err)
+	}
+
+	meta := common.LoadPageMeta(buf)
+	oldChecksum, newChecksum := meta.Checksum(), meta.Sum64()
+	if oldChecksum == newChecksum {
+		return oldChecksum, newChecksum, nil
+	}
+	meta.SetChecksum(newChecksum)
+
+	if err := guts_cli.WritePage(path, buf); err != nil {
+		return 0, 0, fmt.Errorf("WritePage %d failed: %w", metaPageId, err)
+	}
+
+	return oldChecksum, newChecksum, nil
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/delaneyj/witchbolt"
 	"github.com/delaneyj/witchbolt/internal/btesting"
+	"github.com/delaneyj/witchbolt/internal/common"
 	"github.com/delaneyj/witchbolt/internal/surgeon"
 )
 
@@ -55,3 +56,22 @@ func TestRevertMetaPage(t *testing.T) {
 				return nil
 			}))
 }
+
+func TestSetMetaTxid(t *testing.T) {
+	db := btesting.MustCreateDB(t)
+	db.Close()
+
+	oldTxid, err := surgeon.SetMetaTxid(db.Path(), 1, 42)
+	assert.NoError(t, err)
+	assert.Equal(t, common.Txid(1), oldTxid)
+
+	db.MustReopen()
+	db.MustCheck()
+	assert.NoError(t, db.View(func(tx *witchbolt.Tx) error {
+		assert.Equal(t, 42, tx.ID())
+		return nil
+	}))
+
+	_, err = surgeon.SetMetaTxid(db.Path(), 2, 42)
+	assert.ErrorContains(t, err, "invalid meta page id: 2")
+}
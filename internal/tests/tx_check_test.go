@@ -89,3 +89,51 @@ func TestTx_RecursivelyCheckPages_CorruptedLeaf(t *testing.T) {
 	}))
 	require.NoError(t, db.Close())
 }
+
+func TestTx_RecursivelyCheckPages_DuplicateKey(t *testing.T) {
+	db := btesting.MustCreateDB(t)
+	db.ForceDisableStrictMode()
+	require.NoError(t,
+		db.Fill([]byte("data"), 1, 10000,
+			func(tx int, k int) []byte { return []byte(fmt.Sprintf("%04d", k)) },
+			func(tx int, k int) []byte { return make([]byte, 100) },
+		))
+	require.NoError(t, db.Close())
+
+	xray := surgeon.NewXRay(db.Path())
+
+	path1, err := xray.FindPathsToKey([]byte("0451"))
+	require.NoError(t, err, "cannot find page that contains key:'0451'")
+	require.Len(t, path1, 1, "Expected only one page that contains key:'0451'")
+
+	srcPage := path1[0][len(path1[0])-1]
+	p, pbuf, err := guts_cli.ReadPage(db.Path(), uint64(srcPage))
+	require.NoError(t, err)
+	require.Greater(t, p.Count(), uint16(2), "page must hold a few keys")
+	idx := p.Count() / 2
+	duplicated := string(p.LeafPageElement(idx - 1).Key())
+	copy(p.LeafPageElement(idx).Key(), duplicated)
+	require.NoError(t, guts_cli.WritePage(db.Path(), pbuf))
+
+	db.MustReopen()
+	db.ForceDisableStrictMode()
+	require.NoError(t, db.View(func(tx *witchbolt.Tx) error {
+		// Collect all the errors.
+		var errors []error
+		for err := range tx.Check() {
+			errors = append(errors, err)
+		}
+		require.Len(t, errors, 1)
+		require.ErrorContains(t, errors[0], fmt.Sprintf("leaf page(%v) needs to be > (found =) than previous element", srcPage))
+
+		var orderErr *witchbolt.PageKeyOrderError
+		require.ErrorAs(t, errors[0], &orderErr)
+		require.True(t, orderErr.Duplicate)
+		require.Equal(t, uint64(srcPage), orderErr.PageID)
+		require.Equal(t, "leaf", orderErr.PageType)
+		require.Equal(t, int(idx), orderErr.Index)
+		require.Equal(t, duplicated, string(orderErr.Key))
+		return nil
+	}))
+	require.NoError(t, db.Close())
+}
@@ -2,6 +2,7 @@ package witchbolt_test
 
 import (
 	crand "crypto/rand"
+	"fmt"
 	"math/rand"
 	"os"
 	"path/filepath"
@@ -12,6 +13,7 @@ import (
 	"github.com/delaneyj/witchbolt"
 	"github.com/delaneyj/witchbolt/errors"
 	"github.com/delaneyj/witchbolt/internal/btesting"
+	"github.com/delaneyj/witchbolt/internal/common"
 )
 
 func TestTx_MoveBucket(t *testing.T) {
@@ -127,6 +129,39 @@ func TestTx_MoveBucket(t *testing.T) {
 			hasIncompatibleKeyInDst: false,
 			expectedErr:             errors.ErrSameBuckets,
 		},
+		{
+			name:                    "the target is bucketToMove itself",
+			srcBucketPath:           []string{"sb1"},
+			dstBucketPath:           []string{"sb1", "bucketToMove"},
+			bucketToMove:            "bucketToMove",
+			bucketExistInSrc:        false,
+			bucketExistInDst:        false,
+			hasIncompatibleKeyInSrc: false,
+			hasIncompatibleKeyInDst: false,
+			expectedErr:             errors.ErrMoveToDescendant,
+		},
+		{
+			name:                    "the target is a descendant of bucketToMove",
+			srcBucketPath:           []string{"sb1"},
+			dstBucketPath:           []string{"sb1", "bucketToMove", "db1", "db2"},
+			bucketToMove:            "bucketToMove",
+			bucketExistInSrc:        false,
+			bucketExistInDst:        false,
+			hasIncompatibleKeyInSrc: false,
+			hasIncompatibleKeyInDst: false,
+			expectedErr:             errors.ErrMoveToDescendant,
+		},
+		{
+			name:                    "the target is a descendant of a top level bucketToMove",
+			srcBucketPath:           []string{},
+			dstBucketPath:           []string{"bucketToMove", "db1"},
+			bucketToMove:            "bucketToMove",
+			bucketExistInSrc:        false,
+			bucketExistInDst:        false,
+			hasIncompatibleKeyInSrc: false,
+			hasIncompatibleKeyInDst: false,
+			expectedErr:             errors.ErrMoveToDescendant,
+		},
 		{
 			name:                    "both the source and target are the root bucket",
 			srcBucketPath:           []string{},
@@ -216,6 +251,125 @@ func TestTx_MoveBucket(t *testing.T) {
 	}
 }
 
+// Ensure that a bucket moves together with its nested buckets, keeps its root
+// page, and can be moved on again after the database is reopened.
+func TestTx_MoveBucket_Nested(t *testing.T) {
+	db := btesting.MustCreateDBWithOption(t, &witchbolt.Options{PageSize: 4096})
+
+	var rootPage common.Pgid
+	err := db.Update(func(tx *witchbolt.Tx) error {
+		a, err := tx.CreateBucket([]byte("a"))
+		require.NoError(t, err)
+		b, err := a.CreateBucket([]byte("b"))
+		require.NoError(t, err)
+		for i := 0; i < 100; i++ {
+			require.NoError(t, b.Put([]byte(fmt.Sprintf("key-%03d", i)), make([]byte, 100)))
+		}
+		c, err := b.CreateBucket([]byte("c"))
+		require.NoError(t, err)
+		require.NoError(t, c.Put([]byte("deep"), []byte("value")))
+		_, err = c.CreateBucket([]byte("d"))
+		require.NoError(t, err)
+		_, err = tx.CreateBucket([]byte("x"))
+		return err
+	})
+	require.NoError(t, err)
+	err = db.View(func(tx *witchbolt.Tx) error {
+		rootPage = tx.Bucket([]byte("a")).Bucket([]byte("b")).RootPage()
+		return nil
+	})
+	require.NoError(t, err)
+	require.NotZero(t, rootPage, "the moved bucket shouldn't be inline")
+
+	t.Log("Moving a/b under x")
+	err = db.Update(func(tx *witchbolt.Tx) error {
+		return tx.MoveBucket([]byte("b"), tx.Bucket([]byte("a")), tx.Bucket([]byte("x")))
+	})
+	require.NoError(t, err)
+
+	db.MustClose()
+	db.MustReopen()
+	db.MustCheck()
+
+	err = db.View(func(tx *witchbolt.Tx) error {
+		require.Nil(t, tx.Bucket([]byte("a")).Bucket([]byte("b")))
+		b := tx.Bucket([]byte("x")).Bucket([]byte("b"))
+		require.NotNil(t, b)
+		require.Equal(t, rootPage, b.RootPage())
+		require.Equal(t, 103, b.Stats().KeyN, "100 keys and c in b, deep and d in b/c")
+		require.Equal(t, []byte("value"), b.Bucket([]byte("c")).Get([]byte("deep")))
+		require.NotNil(t, b.Bucket([]byte("c")).Bucket([]byte("d")))
+		return nil
+	})
+	require.NoError(t, err)
+
+	t.Log("Moving the nested x/b/c to the top level and x/b back under a")
+	err = db.Update(func(tx *witchbolt.Tx) error {
+		b := tx.Bucket([]byte("x")).Bucket([]byte("b"))
+		require.NoError(t, tx.MoveBucket([]byte("c"), b, nil))
+		return tx.MoveBucket([]byte("b"), tx.Bucket([]byte("x")), tx.Bucket([]byte("a")))
+	})
+	require.NoError(t, err)
+
+	db.MustClose()
+	db.MustReopen()
+	db.MustCheck()
+
+	err = db.View(func(tx *witchbolt.Tx) error {
+		require.Nil(t, tx.Bucket([]byte("x")).Bucket([]byte("b")))
+		b := tx.Bucket([]byte("a")).Bucket([]byte("b"))
+		require.NotNil(t, b)
+		require.Nil(t, b.Bucket([]byte("c")))
+		c := tx.Bucket([]byte("c"))
+		require.NotNil(t, c)
+		require.Equal(t, []byte("value"), c.Get([]byte("deep")))
+		require.NotNil(t, c.Bucket([]byte("d")))
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+// Ensure that changes made to a bucket earlier in the transaction that moves
+// it are kept.
+func TestTx_MoveBucket_ModifiedInSameTx(t *testing.T) {
+	db := btesting.MustCreateDBWithOption(t, &witchbolt.Options{PageSize: 4096})
+
+	err := db.Update(func(tx *witchbolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("src"))
+		require.NoError(t, err)
+		child, err := b.CreateBucket([]byte("child"))
+		require.NoError(t, err)
+		for i := 0; i < 100; i++ {
+			require.NoError(t, child.Put([]byte(fmt.Sprintf("key-%03d", i)), make([]byte, 100)))
+		}
+		_, err = tx.CreateBucket([]byte("dst"))
+		return err
+	})
+	require.NoError(t, err)
+
+	err = db.Update(func(tx *witchbolt.Tx) error {
+		child := tx.Bucket([]byte("src")).Bucket([]byte("child"))
+		require.NoError(t, child.Put([]byte("added"), []byte("value")))
+		require.NoError(t, child.Delete([]byte("key-000")))
+		return tx.MoveBucket([]byte("child"), tx.Bucket([]byte("src")), tx.Bucket([]byte("dst")))
+	})
+	require.NoError(t, err)
+
+	db.MustClose()
+	db.MustReopen()
+	db.MustCheck()
+
+	err = db.View(func(tx *witchbolt.Tx) error {
+		child := tx.Bucket([]byte("dst")).Bucket([]byte("child"))
+		require.NotNil(t, child)
+		require.Equal(t, []byte("value"), child.Get([]byte("added")))
+		require.Nil(t, child.Get([]byte("key-000")))
+		require.Equal(t, 100, child.Stats().KeyN)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
 func TestBucket_MoveBucket_DiffDB(t *testing.T) {
 	srcBucketPath := []string{"sb1", "sb2"}
 	dstBucketPath := []string{"db1", "db2"}
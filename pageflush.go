@@ -1,12 +1,19 @@
 package witchbolt
 
 import (
+	"errors"
 	"fmt"
 	"time"
 )
 
 // PageFlushObserver is notified when a set of dirty pages has been flushed to disk.
 type PageFlushObserver interface {
+	// OnPageFlush runs on the committing goroutine once the commit succeeds
+	// and the writer lock has been released. It therefore delays the return
+	// of Commit, and flushes of successive transactions may be delivered
+	// concurrently and out of TxID order. The observers of one flush are
+	// called one at a time in registration order, share info, and must not
+	// modify it. A returned error is logged; it cannot undo the commit.
 	OnPageFlush(info PageFlushInfo) error
 }
 
@@ -15,18 +22,45 @@ type PageFlushObserverRegistration struct {
 	Observer PageFlushObserver
 	Start    func(*DB) (PageFlushObserver, error)
 	Close    func() error
+	// Filter, if set, is called before OnPageFlush and skips the observer
+	// for flushes it returns false for, for example ones whose Buckets do
+	// not include the bucket of interest. A nil Filter observes every flush.
+	Filter func(PageFlushInfo) bool
+}
+
+// pageFlushObserver is a registered observer and its optional filter.
+type pageFlushObserver struct {
+	observer PageFlushObserver
+	filter   func(PageFlushInfo) bool
+}
+
+// pageFlushCloser detaches an observer started from a registration. It is
+// held by pointer so a detach can find and remove its own entry.
+type pageFlushCloser struct {
+	close func() error
 }
 
 // PageFlushInfo captures metadata about a completed page flush.
 type PageFlushInfo struct {
-	TxID          uint64
-	ParentTxID    uint64
-	DBPath        string
-	PageSize      int
+	TxID       uint64
+	ParentTxID uint64
+	DBPath     string
+	PageSize   int
+	// PageCount is len(Frames), so it counts the meta page too.
 	PageCount     int
 	HighWaterMark uint64
 	Timestamp     time.Time
-	Frames        []PageFrame
+	// Frames holds every page the commit wrote: its meta page first, then
+	// the dirty pages. Writing them over a copy of the database as of
+	// ParentTxID yields the database as of TxID.
+	Frames []PageFrame
+	// Buckets lists, sorted, the top-level buckets the commit created,
+	// deleted, moved or wrote to, including through nested buckets.
+	Buckets []string
+	// FreedPages lists the pages the commit returned to the freelist. They
+	// are pending until no reader can see them, so their on-disk contents are
+	// stale but unchanged.
+	FreedPages []uint64
 }
 
 // PageFrame contains a single page payload for observers.
@@ -36,6 +70,25 @@ type PageFrame struct {
 	Data     []byte
 }
 
+// notifyPageFlush calls each observer whose filter accepts info in turn,
+// returning their errors joined. With stopOnError it returns at the first
+// error instead.
+func notifyPageFlush(observers []pageFlushObserver, info PageFlushInfo, stopOnError bool) error {
+	var errs []error
+	for i, o := range observers {
+		if o.filter != nil && !o.filter(info) {
+			continue
+		}
+		if err := o.observer.OnPageFlush(info); err != nil {
+			errs = append(errs, fmt.Errorf("page flush observer %d (%T): %w", i, o.observer, err))
+			if stopOnError {
+				break
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
 func (f PageFrame) validate(pageSize int) error {
 	expected := int((f.Overflow + 1) * uint32(pageSize))
 	if len(f.Data) != expected {
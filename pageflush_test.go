@@ -0,0 +1,246 @@
+package witchbolt_test
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/delaneyj/witchbolt"
+	berrors "github.com/delaneyj/witchbolt/errors"
+	"github.com/delaneyj/witchbolt/internal/btesting"
+)
+
+type recordingFlushObserver struct {
+	infos []witchbolt.PageFlushInfo
+}
+
+func (o *recordingFlushObserver) OnPageFlush(info witchbolt.PageFlushInfo) error {
+	o.infos = append(o.infos, info)
+	return nil
+}
+
+func TestPageFlushInfo_MetaAndFreedPages(t *testing.T) {
+	db := btesting.MustCreateDB(t)
+	require.NoError(t, db.Update(func(tx *witchbolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("widgets"))
+		if err != nil {
+			return err
+		}
+		for i := 0; i < 1000; i++ {
+			if err := b.Put([]byte(fmt.Sprintf("key-%04d", i)), make([]byte, 100)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+
+	observer := &recordingFlushObserver{}
+	db.RegisterPageFlushObserver(observer)
+	require.NoError(t, db.Update(func(tx *witchbolt.Tx) error {
+		return tx.DeleteBucket([]byte("widgets"))
+	}))
+	require.Len(t, observer.infos, 1)
+	info := observer.infos[0]
+
+	t.Log("The meta page of the commit is the first frame")
+	require.NotEmpty(t, info.Frames)
+	require.Equal(t, info.TxID%2, info.Frames[0].ID)
+	require.Equal(t, len(info.Frames), info.PageCount)
+
+	t.Log("Every page of the deleted bucket is reported as freed")
+	require.Greater(t, len(info.FreedPages), 10)
+	written := make(map[uint64]bool)
+	for _, frame := range info.Frames {
+		written[frame.ID] = true
+	}
+	for _, id := range info.FreedPages {
+		require.Greater(t, id, uint64(1), "meta pages are never freed")
+		require.False(t, written[id], "page %d is both freed and written", id)
+	}
+}
+
+type namedFlushObserver struct {
+	name  string
+	err   error
+	calls *[]string
+}
+
+func (o *namedFlushObserver) OnPageFlush(witchbolt.PageFlushInfo) error {
+	*o.calls = append(*o.calls, o.name)
+	return o.err
+}
+
+func TestDB_PageFlushObservers(t *testing.T) {
+	db := btesting.MustCreateDB(t)
+	var calls []string
+	first := &namedFlushObserver{name: "first", calls: &calls}
+	failing := &namedFlushObserver{name: "failing", err: errors.New("audit unavailable"), calls: &calls}
+	last := &namedFlushObserver{name: "last", calls: &calls}
+	db.RegisterPageFlushObserver(first)
+	db.RegisterPageFlushObserver(failing)
+	db.RegisterPageFlushObserver(last)
+	put := func() {
+		t.Helper()
+		require.NoError(t, db.Update(func(tx *witchbolt.Tx) error {
+			_, err := tx.CreateBucketIfNotExists([]byte("widgets"))
+			return err
+		}))
+	}
+
+	put()
+	require.Equal(t, []string{"first", "failing", "last"}, calls, "an error does not stop later observers")
+
+	calls = nil
+	db.StopPageFlushOnError = true
+	put()
+	require.Equal(t, []string{"first", "failing"}, calls)
+	db.StopPageFlushOnError = false
+
+	calls = nil
+	db.UnregisterPageFlushObserver(failing)
+	put()
+	require.Equal(t, []string{"first", "last"}, calls, "only the matching observer is removed")
+
+	calls = nil
+	db.RegisterPageFlushObserver(first)
+	db.UnregisterPageFlushObserver(first)
+	put()
+	require.Equal(t, []string{"last", "first"}, calls, "the earliest registration is removed")
+}
+
+func TestPageFlushInfo_Buckets(t *testing.T) {
+	audit := &recordingFlushObserver{}
+	all := &recordingFlushObserver{}
+	db := btesting.MustCreateDBWithOption(t, &witchbolt.Options{
+		PageFlushObservers: []witchbolt.PageFlushObserverRegistration{
+			{
+				Observer: audit,
+				Filter: func(info witchbolt.PageFlushInfo) bool {
+					return slices.Contains(info.Buckets, "audit")
+				},
+			},
+			{Observer: all},
+		},
+	})
+	update := func(fn func(tx *witchbolt.Tx) error) []string {
+		t.Helper()
+		require.NoError(t, db.Update(fn))
+		return all.infos[len(all.infos)-1].Buckets
+	}
+
+	require.Equal(t, []string{"audit", "temp"}, update(func(tx *witchbolt.Tx) error {
+		for _, name := range []string{"audit", "temp"} {
+			b, err := tx.CreateBucket([]byte(name))
+			if err != nil {
+				return err
+			}
+			if _, err := b.CreateBucket([]byte("nested")); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+
+	require.Equal(t, []string{"temp"}, update(func(tx *witchbolt.Tx) error {
+		require.NotNil(t, tx.Bucket([]byte("audit")), "reading a bucket does not count")
+		return tx.Bucket([]byte("temp")).Bucket([]byte("nested")).Put([]byte("k"), []byte("v"))
+	}), "a nested write reports its top-level bucket")
+
+	require.Equal(t, []string{"audit", "temp"}, update(func(tx *witchbolt.Tx) error {
+		return tx.MoveBucket([]byte("nested"), tx.Bucket([]byte("temp")), tx.Bucket([]byte("audit")).Bucket([]byte("nested")))
+	}))
+
+	require.Equal(t, []string{"moved", "temp"}, update(func(tx *witchbolt.Tx) error {
+		b, err := tx.Bucket([]byte("temp")).CreateBucket([]byte("moved"))
+		if err != nil {
+			return err
+		}
+		if err := b.Put([]byte("k"), []byte("v")); err != nil {
+			return err
+		}
+		return tx.MoveBucket([]byte("moved"), tx.Bucket([]byte("temp")), nil)
+	}), "moving a bucket to the top level reports it")
+
+	require.Equal(t, []string{"temp"}, update(func(tx *witchbolt.Tx) error {
+		return tx.DeleteBucket([]byte("temp"))
+	}))
+
+	require.Len(t, all.infos, 5)
+	require.Len(t, audit.infos, 2, "the filter skips flushes that do not touch the audit bucket")
+	for _, info := range audit.infos {
+		require.Contains(t, info.Buckets, "audit")
+	}
+}
+
+func TestDB_AttachObserver(t *testing.T) {
+	db := btesting.MustCreateDB(t)
+	put := func() {
+		t.Helper()
+		require.NoError(t, db.Update(func(tx *witchbolt.Tx) error {
+			b, err := tx.CreateBucketIfNotExists([]byte("widgets"))
+			if err != nil {
+				return err
+			}
+			return b.Put([]byte("k"), []byte("v"))
+		}))
+	}
+	attach := func(observer *recordingFlushObserver, closes *int) func() error {
+		t.Helper()
+		detach, err := db.AttachObserver(witchbolt.PageFlushObserverRegistration{
+			Start: func(*witchbolt.DB) (witchbolt.PageFlushObserver, error) { return observer, nil },
+			Close: func() error {
+				*closes++
+				return nil
+			},
+		})
+		require.NoError(t, err)
+		return detach
+	}
+
+	var detachedCloses, attachedCloses int
+	detached, attached := &recordingFlushObserver{}, &recordingFlushObserver{}
+	detach := attach(detached, &detachedCloses)
+	attach(attached, &attachedCloses)
+	put()
+	require.Len(t, detached.infos, 1)
+
+	t.Log("Detaching unregisters the observer and runs Close once")
+	require.NoError(t, detach())
+	require.NoError(t, detach())
+	require.Equal(t, 1, detachedCloses)
+	put()
+	require.Len(t, detached.infos, 1)
+	require.Len(t, attached.infos, 2)
+
+	t.Log("Close detaches the observers still attached")
+	closed := db.DB
+	require.NoError(t, db.Close())
+	require.Equal(t, 1, attachedCloses)
+	_, err := closed.AttachObserver(witchbolt.PageFlushObserverRegistration{Observer: attached})
+	require.ErrorIs(t, err, berrors.ErrDatabaseNotOpen)
+
+	t.Log("A failing Start is returned and registers nothing")
+	db = btesting.MustCreateDB(t)
+	_, err = db.AttachObserver(witchbolt.PageFlushObserverRegistration{
+		Start: func(*witchbolt.DB) (witchbolt.PageFlushObserver, error) { return nil, errors.New("boom") },
+	})
+	require.EqualError(t, err, "boom")
+
+	t.Log("An observer whose database closes while it starts is closed again")
+	var racedCloses int
+	_, err = db.AttachObserver(witchbolt.PageFlushObserverRegistration{
+		Start: func(*witchbolt.DB) (witchbolt.PageFlushObserver, error) {
+			require.NoError(t, db.Close())
+			return attached, nil
+		},
+		Close: func() error {
+			racedCloses++
+			return nil
+		},
+	})
+	require.ErrorIs(t, err, berrors.ErrDatabaseNotOpen)
+	require.Equal(t, 1, racedCloses)
+}
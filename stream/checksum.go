@@ -0,0 +1,38 @@
+package stream
+
+import (
+	"fmt"
+	"hash/crc32"
+	"hash/crc64"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+var (
+	crcTable    = crc64.MakeTable(crc64.ISO)
+	crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// headerValue returns the algorithm as recorded in headers, which leave the
+// default out.
+func (a ChecksumAlgorithm) headerValue() ChecksumAlgorithm {
+	if a == ChecksumCRC64ISO {
+		return ""
+	}
+	return a
+}
+
+// sum returns the checksum of data with the algorithm, an empty one meaning
+// ChecksumCRC64ISO.
+func (a ChecksumAlgorithm) sum(data []byte) (uint64, error) {
+	switch a {
+	case "", ChecksumCRC64ISO:
+		return crc64.Checksum(data, crcTable), nil
+	case ChecksumCRC32C:
+		return uint64(crc32.Checksum(data, crc32cTable)), nil
+	case ChecksumXXHash64:
+		return xxhash.Sum64(data), nil
+	default:
+		return 0, fmt.Errorf("unknown checksum algorithm %q", a)
+	}
+}
@@ -0,0 +1,125 @@
+package stream
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/delaneyj/witchbolt"
+)
+
+func TestChecksumAlgorithmSum(t *testing.T) {
+	data := []byte("segment-payload")
+	sums := make(map[uint64]ChecksumAlgorithm)
+	for _, alg := range []ChecksumAlgorithm{ChecksumCRC64ISO, ChecksumCRC32C, ChecksumXXHash64} {
+		sum, err := alg.sum(data)
+		require.NoError(t, err)
+		require.NotContains(t, sums, sum, "%s and %s agree", alg, sums[sum])
+		sums[sum] = alg
+	}
+
+	def, err := ChecksumAlgorithm("").sum(data)
+	require.NoError(t, err)
+	crc, err := ChecksumCRC64ISO.sum(data)
+	require.NoError(t, err)
+	require.Equal(t, crc, def, "an empty algorithm is CRC64/ISO")
+
+	_, err = ChecksumAlgorithm("md5").sum(data)
+	require.ErrorContains(t, err, "unknown checksum algorithm")
+
+	segment := &Segment{Header: SegmentHeader{TxID: 7, Checksum: crc, ChecksumAlgorithm: ChecksumCRC32C}, Data: data}
+	require.Error(t, verifySegmentChecksum(segment), "the checksum is verified with the header's algorithm")
+	segment.Header.Checksum, err = ChecksumCRC32C.sum(data)
+	require.NoError(t, err)
+	require.NoError(t, verifySegmentChecksum(segment))
+}
+
+func TestChecksumAlgorithmConfig(t *testing.T) {
+	db, err := witchbolt.Open(filepath.Join(t.TempDir(), "db"), 0o600, nil)
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = NewController(db, Config{ShadowDir: t.TempDir(), ChecksumAlgorithm: "md5"}, nil)
+	require.ErrorContains(t, err, `unknown checksum algorithm "md5"`)
+}
+
+// TestChecksumAlgorithmMixedGenerations restarts replication with a different
+// algorithm for every generation, and checks that each artefact records, and
+// verifies with, the algorithm it was written with.
+func TestChecksumAlgorithmMixedGenerations(t *testing.T) {
+	dir := t.TempDir()
+	db, err := witchbolt.Open(filepath.Join(dir, "db"), 0o600, nil)
+	require.NoError(t, err)
+	defer db.Close()
+
+	replicaPath := filepath.Join(dir, "replica")
+	algorithms := []ChecksumAlgorithm{"", ChecksumCRC32C, ChecksumXXHash64, ChecksumCRC64ISO}
+	for i, alg := range algorithms {
+		cfg := Config{
+			ShadowDir:         filepath.Join(dir, "shadow"),
+			ChecksumAlgorithm: alg,
+			Replicas:          []ReplicaConfig{&FileReplicaConfig{Path: replicaPath}},
+		}
+		ctrl, err := Enable(context.Background(), db, cfg)
+		require.NoError(t, err)
+		_, err = ctrl.Snapshot(context.Background())
+		require.NoError(t, err)
+		putKeys(t, db, "widgets", 3*(i+1))
+
+		scrubErrs, err := ctrl.Scrub(context.Background())
+		require.NoError(t, err)
+		require.Empty(t, scrubErrs)
+		require.NoError(t, ctrl.Stop(context.Background()))
+	}
+
+	replica, err := NewFileReplica(&FileReplicaConfig{Path: replicaPath})
+	require.NoError(t, err)
+	result, err := VerifyReplica(context.Background(), replica)
+	require.NoError(t, err)
+	require.True(t, result.OK(), "errors: %v", result.Errors)
+
+	t.Log("Every artefact of every generation verifies with its own algorithm")
+	seen := make(map[ChecksumAlgorithm]int)
+	err = filepath.WalkDir(replicaPath, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		switch {
+		case strings.Contains(path, "snapshot"):
+			snapshot, err := decodeSnapshotFile(data)
+			require.NoError(t, err, path)
+			require.NoError(t, verifySnapshotChecksum(snapshot), path)
+			seen[snapshot.Header.ChecksumAlgorithm]++
+		case strings.Contains(path, "segment"):
+			segment, err := decodeSegmentFile(data)
+			require.NoError(t, err, path)
+			require.NoError(t, verifySegmentChecksum(segment), path)
+			seen[segment.Header.ChecksumAlgorithm]++
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, seen, 3, "CRC64/ISO is recorded as the empty default")
+	require.Contains(t, seen, ChecksumCRC32C)
+	require.Contains(t, seen, ChecksumXXHash64)
+
+	t.Log("The latest generation restores")
+	target := filepath.Join(t.TempDir(), "restored.db")
+	err = RestoreStandalone(context.Background(), Config{
+		Replicas: []ReplicaConfig{&FileReplicaConfig{Path: replicaPath}},
+		Restore:  RestoreConfig{TargetPath: target},
+	})
+	require.NoError(t, err)
+	restored, err := witchbolt.Open(target, 0o600, &witchbolt.Options{ReadOnly: true})
+	require.NoError(t, err)
+	defer restored.Close()
+	require.NoError(t, restored.View(func(tx *witchbolt.Tx) error {
+		require.Equal(t, []byte("value-0011"), tx.Bucket([]byte("widgets")).Get([]byte("key-0011")))
+		return nil
+	}))
+}
@@ -0,0 +1,265 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// shadowReplicaName identifies the controller's shadow directory in
+// SegmentCompaction results.
+const shadowReplicaName = "shadow"
+
+// SegmentCompaction describes one run of segments merged into a single
+// replacement segment.
+type SegmentCompaction struct {
+	// Replica names the replica, or "shadow" for the local shadow directory.
+	Replica    string
+	Generation string
+	// Segments is the number of segments the run held before compaction.
+	Segments  int
+	Pages     int
+	FirstTxID uint64
+	LastTxID  uint64
+}
+
+// CompactSegments merges every contiguous run of segments recorded since the
+// head snapshot of generation into one segment per run, in the shadow
+// directory and on every replica. Later writes to a page replace earlier ones,
+// so the merged segment restores to the same state as the run it replaces. An
+// empty generation selects the current one.
+func (c *Controller) CompactSegments(ctx context.Context, generation string) ([]SegmentCompaction, error) {
+	if generation == "" {
+		c.mu.RLock()
+		generation = c.currentGen
+		c.mu.RUnlock()
+	}
+	if generation == "" {
+		return nil, nil
+	}
+	var compacted []SegmentCompaction
+	var errs []error
+	if !c.config.DisableShadow {
+		runs, err := c.compactShadowSegments(generation)
+		compacted = append(compacted, runs...)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", shadowReplicaName, err))
+		}
+	}
+	runs, err := CompactReplicaSegments(ctx, c.replicas, generation)
+	compacted = append(compacted, runs...)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	return compacted, aggregateErrors("compact segments", errs)
+}
+
+// CompactReplicaSegments merges the contiguous segment runs listed in each
+// replica's state manifest for generation. An empty generation compacts the
+// generation each manifest currently references. Replicas reporting a
+// different generation are skipped.
+func CompactReplicaSegments(ctx context.Context, replicas []Replica, generation string) ([]SegmentCompaction, error) {
+	var compacted []SegmentCompaction
+	var errs []error
+	for _, replica := range replicas {
+		if err := ctx.Err(); err != nil {
+			return compacted, err
+		}
+		runs, err := compactReplica(ctx, replica, generation)
+		compacted = append(compacted, runs...)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", replica.Name(), err))
+		}
+	}
+	return compacted, aggregateErrors("compact segments", errs)
+}
+
+func compactReplica(ctx context.Context, replica Replica, generation string) ([]SegmentCompaction, error) {
+	state, err := replica.LatestState(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if state == nil || state.Generation == "" {
+		return nil, nil
+	}
+	if generation == "" {
+		generation = state.Generation
+	}
+	if state.Generation != generation {
+		return nil, nil
+	}
+	var compacted []SegmentCompaction
+	for _, run := range descriptorRuns(state.Segments) {
+		segments := make([]*Segment, 0, len(run))
+		for _, desc := range run {
+			segment, err := replica.FetchSegment(ctx, generation, desc)
+			if err != nil {
+				return compacted, fmt.Errorf("fetch segment %s: %w", desc.Name, err)
+			}
+			segments = append(segments, segment)
+		}
+		merged, err := mergeSegments(segments)
+		if err != nil {
+			return compacted, err
+		}
+		if err := replica.ReplaceSegments(ctx, generation, merged, run); err != nil {
+			return compacted, err
+		}
+		compacted = append(compacted, newSegmentCompaction(replica.Name(), generation, len(run), merged))
+	}
+	return compacted, nil
+}
+
+// compactShadowSegments merges the shadow segments written after the newest
+// shadow snapshot of generation.
+func (c *Controller) compactShadowSegments(generation string) ([]SegmentCompaction, error) {
+	genDir := filepath.Join(c.shadowDir, generation)
+	var afterTxID uint64
+	entries, err := os.ReadDir(filepath.Join(genDir, "snapshots"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".snapshot.cbor") {
+			continue
+		}
+		if _, txid, err := parseSnapshotObject(entry.Name()); err == nil && txid > afterTxID {
+			afterTxID = txid
+		}
+	}
+	segDir := filepath.Join(genDir, "segments")
+	segments, err := loadSegmentsFromDir(segDir, afterTxID)
+	if err != nil {
+		return nil, err
+	}
+	var compacted []SegmentCompaction
+	for _, run := range segmentRuns(segments) {
+		merged, err := mergeSegments(run)
+		if err != nil {
+			return compacted, err
+		}
+		if err := c.writeSegmentToShadow(generation, merged); err != nil {
+			return compacted, err
+		}
+		for _, segment := range run[:len(run)-1] {
+			name := fmt.Sprintf("%016x.segment.cbor", segment.Header.TxID)
+			if err := os.Remove(filepath.Join(segDir, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return compacted, err
+			}
+		}
+		compacted = append(compacted, newSegmentCompaction(shadowReplicaName, generation, len(run), merged))
+	}
+	return compacted, nil
+}
+
+func newSegmentCompaction(replica, generation string, segments int, merged *Segment) SegmentCompaction {
+	return SegmentCompaction{
+		Replica:    replica,
+		Generation: generation,
+		Segments:   segments,
+		Pages:      len(merged.Pages),
+		FirstTxID:  merged.Header.ParentTxID + 1,
+		LastTxID:   merged.Header.TxID,
+	}
+}
+
+// descriptorRuns splits descs into runs whose TxID ranges follow on from each
+// other, dropping runs too short to be worth merging.
+func descriptorRuns(descs []SegmentDescriptor) [][]SegmentDescriptor {
+	var runs [][]SegmentDescriptor
+	start := 0
+	for i := 1; i <= len(descs); i++ {
+		if i < len(descs) && descs[i].FirstTxID == descs[i-1].LastTxID+1 {
+			continue
+		}
+		if i-start > 1 {
+			runs = append(runs, descs[start:i])
+		}
+		start = i
+	}
+	return runs
+}
+
+// segmentRuns splits segments, sorted by TxID, into runs chained by
+// ParentTxID and recorded for the same database, dropping runs too short to
+// be worth merging.
+func segmentRuns(segments []*Segment) [][]*Segment {
+	var runs [][]*Segment
+	start := 0
+	for i := 1; i <= len(segments); i++ {
+		if i < len(segments) && segments[i].Header.ParentTxID == segments[i-1].Header.TxID &&
+			segments[i].Header.DatabaseID == segments[i-1].Header.DatabaseID {
+			continue
+		}
+		if i-start > 1 {
+			runs = append(runs, segments[start:i])
+		}
+		start = i
+	}
+	return runs
+}
+
+// mergeSegments combines a contiguous run into one segment spanning its TxID
+// range. Only the last frame written to each page is kept, and frames stay in
+// write order so a later overflow frame still overwrites the pages it spans.
+// The result uses the codec of the newest segment in the run.
+func mergeSegments(run []*Segment) (*Segment, error) {
+	var frames []PageFrame
+	// latest maps a page to its newest frame, or to -1 when the newest
+	// event was the page being freed.
+	latest := make(map[uint64]int)
+	for _, segment := range run {
+		if err := populateSegmentPages(segment); err != nil {
+			return nil, err
+		}
+		for _, id := range segment.Header.FreedPages {
+			latest[id] = -1
+		}
+		for _, frame := range segment.Pages {
+			latest[frame.ID] = len(frames)
+			frames = append(frames, frame)
+		}
+	}
+	pages := make([]PageFrame, 0, len(latest))
+	for i, frame := range frames {
+		if latest[frame.ID] == i {
+			pages = append(pages, frame)
+		}
+	}
+	var freed []uint64
+	for id, idx := range latest {
+		if idx < 0 {
+			freed = append(freed, id)
+		}
+	}
+	slices.Sort(freed)
+
+	first, last := run[0], run[len(run)-1]
+	merged := &Segment{Header: last.Header, Pages: pages}
+	merged.Header.ParentTxID = first.Header.ParentTxID
+	merged.Header.PageCount = len(pages)
+	merged.Header.FreedPages = freed
+	payload := buildSegmentPayload(merged)
+	raw, err := encodeSegmentCBORPayload(&payload)
+	if err != nil {
+		return nil, fmt.Errorf("marshal segment payload: %w", err)
+	}
+	settings := compressionSettings{
+		Codec:  last.Header.Compression,
+		Level:  last.Header.CompressionLevel,
+		Window: last.Header.CompressionWindow,
+	}
+	compressed, err := compressBuffer(settings, raw)
+	if err != nil {
+		return nil, fmt.Errorf("compress segment payload: %w", err)
+	}
+	merged.Data = compressed
+	if merged.Header.Checksum, err = merged.Header.ChecksumAlgorithm.sum(compressed); err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
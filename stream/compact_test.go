@@ -0,0 +1,197 @@
+package stream
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+// restoreBytes restores from the replica described by cfg and returns the
+// resulting database file.
+func restoreBytes(t *testing.T, cfg ReplicaConfig) []byte {
+	t.Helper()
+	target := filepath.Join(t.TempDir(), "restored.db")
+	require.NoError(t, RestoreStandalone(context.Background(), Config{
+		Replicas: []ReplicaConfig{cfg},
+		Restore:  RestoreConfig{TargetPath: target},
+	}))
+	data, err := os.ReadFile(target)
+	require.NoError(t, err)
+	return data
+}
+
+func TestControllerCompactSegments(t *testing.T) {
+	db, ctrl, replicaPath := openReplicatedDB(t, Config{})
+	putKeys(t, db, "widgets", 6)
+
+	replica, err := NewFileReplica(&FileReplicaConfig{Path: replicaPath})
+	require.NoError(t, err)
+	before, err := replica.LatestState(context.Background())
+	require.NoError(t, err)
+	require.Greater(t, len(before.Segments), 1)
+	first, last := before.Segments[0].FirstTxID, before.Segments[len(before.Segments)-1].LastTxID
+	wantRestore := restoreBytes(t, &FileReplicaConfig{Path: replicaPath})
+
+	compacted, err := ctrl.CompactSegments(context.Background(), "")
+	require.NoError(t, err)
+	require.Len(t, compacted, 2)
+	for _, run := range compacted {
+		require.Equal(t, before.Generation, run.Generation)
+		require.Equal(t, len(before.Segments), run.Segments)
+		require.Equal(t, first, run.FirstTxID)
+		require.Equal(t, last, run.LastTxID)
+	}
+	require.Equal(t, shadowReplicaName, compacted[0].Replica)
+	require.Equal(t, replicaPath, compacted[1].Replica)
+
+	after, err := replica.LatestState(context.Background())
+	require.NoError(t, err)
+	require.Len(t, after.Segments, 1)
+	require.Equal(t, first, after.Segments[0].FirstTxID)
+	require.Equal(t, last, after.Segments[0].LastTxID)
+	entries, err := os.ReadDir(filepath.Join(replicaPath, before.Generation, "segments"))
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "original segments are deleted")
+	require.Equal(t, wantRestore, restoreBytes(t, &FileReplicaConfig{Path: replicaPath}), "the merged segment restores the same database")
+
+	snapshot, shadowSegments, err := ctrl.localRestoreState()
+	require.NoError(t, err)
+	require.Len(t, shadowSegments, 1, "shadow segments after the snapshot are merged")
+	local := filepath.Join(t.TempDir(), "local.db")
+	require.NoError(t, restoreToTarget(snapshot, shadowSegments, local, RestoreConfig{}))
+	localData, err := os.ReadFile(local)
+	require.NoError(t, err)
+	require.Equal(t, wantRestore, localData)
+
+	// Writes after compaction chain onto the merged segment.
+	putKeys(t, db, "gadgets", 2)
+	after, err = replica.LatestState(context.Background())
+	require.NoError(t, err)
+	require.Len(t, after.Segments, 3)
+	for i := 1; i < len(after.Segments); i++ {
+		require.Equal(t, after.Segments[i-1].LastTxID+1, after.Segments[i].FirstTxID)
+	}
+
+	compacted, err = ctrl.CompactSegments(context.Background(), before.Generation)
+	require.NoError(t, err)
+	require.Len(t, compacted, 2)
+	require.Equal(t, 3, compacted[1].Segments)
+}
+
+func TestCompactReplicaSegmentsRemote(t *testing.T) {
+	testCases := []struct {
+		name string
+		cfg  func(t *testing.T) ReplicaConfig
+	}{
+		{
+			name: "sftp",
+			cfg: func(t *testing.T) ReplicaConfig {
+				srv := newTestSFTPServer(t)
+				return srv.config(func(cfg *SFTPReplicaConfig) {
+					cfg.HostKeyFingerprint = ssh.FingerprintSHA256(srv.hostKey.PublicKey())
+				})
+			},
+		},
+		{
+			name: "webdav",
+			cfg: func(t *testing.T) ReplicaConfig {
+				srv, _ := newTestWebDAVServer(t)
+				return &WebDAVReplicaConfig{URL: srv.URL, User: "replicator", Password: "secret"}
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := tc.cfg(t)
+			db, _, _ := openReplicatedDB(t, Config{Replicas: []ReplicaConfig{cfg}})
+			putKeys(t, db, "widgets", 4)
+			want := restoreBytes(t, cfg)
+
+			replica, err := cfg.buildReplica(context.Background())
+			require.NoError(t, err)
+			defer replica.Close(context.Background())
+			before, err := replica.LatestState(context.Background())
+			require.NoError(t, err)
+
+			compacted, err := CompactReplicaSegments(context.Background(), []Replica{replica}, "")
+			require.NoError(t, err)
+			require.Len(t, compacted, 1)
+			require.Equal(t, len(before.Segments), compacted[0].Segments)
+
+			after, err := replica.LatestState(context.Background())
+			require.NoError(t, err)
+			require.Len(t, after.Segments, 1)
+			require.Equal(t, want, restoreBytes(t, cfg))
+			for _, desc := range before.Segments[:len(before.Segments)-1] {
+				_, err := replica.FetchSegment(context.Background(), before.Generation, desc)
+				require.Error(t, err, "original segment %s is deleted", desc.Name)
+			}
+		})
+	}
+}
+
+func TestMergeSegmentsKeepsLatestFrames(t *testing.T) {
+	run := []*Segment{
+		{Header: SegmentHeader{TxID: 5, ParentTxID: 4, Compression: CompressionNone}, Pages: []PageFrame{
+			{ID: 3, Data: []byte("a-3")},
+			{ID: 7, Data: []byte("a-7")},
+		}},
+		{Header: SegmentHeader{TxID: 6, ParentTxID: 5, Compression: CompressionNone}, Pages: []PageFrame{
+			{ID: 2, Overflow: 1, Data: []byte("b-2b-3")},
+		}},
+		{Header: SegmentHeader{TxID: 7, ParentTxID: 6, Compression: CompressionNone}, Pages: []PageFrame{
+			{ID: 7, Data: []byte("c-7")},
+		}},
+	}
+	merged, err := mergeSegments(run)
+	require.NoError(t, err)
+	require.Equal(t, uint64(4), merged.Header.ParentTxID)
+	require.Equal(t, uint64(7), merged.Header.TxID)
+	require.Equal(t, 3, merged.Header.PageCount)
+	require.Equal(t, []PageFrame{
+		{ID: 3, Data: []byte("a-3")},
+		{ID: 2, Overflow: 1, Data: []byte("b-2b-3")},
+		{ID: 7, Data: []byte("c-7")},
+	}, merged.Pages, "frames stay in write order so overflow frames overwrite older pages")
+	require.NoError(t, verifySegmentChecksum(merged))
+
+	decoded := &Segment{Header: merged.Header, Data: merged.Data}
+	require.NoError(t, populateSegmentPages(decoded))
+	require.Equal(t, merged.Pages, decoded.Pages)
+}
+
+func TestMergeSegmentsTracksFreedPages(t *testing.T) {
+	run := []*Segment{
+		{Header: SegmentHeader{TxID: 5, ParentTxID: 4, Compression: CompressionNone, FreedPages: []uint64{9}}, Pages: []PageFrame{
+			{ID: 3, Data: []byte("a-3")},
+			{ID: 4, Data: []byte("a-4")},
+		}},
+		{Header: SegmentHeader{TxID: 6, ParentTxID: 5, Compression: CompressionNone, FreedPages: []uint64{3, 8}}, Pages: []PageFrame{
+			{ID: 9, Data: []byte("b-9")},
+		}},
+	}
+	merged, err := mergeSegments(run)
+	require.NoError(t, err)
+	require.Equal(t, []PageFrame{
+		{ID: 4, Data: []byte("a-4")},
+		{ID: 9, Data: []byte("b-9")},
+	}, merged.Pages, "a page freed after its write is dropped, one rewritten after its free is kept")
+	require.Equal(t, []uint64{3, 8}, merged.Header.FreedPages)
+}
+
+func TestDescriptorRuns(t *testing.T) {
+	descs := []SegmentDescriptor{
+		{Name: "a", FirstTxID: 2, LastTxID: 2},
+		{Name: "b", FirstTxID: 3, LastTxID: 3},
+		{Name: "c", FirstTxID: 5, LastTxID: 5},
+		{Name: "d", FirstTxID: 7, LastTxID: 7},
+		{Name: "e", FirstTxID: 8, LastTxID: 9},
+		{Name: "f", FirstTxID: 10, LastTxID: 10},
+	}
+	runs := descriptorRuns(descs)
+	require.Equal(t, [][]SegmentDescriptor{descs[0:2], descs[3:6]}, runs, "single segments between gaps are left alone")
+}
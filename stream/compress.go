@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"io"
 
+	"github.com/klauspost/compress/s2"
 	"github.com/klauspost/compress/zstd"
+
+	"github.com/delaneyj/witchbolt/internal/compress"
 )
 
 type compressionSettings struct {
@@ -18,6 +21,9 @@ func normalizeCompressionSettings(settings compressionSettings) compressionSetti
 	switch settings.Codec {
 	case CompressionZSTD:
 		settings.Level = normalizeZSTDLevel(settings.Level)
+		settings.Window = normalizeZSTDWindow(settings.Window)
+	case CompressionS2:
+		settings.Level = normalizeS2Level(settings.Level)
 		settings.Window = 0
 	case CompressionNone:
 		settings.Level = 0
@@ -30,14 +36,15 @@ func normalizeCompressionSettings(settings compressionSettings) compressionSetti
 }
 
 func normalizeZSTDLevel(level int) int {
-	if level <= 0 {
-		return 0
-	}
-	if level > 11 {
-		level = 11
-	}
-	table := []int{0, -5, -3, -1, 0, 3, 6, 9, 12, 15, 18, 22}
-	return table[level]
+	return compress.ZSTDLevel(level)
+}
+
+func normalizeZSTDWindow(window int) int {
+	return compress.ZSTDWindow(window)
+}
+
+func normalizeS2Level(level int) int {
+	return compress.S2Level(level)
 }
 
 func compressBuffer(settings compressionSettings, payload []byte) ([]byte, error) {
@@ -45,36 +52,67 @@ func compressBuffer(settings compressionSettings, payload []byte) ([]byte, error
 	case CompressionNone:
 		return append([]byte(nil), payload...), nil
 	case CompressionZSTD:
-		options := []zstd.EOption{}
-		if settings.Level != 0 {
-			options = append(options, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(settings.Level)))
-		}
-		encoder, err := zstd.NewWriter(nil, options...)
+		encoder, err := zstd.NewWriter(nil, compress.ZSTDOptions(settings.Level, settings.Window)...)
 		if err != nil {
 			return nil, fmt.Errorf("create zstd writer: %w", err)
 		}
 		defer encoder.Close()
 		return encoder.EncodeAll(payload, make([]byte, 0, len(payload))), nil
+	case CompressionS2:
+		var buf bytes.Buffer
+		buf.Grow(len(payload) / 2)
+		writer := s2.NewWriter(&buf, compress.S2Options(settings.Level)...)
+		if _, err := writer.Write(payload); err != nil {
+			writer.Close()
+			return nil, fmt.Errorf("s2 write: %w", err)
+		}
+		if err := writer.Close(); err != nil {
+			return nil, fmt.Errorf("s2 close: %w", err)
+		}
+		return buf.Bytes(), nil
 	default:
 		return nil, fmt.Errorf("unknown compression codec: %s", settings.Codec)
 	}
 }
 
-func decompressBuffer(codec CompressionType, payload []byte) ([]byte, error) {
+// decompressBuffer decodes payload. window is the CompressionWindow recorded
+// in the artefact header; a non-zero value caps the zstd decoder's window
+// allocation at the size the encoder used. Zero keeps the library limit.
+func decompressBuffer(codec CompressionType, window int, payload []byte) ([]byte, error) {
+	if codec == CompressionNone {
+		return payload, nil
+	}
+	reader, err := decompressReader(codec, window, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	out, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("%s read: %w", codec, err)
+	}
+	return out, nil
+}
+
+// decompressReader returns a reader decoding the compressed stream r, for
+// payloads too large to decode in one buffer. window is as for
+// decompressBuffer.
+func decompressReader(codec CompressionType, window int, r io.Reader) (io.ReadCloser, error) {
 	switch codec {
 	case CompressionNone:
-		return payload, nil
+		return io.NopCloser(r), nil
 	case CompressionZSTD:
-		decoder, err := zstd.NewReader(bytes.NewReader(payload))
-		if err != nil {
-			return nil, fmt.Errorf("create zstd reader: %w", err)
+		var options []zstd.DOption
+		if window != 0 {
+			options = append(options, zstd.WithDecoderMaxWindow(uint64(normalizeZSTDWindow(window))))
 		}
-		defer decoder.Close()
-		out, err := io.ReadAll(decoder)
+		decoder, err := zstd.NewReader(r, options...)
 		if err != nil {
-			return nil, fmt.Errorf("zstd read: %w", err)
+			return nil, fmt.Errorf("create zstd reader: %w", err)
 		}
-		return out, nil
+		return decoder.IOReadCloser(), nil
+	case CompressionS2:
+		return io.NopCloser(s2.NewReader(r)), nil
 	default:
 		return nil, fmt.Errorf("unknown compression codec: %s", codec)
 	}
@@ -23,13 +23,25 @@ func BenchmarkCompressZSTD(b *testing.B) {
 	runCompressBench(b, payload, settings)
 }
 
+func BenchmarkCompressS2(b *testing.B) {
+	payload := makePayload(1 << 20)
+	settings := CompressionConfig{Codec: CompressionS2}.normalized()
+	runCompressBench(b, payload, settings)
+}
+
+func BenchmarkCompressS2Better(b *testing.B) {
+	payload := makePayload(1 << 20)
+	settings := CompressionConfig{Codec: CompressionS2, Level: 6}.normalized()
+	runCompressBench(b, payload, settings)
+}
+
 func runCompressBench(b *testing.B, payload []byte, settings compressionSettings) {
 	b.ReportAllocs()
 	compressed, err := compressBuffer(settings, payload)
 	if err != nil {
 		b.Fatalf("warmup compress: %v", err)
 	}
-	if _, err := decompressBuffer(settings.Codec, compressed); err != nil {
+	if _, err := decompressBuffer(settings.Codec, settings.Window, compressed); err != nil {
 		b.Fatalf("warmup decompress: %v", err)
 	}
 	b.ResetTimer()
@@ -38,7 +50,7 @@ func runCompressBench(b *testing.B, payload []byte, settings compressionSettings
 		if err != nil {
 			b.Fatalf("compress failed: %v", err)
 		}
-		if _, err := decompressBuffer(settings.Codec, out); err != nil {
+		if _, err := decompressBuffer(settings.Codec, settings.Window, out); err != nil {
 			b.Fatalf("decompress failed: %v", err)
 		}
 	}
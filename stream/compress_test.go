@@ -2,6 +2,8 @@ package stream
 
 import (
 	"bytes"
+	"fmt"
+	"math/rand"
 	"testing"
 )
 
@@ -10,16 +12,19 @@ func TestCompressionRoundTrip(t *testing.T) {
 	cases := []CompressionConfig{
 		{Codec: CompressionNone},
 		{Codec: CompressionZSTD, Level: 6},
+		{Codec: CompressionS2},
+		{Codec: CompressionS2, Level: 6},
+		{Codec: CompressionS2, Level: 11},
 	}
 	for _, cfg := range cases {
 		cfg := cfg
 		settings := cfg.normalized()
-		t.Run(string(settings.Codec), func(t *testing.T) {
+		t.Run(fmt.Sprintf("%s-%d", settings.Codec, settings.Level), func(t *testing.T) {
 			compressed, err := compressBuffer(settings, payload)
 			if err != nil {
 				t.Fatalf("compress: %v", err)
 			}
-			out, err := decompressBuffer(settings.Codec, compressed)
+			out, err := decompressBuffer(settings.Codec, settings.Window, compressed)
 			if err != nil {
 				t.Fatalf("decompress: %v", err)
 			}
@@ -38,3 +43,55 @@ func TestCompressionLevelNormalization(t *testing.T) {
 		t.Fatalf("expected zstd mapped to -3 got %d", level)
 	}
 }
+
+func TestCompressionS2LevelNormalization(t *testing.T) {
+	cases := map[int]int{0: 0, 1: 1, 4: 1, 5: 2, 8: 2, 9: 3, 15: 3}
+	for in, want := range cases {
+		if got := normalizeS2Level(in); got != want {
+			t.Fatalf("normalizeS2Level(%d): expected %d got %d", in, want, got)
+		}
+	}
+}
+
+func TestCompressionZSTDWindowNormalization(t *testing.T) {
+	cases := map[int]int{0: 0, -1: 0, 1: 1 << 10, 5000: 8 << 10, 32 << 20: 32 << 20, 1 << 40: 1 << 29}
+	for in, want := range cases {
+		settings := CompressionConfig{Codec: CompressionZSTD, Window: in}.normalized()
+		if settings.Window != want {
+			t.Fatalf("window %d: expected %d got %d", in, want, settings.Window)
+		}
+	}
+	if settings := (CompressionConfig{Codec: CompressionS2, Window: 1 << 20}).normalized(); settings.Window != 0 {
+		t.Fatalf("expected s2 window to be ignored, got %d", settings.Window)
+	}
+}
+
+func TestCompressionZSTDLargeWindow(t *testing.T) {
+	// A random block repeated once is incompressible unless the second copy
+	// can reach back past the default 8 MiB window to the first.
+	block := make([]byte, 9<<20)
+	rand.New(rand.NewSource(1)).Read(block)
+	payload := append(append([]byte{}, block...), block...)
+
+	compress := func(cfg CompressionConfig) ([]byte, compressionSettings) {
+		t.Helper()
+		settings := cfg.normalized()
+		compressed, err := compressBuffer(settings, payload)
+		if err != nil {
+			t.Fatalf("compress: %v", err)
+		}
+		return compressed, settings
+	}
+	small, _ := compress(CompressionConfig{Codec: CompressionZSTD, Level: 6})
+	large, settings := compress(CompressionConfig{Codec: CompressionZSTD, Level: 6, Window: 32 << 20})
+	if len(large) >= len(small)*3/4 {
+		t.Fatalf("expected 32 MiB window to beat default: %d vs %d bytes", len(large), len(small))
+	}
+	out, err := decompressBuffer(settings.Codec, settings.Window, large)
+	if err != nil {
+		t.Fatalf("decompress: %v", err)
+	}
+	if !bytes.Equal(payload, out) {
+		t.Fatal("round trip mismatch with 32 MiB window")
+	}
+}
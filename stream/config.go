@@ -4,24 +4,60 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/delaneyj/witchbolt"
 )
 
-// CompressionType enumerates the available wire compression codecs.
-type CompressionType string
+// CompressionType enumerates the available wire compression codecs. It is
+// the type Tx.WriteToCompressed takes, so database copies and snapshots share
+// their codecs.
+type CompressionType = witchbolt.CompressionType
 
 const (
 	// CompressionNone disables compression for segments and snapshots.
-	CompressionNone CompressionType = "none"
+	CompressionNone = witchbolt.CompressionNone
 	// CompressionZSTD compresses payloads with Zstandard.
-	CompressionZSTD CompressionType = "zstd"
+	CompressionZSTD = witchbolt.CompressionZSTD
+	// CompressionS2 compresses payloads with the S2 (Snappy-compatible) framed
+	// stream format, trading ratio for much lower CPU usage than Zstandard.
+	CompressionS2 = witchbolt.CompressionS2
 )
 
 // CompressionConfig defines codec-agnostic tuning parameters.
 type CompressionConfig struct {
-	Codec  CompressionType `json:"codec"`
-	Level  int             `json:"level,omitempty"`
-	Window int             `json:"window,omitempty"`
+	Codec CompressionType `json:"codec"`
+	Level int             `json:"level,omitempty"`
+	// Window sets the zstd match window in bytes, rounded up to a power of
+	// two between 1 KiB and 512 MiB. Larger windows find repeats further
+	// apart, such as duplicated pages in big snapshots, at the cost of
+	// encoder and decoder memory. Zero keeps the library default; other
+	// codecs ignore it.
+	Window int `json:"window,omitempty"`
+}
+
+// SnapshotProfile names a snapshot compression preset, so operators can pick
+// between speed and size without knowing codec levels.
+type SnapshotProfile string
+
+const (
+	// SnapshotProfileFast compresses snapshots with zstd level 2.
+	SnapshotProfileFast SnapshotProfile = "fast"
+	// SnapshotProfileBalanced compresses snapshots with zstd level 6, the
+	// default.
+	SnapshotProfileBalanced SnapshotProfile = "balanced"
+	// SnapshotProfileMax compresses snapshots with zstd level 11.
+	SnapshotProfileMax SnapshotProfile = "max"
+)
+
+var snapshotProfileLevels = map[SnapshotProfile]int{
+	SnapshotProfileFast:     2,
+	SnapshotProfileBalanced: 6,
+	SnapshotProfileMax:      11,
 }
 
 func bytesTrimSpace(b []byte) []byte {
@@ -50,20 +86,91 @@ func (c *CompressionConfig) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// OverflowPolicy enumerates what a commit does when the async replication
+// queue is full.
+type OverflowPolicy string
+
+const (
+	// OverflowBlock waits for the queue to drain a slot, applying back
+	// pressure to writers.
+	OverflowBlock OverflowPolicy = "block"
+	// OverflowDrop discards the segment, logs ErrReplicationQueueFull and
+	// snapshots once the queue catches up, starting a new generation.
+	OverflowDrop OverflowPolicy = "drop"
+)
+
+// ChecksumAlgorithm enumerates the checksums segment and snapshot headers can
+// record for their payload.
+type ChecksumAlgorithm string
+
+const (
+	// ChecksumCRC64ISO is CRC-64 with the ISO polynomial, the default. It is
+	// left out of headers, so artefacts written with it decode the same as
+	// ones written before headers named their algorithm.
+	ChecksumCRC64ISO ChecksumAlgorithm = "crc64-iso"
+	// ChecksumCRC32C is CRC-32 with the Castagnoli polynomial.
+	ChecksumCRC32C ChecksumAlgorithm = "crc32c"
+	// ChecksumXXHash64 is the 64-bit xxHash.
+	ChecksumXXHash64 ChecksumAlgorithm = "xxhash64"
+)
+
 // Config drives the stream controller behaviour.
 type Config struct {
 	// ShadowDir stores local segments and snapshots before upload.
 	ShadowDir string `json:"shadowDir"`
 
+	// DisableShadow skips the local shadow copy of every segment and
+	// snapshot, leaving replicas as the only source for restores. At least
+	// one replica is required when set.
+	DisableShadow bool `json:"disableShadow"`
+
 	// SnapshotInterval controls how frequently full snapshots are taken.
 	SnapshotInterval time.Duration `json:"snapshotInterval"`
 
+	// SnapshotAfterBytes and SnapshotAfterSegments also take a snapshot once
+	// this many compressed segment bytes or segments have been written since
+	// the last one, bounding the chain a restore must replay after a burst of
+	// writes. Whichever of the interval and these thresholds trips first
+	// wins; zero disables a threshold.
+	SnapshotAfterBytes    int64 `json:"snapshotAfterBytes"`
+	SnapshotAfterSegments int   `json:"snapshotAfterSegments"`
+
+	// ChecksumAlgorithm selects the checksum recorded in the headers of new
+	// segments and snapshots. Each header names its algorithm, so verify,
+	// scrub and restore handle replicas whose generations mix algorithms.
+	// The default is ChecksumCRC64ISO.
+	ChecksumAlgorithm ChecksumAlgorithm `json:"checksumAlgorithm"`
+
 	// Retention governs automatic pruning of old artefacts.
 	Retention RetentionConfig `json:"retention"`
 
 	// Compression configures the codec and tuning options for artefacts.
 	Compression CompressionConfig `json:"compression"`
 
+	// SnapshotProfile selects the zstd level snapshots are compressed with:
+	// fast, balanced or max. It applies only when Compression leaves both
+	// codec and level unset, including in replica overrides; segments are
+	// unaffected.
+	SnapshotProfile SnapshotProfile `json:"snapshotProfile"`
+
+	// DeterministicSnapshots makes snapshots of identical database state
+	// byte-identical, for content-addressed storage that deduplicates
+	// artefacts. The header timestamp is zeroed; the snapshot time is kept
+	// in the object name and state manifest instead.
+	DeterministicSnapshots bool `json:"deterministicSnapshots"`
+
+	// AsyncReplication moves uploads off the commit path: OnPageFlush builds
+	// the segment and queues it for a background goroutine, so a slow
+	// replica no longer stalls writers. Stop drains the queue.
+	AsyncReplication bool `json:"asyncReplication"`
+
+	// ReplicationQueueDepth bounds the AsyncReplication queue. Zero uses 64.
+	ReplicationQueueDepth int `json:"replicationQueueDepth"`
+
+	// ReplicationOverflow selects what a commit does when the
+	// AsyncReplication queue is full. The default blocks until a slot frees.
+	ReplicationOverflow OverflowPolicy `json:"replicationOverflow"`
+
 	// Replicas defines zero or more remote destinations.
 	Replicas []ReplicaConfig `json:"replicas"`
 
@@ -74,6 +181,253 @@ type Config struct {
 	// DataLossWindowThreshold controls the alerting threshold for acceptable
 	// replication lag duration. Zero disables warnings.
 	DataLossWindowThreshold time.Duration `json:"dataLossWindowThreshold"`
+
+	// Metrics optionally receives upload, prune and lag measurements.
+	Metrics Metrics `json:"-"`
+
+	// Events optionally receives replication lifecycle callbacks.
+	Events Events `json:"-"`
+}
+
+// UnmarshalJSON decodes a controller configuration. Durations may be given as
+// Go duration strings ("5m") or integer nanoseconds, and each replica entry is
+// decoded according to its "type" field (file, s3, sftp, nats or webdav).
+func (c *Config) UnmarshalJSON(data []byte) error {
+	type alias Config
+	aux := struct {
+		*alias
+		SnapshotInterval        jsonDuration      `json:"snapshotInterval"`
+		DataLossWindowThreshold jsonDuration      `json:"dataLossWindowThreshold"`
+		Replicas                []json.RawMessage `json:"replicas"`
+	}{
+		alias:                   (*alias)(c),
+		SnapshotInterval:        jsonDuration(c.SnapshotInterval),
+		DataLossWindowThreshold: jsonDuration(c.DataLossWindowThreshold),
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	c.SnapshotInterval = time.Duration(aux.SnapshotInterval)
+	c.DataLossWindowThreshold = time.Duration(aux.DataLossWindowThreshold)
+	if aux.Replicas != nil {
+		c.Replicas = make([]ReplicaConfig, 0, len(aux.Replicas))
+		for i, raw := range aux.Replicas {
+			rc, err := decodeReplicaConfig(raw)
+			if err != nil {
+				return fmt.Errorf("replica at index %d: %w", i, err)
+			}
+			c.Replicas = append(c.Replicas, rc)
+		}
+	}
+	return nil
+}
+
+// Defaults the controller applies to zero durations.
+const (
+	defaultSnapshotInterval  = 6 * time.Hour
+	defaultSnapshotRetention = 24 * time.Hour
+)
+
+// ConfigError lists every problem Config.Validate found.
+type ConfigError struct {
+	Problems []error
+}
+
+func (e *ConfigError) Error() string {
+	msgs := make([]string, len(e.Problems))
+	for i, err := range e.Problems {
+		msgs[i] = err.Error()
+	}
+	return "stream: invalid config: " + strings.Join(msgs, "; ")
+}
+
+func (e *ConfigError) Unwrap() []error {
+	return e.Problems
+}
+
+// Validate checks c for mistakes that would otherwise only surface once
+// replication runs: negative durations and counts, unknown codecs, profiles,
+// policies and checksum algorithms, retention shorter than the snapshot
+// interval, and incomplete replica configs. Enable, Observer and
+// RestoreStandalone call it before building any replica. Every problem found
+// is reported in one *ConfigError; fields are named as in the JSON encoding.
+func (c Config) Validate() error {
+	problems := c.settingsProblems()
+	if c.DisableShadow && len(c.Replicas) == 0 {
+		problems = append(problems, fmt.Errorf("at least one replica is required when disableShadow is set"))
+	}
+	for i, rc := range c.Replicas {
+		if rc == nil {
+			problems = append(problems, fmt.Errorf("replicas[%d]: replica config is nil", i))
+			continue
+		}
+		if v, ok := rc.(interface{ validate() error }); ok {
+			if err := v.validate(); err != nil {
+				problems = append(problems, fmt.Errorf("replicas[%d]: %w", i, err))
+			}
+		}
+		if o, ok := rc.(interface{ configOptions() ReplicaOptions }); ok {
+			for _, err := range c.replicaOptionsProblems(o.configOptions()) {
+				problems = append(problems, fmt.Errorf("replicas[%d]: %w", i, err))
+			}
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return &ConfigError{Problems: problems}
+}
+
+// settingsProblems checks the fields of c that don't depend on its replicas.
+// NewController runs it too, for replicas built without Enable.
+func (c Config) settingsProblems() []error {
+	var problems []error
+	negative := func(name string, isNegative bool) {
+		if isNegative {
+			problems = append(problems, fmt.Errorf("%s must not be negative", name))
+		}
+	}
+	negative("snapshotInterval", c.SnapshotInterval < 0)
+	negative("snapshotAfterBytes", c.SnapshotAfterBytes < 0)
+	negative("snapshotAfterSegments", c.SnapshotAfterSegments < 0)
+	negative("replicationQueueDepth", c.ReplicationQueueDepth < 0)
+	negative("dataLossWindowThreshold", c.DataLossWindowThreshold < 0)
+	negative("restore.fetchConcurrency", c.Restore.FetchConcurrency < 0)
+	negative("retention.checkInterval", c.Retention.CheckInterval < 0)
+	problems = append(problems, c.retentionProblems("retention", c.Retention, true)...)
+	problems = append(problems, c.Compression.problems("compression")...)
+
+	if _, ok := snapshotProfileLevels[c.SnapshotProfile]; !ok && c.SnapshotProfile != "" {
+		problems = append(problems, fmt.Errorf("unknown snapshot profile %q", c.SnapshotProfile))
+	}
+	switch c.ReplicationOverflow {
+	case "", OverflowBlock, OverflowDrop:
+	default:
+		problems = append(problems, fmt.Errorf("unknown replication overflow policy %q", c.ReplicationOverflow))
+	}
+	switch c.ChecksumAlgorithm {
+	case "", ChecksumCRC64ISO, ChecksumCRC32C, ChecksumXXHash64:
+	default:
+		problems = append(problems, fmt.Errorf("unknown checksum algorithm %q", c.ChecksumAlgorithm))
+	}
+	return problems
+}
+
+// retentionProblems checks the retention policy r, named name. Only the
+// controller-level policy is compared against the snapshot interval; an
+// unset retention gets a default at Start and isn't compared.
+func (c Config) retentionProblems(name string, r RetentionConfig, checkInterval bool) []error {
+	var problems []error
+	if r.SnapshotInterval < 0 {
+		problems = append(problems, fmt.Errorf("%s.snapshotInterval must not be negative", name))
+	}
+	if r.SnapshotRetention < 0 {
+		problems = append(problems, fmt.Errorf("%s.snapshotRetention must not be negative", name))
+	}
+	if !checkInterval || r.SnapshotRetention <= 0 {
+		return problems
+	}
+	interval := c.SnapshotInterval
+	if interval <= 0 {
+		interval = defaultSnapshotInterval
+	}
+	if r.SnapshotRetention < interval {
+		problems = append(problems, fmt.Errorf("%s.snapshotRetention %s is shorter than the snapshot interval %s", name, r.SnapshotRetention, interval))
+	}
+	return problems
+}
+
+// replicaOptionsProblems checks the per-replica overrides of opts.
+func (c Config) replicaOptionsProblems(opts ReplicaOptions) []error {
+	var problems []error
+	if opts.Compression != nil {
+		problems = append(problems, opts.Compression.problems("compression")...)
+	}
+	if opts.Retention != nil {
+		problems = append(problems, c.retentionProblems("retention", *opts.Retention, false)...)
+	}
+	return problems
+}
+
+// problems checks the codec and tuning of c, named name.
+func (c CompressionConfig) problems(name string) []error {
+	var problems []error
+	switch c.Codec {
+	case "", CompressionNone, CompressionZSTD, CompressionS2:
+	default:
+		problems = append(problems, fmt.Errorf("%s.codec: unknown compression codec %q", name, c.Codec))
+	}
+	if c.Level < 0 {
+		problems = append(problems, fmt.Errorf("%s.level must not be negative", name))
+	}
+	if c.Window < 0 {
+		problems = append(problems, fmt.Errorf("%s.window must not be negative", name))
+	}
+	return problems
+}
+
+// jsonDuration accepts either a duration string or integer nanoseconds.
+type jsonDuration time.Duration
+
+func (d *jsonDuration) UnmarshalJSON(data []byte) error {
+	data = bytesTrimSpace(data)
+	if len(data) == 0 || string(data) == "null" {
+		return nil
+	}
+	if data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		if s == "" {
+			*d = 0
+			return nil
+		}
+		parsed, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		*d = jsonDuration(parsed)
+		return nil
+	}
+	var n int64
+	if err := json.Unmarshal(data, &n); err != nil {
+		return err
+	}
+	*d = jsonDuration(n)
+	return nil
+}
+
+// jsonFileMode accepts either an octal string such as "0775" or an integer.
+type jsonFileMode os.FileMode
+
+func (m *jsonFileMode) UnmarshalJSON(data []byte) error {
+	data = bytesTrimSpace(data)
+	if len(data) == 0 || string(data) == "null" {
+		return nil
+	}
+	if data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		if s == "" {
+			*m = 0
+			return nil
+		}
+		parsed, err := strconv.ParseUint(strings.TrimPrefix(s, "0o"), 8, 32)
+		if err != nil {
+			return fmt.Errorf("invalid file mode %q: %w", s, err)
+		}
+		*m = jsonFileMode(parsed)
+		return nil
+	}
+	var n uint32
+	if err := json.Unmarshal(data, &n); err != nil {
+		return err
+	}
+	*m = jsonFileMode(n)
+	return nil
 }
 
 // RetentionConfig describes snapshot & segment pruning rules.
@@ -89,6 +443,41 @@ type RetentionConfig struct {
 	CheckInterval time.Duration `json:"checkInterval"`
 }
 
+// UnmarshalJSON decodes retention rules, accepting duration strings.
+func (r *RetentionConfig) UnmarshalJSON(data []byte) error {
+	type alias RetentionConfig
+	aux := struct {
+		*alias
+		SnapshotInterval  jsonDuration `json:"snapshotInterval"`
+		SnapshotRetention jsonDuration `json:"snapshotRetention"`
+		CheckInterval     jsonDuration `json:"checkInterval"`
+	}{
+		alias:             (*alias)(r),
+		SnapshotInterval:  jsonDuration(r.SnapshotInterval),
+		SnapshotRetention: jsonDuration(r.SnapshotRetention),
+		CheckInterval:     jsonDuration(r.CheckInterval),
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	r.SnapshotInterval = time.Duration(aux.SnapshotInterval)
+	r.SnapshotRetention = time.Duration(aux.SnapshotRetention)
+	r.CheckInterval = time.Duration(aux.CheckInterval)
+	return nil
+}
+
+// inherit fills the zero fields of r from base.
+func (r RetentionConfig) inherit(base RetentionConfig) RetentionConfig {
+	if r.SnapshotInterval <= 0 {
+		r.SnapshotInterval = base.SnapshotInterval
+	}
+	if r.SnapshotRetention <= 0 {
+		r.SnapshotRetention = base.SnapshotRetention
+	}
+	r.CheckInterval = base.CheckInterval
+	return r
+}
+
 // RestoreConfig instructs the controller how and when to restore.
 type RestoreConfig struct {
 	// Enabled toggles automatic restores.
@@ -99,6 +488,62 @@ type RestoreConfig struct {
 
 	// TempDir controls where intermediate restore files live.
 	TempDir string `json:"tempDir"`
+
+	// AllowGaps restores even when a segment is missing between the snapshot
+	// and the newest segment, logging a warning instead of failing with a
+	// *SegmentGapError. The restored database is likely to be corrupt.
+	AllowGaps bool `json:"allowGaps"`
+
+	// FetchConcurrency bounds how many segments are fetched from a replica
+	// in parallel. Zero uses 8.
+	FetchConcurrency int `json:"fetchConcurrency"`
+
+	// Plan, if set, is called once before anything is written with the
+	// snapshot's page count and the number of segments to apply, so progress
+	// can be reported against the whole restore.
+	Plan func(snapshotPages uint64, segments int) `json:"-"`
+
+	// SnapshotProgress, if set, is called while the snapshot is decompressed
+	// into the restore target with the compressed bytes consumed so far and
+	// the snapshot's compressed size.
+	SnapshotProgress func(read, total int64) `json:"-"`
+
+	// Progress, if set, is called after each segment is applied with the
+	// number of segments applied so far, including any a resumed restore
+	// skipped, the number to apply and the TxID just reached.
+	Progress func(applied, total int, txid uint64) `json:"-"`
+}
+
+// ReplicaOptions holds settings shared by every replica backend. Built-in
+// replica configs embed it, so its fields sit alongside backend fields.
+type ReplicaOptions struct {
+	// Compression overrides Config.Compression for artefacts written to this
+	// replica. Nil inherits the controller default.
+	Compression *CompressionConfig `json:"compression,omitempty"`
+
+	// Retention overrides Config.Retention when pruning this replica. Zero
+	// fields inherit the controller value; CheckInterval is controller-wide
+	// and ignored here. Nil inherits the controller policy.
+	Retention *RetentionConfig `json:"retention,omitempty"`
+}
+
+// configOptions exposes the options of the replica configs that embed
+// ReplicaOptions to Config.Validate.
+func (o ReplicaOptions) configOptions() ReplicaOptions {
+	return o
+}
+
+// replicaOptionsProvider is implemented by built-in replicas to expose the
+// ReplicaOptions they were constructed with.
+type replicaOptionsProvider interface {
+	replicaOptions() ReplicaOptions
+}
+
+func replicaOptionsOf(replica Replica) ReplicaOptions {
+	if p, ok := replica.(replicaOptionsProvider); ok {
+		return p.replicaOptions()
+	}
+	return ReplicaOptions{}
 }
 
 // ReplicaConfig describes a backend-specific replica configuration.
@@ -106,6 +551,18 @@ type ReplicaConfig interface {
 	buildReplica(ctx context.Context) (Replica, error)
 }
 
+// withSnapshotProfile fills in the codec and level of profile unless c sets
+// either explicitly.
+func (c CompressionConfig) withSnapshotProfile(profile SnapshotProfile) CompressionConfig {
+	level, ok := snapshotProfileLevels[profile]
+	if !ok || c.Codec != "" || c.Level != 0 {
+		return c
+	}
+	c.Codec = CompressionZSTD
+	c.Level = level
+	return c
+}
+
 func (c CompressionConfig) normalized() compressionSettings {
 	if c.Codec == "" {
 		c.Codec = CompressionZSTD
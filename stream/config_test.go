@@ -0,0 +1,244 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/delaneyj/witchbolt"
+)
+
+func TestConfigUnmarshalJSON(t *testing.T) {
+	data := []byte(`{
+		"shadowDir": "/var/lib/stream",
+		"snapshotInterval": "5m",
+		"dataLossWindowThreshold": 1000000000,
+		"retention": {"snapshotRetention": "24h", "checkInterval": "1h"},
+		"compression": "s2",
+		"replicas": [
+			{"type": "file", "path": "/backups", "compression": {"codec": "none"}},
+			{"type": "s3", "bucket": "example", "prefix": "db", "retention": {"snapshotRetention": "8760h"}}
+		]
+	}`)
+	var cfg Config
+	require.NoError(t, json.Unmarshal(data, &cfg))
+	require.Equal(t, "/var/lib/stream", cfg.ShadowDir)
+	require.Equal(t, 5*time.Minute, cfg.SnapshotInterval)
+	require.Equal(t, time.Second, cfg.DataLossWindowThreshold)
+	require.Equal(t, 24*time.Hour, cfg.Retention.SnapshotRetention)
+	require.Equal(t, time.Hour, cfg.Retention.CheckInterval)
+	require.Equal(t, CompressionS2, cfg.Compression.Codec)
+	require.Len(t, cfg.Replicas, 2)
+
+	file, ok := cfg.Replicas[0].(*FileReplicaConfig)
+	require.True(t, ok)
+	require.Equal(t, "/backups", file.Path)
+	require.NotNil(t, file.Compression)
+	require.Equal(t, CompressionNone, file.Compression.Codec)
+
+	s3, ok := cfg.Replicas[1].(*S3CompatibleConfig)
+	require.True(t, ok)
+	require.Equal(t, "example", s3.Bucket)
+	require.Nil(t, s3.Compression)
+	require.Nil(t, file.Retention)
+	require.NotNil(t, s3.Retention)
+	require.Equal(t, 8760*time.Hour, s3.Retention.SnapshotRetention)
+}
+
+func TestConfigUnmarshalJSONUnknownReplica(t *testing.T) {
+	var cfg Config
+	err := json.Unmarshal([]byte(`{"replicas": [{"type": "ftp"}]}`), &cfg)
+	require.ErrorContains(t, err, `unknown replica type "ftp"`)
+}
+
+func TestReplicaConfigFileModes(t *testing.T) {
+	var cfg Config
+	require.NoError(t, json.Unmarshal([]byte(`{"replicas": [
+		{"type": "file", "path": "/backups", "dirMode": "0775", "fileMode": 432},
+		{"type": "sftp", "host": "nas", "dirMode": "0o770", "fileMode": "660"}
+	]}`), &cfg))
+	file := cfg.Replicas[0].(*FileReplicaConfig)
+	require.Equal(t, "/backups", file.Path)
+	require.Equal(t, os.FileMode(0o775), file.DirMode)
+	require.Equal(t, os.FileMode(0o660), file.FileMode)
+	sftp := cfg.Replicas[1].(*SFTPReplicaConfig)
+	require.Equal(t, "nas", sftp.Host)
+	require.Equal(t, os.FileMode(0o770), sftp.DirMode)
+	require.Equal(t, os.FileMode(0o660), sftp.FileMode)
+
+	err := json.Unmarshal([]byte(`{"replicas": [{"type": "file", "dirMode": "rwx"}]}`), &cfg)
+	require.ErrorContains(t, err, `invalid file mode "rwx"`)
+}
+
+func TestSnapshotProfileCompression(t *testing.T) {
+	zstdLevel := func(level int) compressionSettings {
+		return CompressionConfig{Codec: CompressionZSTD, Level: level}.normalized()
+	}
+	cases := []struct {
+		name        string
+		compression CompressionConfig
+		profile     SnapshotProfile
+		want        compressionSettings
+	}{
+		{name: "default", want: zstdLevel(6)},
+		{name: "fast", profile: SnapshotProfileFast, want: zstdLevel(2)},
+		{name: "balanced", profile: SnapshotProfileBalanced, want: zstdLevel(6)},
+		{name: "max", profile: SnapshotProfileMax, want: zstdLevel(11)},
+		{
+			name:        "window kept",
+			compression: CompressionConfig{Window: 1 << 20},
+			profile:     SnapshotProfileMax,
+			want:        CompressionConfig{Codec: CompressionZSTD, Level: 11, Window: 1 << 20}.normalized(),
+		},
+		{
+			name:        "explicit codec wins",
+			compression: CompressionConfig{Codec: CompressionS2},
+			profile:     SnapshotProfileMax,
+			want:        CompressionConfig{Codec: CompressionS2}.normalized(),
+		},
+		{
+			name:        "explicit level wins",
+			compression: CompressionConfig{Level: 9},
+			profile:     SnapshotProfileFast,
+			want:        zstdLevel(9),
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.want, tc.compression.withSnapshotProfile(tc.profile).normalized())
+		})
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	valid := Config{
+		SnapshotInterval: time.Hour,
+		Retention:        RetentionConfig{SnapshotRetention: 24 * time.Hour},
+		Compression:      CompressionConfig{Codec: CompressionS2},
+		Replicas: []ReplicaConfig{
+			&FileReplicaConfig{Path: "/backups"},
+			&S3CompatibleConfig{Bucket: "example"},
+			&SFTPReplicaConfig{Host: "backup", User: "replicator", KeyPath: "/key"},
+			&NATSReplicaConfig{Bucket: "backups"},
+			&WebDAVReplicaConfig{URL: "https://dav.example.com"},
+		},
+	}
+	require.NoError(t, valid.Validate())
+	require.NoError(t, Config{}.Validate(), "the zero config uses defaults")
+
+	hot := valid
+	hot.Replicas = []ReplicaConfig{&FileReplicaConfig{Path: "/hot", ReplicaOptions: ReplicaOptions{Retention: &RetentionConfig{SnapshotRetention: time.Millisecond}}}}
+	require.NoError(t, hot.Validate(), "a replica's retention isn't compared against the snapshot interval")
+
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		want   string
+	}{
+		{
+			name:   "negative interval",
+			modify: func(c *Config) { c.SnapshotInterval = -time.Minute },
+			want:   "snapshotInterval must not be negative",
+		},
+		{
+			name:   "retention shorter than the interval",
+			modify: func(c *Config) { c.Retention.SnapshotRetention = time.Minute },
+			want:   "retention.snapshotRetention 1m0s is shorter than the snapshot interval 1h0m0s",
+		},
+		{
+			name: "retention shorter than the default interval",
+			modify: func(c *Config) {
+				c.SnapshotInterval = 0
+				c.Retention.SnapshotRetention = time.Hour
+			},
+			want: "the snapshot interval 6h0m0s",
+		},
+		{
+			name:   "unknown codec",
+			modify: func(c *Config) { c.Compression.Codec = "lz4" },
+			want:   `compression.codec: unknown compression codec "lz4"`,
+		},
+		{
+			name:   "unknown overflow policy",
+			modify: func(c *Config) { c.ReplicationOverflow = "spill" },
+			want:   `unknown replication overflow policy "spill"`,
+		},
+		{
+			name:   "nil replica",
+			modify: func(c *Config) { c.Replicas = append(c.Replicas, nil) },
+			want:   "replicas[5]: replica config is nil",
+		},
+		{
+			name:   "incomplete replica",
+			modify: func(c *Config) { c.Replicas[2] = &SFTPReplicaConfig{Host: "backup"} },
+			want:   "replicas[2]: sftp user is required",
+		},
+		{
+			name: "replica override",
+			modify: func(c *Config) {
+				c.Replicas[0] = &FileReplicaConfig{Path: "/backups", ReplicaOptions: ReplicaOptions{Compression: &CompressionConfig{Window: -1}}}
+			},
+			want: "replicas[0]: compression.window must not be negative",
+		},
+		{
+			name: "no replica without shadow",
+			modify: func(c *Config) {
+				c.DisableShadow = true
+				c.Replicas = nil
+			},
+			want: "at least one replica is required",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := valid
+			cfg.Replicas = slices.Clone(valid.Replicas)
+			tc.modify(&cfg)
+			err := cfg.Validate()
+			var cfgErr *ConfigError
+			require.ErrorAs(t, err, &cfgErr)
+			require.Len(t, cfgErr.Problems, 1)
+			require.ErrorContains(t, err, tc.want)
+		})
+	}
+
+	t.Log("Every problem is reported at once")
+	cfg := valid
+	cfg.SnapshotInterval = -1
+	cfg.ReplicationQueueDepth = -1
+	cfg.Replicas = []ReplicaConfig{&FileReplicaConfig{}, &S3CompatibleConfig{Bucket: "example", PartSize: 1}}
+	err := cfg.Validate()
+	var cfgErr *ConfigError
+	require.ErrorAs(t, err, &cfgErr)
+	require.Len(t, cfgErr.Problems, 4)
+	require.Equal(t, "stream: invalid config: snapshotInterval must not be negative; replicationQueueDepth must not be negative; "+
+		"replicas[0]: file replica path is empty; replicas[1]: partSize must be at least 5 MiB", err.Error())
+}
+
+func TestEnableValidatesConfig(t *testing.T) {
+	db, err := witchbolt.Open(filepath.Join(t.TempDir(), "db"), 0o600, nil)
+	require.NoError(t, err)
+	defer db.Close()
+
+	replicaPath := filepath.Join(t.TempDir(), "replica")
+	_, err = Enable(context.Background(), db, Config{
+		ShadowDir:        t.TempDir(),
+		SnapshotInterval: -time.Second,
+		Replicas:         []ReplicaConfig{&FileReplicaConfig{Path: replicaPath}},
+	})
+	require.ErrorContains(t, err, "snapshotInterval must not be negative")
+	require.NoDirExists(t, replicaPath, "no replica is built from an invalid config")
+
+	err = RestoreStandalone(context.Background(), Config{
+		Replicas: []ReplicaConfig{&FileReplicaConfig{Path: replicaPath}},
+		Restore:  RestoreConfig{TargetPath: filepath.Join(t.TempDir(), "db"), FetchConcurrency: -1},
+	})
+	require.ErrorContains(t, err, "restore.fetchConcurrency must not be negative")
+	require.NoDirExists(t, replicaPath)
+}
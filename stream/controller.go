@@ -6,7 +6,6 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"fmt"
-	"hash/crc64"
 	"os"
 	"path/filepath"
 	"strings"
@@ -25,51 +24,136 @@ type Controller struct {
 	shadowDir   string
 	compression compressionSettings
 
+	// replicaCompression holds the effective codec for each entry in
+	// replicas, honouring ReplicaOptions.Compression overrides.
+	replicaCompression []compressionSettings
+
+	// snapshotCompression and replicaSnapshotCompression are their snapshot
+	// counterparts, with Config.SnapshotProfile applied.
+	snapshotCompression        compressionSettings
+	replicaSnapshotCompression []compressionSettings
+
+	// databaseID is recorded in every segment and snapshot header so
+	// restores can reject artefacts of another database.
+	databaseID string
+
+	metrics Metrics
+	events  Events
+
 	mu              sync.RWMutex
 	currentGen      string
 	lastTxID        uint64
 	lastSnapshot    time.Time
 	lastReplication time.Time
-	replicaLag      map[string]time.Time
+	// bytesSinceSnapshot and segmentsSinceSnapshot count the segment data
+	// written since lastSnapshot, for SnapshotAfterBytes and
+	// SnapshotAfterSegments.
+	bytesSinceSnapshot    int64
+	segmentsSinceSnapshot int
+	// pendingSince is when the segment the replication loop is uploading
+	// was queued, and zero while the loop is idle.
+	pendingSince time.Time
+	// resnapshot forces the next maybeSnapshot after a queued segment was
+	// dropped.
+	resnapshot bool
+	replicaLag map[string]time.Time
+
+	// queue carries segments to the replication loop when AsyncReplication
+	// is set. queueMu is held for reading while sending, so Stop can mark
+	// the queue closed once no send is in flight.
+	queue       chan queuedSegment
+	queueMu     sync.RWMutex
+	queueClosed bool
 
 	retentionCh chan struct{}
 	closeCh     chan struct{}
 	wg          sync.WaitGroup
 }
 
-var crcTable = crc64.MakeTable(crc64.ISO)
-
 // NewController creates a stream controller for the provided database.
 func NewController(db *witchbolt.DB, cfg Config, replicas []Replica) (*Controller, error) {
 	if db == nil {
 		return nil, fmt.Errorf("db is nil")
 	}
-	if cfg.ShadowDir == "" {
-		cfg.ShadowDir = filepath.Join(filepath.Dir(db.Path()), "stream")
+	if cfg.DisableShadow {
+		if len(replicas) == 0 {
+			return nil, fmt.Errorf("stream: at least one replica is required when the shadow dir is disabled")
+		}
+		cfg.ShadowDir = ""
+	} else {
+		if cfg.ShadowDir == "" {
+			cfg.ShadowDir = filepath.Join(filepath.Dir(db.Path()), "stream")
+		}
+		if err := os.MkdirAll(cfg.ShadowDir, 0o755); err != nil {
+			return nil, fmt.Errorf("create shadow dir: %w", err)
+		}
 	}
-	if err := os.MkdirAll(cfg.ShadowDir, 0o755); err != nil {
-		return nil, fmt.Errorf("create shadow dir: %w", err)
+	if problems := cfg.settingsProblems(); len(problems) > 0 {
+		return nil, &ConfigError{Problems: problems}
 	}
+	snapshotCompression := cfg.Compression.withSnapshotProfile(cfg.SnapshotProfile).normalized()
 	compression := cfg.Compression.normalized()
 	cfg.Compression.Codec = compression.Codec
 	cfg.Compression.Level = compression.Level
 	cfg.Compression.Window = compression.Window
 
+	replicaCompression := make([]compressionSettings, len(replicas))
+	replicaSnapshotCompression := make([]compressionSettings, len(replicas))
+	for i, replica := range replicas {
+		replicaCompression[i] = compression
+		replicaSnapshotCompression[i] = snapshotCompression
+		if override := replicaOptionsOf(replica).Compression; override != nil {
+			replicaCompression[i] = override.normalized()
+			replicaSnapshotCompression[i] = override.withSnapshotProfile(cfg.SnapshotProfile).normalized()
+		}
+	}
+
+	databaseID, err := loadDatabaseID(db)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics := cfg.Metrics
+	if metrics == nil {
+		metrics = noopMetrics{}
+	}
+	events := cfg.Events
+	if events == nil {
+		events = NopEvents{}
+	}
+	var queue chan queuedSegment
+	if cfg.AsyncReplication {
+		if cfg.ReplicationQueueDepth <= 0 {
+			cfg.ReplicationQueueDepth = defaultReplicationQueueDepth
+		}
+		queue = make(chan queuedSegment, cfg.ReplicationQueueDepth)
+	}
+
 	ctrl := &Controller{
-		db:          db,
-		config:      cfg,
-		replicas:    replicas,
-		shadowDir:   cfg.ShadowDir,
-		compression: compression,
-		replicaLag:  make(map[string]time.Time),
-		retentionCh: make(chan struct{}, 1),
-		closeCh:     make(chan struct{}),
+		db:                         db,
+		config:                     cfg,
+		replicas:                   replicas,
+		shadowDir:                  cfg.ShadowDir,
+		compression:                compression,
+		replicaCompression:         replicaCompression,
+		snapshotCompression:        snapshotCompression,
+		replicaSnapshotCompression: replicaSnapshotCompression,
+		databaseID:                 databaseID,
+		metrics:                    metrics,
+		events:                     events,
+		replicaLag:                 make(map[string]time.Time),
+		retentionCh:                make(chan struct{}, 1),
+		closeCh:                    make(chan struct{}),
+		queue:                      queue,
 	}
 	return ctrl, nil
 }
 
 // Enable constructs and starts a controller based on the provided configuration.
 func Enable(ctx context.Context, db *witchbolt.DB, cfg Config) (*Controller, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
 	replicas, err := BuildReplicas(ctx, cfg)
 	if err != nil {
 		return nil, err
@@ -89,13 +173,16 @@ func Enable(ctx context.Context, db *witchbolt.DB, cfg Config) (*Controller, err
 // Start attaches the controller to the DB and launches background tasks.
 func (c *Controller) Start(ctx context.Context) error {
 	if c.config.SnapshotInterval <= 0 {
-		c.config.SnapshotInterval = 6 * time.Hour
+		c.config.SnapshotInterval = defaultSnapshotInterval
 	}
 	if c.config.Retention.CheckInterval <= 0 {
 		c.config.Retention.CheckInterval = time.Hour
 	}
 	if c.config.Retention.SnapshotRetention <= 0 {
-		c.config.Retention.SnapshotRetention = 24 * time.Hour
+		c.config.Retention.SnapshotRetention = defaultSnapshotRetention
+	}
+	if err := c.checkReplicas(ctx); err != nil {
+		return err
 	}
 	if c.config.Restore.Enabled {
 		if err := c.ensureRestored(ctx); err != nil {
@@ -104,11 +191,31 @@ func (c *Controller) Start(ctx context.Context) error {
 	}
 	c.wg.Add(1)
 	go c.retentionLoop()
+	if c.queue != nil {
+		c.wg.Add(1)
+		go c.replicationLoop()
+	}
 	return nil
 }
 
-// Stop detaches the controller and waits for background tasks to finish.
+// checkReplicas runs HealthCheck on every replica so misconfiguration is
+// reported by Start rather than by the first flush.
+func (c *Controller) checkReplicas(ctx context.Context) error {
+	var errs []error
+	for _, replica := range c.replicas {
+		if err := replica.HealthCheck(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", replica.Name(), err))
+		}
+	}
+	return aggregateErrors("replica health check", errs)
+}
+
+// Stop detaches the controller and waits for background tasks to finish,
+// uploading any segments still queued by AsyncReplication.
 func (c *Controller) Stop(ctx context.Context) error {
+	c.queueMu.Lock()
+	c.queueClosed = true
+	c.queueMu.Unlock()
 	close(c.closeCh)
 	c.wg.Wait()
 	c.db.UnregisterPageFlushObserver(c)
@@ -130,6 +237,9 @@ func (c *Controller) OnPageFlush(info witchbolt.PageFlushInfo) error {
 	if err != nil {
 		return err
 	}
+	if c.queue != nil {
+		return c.enqueueSegment(info, segment)
+	}
 	return c.persistSegment(info, segment)
 }
 
@@ -155,11 +265,14 @@ func (c *Controller) buildSegment(info witchbolt.PageFlushInfo) (*Segment, error
 		ParentTxID:        info.ParentTxID,
 		PageCount:         len(frames),
 		PageSize:          info.PageSize,
+		ChecksumAlgorithm: c.config.ChecksumAlgorithm.headerValue(),
 		Compression:       c.compression.Codec,
 		CompressionLevel:  c.compression.Level,
 		CompressionWindow: c.compression.Window,
 		CreatedAt:         createdAt,
 		HighWaterMark:     info.HighWaterMark,
+		FreedPages:        append([]uint64(nil), info.FreedPages...),
+		DatabaseID:        c.databaseID,
 	}
 
 	segment := &Segment{
@@ -176,7 +289,9 @@ func (c *Controller) buildSegment(info witchbolt.PageFlushInfo) (*Segment, error
 		return nil, fmt.Errorf("compress segment payload: %w", err)
 	}
 	segment.Data = compressed
-	segment.Header.Checksum = crc64.Checksum(compressed, crcTable)
+	if segment.Header.Checksum, err = segment.Header.ChecksumAlgorithm.sum(compressed); err != nil {
+		return nil, err
+	}
 	return segment, nil
 }
 
@@ -185,6 +300,11 @@ func (c *Controller) persistSegment(info witchbolt.PageFlushInfo, segment *Segme
 
 	c.mu.Lock()
 	generation := c.currentGen
+	if generation != "" && info.TxID <= c.lastTxID {
+		// A snapshot taken while the segment was queued already covers it.
+		c.mu.Unlock()
+		return nil
+	}
 	if generation == "" || (c.lastTxID != 0 && info.ParentTxID != c.lastTxID) {
 		generation = newGenerationID()
 		logger.Infof("stream: starting generation %s (tx=%d)", generation, info.TxID)
@@ -193,23 +313,39 @@ func (c *Controller) persistSegment(info witchbolt.PageFlushInfo, segment *Segme
 	}
 	c.lastTxID = info.TxID
 	c.lastReplication = time.Now()
+	c.bytesSinceSnapshot += int64(len(segment.Data))
+	c.segmentsSinceSnapshot++
 	c.mu.Unlock()
 
-	if err := c.writeSegmentToShadow(generation, segment); err != nil {
-		return err
+	if !c.config.DisableShadow {
+		if err := c.writeSegmentToShadow(generation, segment); err != nil {
+			return err
+		}
 	}
 
 	ctx := context.Background()
 	var errs []error
-	for _, replica := range c.replicas {
-		if err := replica.PutSegment(ctx, generation, segment); err != nil {
+	variants := map[compressionSettings]*Segment{c.compression: segment}
+	for i, replica := range c.replicas {
+		target, err := recompressSegment(variants, segment, c.replicaCompression[i])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s recompress segment: %w", replica.Name(), err))
+			c.events.OnReplicaError(replica.Name(), "segment", err)
+			continue
+		}
+		if err := replica.PutSegment(ctx, generation, target); err != nil {
 			errs = append(errs, fmt.Errorf("%s put segment: %w", replica.Name(), err))
+			c.metrics.UploadFailed(replica.Name(), "segment")
+			c.events.OnReplicaError(replica.Name(), "segment", err)
 		} else {
 			c.mu.Lock()
 			c.replicaLag[replica.Name()] = time.Now()
 			c.mu.Unlock()
+			c.metrics.SegmentUploaded(replica.Name(), len(target.Data))
+			c.events.OnSegmentReplicated(replica.Name(), generation, segment.Header.TxID)
 		}
 	}
+	c.metrics.DataLossWindow(c.DataLossWindow())
 
 	if err := c.maybeSnapshot(ctx, generation); err != nil {
 		errs = append(errs, err)
@@ -248,9 +384,18 @@ func (c *Controller) maybeSnapshot(ctx context.Context, generation string) error
 
 	c.mu.RLock()
 	last := c.lastSnapshot
+	pendingBytes, pendingSegments := c.bytesSinceSnapshot, c.segmentsSinceSnapshot
+	resnapshot := c.resnapshot
 	c.mu.RUnlock()
 
-	if !last.IsZero() && time.Since(last) < interval {
+	due := resnapshot || last.IsZero() || time.Since(last) >= interval
+	if after := c.config.SnapshotAfterBytes; after > 0 && pendingBytes >= after {
+		due = true
+	}
+	if after := c.config.SnapshotAfterSegments; after > 0 && pendingSegments >= after {
+		due = true
+	}
+	if !due {
 		return nil
 	}
 
@@ -263,12 +408,43 @@ func (c *Controller) maybeSnapshot(ctx context.Context, generation string) error
 	}
 
 	c.mu.Lock()
-	c.lastSnapshot = snapshot.Header.CreatedAt
+	c.lastSnapshot = snapshot.createdAt()
+	c.bytesSinceSnapshot, c.segmentsSinceSnapshot = 0, 0
+	c.resnapshot = false
+	if c.currentGen == generation && c.lastTxID < snapshot.Header.TxID {
+		c.lastTxID = snapshot.Header.TxID
+	}
 	c.mu.Unlock()
 
 	return nil
 }
 
+// Snapshot creates and replicates a snapshot immediately, regardless of
+// SnapshotInterval, and returns its header. A controller that has not seen a
+// write yet starts a new generation rooted at the snapshot.
+func (c *Controller) Snapshot(ctx context.Context) (SnapshotHeader, error) {
+	c.mu.Lock()
+	generation := c.currentGen
+	if generation == "" {
+		generation = newGenerationID()
+		c.currentGen = generation
+	}
+	c.mu.Unlock()
+
+	snapshot, err := c.createSnapshot(ctx, generation)
+	if err != nil {
+		return SnapshotHeader{}, fmt.Errorf("create snapshot: %w", err)
+	}
+	c.mu.Lock()
+	c.lastSnapshot = snapshot.createdAt()
+	c.bytesSinceSnapshot, c.segmentsSinceSnapshot = 0, 0
+	if c.currentGen == generation && c.lastTxID < snapshot.Header.TxID {
+		c.lastTxID = snapshot.Header.TxID
+	}
+	c.mu.Unlock()
+	return snapshot.Header, nil
+}
+
 func (c *Controller) createSnapshot(ctx context.Context, generation string) (*Snapshot, error) {
 	var snap *Snapshot
 	err := c.db.View(func(tx *witchbolt.Tx) error {
@@ -281,7 +457,7 @@ func (c *Controller) createSnapshot(ctx context.Context, generation string) (*Sn
 			return fmt.Errorf("invalid page size: %d", pageSize)
 		}
 		raw := buf.Bytes()
-		compressed, err := compressBuffer(c.compression, raw)
+		compressed, err := compressBuffer(c.snapshotCompression, raw)
 		if err != nil {
 			return fmt.Errorf("compress snapshot: %w", err)
 		}
@@ -297,14 +473,21 @@ func (c *Controller) createSnapshot(ctx context.Context, generation string) (*Sn
 				TxID:              txNum,
 				PageCount:         pageCount,
 				PageSize:          pageSize,
-				Compression:       c.compression.Codec,
-				CompressionLevel:  c.compression.Level,
-				CompressionWindow: c.compression.Window,
+				ChecksumAlgorithm: c.config.ChecksumAlgorithm.headerValue(),
+				Compression:       c.snapshotCompression.Codec,
+				CompressionLevel:  c.snapshotCompression.Level,
+				CompressionWindow: c.snapshotCompression.Window,
 				CreatedAt:         time.Now().UTC(),
+				DatabaseID:        c.databaseID,
 			},
 			Data: compressed,
 		}
-		return nil
+		if c.config.DeterministicSnapshots {
+			snap.Timestamp = snap.Header.CreatedAt
+			snap.Header.CreatedAt = time.Time{}
+		}
+		snap.Header.Checksum, err = snap.Header.ChecksumAlgorithm.sum(compressed)
+		return err
 	})
 	if err != nil {
 		return nil, err
@@ -313,20 +496,34 @@ func (c *Controller) createSnapshot(ctx context.Context, generation string) (*Sn
 		return nil, nil
 	}
 
-	if err := c.writeSnapshotToShadow(generation, snap); err != nil {
-		return nil, err
+	if !c.config.DisableShadow {
+		if err := c.writeSnapshotToShadow(generation, snap); err != nil {
+			return nil, err
+		}
 	}
 
 	var errs []error
-	for _, replica := range c.replicas {
-		if err := replica.PutSnapshot(ctx, generation, snap); err != nil {
+	variants := map[compressionSettings]*Snapshot{c.snapshotCompression: snap}
+	for i, replica := range c.replicas {
+		target, err := recompressSnapshot(variants, snap, c.replicaSnapshotCompression[i])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s recompress snapshot: %w", replica.Name(), err))
+			c.events.OnReplicaError(replica.Name(), "snapshot", err)
+			continue
+		}
+		if err := replica.PutSnapshot(ctx, generation, target); err != nil {
 			errs = append(errs, fmt.Errorf("%s put snapshot: %w", replica.Name(), err))
+			c.metrics.UploadFailed(replica.Name(), "snapshot")
+			c.events.OnReplicaError(replica.Name(), "snapshot", err)
 		} else {
 			c.mu.Lock()
 			c.replicaLag[replica.Name()] = time.Now()
 			c.mu.Unlock()
+			c.metrics.SnapshotUploaded(replica.Name(), len(target.Data))
 		}
 	}
+	c.metrics.DataLossWindow(c.DataLossWindow())
+	c.events.OnSnapshotCreated(generation, snap.Header)
 
 	if len(errs) > 0 {
 		return nil, aggregateErrors("replicate snapshot", errs)
@@ -341,7 +538,7 @@ func (c *Controller) writeSnapshotToShadow(generation string, snapshot *Snapshot
 	if err := os.MkdirAll(dir, 0o755); err != nil {
 		return fmt.Errorf("create snapshot dir: %w", err)
 	}
-	filename := fmt.Sprintf("%s-%016x.snapshot.cbor", snapshot.Header.CreatedAt.Format(time.RFC3339Nano), snapshot.Header.TxID)
+	filename := fmt.Sprintf("%s-%016x.snapshot.cbor", snapshot.createdAt().Format(time.RFC3339Nano), snapshot.Header.TxID)
 	path := filepath.Join(dir, filename)
 	encoded, err := marshalSnapshot(snapshot)
 	if err != nil {
@@ -353,6 +550,56 @@ func (c *Controller) writeSnapshotToShadow(generation string, snapshot *Snapshot
 	return nil
 }
 
+// recompressSegment returns segment encoded with settings, reusing variants
+// already produced during the current flush.
+func recompressSegment(variants map[compressionSettings]*Segment, segment *Segment, settings compressionSettings) (*Segment, error) {
+	if variant, ok := variants[settings]; ok {
+		return variant, nil
+	}
+	raw, err := decompressBuffer(segment.Header.Compression, segment.Header.CompressionWindow, segment.Data)
+	if err != nil {
+		return nil, err
+	}
+	compressed, err := compressBuffer(settings, raw)
+	if err != nil {
+		return nil, err
+	}
+	variant := &Segment{Header: segment.Header, Pages: segment.Pages, Data: compressed}
+	variant.Header.Compression = settings.Codec
+	variant.Header.CompressionLevel = settings.Level
+	variant.Header.CompressionWindow = settings.Window
+	if variant.Header.Checksum, err = variant.Header.ChecksumAlgorithm.sum(compressed); err != nil {
+		return nil, err
+	}
+	variants[settings] = variant
+	return variant, nil
+}
+
+// recompressSnapshot returns snapshot encoded with settings, reusing variants
+// already produced for the current snapshot.
+func recompressSnapshot(variants map[compressionSettings]*Snapshot, snapshot *Snapshot, settings compressionSettings) (*Snapshot, error) {
+	if variant, ok := variants[settings]; ok {
+		return variant, nil
+	}
+	raw, err := decompressBuffer(snapshot.Header.Compression, snapshot.Header.CompressionWindow, snapshot.Data)
+	if err != nil {
+		return nil, err
+	}
+	compressed, err := compressBuffer(settings, raw)
+	if err != nil {
+		return nil, err
+	}
+	variant := &Snapshot{Header: snapshot.Header, Data: compressed, Timestamp: snapshot.Timestamp}
+	variant.Header.Compression = settings.Codec
+	variant.Header.CompressionLevel = settings.Level
+	variant.Header.CompressionWindow = settings.Window
+	if variant.Header.Checksum, err = variant.Header.ChecksumAlgorithm.sum(compressed); err != nil {
+		return nil, err
+	}
+	variants[settings] = variant
+	return variant, nil
+}
+
 func (c *Controller) retentionLoop() {
 	defer c.wg.Done()
 	interval := c.config.Retention.CheckInterval
@@ -382,10 +629,19 @@ func (c *Controller) enforceRetention(ctx context.Context) {
 	generation := c.currentGen
 	c.mu.RUnlock()
 	for _, replica := range c.replicas {
-		if err := replica.Prune(ctx, generation, retention); err != nil {
+		policy := retention
+		if override := replicaOptionsOf(replica).Retention; override != nil {
+			policy = override.inherit(retention)
+		}
+		err := replica.Prune(ctx, generation, policy)
+		if err != nil {
 			c.db.Logger().Warningf("stream: prune %s failed: %v", replica.Name(), err)
+			c.events.OnReplicaError(replica.Name(), "prune", err)
 		}
+		c.metrics.PruneCompleted(replica.Name(), err)
 	}
+	c.metrics.DataLossWindow(c.DataLossWindow())
+	c.events.OnPruneComplete(generation)
 }
 
 func (c *Controller) triggerRetention() {
@@ -400,13 +656,21 @@ func (c *Controller) DataLossWindow() time.Duration {
 	now := time.Now()
 	c.mu.RLock()
 	defer c.mu.RUnlock()
+	return c.dataLossWindowLocked(now)
+}
+
+func (c *Controller) dataLossWindowLocked(now time.Time) time.Duration {
+	var maxLag time.Duration
+	if !c.pendingSince.IsZero() {
+		// Commits still queued for upload are at risk since they were queued.
+		maxLag = now.Sub(c.pendingSince)
+	}
 	if len(c.replicaLag) == 0 {
 		if c.lastReplication.IsZero() {
-			return 0
+			return maxLag
 		}
-		return now.Sub(c.lastReplication)
+		return max(maxLag, now.Sub(c.lastReplication))
 	}
-	var maxLag time.Duration
 	for _, ts := range c.replicaLag {
 		if ts.IsZero() {
 			continue
@@ -419,6 +683,61 @@ func (c *Controller) DataLossWindow() time.Duration {
 	return maxLag
 }
 
+// Status is a point-in-time view of replication progress.
+type Status struct {
+	CurrentGeneration string          `json:"currentGeneration"`
+	LastTxID          uint64          `json:"lastTxId"`
+	LastSnapshot      time.Time       `json:"lastSnapshot"`
+	LastReplication   time.Time       `json:"lastReplication"`
+	Replicas          []ReplicaStatus `json:"replicas"`
+	// QueuedSegments is the AsyncReplication backlog, excluding the segment
+	// being uploaded.
+	QueuedSegments int `json:"queuedSegments"`
+
+	// DataLossWindow is the worst-case lag, as returned by DataLossWindow.
+	DataLossWindow          time.Duration `json:"dataLossWindow"`
+	DataLossWindowThreshold time.Duration `json:"dataLossWindowThreshold"`
+	// ThresholdExceeded is set when a non-zero threshold is configured and
+	// DataLossWindow is larger than it.
+	ThresholdExceeded bool `json:"thresholdExceeded"`
+}
+
+// ReplicaStatus reports when a replica last accepted an artefact. LastSuccess
+// is zero, and Lag is zero, until the first successful upload.
+type ReplicaStatus struct {
+	Name        string        `json:"name"`
+	LastSuccess time.Time     `json:"lastSuccess"`
+	Lag         time.Duration `json:"lag"`
+}
+
+// Status returns the controller's replication state. Replicas are listed in
+// configuration order.
+func (c *Controller) Status() Status {
+	now := time.Now()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	status := Status{
+		CurrentGeneration:       c.currentGen,
+		LastTxID:                c.lastTxID,
+		LastSnapshot:            c.lastSnapshot,
+		LastReplication:         c.lastReplication,
+		Replicas:                make([]ReplicaStatus, len(c.replicas)),
+		QueuedSegments:          len(c.queue),
+		DataLossWindow:          c.dataLossWindowLocked(now),
+		DataLossWindowThreshold: c.config.DataLossWindowThreshold,
+	}
+	for i, replica := range c.replicas {
+		rs := ReplicaStatus{Name: replica.Name()}
+		if ts := c.replicaLag[rs.Name]; !ts.IsZero() {
+			rs.LastSuccess = ts
+			rs.Lag = now.Sub(ts)
+		}
+		status.Replicas[i] = rs
+	}
+	status.ThresholdExceeded = status.DataLossWindowThreshold > 0 && status.DataLossWindow > status.DataLossWindowThreshold
+	return status
+}
+
 func aggregateErrors(prefix string, errs []error) error {
 	if len(errs) == 0 {
 		return nil
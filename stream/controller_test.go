@@ -0,0 +1,524 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/delaneyj/witchbolt"
+)
+
+func TestControllerPerReplicaCompression(t *testing.T) {
+	dir := t.TempDir()
+	plainPath := filepath.Join(dir, "plain")
+	zstdPath := filepath.Join(dir, "zstd")
+	db, _, _ := openReplicatedDB(t, Config{
+		Compression: CompressionConfig{Codec: CompressionZSTD, Level: 6},
+		Replicas: []ReplicaConfig{
+			&FileReplicaConfig{ReplicaOptions: ReplicaOptions{Compression: &CompressionConfig{Codec: CompressionNone}}, Path: plainPath},
+			&FileReplicaConfig{Path: zstdPath},
+		},
+	})
+	putKeys(t, db, "widgets", 3)
+
+	for path, codec := range map[string]CompressionType{plainPath: CompressionNone, zstdPath: CompressionZSTD} {
+		replica, err := NewFileReplica(&FileReplicaConfig{Path: path})
+		require.NoError(t, err)
+		state, err := replica.LatestState(context.Background())
+		require.NoError(t, err)
+		require.NotNil(t, state.Snapshot)
+		require.NotEmpty(t, state.Segments)
+
+		snapshot, err := replica.FetchSnapshot(context.Background(), state.Generation, state.Snapshot)
+		require.NoError(t, err)
+		require.Equal(t, codec, snapshot.Header.Compression)
+		require.NoError(t, verifySnapshotChecksum(snapshot))
+		for _, desc := range state.Segments {
+			segment, err := replica.FetchSegment(context.Background(), state.Generation, desc)
+			require.NoError(t, err)
+			require.Equal(t, codec, segment.Header.Compression)
+			require.NoError(t, verifySegmentChecksum(segment))
+		}
+
+		target := filepath.Join(t.TempDir(), "restored.db")
+		require.NoError(t, RestoreStandalone(context.Background(), Config{
+			Replicas: []ReplicaConfig{&FileReplicaConfig{Path: path}},
+			Restore:  RestoreConfig{TargetPath: target},
+		}))
+		requireKeys(t, target, "widgets", 3)
+	}
+}
+
+// requireKeys opens the database at path and asserts the bucket holds n keys.
+func requireKeys(t *testing.T, path string, bucket string, n int) {
+	t.Helper()
+	restored, err := witchbolt.Open(path, 0o600, &witchbolt.Options{ReadOnly: true})
+	require.NoError(t, err)
+	defer restored.Close()
+	require.NoError(t, restored.View(func(tx *witchbolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		require.NotNil(t, b)
+		require.Equal(t, n, b.Stats().KeyN)
+		return nil
+	}))
+}
+
+func TestControllerStartRunsHealthCheck(t *testing.T) {
+	dir := t.TempDir()
+	db, err := witchbolt.Open(filepath.Join(dir, "db"), 0o600, nil)
+	require.NoError(t, err)
+	defer db.Close()
+
+	replicaPath := filepath.Join(dir, "replica")
+	replica, err := NewFileReplica(&FileReplicaConfig{Path: replicaPath})
+	require.NoError(t, err)
+	require.NoError(t, replica.HealthCheck(context.Background()))
+
+	require.NoError(t, os.Remove(replicaPath))
+	require.NoError(t, os.WriteFile(replicaPath, nil, 0o600))
+	ctrl, err := NewController(db, Config{ShadowDir: filepath.Join(dir, "shadow")}, []Replica{replica})
+	require.NoError(t, err)
+	err = ctrl.Start(context.Background())
+	require.ErrorContains(t, err, "replica health check")
+	require.ErrorContains(t, err, "is not a directory")
+}
+
+func TestControllerStatus(t *testing.T) {
+	db, ctrl, replicaPath := openReplicatedDB(t, Config{DataLossWindowThreshold: time.Hour})
+	status := ctrl.Status()
+	require.Empty(t, status.CurrentGeneration)
+	require.Len(t, status.Replicas, 1)
+	require.Equal(t, replicaPath, status.Replicas[0].Name)
+	require.True(t, status.Replicas[0].LastSuccess.IsZero())
+
+	putKeys(t, db, "widgets", 3)
+	var txid uint64
+	require.NoError(t, db.View(func(tx *witchbolt.Tx) error {
+		txid = uint64(tx.ID())
+		return nil
+	}))
+
+	status = ctrl.Status()
+	require.NotEmpty(t, status.CurrentGeneration)
+	require.Equal(t, txid, status.LastTxID)
+	require.False(t, status.LastSnapshot.IsZero())
+	require.False(t, status.LastReplication.IsZero())
+	require.False(t, status.Replicas[0].LastSuccess.IsZero())
+	require.Equal(t, time.Hour, status.DataLossWindowThreshold)
+	require.Less(t, status.DataLossWindow, time.Hour)
+	require.False(t, status.ThresholdExceeded)
+
+	ctrl.config.DataLossWindowThreshold = time.Nanosecond
+	time.Sleep(time.Millisecond)
+	status = ctrl.Status()
+	require.GreaterOrEqual(t, status.Replicas[0].Lag, time.Millisecond)
+	require.True(t, status.ThresholdExceeded)
+}
+
+func TestControllerDisableShadow(t *testing.T) {
+	shadowDir := filepath.Join(t.TempDir(), "shadow")
+	db, _, replicaPath := openReplicatedDB(t, Config{ShadowDir: shadowDir, DisableShadow: true})
+	putKeys(t, db, "widgets", 3)
+
+	_, err := os.Stat(shadowDir)
+	require.ErrorIs(t, err, os.ErrNotExist, "no shadow files may be written")
+
+	target := filepath.Join(t.TempDir(), "restored.db")
+	require.NoError(t, RestoreStandalone(context.Background(), Config{
+		Replicas: []ReplicaConfig{&FileReplicaConfig{Path: replicaPath}},
+		Restore:  RestoreConfig{TargetPath: target},
+	}))
+	requireKeys(t, target, "widgets", 3)
+
+	_, err = NewController(db, Config{DisableShadow: true}, nil)
+	require.ErrorContains(t, err, "at least one replica is required")
+}
+
+// recordingEvents captures lifecycle callbacks as short strings.
+type recordingEvents struct {
+	NopEvents
+	mu     sync.Mutex
+	events []string
+}
+
+func (e *recordingEvents) record(format string, args ...any) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.events = append(e.events, fmt.Sprintf(format, args...))
+}
+
+func (e *recordingEvents) OnSegmentReplicated(replica, generation string, txid uint64) {
+	e.record("segment %s", filepath.Base(replica))
+}
+
+func (e *recordingEvents) OnSnapshotCreated(generation string, header SnapshotHeader) {
+	e.record("snapshot %d", header.TxID)
+}
+
+func (e *recordingEvents) OnReplicaError(replica, op string, err error) {
+	e.record("error %s %s", filepath.Base(replica), op)
+}
+
+func (e *recordingEvents) OnPruneComplete(generation string) {
+	e.record("prune")
+}
+
+func (e *recordingEvents) OnRestore(target string, txid uint64) {
+	e.record("restore %d", txid)
+}
+
+// take returns and clears the recorded events other than prune events,
+// which the background retention loop may emit at any time.
+func (e *recordingEvents) take() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var events, prunes []string
+	for _, event := range e.events {
+		if strings.HasSuffix(event, "prune") {
+			prunes = append(prunes, event)
+		} else {
+			events = append(events, event)
+		}
+	}
+	e.events = prunes
+	return events
+}
+
+func (e *recordingEvents) has(event string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return slices.Contains(e.events, event)
+}
+
+func TestControllerEvents(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good")
+	bad := filepath.Join(dir, "bad")
+	events := &recordingEvents{}
+	db, _, _ := openReplicatedDB(t, Config{
+		Replicas: []ReplicaConfig{&FileReplicaConfig{Path: good}, &FileReplicaConfig{Path: bad}},
+		Events:   events,
+	})
+
+	putKeys(t, db, "widgets", 1)
+	var txid uint64
+	require.NoError(t, db.View(func(tx *witchbolt.Tx) error {
+		txid = uint64(tx.ID())
+		return nil
+	}))
+	require.Equal(t, []string{"segment good", "segment bad", fmt.Sprintf("snapshot %d", txid)}, events.take())
+
+	require.NoError(t, os.RemoveAll(bad))
+	require.NoError(t, os.WriteFile(bad, nil, 0o600))
+	putKeys(t, db, "widgets", 2)
+	require.Contains(t, events.take(), "error bad segment")
+
+	require.Eventually(t, func() bool {
+		return events.has("error bad prune") && events.has("prune")
+	}, 5*time.Second, 10*time.Millisecond, "retention runs after a snapshot")
+
+	target := filepath.Join(t.TempDir(), "restored.db")
+	require.NoError(t, RestoreStandalone(context.Background(), Config{
+		Replicas: []ReplicaConfig{&FileReplicaConfig{Path: good}},
+		Restore:  RestoreConfig{TargetPath: target},
+		Events:   events,
+	}))
+	require.NoError(t, db.View(func(tx *witchbolt.Tx) error {
+		txid = uint64(tx.ID())
+		return nil
+	}))
+	require.Equal(t, []string{fmt.Sprintf("restore %d", txid)}, events.take())
+}
+
+func TestControllerSnapshot(t *testing.T) {
+	db, ctrl, replicaPath := openReplicatedDB(t, Config{SnapshotInterval: time.Hour})
+	replica, err := NewFileReplica(&FileReplicaConfig{Path: replicaPath})
+	require.NoError(t, err)
+
+	header, err := ctrl.Snapshot(context.Background())
+	require.NoError(t, err)
+	status := ctrl.Status()
+	require.NotEmpty(t, status.CurrentGeneration, "a snapshot before any write starts a generation")
+	require.Equal(t, header.CreatedAt, status.LastSnapshot)
+	state, err := replica.LatestState(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, status.CurrentGeneration, state.Generation)
+	require.NotNil(t, state.Snapshot)
+	require.Empty(t, state.Segments)
+
+	putKeys(t, db, "widgets", 3)
+	state, err = replica.LatestState(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, status.CurrentGeneration, state.Generation, "later writes chain onto the snapshot")
+	require.Len(t, state.Segments, 3, "the interval has not elapsed so no automatic snapshot is taken")
+
+	header, err = ctrl.Snapshot(context.Background())
+	require.NoError(t, err)
+	var txid uint64
+	require.NoError(t, db.View(func(tx *witchbolt.Tx) error {
+		txid = uint64(tx.ID())
+		return nil
+	}))
+	require.Equal(t, txid, header.TxID)
+	state, err = replica.LatestState(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, state.Segments, "the forced snapshot becomes the new head")
+	require.Contains(t, state.Snapshot.Name, fmt.Sprintf("%016x", txid))
+}
+
+func TestControllerSnapshotThresholds(t *testing.T) {
+	currentTxID := func(db *witchbolt.DB) uint64 {
+		var txid uint64
+		require.NoError(t, db.View(func(tx *witchbolt.Tx) error {
+			txid = uint64(tx.ID())
+			return nil
+		}))
+		return txid
+	}
+
+	t.Run("segments", func(t *testing.T) {
+		db, _, replicaPath := openReplicatedDB(t, Config{SnapshotInterval: time.Hour, SnapshotAfterSegments: 3})
+		replica, err := NewFileReplica(&FileReplicaConfig{Path: replicaPath})
+		require.NoError(t, err)
+
+		putKeys(t, db, "widgets", 3)
+		state, err := replica.LatestState(context.Background())
+		require.NoError(t, err)
+		require.Len(t, state.Segments, 2, "the first write snapshots; two segments are below the threshold")
+
+		putKeys(t, db, "gadgets", 1)
+		state, err = replica.LatestState(context.Background())
+		require.NoError(t, err)
+		require.Empty(t, state.Segments, "the third segment since the snapshot trips the threshold")
+		require.Contains(t, state.Snapshot.Name, fmt.Sprintf("%016x", currentTxID(db)))
+	})
+
+	t.Run("bytes", func(t *testing.T) {
+		db, ctrl, replicaPath := openReplicatedDB(t, Config{SnapshotInterval: time.Hour, SnapshotAfterBytes: 1 << 30})
+		replica, err := NewFileReplica(&FileReplicaConfig{Path: replicaPath})
+		require.NoError(t, err)
+
+		putKeys(t, db, "widgets", 3)
+		state, err := replica.LatestState(context.Background())
+		require.NoError(t, err)
+		require.Len(t, state.Segments, 2, "small segments stay below the byte threshold")
+
+		ctrl.config.SnapshotAfterBytes = 1
+		putKeys(t, db, "gadgets", 1)
+		state, err = replica.LatestState(context.Background())
+		require.NoError(t, err)
+		require.Empty(t, state.Segments, "any segment data trips a one byte threshold")
+		require.Contains(t, state.Snapshot.Name, fmt.Sprintf("%016x", currentTxID(db)))
+	})
+}
+
+func TestControllerDeterministicSnapshots(t *testing.T) {
+	for _, deterministic := range []bool{false, true} {
+		t.Run(fmt.Sprintf("deterministic=%t", deterministic), func(t *testing.T) {
+			db, ctrl, replicaPath := openReplicatedDB(t, Config{
+				SnapshotInterval:       time.Hour,
+				DeterministicSnapshots: deterministic,
+			})
+			putKeys(t, db, "widgets", 3)
+			replica, err := NewFileReplica(&FileReplicaConfig{Path: replicaPath})
+			require.NoError(t, err)
+
+			snapshotBytes := func() ([]byte, *SnapshotDescriptor) {
+				t.Helper()
+				_, err := ctrl.Snapshot(context.Background())
+				require.NoError(t, err)
+				state, err := replica.LatestState(context.Background())
+				require.NoError(t, err)
+				require.NotNil(t, state.Snapshot)
+				data, err := os.ReadFile(filepath.Join(replicaPath, filepath.FromSlash(state.Snapshot.Name)))
+				require.NoError(t, err)
+				return data, state.Snapshot
+			}
+			first, firstDesc := snapshotBytes()
+			// Header timestamps encode with second precision.
+			time.Sleep(time.Until(firstDesc.Timestamp.Truncate(time.Second).Add(time.Second)))
+			second, secondDesc := snapshotBytes()
+			require.NotEqual(t, firstDesc.Name, secondDesc.Name, "snapshot names still carry their timestamps")
+			require.True(t, secondDesc.Timestamp.After(firstDesc.Timestamp))
+			require.Equal(t, secondDesc.Timestamp, ctrl.Status().LastSnapshot)
+			if !deterministic {
+				require.NotEqual(t, first, second, "header timestamps differ by default")
+				return
+			}
+			require.Equal(t, first, second, "identical state yields identical snapshot bytes")
+			target := filepath.Join(t.TempDir(), "restored.db")
+			require.NoError(t, RestoreStandalone(context.Background(), Config{
+				Replicas: []ReplicaConfig{&FileReplicaConfig{Path: replicaPath}},
+				Restore:  RestoreConfig{TargetPath: target},
+			}))
+			requireKeys(t, target, "widgets", 3)
+		})
+	}
+}
+
+func TestControllerPerReplicaRetention(t *testing.T) {
+	dir := t.TempDir()
+	hotPath := filepath.Join(dir, "hot")
+	coldPath := filepath.Join(dir, "cold")
+	db, ctrl, _ := openReplicatedDB(t, Config{
+		SnapshotInterval: time.Hour,
+		Retention:        RetentionConfig{SnapshotRetention: 24 * time.Hour},
+		Replicas: []ReplicaConfig{
+			&FileReplicaConfig{ReplicaOptions: ReplicaOptions{Retention: &RetentionConfig{SnapshotRetention: time.Millisecond}}, Path: hotPath},
+			&FileReplicaConfig{Path: coldPath},
+		},
+	})
+
+	const snapshots = 3
+	for i := 0; i < snapshots; i++ {
+		putKeys(t, db, fmt.Sprintf("bucket-%d", i), 1)
+		_, err := ctrl.Snapshot(context.Background())
+		require.NoError(t, err)
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	countSnapshots := func(path string) int {
+		generation := ctrl.Status().CurrentGeneration
+		entries, err := os.ReadDir(filepath.Join(path, generation, "snapshots"))
+		require.NoError(t, err)
+		return len(entries)
+	}
+	cold := countSnapshots(coldPath)
+	require.GreaterOrEqual(t, cold, snapshots)
+	ctrl.enforceRetention(context.Background())
+	require.Equal(t, 1, countSnapshots(hotPath), "the override prunes all but the newest snapshot")
+	require.Equal(t, cold, countSnapshots(coldPath), "the controller policy keeps every snapshot")
+}
+
+func TestControllerSnapshotProfile(t *testing.T) {
+	db, ctrl, replicaPath := openReplicatedDB(t, Config{SnapshotProfile: SnapshotProfileFast})
+	putKeys(t, db, "widgets", 2)
+	fast := CompressionConfig{Codec: CompressionZSTD, Level: 2}.normalized()
+	require.Equal(t, fast, ctrl.snapshotCompression)
+	require.Equal(t, CompressionConfig{}.normalized(), ctrl.compression, "segments keep the default")
+
+	replica, err := NewFileReplica(&FileReplicaConfig{Path: replicaPath})
+	require.NoError(t, err)
+	state, err := replica.LatestState(context.Background())
+	require.NoError(t, err)
+	snapshot, err := replica.FetchSnapshot(context.Background(), state.Generation, state.Snapshot)
+	require.NoError(t, err)
+	require.Equal(t, fast.Level, snapshot.Header.CompressionLevel)
+	require.NotEmpty(t, restoreBytes(t, &FileReplicaConfig{Path: replicaPath}))
+}
+
+func TestNewControllerRejectsUnknownSnapshotProfile(t *testing.T) {
+	db, err := witchbolt.Open(filepath.Join(t.TempDir(), "db"), 0o600, nil)
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = NewController(db, Config{ShadowDir: t.TempDir(), SnapshotProfile: "tiny"}, nil)
+	require.ErrorContains(t, err, `unknown snapshot profile "tiny"`)
+}
+
+func TestControllerDatabaseID(t *testing.T) {
+	db, ctrl, replicaPath := openReplicatedDB(t, Config{})
+	putKeys(t, db, "widgets", 2)
+	require.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, ctrl.databaseID)
+
+	again, err := NewController(db, Config{ShadowDir: t.TempDir()}, nil)
+	require.NoError(t, err)
+	require.Equal(t, ctrl.databaseID, again.databaseID, "the identity is stored in the database")
+
+	replica, err := NewFileReplica(&FileReplicaConfig{Path: replicaPath})
+	require.NoError(t, err)
+	state, err := replica.LatestState(context.Background())
+	require.NoError(t, err)
+	snapshot, err := replica.FetchSnapshot(context.Background(), state.Generation, state.Snapshot)
+	require.NoError(t, err)
+	require.Equal(t, ctrl.databaseID, snapshot.Header.DatabaseID)
+	segment, err := replica.FetchSegment(context.Background(), state.Generation, state.Segments[0])
+	require.NoError(t, err)
+	require.Equal(t, ctrl.databaseID, segment.Header.DatabaseID)
+
+	target := filepath.Join(t.TempDir(), "restored.db")
+	require.NoError(t, RestoreStandalone(context.Background(), Config{
+		Replicas: []ReplicaConfig{&FileReplicaConfig{Path: replicaPath}},
+		Restore:  RestoreConfig{TargetPath: target},
+	}))
+	restored, err := witchbolt.Open(target, 0o600, &witchbolt.Options{ReadOnly: true})
+	require.NoError(t, err)
+	defer restored.Close()
+	id, err := loadDatabaseID(restored)
+	require.NoError(t, err)
+	require.Equal(t, ctrl.databaseID, id, "a restore keeps the identity")
+}
+
+func TestNewControllerReadOnlyWithoutDatabaseID(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db")
+	db, err := witchbolt.Open(path, 0o600, nil)
+	require.NoError(t, err)
+	putKeys(t, db, "widgets", 1)
+	require.NoError(t, db.Close())
+
+	db, err = witchbolt.Open(path, 0o600, &witchbolt.Options{ReadOnly: true})
+	require.NoError(t, err)
+	defer db.Close()
+	ctrl, err := NewController(db, Config{ShadowDir: t.TempDir()}, nil)
+	require.NoError(t, err, "a read-only database that was never replicated can still be snapshotted")
+	require.Empty(t, ctrl.databaseID)
+	require.NoError(t, db.View(func(tx *witchbolt.Tx) error {
+		require.Nil(t, tx.Bucket([]byte(IdentityBucket)), "nothing is written to a read-only database")
+		return nil
+	}))
+}
+
+func TestObserverAttachAndDetach(t *testing.T) {
+	dir := t.TempDir()
+	db, err := witchbolt.Open(filepath.Join(dir, "db"), 0o600, nil)
+	require.NoError(t, err)
+	defer db.Close()
+	putKeys(t, db, "before", 2)
+
+	replicaPath := filepath.Join(dir, "replica")
+	replica, err := NewFileReplica(&FileReplicaConfig{Path: replicaPath})
+	require.NoError(t, err)
+	detach, err := db.AttachObserver(Observer(context.Background(), Config{
+		ShadowDir:        filepath.Join(dir, "shadow"),
+		SnapshotInterval: time.Hour,
+		Replicas:         []ReplicaConfig{&FileReplicaConfig{Path: replicaPath}},
+	}))
+	require.NoError(t, err)
+
+	t.Log("Segments flow while attached")
+	putKeys(t, db, "widgets", 3)
+	attached, err := replica.LatestState(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, attached.Snapshot)
+	require.Len(t, attached.Segments, 2, "the first write snapshots")
+
+	t.Log("Detaching stops the controller and replication")
+	require.NoError(t, detach())
+	require.NoError(t, detach(), "detaching twice is a no-op")
+	putKeys(t, db, "after", 2)
+	detached, err := replica.LatestState(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, attached, detached)
+
+	target := filepath.Join(t.TempDir(), "restored.db")
+	require.NoError(t, RestoreStandalone(context.Background(), Config{
+		Replicas: []ReplicaConfig{&FileReplicaConfig{Path: replicaPath}},
+		Restore:  RestoreConfig{TargetPath: target},
+	}))
+	requireKeys(t, target, "before", 2)
+	requireKeys(t, target, "widgets", 3)
+	restored, err := witchbolt.Open(target, 0o600, &witchbolt.Options{ReadOnly: true})
+	require.NoError(t, err)
+	defer restored.Close()
+	require.NoError(t, restored.View(func(tx *witchbolt.Tx) error {
+		require.Nil(t, tx.Bucket([]byte("after")), "writes after detaching are not replicated")
+		return nil
+	}))
+}
@@ -0,0 +1,54 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrGenerationInUse is returned by DeleteReplicaGeneration when a replica's
+// state manifest still references the generation, making it the generation
+// restores read from.
+var ErrGenerationInUse = errors.New("stream: generation is referenced by replica state")
+
+// DeleteReplicaGeneration removes every snapshot and segment of generation
+// from replicas. Deleting the generation a replica's _state.json references
+// would leave that replica with nothing to restore, so it fails with
+// ErrGenerationInUse before touching any replica unless force is set; forced
+// deletes also clear the manifest.
+func DeleteReplicaGeneration(ctx context.Context, replicas []Replica, generation string, force bool) error {
+	if err := checkGenerationID(generation); err != nil {
+		return err
+	}
+	if !force {
+		for _, replica := range replicas {
+			state, err := replica.LatestState(ctx)
+			if err != nil {
+				return fmt.Errorf("%s: %w", replica.Name(), err)
+			}
+			if state != nil && state.Generation == generation {
+				return fmt.Errorf("%s: %w", replica.Name(), ErrGenerationInUse)
+			}
+		}
+	}
+	var errs []error
+	for _, replica := range replicas {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := replica.DeleteGeneration(ctx, generation); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", replica.Name(), err))
+		}
+	}
+	return aggregateErrors("delete generation", errs)
+}
+
+// checkGenerationID rejects generation names that would escape the
+// generation's own directory or prefix.
+func checkGenerationID(generation string) error {
+	if generation == "" || generation == "." || generation == ".." || strings.ContainsAny(generation, `/\`) {
+		return fmt.Errorf("stream: invalid generation %q", generation)
+	}
+	return nil
+}
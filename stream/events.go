@@ -0,0 +1,33 @@
+package stream
+
+// Events receives replication lifecycle callbacks from a Controller. Each
+// callback fires after the action it describes has finished and is invoked
+// synchronously from the replication path, so implementations must return
+// quickly and hand slow work such as alerting off to another goroutine.
+// Embed NopEvents to implement only the callbacks of interest.
+type Events interface {
+	// OnSegmentReplicated fires once per replica that accepted a segment.
+	OnSegmentReplicated(replica, generation string, txid uint64)
+	// OnSnapshotCreated fires after a snapshot has been written to the
+	// shadow directory and offered to every replica.
+	OnSnapshotCreated(generation string, header SnapshotHeader)
+	// OnReplicaError fires when a replica operation fails. op is one of
+	// "segment", "snapshot" or "prune".
+	OnReplicaError(replica, op string, err error)
+	// OnPruneComplete fires after a retention pass over every replica.
+	// Failures are reported separately through OnReplicaError.
+	OnPruneComplete(generation string)
+	// OnRestore fires after a database has been restored to target at txid.
+	OnRestore(target string, txid uint64)
+}
+
+// NopEvents implements Events with callbacks that do nothing.
+type NopEvents struct{}
+
+var _ Events = NopEvents{}
+
+func (NopEvents) OnSegmentReplicated(string, string, uint64) {}
+func (NopEvents) OnSnapshotCreated(string, SnapshotHeader)   {}
+func (NopEvents) OnReplicaError(string, string, error)       {}
+func (NopEvents) OnPruneComplete(string)                     {}
+func (NopEvents) OnRestore(string, uint64)                   {}
@@ -0,0 +1,80 @@
+package stream
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type walFrame struct {
+	TxID   uint64
+	PageID uint64
+	Data   []byte
+}
+
+func readWALExport(t *testing.T, r io.Reader) (uint32, []walFrame) {
+	t.Helper()
+	header := make([]byte, 12)
+	_, err := io.ReadFull(r, header)
+	require.NoError(t, err)
+	require.Equal(t, walExportMagic, string(header[:4]))
+	require.Equal(t, uint32(walExportVersion), binary.BigEndian.Uint32(header[4:]))
+
+	var frames []walFrame
+	for {
+		var length uint32
+		if err := binary.Read(r, binary.BigEndian, &length); err == io.EOF {
+			return binary.BigEndian.Uint32(header[8:]), frames
+		} else {
+			require.NoError(t, err)
+		}
+		body := make([]byte, length)
+		_, err := io.ReadFull(r, body)
+		require.NoError(t, err)
+		frames = append(frames, walFrame{
+			TxID:   binary.BigEndian.Uint64(body[0:]),
+			PageID: binary.BigEndian.Uint64(body[8:]),
+			Data:   body[walFrameHeaderSize:],
+		})
+	}
+}
+
+func TestExportWAL(t *testing.T) {
+	db, _, replicaPath := openReplicatedDB(t, Config{})
+	putKeys(t, db, "widgets", 3)
+
+	replica, err := NewFileReplica(&FileReplicaConfig{Path: replicaPath})
+	require.NoError(t, err)
+	state, err := replica.LatestState(context.Background())
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, len(state.Segments), 2)
+
+	var buf bytes.Buffer
+	stats, err := ExportWAL(context.Background(), replica, &buf)
+	require.NoError(t, err)
+	require.Equal(t, state.Generation, stats.Generation)
+	require.Equal(t, len(state.Segments), stats.Segments)
+
+	pageSize, frames := readWALExport(t, &buf)
+	require.Equal(t, uint32(db.Info().PageSize), pageSize)
+	require.Len(t, frames, stats.Frames)
+
+	var want []walFrame
+	for _, desc := range state.Segments {
+		segment, err := replica.FetchSegment(context.Background(), state.Generation, desc)
+		require.NoError(t, err)
+		for _, page := range segment.Pages {
+			want = append(want, walFrame{TxID: segment.Header.TxID, PageID: page.ID, Data: page.Data})
+		}
+	}
+	require.Equal(t, want, frames)
+	require.Equal(t, frames[0].TxID, stats.FirstTxID)
+	require.Equal(t, frames[len(frames)-1].TxID, stats.LastTxID)
+	for i := 1; i < len(frames); i++ {
+		require.LessOrEqual(t, frames[i-1].TxID, frames[i].TxID, "frames must be in txid order")
+	}
+}
@@ -2,6 +2,7 @@ package stream
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 
 	"github.com/delaneyj/witchbolt"
@@ -23,7 +24,40 @@ func BuildReplicas(ctx context.Context, cfg Config) ([]Replica, error) {
 	return replicas, nil
 }
 
-// Observer returns a PageFlushObserverRegistration that wires stream into witchbolt.Open options.
+// replicaConfigTypes maps the "type" discriminator of an encoded replica
+// entry to a constructor for its concrete configuration.
+var replicaConfigTypes = map[string]func() ReplicaConfig{
+	"file":   func() ReplicaConfig { return &FileReplicaConfig{} },
+	"s3":     func() ReplicaConfig { return &S3CompatibleConfig{} },
+	"sftp":   func() ReplicaConfig { return &SFTPReplicaConfig{} },
+	"nats":   func() ReplicaConfig { return &NATSReplicaConfig{} },
+	"webdav": func() ReplicaConfig { return &WebDAVReplicaConfig{} },
+}
+
+func decodeReplicaConfig(data []byte) (ReplicaConfig, error) {
+	var header struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, err
+	}
+	if header.Type == "" {
+		return nil, fmt.Errorf("replica type is required")
+	}
+	newConfig, ok := replicaConfigTypes[header.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown replica type %q", header.Type)
+	}
+	rc := newConfig()
+	if err := json.Unmarshal(data, rc); err != nil {
+		return nil, fmt.Errorf("decode %s replica: %w", header.Type, err)
+	}
+	return rc, nil
+}
+
+// Observer returns a PageFlushObserverRegistration that wires stream into
+// witchbolt.Open options or DB.AttachObserver. The DB registers the started
+// controller, and Close stops it.
 func Observer(ctx context.Context, cfg Config) witchbolt.PageFlushObserverRegistration {
 	factoryCtx := ctx
 	if factoryCtx == nil {
@@ -32,6 +66,9 @@ func Observer(ctx context.Context, cfg Config) witchbolt.PageFlushObserverRegist
 	var ctrl *Controller
 	return witchbolt.PageFlushObserverRegistration{
 		Start: func(db *witchbolt.DB) (witchbolt.PageFlushObserver, error) {
+			if err := cfg.Validate(); err != nil {
+				return nil, err
+			}
 			replicas, err := BuildReplicas(factoryCtx, cfg)
 			if err != nil {
 				return nil, err
@@ -40,9 +77,7 @@ func Observer(ctx context.Context, cfg Config) witchbolt.PageFlushObserverRegist
 			if err != nil {
 				return nil, err
 			}
-			db.RegisterPageFlushObserver(ctrl)
 			if err := ctrl.Start(factoryCtx); err != nil {
-				db.UnregisterPageFlushObserver(ctrl)
 				return nil, err
 			}
 			return ctrl, nil
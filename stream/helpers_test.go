@@ -0,0 +1,96 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/delaneyj/witchbolt"
+)
+
+// openReplicatedDB opens a fresh database with a controller replicating to a
+// file replica rooted in a temporary directory.
+func openReplicatedDB(t *testing.T, cfg Config) (*witchbolt.DB, *Controller, string) {
+	t.Helper()
+	dir := t.TempDir()
+	db, err := witchbolt.Open(filepath.Join(dir, "db"), 0o600, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	replicaPath := filepath.Join(dir, "replica")
+	if cfg.ShadowDir == "" {
+		cfg.ShadowDir = filepath.Join(dir, "shadow")
+	}
+	if len(cfg.Replicas) == 0 {
+		cfg.Replicas = []ReplicaConfig{&FileReplicaConfig{Path: replicaPath}}
+	}
+	ctrl, err := Enable(context.Background(), db, cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = ctrl.Stop(context.Background()) })
+	return db, ctrl, replicaPath
+}
+
+// putKeys writes n keys into the named bucket, one transaction per key.
+func putKeys(t *testing.T, db *witchbolt.DB, bucket string, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		err := db.Update(func(tx *witchbolt.Tx) error {
+			b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+			if err != nil {
+				return err
+			}
+			return b.Put([]byte(fmt.Sprintf("key-%04d", i)), []byte(fmt.Sprintf("value-%04d", i)))
+		})
+		require.NoError(t, err)
+	}
+}
+
+// requireRestoredMatches asserts that the database restored at path reached
+// the TxID of source and holds exactly its buckets, keys and values.
+func requireRestoredMatches(t *testing.T, source *witchbolt.DB, path string) {
+	t.Helper()
+	var wantTxID int
+	var want map[string]string
+	require.NoError(t, source.View(func(tx *witchbolt.Tx) error {
+		wantTxID, want = tx.ID(), dumpTx(t, tx)
+		return nil
+	}))
+
+	restored, err := witchbolt.Open(path, 0o600, &witchbolt.Options{ReadOnly: true})
+	require.NoError(t, err)
+	defer restored.Close()
+	require.NoError(t, restored.View(func(tx *witchbolt.Tx) error {
+		require.Equal(t, wantTxID, tx.ID(), "the restore reaches the source's TxID")
+		require.Equal(t, want, dumpTx(t, tx))
+		return nil
+	}))
+}
+
+// dumpTx returns every key of tx keyed by its bucket path, with nested
+// buckets mapped to "<bucket>".
+func dumpTx(t *testing.T, tx *witchbolt.Tx) map[string]string {
+	t.Helper()
+	out := make(map[string]string)
+	var walk func(prefix string, b *witchbolt.Bucket)
+	walk = func(prefix string, b *witchbolt.Bucket) {
+		require.NoError(t, b.ForEach(func(k, v []byte) error {
+			path := prefix + "/" + string(k)
+			if v == nil {
+				out[path] = "<bucket>"
+				walk(path, b.Bucket(k))
+				return nil
+			}
+			out[path] = string(v)
+			return nil
+		}))
+	}
+	require.NoError(t, tx.ForEach(func(name []byte, b *witchbolt.Bucket) error {
+		out[string(name)] = "<bucket>"
+		walk(string(name), b)
+		return nil
+	}))
+	return out
+}
@@ -0,0 +1,93 @@
+package stream
+
+import (
+	"crypto/rand"
+	"fmt"
+
+	"github.com/delaneyj/witchbolt"
+)
+
+// IdentityBucket is the reserved bucket holding the database identity. It
+// lives in the database itself, so snapshots and restores carry it along.
+// Tools that list a database's buckets should skip it.
+const IdentityBucket = "__witchbolt_stream__"
+
+var identityKey = []byte("databaseId")
+
+// loadDatabaseID returns the identity stored in db, creating a random UUID
+// the first time a writable database is replicated. A read-only database
+// without one cannot store it and gets the empty identity, which restores
+// accept like that of artefacts written before identities were recorded.
+func loadDatabaseID(db *witchbolt.DB) (string, error) {
+	var id string
+	err := db.View(func(tx *witchbolt.Tx) error {
+		if b := tx.Bucket([]byte(IdentityBucket)); b != nil {
+			id = string(b.Get(identityKey))
+		}
+		return nil
+	})
+	if err != nil || id != "" {
+		return id, err
+	}
+	if db.IsReadOnly() {
+		return "", nil
+	}
+	id, err = newDatabaseID()
+	if err != nil {
+		return "", err
+	}
+	err = db.Update(func(tx *witchbolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(IdentityBucket))
+		if err != nil {
+			return err
+		}
+		if existing := b.Get(identityKey); existing != nil {
+			id = string(existing)
+			return nil
+		}
+		return b.Put(identityKey, []byte(id))
+	})
+	if err != nil {
+		return "", fmt.Errorf("store database identity: %w", err)
+	}
+	return id, nil
+}
+
+func newDatabaseID() (string, error) {
+	var buf [16]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return "", fmt.Errorf("generate database identity: %w", err)
+	}
+	buf[6] = buf[6]&0x0f | 0x40 // version 4
+	buf[8] = buf[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", buf[0:4], buf[4:6], buf[6:8], buf[8:10], buf[10:]), nil
+}
+
+// DatabaseMismatchError reports a segment recorded for a different database
+// than the snapshot it would be applied to, as happens when two databases
+// share a replica path or prefix.
+type DatabaseMismatchError struct {
+	// DatabaseID is the identity recorded in the snapshot.
+	DatabaseID string
+	// Segment is the TxID of the offending segment.
+	Segment uint64
+	// SegmentDatabaseID is the identity recorded in that segment's header.
+	SegmentDatabaseID string
+}
+
+func (e *DatabaseMismatchError) Error() string {
+	return fmt.Sprintf("stream: database mismatch: segment %016x belongs to database %s, snapshot to %s", e.Segment, e.SegmentDatabaseID, e.DatabaseID)
+}
+
+// checkDatabaseIdentity confirms every segment was recorded for the
+// snapshot's database. Artefacts written before identities were recorded
+// carry none and are accepted.
+func checkDatabaseIdentity(snapshot *Snapshot, segments []*Segment) error {
+	id := snapshot.Header.DatabaseID
+	for _, segment := range segments {
+		if id != "" && segment.Header.DatabaseID != "" && segment.Header.DatabaseID != id {
+			return &DatabaseMismatchError{DatabaseID: id, Segment: segment.Header.TxID, SegmentDatabaseID: segment.Header.DatabaseID}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package stream
+
+import "time"
+
+// Metrics receives replication measurements from a Controller. Methods are
+// called synchronously from the replication path, so implementations must be
+// safe for concurrent use and return quickly. The streamprom package provides
+// a Prometheus implementation; leaving Config.Metrics nil disables metrics.
+type Metrics interface {
+	// SegmentUploaded records a segment of size bytes accepted by replica.
+	SegmentUploaded(replica string, size int)
+	// SnapshotUploaded records a snapshot of size bytes accepted by replica.
+	SnapshotUploaded(replica string, size int)
+	// UploadFailed records a failed upload of kind ("segment" or "snapshot").
+	UploadFailed(replica string, kind string)
+	// PruneCompleted records a retention pass over replica; err is nil on success.
+	PruneCompleted(replica string, err error)
+	// DataLossWindow reports the controller's current worst-case lag.
+	DataLossWindow(window time.Duration)
+}
+
+type noopMetrics struct{}
+
+func (noopMetrics) SegmentUploaded(string, int)  {}
+func (noopMetrics) SnapshotUploaded(string, int) {}
+func (noopMetrics) UploadFailed(string, string)  {}
+func (noopMetrics) PruneCompleted(string, error) {}
+func (noopMetrics) DataLossWindow(time.Duration) {}
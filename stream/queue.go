@@ -0,0 +1,88 @@
+package stream
+
+import (
+	"errors"
+	"time"
+
+	"github.com/delaneyj/witchbolt"
+)
+
+// defaultReplicationQueueDepth is the AsyncReplication queue size when
+// Config.ReplicationQueueDepth is unset.
+const defaultReplicationQueueDepth = 64
+
+// ErrReplicationQueueFull is returned by OnPageFlush when the
+// AsyncReplication queue is full and ReplicationOverflow is OverflowDrop.
+var ErrReplicationQueueFull = errors.New("stream: replication queue full, segment dropped")
+
+// queuedSegment is a built segment waiting for the replication loop.
+type queuedSegment struct {
+	info     witchbolt.PageFlushInfo
+	segment  *Segment
+	queuedAt time.Time
+}
+
+// enqueueSegment hands segment to the replication loop. Once Stop has closed
+// the queue it waits for the loop to drain and uploads synchronously instead.
+func (c *Controller) enqueueSegment(info witchbolt.PageFlushInfo, segment *Segment) error {
+	info.Frames = nil // the segment holds its own copy
+	item := queuedSegment{info: info, segment: segment, queuedAt: time.Now()}
+
+	c.queueMu.RLock()
+	if c.queueClosed {
+		c.queueMu.RUnlock()
+		c.wg.Wait()
+		return c.persistSegment(info, segment)
+	}
+	defer c.queueMu.RUnlock()
+
+	if c.config.ReplicationOverflow != OverflowDrop {
+		c.queue <- item
+		return nil
+	}
+	select {
+	case c.queue <- item:
+		return nil
+	default:
+		c.mu.Lock()
+		c.resnapshot = true
+		c.mu.Unlock()
+		return ErrReplicationQueueFull
+	}
+}
+
+// replicationLoop uploads queued segments in commit order until Stop, then
+// drains whatever is left.
+func (c *Controller) replicationLoop() {
+	defer c.wg.Done()
+	for {
+		select {
+		case item := <-c.queue:
+			c.replicateQueued(item)
+		case <-c.closeCh:
+			for {
+				select {
+				case item := <-c.queue:
+					c.replicateQueued(item)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (c *Controller) replicateQueued(item queuedSegment) {
+	c.mu.Lock()
+	c.pendingSince = item.queuedAt
+	c.mu.Unlock()
+
+	err := c.persistSegment(item.info, item.segment)
+
+	c.mu.Lock()
+	c.pendingSince = time.Time{}
+	c.mu.Unlock()
+	if err != nil {
+		c.db.Logger().Errorf("stream: replicate segment %016x: %v", item.info.TxID, err)
+	}
+}
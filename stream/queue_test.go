@@ -0,0 +1,117 @@
+package stream
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/delaneyj/witchbolt"
+)
+
+// gatedReplica holds every segment upload until gate is closed, signalling
+// entered as each upload starts.
+type gatedReplica struct {
+	Replica
+	gate    chan struct{}
+	entered chan struct{}
+}
+
+func (r *gatedReplica) PutSegment(ctx context.Context, generation string, segment *Segment) error {
+	select {
+	case r.entered <- struct{}{}:
+	default:
+	}
+	<-r.gate
+	return r.Replica.PutSegment(ctx, generation, segment)
+}
+
+// startAsyncController replicates a new database to a gated file replica with
+// AsyncReplication enabled.
+func startAsyncController(t *testing.T, cfg Config) (*witchbolt.DB, *Controller, *gatedReplica, string) {
+	t.Helper()
+	dir := t.TempDir()
+	db, err := witchbolt.Open(filepath.Join(dir, "db"), 0o600, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	replicaPath := filepath.Join(dir, "replica")
+	file, err := NewFileReplica(&FileReplicaConfig{Path: replicaPath})
+	require.NoError(t, err)
+	replica := &gatedReplica{Replica: file, gate: make(chan struct{}), entered: make(chan struct{}, 16)}
+
+	cfg.ShadowDir = filepath.Join(dir, "shadow")
+	cfg.AsyncReplication = true
+	ctrl, err := NewController(db, cfg, []Replica{replica})
+	require.NoError(t, err)
+	db.RegisterPageFlushObserver(ctrl)
+	require.NoError(t, ctrl.Start(context.Background()))
+	return db, ctrl, replica, replicaPath
+}
+
+func TestControllerAsyncReplication(t *testing.T) {
+	db, ctrl, replica, replicaPath := startAsyncController(t, Config{ReplicationQueueDepth: 8})
+
+	t.Log("Commits return while the replica is stalled")
+	putKeys(t, db, "widgets", 4)
+	<-replica.entered
+	require.Eventually(t, func() bool { return ctrl.Status().QueuedSegments == 3 }, 5*time.Second, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	require.GreaterOrEqual(t, ctrl.DataLossWindow(), 20*time.Millisecond, "the backlog counts toward the data loss window")
+
+	t.Log("Stop drains the queue")
+	close(replica.gate)
+	require.NoError(t, ctrl.Stop(context.Background()))
+	require.Zero(t, ctrl.Status().QueuedSegments)
+
+	var txid uint64
+	require.NoError(t, db.View(func(tx *witchbolt.Tx) error {
+		txid = uint64(tx.ID())
+		return nil
+	}))
+	require.Equal(t, txid, ctrl.Status().LastTxID)
+	target := filepath.Join(t.TempDir(), "restored.db")
+	require.NoError(t, RestoreStandalone(context.Background(), Config{
+		Replicas: []ReplicaConfig{&FileReplicaConfig{Path: replicaPath}},
+		Restore:  RestoreConfig{TargetPath: target},
+	}))
+	requireKeys(t, target, "widgets", 4)
+}
+
+func TestControllerAsyncReplicationDrop(t *testing.T) {
+	db, ctrl, replica, replicaPath := startAsyncController(t, Config{
+		ReplicationQueueDepth: 1,
+		ReplicationOverflow:   OverflowDrop,
+	})
+
+	putKeys(t, db, "widgets", 1)
+	<-replica.entered
+	putKeys(t, db, "gadgets", 1)
+	require.Equal(t, 1, ctrl.Status().QueuedSegments)
+
+	t.Log("A full queue drops segments instead of blocking")
+	require.ErrorIs(t, ctrl.OnPageFlush(witchbolt.PageFlushInfo{TxID: 100, ParentTxID: 99, PageSize: 4096}), ErrReplicationQueueFull)
+	putKeys(t, db, "gizmos", 2)
+
+	t.Log("The controller snapshots once the queue catches up")
+	close(replica.gate)
+	require.NoError(t, ctrl.Stop(context.Background()))
+	target := filepath.Join(t.TempDir(), "restored.db")
+	require.NoError(t, RestoreStandalone(context.Background(), Config{
+		Replicas: []ReplicaConfig{&FileReplicaConfig{Path: replicaPath}},
+		Restore:  RestoreConfig{TargetPath: target},
+	}))
+	requireKeys(t, target, "widgets", 1)
+	requireKeys(t, target, "gadgets", 1)
+	requireKeys(t, target, "gizmos", 2)
+}
+
+func TestNewControllerRejectsUnknownOverflowPolicy(t *testing.T) {
+	db, err := witchbolt.Open(filepath.Join(t.TempDir(), "db"), 0o600, nil)
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = NewController(db, Config{ShadowDir: t.TempDir(), ReplicationOverflow: "spill"}, nil)
+	require.ErrorContains(t, err, `unknown replication overflow policy "spill"`)
+}
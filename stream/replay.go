@@ -0,0 +1,80 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// ReplayReplica rebuilds the database at path from the latest snapshot of
+// the replica's generation and applies its segments one at a time, in TxID
+// order, so a caller can inspect every transaction of the chain.
+//
+// fn is called once the snapshot is written, with its TxID and no pages, and
+// again after each segment, with the segment's TxID and the ids of the pages
+// its frames wrote. While fn runs, path holds the database as of that TxID
+// and can be opened read-only. A gap in the chain is reported as a
+// *SegmentGapError before anything is written.
+func ReplayReplica(ctx context.Context, replica Replica, path string, fn func(txid uint64, pages []uint64) error) error {
+	state, err := replica.LatestState(ctx)
+	if err != nil {
+		return fmt.Errorf("read state from %s: %w", replica.Name(), err)
+	}
+	if state == nil || state.Snapshot == nil {
+		return fmt.Errorf("stream: replica %s has no snapshot to replay", replica.Name())
+	}
+	snapshot, err := replica.FetchSnapshot(ctx, state.Generation, state.Snapshot)
+	if err == nil {
+		err = verifySnapshotChecksum(snapshot)
+	}
+	if err != nil {
+		return fmt.Errorf("fetch snapshot from %s: %w", replica.Name(), err)
+	}
+	segments, err := fetchSegments(ctx, replica, state.Generation, state.Segments, 0)
+	if err != nil {
+		return fmt.Errorf("fetch segment from %s: %w", replica.Name(), err)
+	}
+	if err := checkDatabaseIdentity(snapshot, segments); err != nil {
+		return err
+	}
+	if err := checkSegmentChain(snapshot, segments); err != nil {
+		return err
+	}
+
+	if err := writeRestoreSnapshot(path, snapshot, nil); err != nil {
+		return err
+	}
+	if err := fn(snapshot.Header.TxID, nil); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	pageSize := snapshot.Header.PageSize
+	for _, segment := range segments {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := applySegment(f, pageSize, segment); err != nil {
+			return err
+		}
+		if err := f.Sync(); err != nil {
+			return err
+		}
+		var pages []uint64
+		for _, frame := range segment.Pages {
+			// A frame of an overflowing page covers its overflow pages too.
+			for i := 0; i == 0 || i < len(frame.Data)/pageSize; i++ {
+				pages = append(pages, frame.ID+uint64(i))
+			}
+		}
+		if err := fn(segment.Header.TxID, pages); err != nil {
+			return err
+		}
+	}
+	return nil
+}
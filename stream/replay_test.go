@@ -0,0 +1,62 @@
+package stream
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/delaneyj/witchbolt"
+)
+
+func TestReplayReplica(t *testing.T) {
+	db, _, replicaPath := openReplicatedDB(t, Config{})
+	putKeys(t, db, "widgets", 3)
+
+	replica, err := NewFileReplica(&FileReplicaConfig{Path: replicaPath})
+	require.NoError(t, err)
+	state, err := replica.LatestState(context.Background())
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, len(state.Segments), 2)
+
+	path := filepath.Join(t.TempDir(), "replay.db")
+	var txids []uint64
+	err = ReplayReplica(context.Background(), replica, path, func(txid uint64, pages []uint64) error {
+		if len(txids) == 0 {
+			require.Empty(t, pages, "the snapshot wrote no segment pages")
+		} else {
+			require.NotEmpty(t, pages)
+		}
+		txids = append(txids, txid)
+
+		replayed, err := witchbolt.Open(path, 0o600, &witchbolt.Options{ReadOnly: true})
+		require.NoError(t, err)
+		defer replayed.Close()
+		return replayed.View(func(tx *witchbolt.Tx) error {
+			require.Equal(t, int(txid), tx.ID(), "the database is at the replayed TxID")
+			return nil
+		})
+	})
+	require.NoError(t, err)
+
+	var want []uint64
+	for _, desc := range state.Segments {
+		want = append(want, desc.LastTxID)
+	}
+	require.Equal(t, state.Segments[0].FirstTxID-1, txids[0], "the chain starts at the snapshot")
+	require.Equal(t, want, txids[1:])
+	requireRestoredMatches(t, db, path)
+
+	t.Log("A gap in the chain fails the replay")
+	gapped := *state
+	gapped.Segments = slices.Delete(slices.Clone(state.Segments), 0, 1)
+	require.NoError(t, replica.writeState(&gapped))
+	var gap *SegmentGapError
+	err = ReplayReplica(context.Background(), replica, filepath.Join(t.TempDir(), "gapped.db"), func(uint64, []uint64) error {
+		t.Fatal("fn must not run for a gapped chain")
+		return nil
+	})
+	require.ErrorAs(t, err, &gap)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"path"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -31,6 +32,25 @@ type Replica interface {
 	// LatestState returns the newest generation snapshot metadata for restores.
 	LatestState(ctx context.Context) (*RestoreState, error)
 
+	// ListGenerations summarises every generation stored on the replica,
+	// including ones the state manifest no longer references.
+	ListGenerations(ctx context.Context) ([]GenerationInfo, error)
+
+	// DeleteGeneration removes every snapshot and segment stored for
+	// generation. If the state manifest references generation it is cleared
+	// first, leaving the replica without a restore point until the next
+	// snapshot.
+	DeleteGeneration(ctx context.Context, generation string) error
+
+	// ReplaceSegments stores merged in place of replaced, a contiguous run of
+	// segments from the state manifest, then deletes the originals. merged
+	// takes the run's position in the manifest.
+	ReplaceSegments(ctx context.Context, generation string, merged *Segment, replaced []SegmentDescriptor) error
+
+	// HealthCheck verifies the destination is reachable and usable without
+	// modifying it. A replica that has not been written to yet is healthy.
+	HealthCheck(ctx context.Context) error
+
 	// Close releases any held resources.
 	Close(ctx context.Context) error
 }
@@ -59,6 +79,75 @@ type SegmentDescriptor struct {
 	Size      int64
 }
 
+// GenerationInfo summarises the artefacts a replica stores for one
+// generation.
+type GenerationInfo struct {
+	ID        string
+	Snapshots int
+	Segments  int
+	// MinTxID and MaxTxID bound the TxIDs of the generation's snapshots and
+	// segments.
+	MinTxID uint64
+	MaxTxID uint64
+}
+
+// generationLister builds GenerationInfo from artefact names relative to the
+// replica root, such as "<generation>/segments/<txid>.segment.cbor".
+type generationLister map[string]*GenerationInfo
+
+func (l generationLister) add(name string) {
+	parts := strings.Split(name, "/")
+	if len(parts) != 3 {
+		return
+	}
+	var txid uint64
+	var err error
+	switch parts[1] {
+	case "snapshots":
+		if !strings.HasSuffix(parts[2], ".snapshot.cbor") {
+			return
+		}
+		_, txid, err = parseSnapshotObject(parts[2])
+	case "segments":
+		if !strings.HasSuffix(parts[2], ".segment.cbor") {
+			return
+		}
+		txid, err = parseSegmentObject(parts[2])
+	default:
+		return
+	}
+	if err != nil {
+		return
+	}
+	info, ok := l[parts[0]]
+	if !ok {
+		info = &GenerationInfo{ID: parts[0], MinTxID: txid, MaxTxID: txid}
+		l[parts[0]] = info
+	}
+	if parts[1] == "snapshots" {
+		info.Snapshots++
+	} else {
+		info.Segments++
+	}
+	info.MinTxID = min(info.MinTxID, txid)
+	info.MaxTxID = max(info.MaxTxID, txid)
+}
+
+// list returns the generations ordered by TxID range, oldest first.
+func (l generationLister) list() []GenerationInfo {
+	infos := make([]GenerationInfo, 0, len(l))
+	for _, info := range l {
+		infos = append(infos, *info)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].MaxTxID != infos[j].MaxTxID {
+			return infos[i].MaxTxID < infos[j].MaxTxID
+		}
+		return infos[i].ID < infos[j].ID
+	})
+	return infos
+}
+
 func snapshotObjectName(generation string, created time.Time, txid uint64) string {
 	return path.Join(generation, "snapshots", fmt.Sprintf("%s-%016x.snapshot.cbor", created.Format(time.RFC3339Nano), txid))
 }
@@ -67,6 +156,37 @@ func segmentObjectName(generation string, txid uint64) string {
 	return path.Join(generation, "segments", fmt.Sprintf("%016x.segment.cbor", txid))
 }
 
+// replaceSegmentDescriptors swaps the replaced run in state for merged,
+// keeping any segments appended since the run was read. It reports false when
+// state no longer lists the run, for example after a newer snapshot.
+func replaceSegmentDescriptors(state *RestoreState, generation string, merged SegmentDescriptor, replaced []SegmentDescriptor) bool {
+	if state.Generation != generation || len(replaced) == 0 {
+		return false
+	}
+	drop := make(map[string]bool, len(replaced))
+	for _, desc := range replaced {
+		drop[desc.Name] = true
+	}
+	segments := make([]SegmentDescriptor, 0, len(state.Segments))
+	found := false
+	for _, desc := range state.Segments {
+		if !drop[desc.Name] {
+			segments = append(segments, desc)
+			continue
+		}
+		if !found {
+			segments = append(segments, merged)
+			found = true
+		}
+	}
+	if !found {
+		return false
+	}
+	state.Segments = segments
+	state.LastUploaded = time.Now().UTC()
+	return true
+}
+
 func parseSnapshotObject(name string) (time.Time, uint64, error) {
 	parts := strings.Split(name, "-")
 	if len(parts) < 2 {
@@ -0,0 +1,314 @@
+package stream
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrBlobNotFound is returned, possibly wrapped, by BlobStore.Get for a key
+// that holds no object.
+var ErrBlobNotFound = errors.New("stream: blob not found")
+
+// BlobStore is the minimal object store a custom backend implements to be
+// used through NewBlobStoreReplica. Keys are slash-separated and relative to
+// the store, for example "<generation>/segments/<txid>.segment.cbor".
+type BlobStore interface {
+	// Put stores size bytes read from body under key, replacing any object
+	// already there.
+	Put(ctx context.Context, key string, body io.Reader, size int64) error
+	// Get returns the object stored under key, or an error wrapping
+	// ErrBlobNotFound.
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Delete removes the object stored under key. Deleting a missing key is
+	// not an error.
+	Delete(ctx context.Context, key string) error
+	// List returns every key beginning with prefix, in any order.
+	List(ctx context.Context, prefix string) ([]string, error)
+}
+
+// BlobStoreReplica adapts a BlobStore to Replica, with the same object layout
+// and state manifest as the built-in replicas. It is not built from Config;
+// pass it to NewController directly.
+type BlobStoreReplica struct {
+	name  string
+	store BlobStore
+	mu    sync.Mutex
+}
+
+// NewBlobStoreReplica returns a replica storing artefacts in store, reported
+// as name in logs and metrics.
+func NewBlobStoreReplica(name string, store BlobStore) *BlobStoreReplica {
+	return &BlobStoreReplica{name: name, store: store}
+}
+
+// Name implements Replica.
+func (r *BlobStoreReplica) Name() string { return r.name }
+
+// Close implements Replica. The store is owned by the caller and left open.
+func (r *BlobStoreReplica) Close(context.Context) error { return nil }
+
+// PutSnapshot uploads the snapshot artefact and updates replica state.
+func (r *BlobStoreReplica) PutSnapshot(ctx context.Context, generation string, snapshot *Snapshot) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	name := snapshotObjectName(generation, snapshot.createdAt(), snapshot.Header.TxID)
+	body, size, err := snapshotReader(snapshot)
+	if err != nil {
+		return err
+	}
+	if err := r.store.Put(ctx, name, body, size); err != nil {
+		return err
+	}
+	desc := SnapshotDescriptor{Name: name, Timestamp: snapshot.createdAt(), Size: int64(len(snapshot.Data))}
+	return r.updateState(ctx, generation, &desc, nil)
+}
+
+// PutSegment uploads the segment artefact and appends it to replica state.
+func (r *BlobStoreReplica) PutSegment(ctx context.Context, generation string, segment *Segment) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	desc, err := r.putSegment(ctx, generation, segment)
+	if err != nil {
+		return err
+	}
+	return r.updateState(ctx, generation, nil, &desc)
+}
+
+// ReplaceSegments uploads merged, swaps it into the state manifest in place of
+// replaced and deletes the original segments.
+func (r *BlobStoreReplica) ReplaceSegments(ctx context.Context, generation string, merged *Segment, replaced []SegmentDescriptor) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	desc, err := r.putSegment(ctx, generation, merged)
+	if err != nil {
+		return err
+	}
+	if err := r.replaceState(ctx, generation, desc, replaced); err != nil {
+		return err
+	}
+	for _, old := range replaced {
+		if old.Name == desc.Name {
+			continue
+		}
+		if err := r.store.Delete(ctx, old.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *BlobStoreReplica) putSegment(ctx context.Context, generation string, segment *Segment) (SegmentDescriptor, error) {
+	name := segmentObjectName(generation, segment.Header.TxID)
+	encoded, err := marshalSegment(segment)
+	if err != nil {
+		return SegmentDescriptor{}, err
+	}
+	if err := r.store.Put(ctx, name, bytes.NewReader(encoded), int64(len(encoded))); err != nil {
+		return SegmentDescriptor{}, err
+	}
+	return SegmentDescriptor{
+		Name:      name,
+		FirstTxID: segment.Header.ParentTxID + 1,
+		LastTxID:  segment.Header.TxID,
+		Timestamp: segment.Header.CreatedAt,
+		Size:      int64(len(segment.Data)),
+	}, nil
+}
+
+// Prune deletes snapshots older than the retention window, always keeping the
+// newest, along with the segments covered by the oldest retained snapshot.
+func (r *BlobStoreReplica) Prune(ctx context.Context, generation string, retention RetentionConfig) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if retention.SnapshotRetention <= 0 {
+		return nil
+	}
+	cutoff := time.Now().Add(-retention.SnapshotRetention)
+	keys, err := r.store.List(ctx, path.Join(generation, "snapshots")+"/")
+	if err != nil {
+		return err
+	}
+	type snapInfo struct {
+		key     string
+		created time.Time
+		txid    uint64
+	}
+	var snaps []snapInfo
+	for _, key := range keys {
+		created, txid, err := parseSnapshotObject(path.Base(key))
+		if err != nil {
+			continue
+		}
+		snaps = append(snaps, snapInfo{key: key, created: created, txid: txid})
+	}
+	if len(snaps) == 0 {
+		return nil
+	}
+	sort.Slice(snaps, func(i, j int) bool { return snaps[i].created.After(snaps[j].created) })
+
+	keepTxID := snaps[0].txid
+	for i, snap := range snaps {
+		if i == 0 || snap.created.After(cutoff) {
+			keepTxID = snap.txid
+			continue
+		}
+		if err := r.store.Delete(ctx, snap.key); err != nil {
+			return err
+		}
+	}
+
+	keys, err = r.store.List(ctx, path.Join(generation, "segments")+"/")
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		txid, err := parseSegmentObject(path.Base(key))
+		if err != nil {
+			continue
+		}
+		if txid <= keepTxID {
+			if err := r.store.Delete(ctx, key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// FetchSnapshot downloads and decodes a snapshot artefact.
+func (r *BlobStoreReplica) FetchSnapshot(ctx context.Context, generation string, desc *SnapshotDescriptor) (*Snapshot, error) {
+	data, err := r.store.Get(ctx, desc.Name)
+	if err != nil {
+		return nil, err
+	}
+	return decodeSnapshotFile(data)
+}
+
+// FetchSegment downloads and decodes a segment artefact.
+func (r *BlobStoreReplica) FetchSegment(ctx context.Context, generation string, desc SegmentDescriptor) (*Segment, error) {
+	data, err := r.store.Get(ctx, desc.Name)
+	if err != nil {
+		return nil, err
+	}
+	return decodeSegmentFile(data)
+}
+
+// LatestState retrieves the replica state manifest. A store without one has
+// an empty state.
+func (r *BlobStoreReplica) LatestState(ctx context.Context) (*RestoreState, error) {
+	data, err := r.store.Get(ctx, stateFileName)
+	if err != nil {
+		if errors.Is(err, ErrBlobNotFound) {
+			return &RestoreState{}, nil
+		}
+		return nil, err
+	}
+	var state RestoreState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// ListGenerations summarises every generation stored in the blob store.
+func (r *BlobStoreReplica) ListGenerations(ctx context.Context) ([]GenerationInfo, error) {
+	keys, err := r.store.List(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	lister := generationLister{}
+	for _, key := range keys {
+		lister.add(key)
+	}
+	return lister.list(), nil
+}
+
+// DeleteGeneration removes every object below the generation, clearing the
+// state manifest first if it references generation.
+func (r *BlobStoreReplica) DeleteGeneration(ctx context.Context, generation string) error {
+	if err := checkGenerationID(generation); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	state, err := r.LatestState(ctx)
+	if err == nil && state.Generation == generation {
+		err = r.store.Delete(ctx, stateFileName)
+	}
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	keys, err := r.store.List(ctx, generation+"/")
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := r.store.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HealthCheck reads the state manifest. A store without one is healthy
+// because it has not been written to yet.
+func (r *BlobStoreReplica) HealthCheck(ctx context.Context) error {
+	if _, err := r.LatestState(ctx); err != nil {
+		return fmt.Errorf("read state manifest: %w", err)
+	}
+	return nil
+}
+
+func (r *BlobStoreReplica) updateState(ctx context.Context, generation string, snapshot *SnapshotDescriptor, segment *SegmentDescriptor) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	state, err := r.LatestState(ctx)
+	if err != nil {
+		return err
+	}
+	if state.Generation != generation {
+		state = &RestoreState{Generation: generation}
+	}
+	if snapshot != nil {
+		state.Snapshot = snapshot
+		state.Segments = nil
+	}
+	if segment != nil {
+		state.Segments = append(state.Segments, *segment)
+	}
+	state.LastUploaded = time.Now().UTC()
+	return r.writeState(ctx, state)
+}
+
+func (r *BlobStoreReplica) replaceState(ctx context.Context, generation string, merged SegmentDescriptor, replaced []SegmentDescriptor) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	state, err := r.LatestState(ctx)
+	if err != nil {
+		return err
+	}
+	if !replaceSegmentDescriptors(state, generation, merged, replaced) {
+		return nil
+	}
+	return r.writeState(ctx, state)
+}
+
+func (r *BlobStoreReplica) writeState(ctx context.Context, state *RestoreState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return r.store.Put(ctx, stateFileName, bytes.NewReader(data), int64(len(data)))
+}
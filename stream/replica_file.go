@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -13,18 +14,52 @@ import (
 	"time"
 )
 
-const stateFileName = "_state.json"
+const (
+	stateFileName = "_state.json"
+
+	defaultReplicaDirMode  os.FileMode = 0o755
+	defaultReplicaFileMode os.FileMode = 0o644
+)
 
 // FileReplica persists artefacts to the local filesystem.
 type FileReplica struct {
 	name     string
 	basePath string
+	opts     ReplicaOptions
+	dirMode  os.FileMode
+	fileMode os.FileMode
 	mu       sync.Mutex
 }
 
 // FileReplicaConfig defines the local filesystem replica behaviour.
 type FileReplicaConfig struct {
+	ReplicaOptions
 	Path string `json:"path"`
+	// DirMode is applied to directories the replica creates. Defaults to 0755.
+	DirMode os.FileMode `json:"dirMode"`
+	// FileMode is applied to artefacts and the state manifest. Defaults to 0644.
+	FileMode os.FileMode `json:"fileMode"`
+}
+
+// UnmarshalJSON decodes the config, accepting octal strings such as "0775"
+// for the permission fields.
+func (cfg *FileReplicaConfig) UnmarshalJSON(data []byte) error {
+	type alias FileReplicaConfig
+	aux := struct {
+		*alias
+		DirMode  jsonFileMode `json:"dirMode"`
+		FileMode jsonFileMode `json:"fileMode"`
+	}{
+		alias:    (*alias)(cfg),
+		DirMode:  jsonFileMode(cfg.DirMode),
+		FileMode: jsonFileMode(cfg.FileMode),
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	cfg.DirMode = os.FileMode(aux.DirMode)
+	cfg.FileMode = os.FileMode(aux.FileMode)
+	return nil
 }
 
 func (cfg *FileReplicaConfig) buildReplica(_ context.Context) (Replica, error) {
@@ -34,21 +69,38 @@ func (cfg *FileReplicaConfig) buildReplica(_ context.Context) (Replica, error) {
 	return NewFileReplica(cfg)
 }
 
-// NewFileReplica constructs a FileReplica backed by a directory tree.
-func NewFileReplica(cfg *FileReplicaConfig) (*FileReplica, error) {
+func (cfg *FileReplicaConfig) validate() error {
 	if cfg == nil {
-		return nil, fmt.Errorf("file replica config is nil")
+		return fmt.Errorf("file replica config is nil")
 	}
 	if cfg.Path == "" {
-		return nil, fmt.Errorf("file replica path is empty")
+		return fmt.Errorf("file replica path is empty")
 	}
-	if err := os.MkdirAll(cfg.Path, 0o755); err != nil {
+	return nil
+}
+
+// NewFileReplica constructs a FileReplica backed by a directory tree.
+func NewFileReplica(cfg *FileReplicaConfig) (*FileReplica, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	dirMode, fileMode := cfg.DirMode.Perm(), cfg.FileMode.Perm()
+	if dirMode == 0 {
+		dirMode = defaultReplicaDirMode
+	}
+	if fileMode == 0 {
+		fileMode = defaultReplicaFileMode
+	}
+	if err := mkdirAllMode(cfg.Path, dirMode); err != nil {
 		return nil, fmt.Errorf("create replica path: %w", err)
 	}
 	replicaName := cfg.Path
 	return &FileReplica{
 		name:     replicaName,
 		basePath: cfg.Path,
+		opts:     cfg.ReplicaOptions,
+		dirMode:  dirMode,
+		fileMode: fileMode,
 	}, nil
 }
 
@@ -57,6 +109,8 @@ func (r *FileReplica) Name() string {
 	return r.name
 }
 
+func (r *FileReplica) replicaOptions() ReplicaOptions { return r.opts }
+
 // PutSnapshot writes the snapshot payload and updates replica state.
 func (r *FileReplica) PutSnapshot(ctx context.Context, generation string, snapshot *Snapshot) error {
 	select {
@@ -65,16 +119,16 @@ func (r *FileReplica) PutSnapshot(ctx context.Context, generation string, snapsh
 	default:
 	}
 	dir := filepath.Join(r.basePath, generation, "snapshots")
-	if err := os.MkdirAll(dir, 0o755); err != nil {
+	if err := mkdirAllMode(dir, r.dirMode); err != nil {
 		return fmt.Errorf("create snapshot dir: %w", err)
 	}
-	filename := fmt.Sprintf("%s-%016x.snapshot.cbor", snapshot.Header.CreatedAt.Format(time.RFC3339Nano), snapshot.Header.TxID)
-	if err := writeSnapshotFile(filepath.Join(dir, filename), snapshot); err != nil {
+	filename := fmt.Sprintf("%s-%016x.snapshot.cbor", snapshot.createdAt().Format(time.RFC3339Nano), snapshot.Header.TxID)
+	if err := writeSnapshotFile(filepath.Join(dir, filename), snapshot, r.fileMode); err != nil {
 		return err
 	}
 	desc := SnapshotDescriptor{
 		Name:      filepath.ToSlash(filepath.Join(generation, "snapshots", filename)),
-		Timestamp: snapshot.Header.CreatedAt,
+		Timestamp: snapshot.createdAt(),
 		Size:      int64(len(snapshot.Data)),
 	}
 	return r.updateState(&RestoreState{
@@ -93,11 +147,11 @@ func (r *FileReplica) PutSegment(ctx context.Context, generation string, segment
 	default:
 	}
 	dir := filepath.Join(r.basePath, generation, "segments")
-	if err := os.MkdirAll(dir, 0o755); err != nil {
+	if err := mkdirAllMode(dir, r.dirMode); err != nil {
 		return fmt.Errorf("create segment dir: %w", err)
 	}
 	filename := fmt.Sprintf("%016x.segment.cbor", segment.Header.TxID)
-	if err := writeSegmentFile(filepath.Join(dir, filename), segment); err != nil {
+	if err := writeSegmentFile(filepath.Join(dir, filename), segment, r.fileMode); err != nil {
 		return err
 	}
 	desc := SegmentDescriptor{
@@ -110,6 +164,47 @@ func (r *FileReplica) PutSegment(ctx context.Context, generation string, segment
 	return r.appendSegment(generation, desc)
 }
 
+// ReplaceSegments writes merged, swaps it into the state manifest in place of
+// replaced and removes the original segment files.
+func (r *FileReplica) ReplaceSegments(ctx context.Context, generation string, merged *Segment, replaced []SegmentDescriptor) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	dir := filepath.Join(r.basePath, generation, "segments")
+	if err := mkdirAllMode(dir, r.dirMode); err != nil {
+		return fmt.Errorf("create segment dir: %w", err)
+	}
+	filename := fmt.Sprintf("%016x.segment.cbor", merged.Header.TxID)
+	if err := writeSegmentFile(filepath.Join(dir, filename), merged, r.fileMode); err != nil {
+		return err
+	}
+	desc := SegmentDescriptor{
+		Name:      filepath.ToSlash(filepath.Join(generation, "segments", filename)),
+		FirstTxID: merged.Header.ParentTxID + 1,
+		LastTxID:  merged.Header.TxID,
+		Timestamp: time.Now().UTC(),
+		Size:      int64(len(merged.Data)),
+	}
+	r.mu.Lock()
+	state, err := r.readState()
+	if err == nil && replaceSegmentDescriptors(state, generation, desc, replaced) {
+		err = r.writeState(state)
+	}
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	for _, old := range replaced {
+		if old.Name == desc.Name {
+			continue
+		}
+		if err := os.Remove(filepath.Join(r.basePath, filepath.FromSlash(old.Name))); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
 // Prune removes expired artefacts according to retention policy.
 func (r *FileReplica) Prune(ctx context.Context, generation string, retention RetentionConfig) error {
 	select {
@@ -172,6 +267,78 @@ func (r *FileReplica) LatestState(ctx context.Context) (*RestoreState, error) {
 	return r.readState()
 }
 
+// ListGenerations summarises the generation directories below the replica
+// path.
+func (r *FileReplica) ListGenerations(ctx context.Context) ([]GenerationInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(r.basePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	lister := generationLister{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		for _, kind := range []string{"snapshots", "segments"} {
+			names, err := os.ReadDir(filepath.Join(r.basePath, entry.Name(), kind))
+			if err != nil {
+				if errors.Is(err, os.ErrNotExist) {
+					continue
+				}
+				return nil, err
+			}
+			for _, name := range names {
+				if !name.IsDir() {
+					lister.add(path.Join(entry.Name(), kind, name.Name()))
+				}
+			}
+		}
+	}
+	return lister.list(), nil
+}
+
+// DeleteGeneration removes the generation directory, clearing the state
+// manifest first if it references generation.
+func (r *FileReplica) DeleteGeneration(ctx context.Context, generation string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := checkGenerationID(generation); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	state, err := r.readState()
+	if err == nil && state.Generation == generation {
+		err = os.Remove(r.statePath())
+	}
+	r.mu.Unlock()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return os.RemoveAll(filepath.Join(r.basePath, generation))
+}
+
+// HealthCheck verifies the replica directory still exists.
+func (r *FileReplica) HealthCheck(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	info, err := os.Stat(r.basePath)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("file replica path %s is not a directory", r.basePath)
+	}
+	return nil
+}
+
 // Close releases resources. No-op for file replica.
 func (r *FileReplica) Close(context.Context) error { return nil }
 
@@ -221,23 +388,60 @@ func (r *FileReplica) writeState(state *RestoreState) error {
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(path, data, 0o644)
+	return writeFileMode(path, data, r.fileMode)
 }
 
-func writeSnapshotFile(path string, snapshot *Snapshot) error {
+func writeSnapshotFile(path string, snapshot *Snapshot, mode os.FileMode) error {
 	data, err := marshalSnapshot(snapshot)
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(path, data, 0o644)
+	return writeFileMode(path, data, mode)
 }
 
-func writeSegmentFile(path string, segment *Segment) error {
+func writeSegmentFile(path string, segment *Segment, mode os.FileMode) error {
 	data, err := marshalSegment(segment)
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(path, data, 0o644)
+	return writeFileMode(path, data, mode)
+}
+
+// writeFileMode writes data to path and chmods it so neither the process
+// umask nor a pre-existing file narrows the configured mode.
+func writeFileMode(path string, data []byte, mode os.FileMode) error {
+	if err := os.WriteFile(path, data, mode); err != nil {
+		return err
+	}
+	return os.Chmod(path, mode)
+}
+
+// mkdirAllMode behaves like os.MkdirAll and chmods every directory it creates
+// to mode. Directories that already exist are left untouched.
+func mkdirAllMode(dir string, mode os.FileMode) error {
+	var missing []string
+	for d := filepath.Clean(dir); ; {
+		if _, err := os.Stat(d); err == nil {
+			break
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		missing = append(missing, d)
+		parent := filepath.Dir(d)
+		if parent == d {
+			break
+		}
+		d = parent
+	}
+	if err := os.MkdirAll(dir, mode); err != nil {
+		return err
+	}
+	for _, d := range missing {
+		if err := os.Chmod(d, mode); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func pruneGeneration(dir string, cutoff time.Time) error {
@@ -259,18 +463,14 @@ func pruneGeneration(dir string, cutoff time.Time) error {
 		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".snapshot.cbor") {
 			continue
 		}
-		data, err := os.ReadFile(filepath.Join(snapDir, entry.Name()))
-		if err != nil {
-			continue
-		}
-		snap, err := decodeSnapshotFile(data)
+		created, txid, err := parseSnapshotObject(entry.Name())
 		if err != nil {
 			continue
 		}
 		snaps = append(snaps, snapInfo{
 			path:    filepath.Join(snapDir, entry.Name()),
-			created: snap.Header.CreatedAt,
-			txid:    snap.Header.TxID,
+			created: created,
+			txid:    txid,
 		})
 	}
 	if len(snaps) == 0 {
@@ -0,0 +1,51 @@
+package stream
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// requireModes walks root and asserts every directory and file below it has
+// the given permissions.
+func requireModes(t *testing.T, root string, dirMode, fileMode os.FileMode) {
+	t.Helper()
+	var files int
+	require.NoError(t, filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		require.NoError(t, err)
+		if path == root {
+			return nil
+		}
+		info, err := d.Info()
+		require.NoError(t, err)
+		if d.IsDir() {
+			require.Equal(t, dirMode, info.Mode().Perm(), path)
+		} else {
+			files++
+			require.Equal(t, fileMode, info.Mode().Perm(), path)
+		}
+		return nil
+	}))
+	require.NotZero(t, files)
+}
+
+func TestFileReplicaModes(t *testing.T) {
+	replicaPath := filepath.Join(t.TempDir(), "shared", "replica")
+	db, _, _ := openReplicatedDB(t, Config{Replicas: []ReplicaConfig{
+		&FileReplicaConfig{Path: replicaPath, DirMode: 0o770, FileMode: 0o660},
+	}})
+	putKeys(t, db, "widgets", 2)
+
+	info, err := os.Stat(filepath.Dir(replicaPath))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o770), info.Mode().Perm(), "created parents use DirMode")
+	requireModes(t, replicaPath, 0o770, 0o660)
+}
+
+func TestFileReplicaDefaultModes(t *testing.T) {
+	db, _, replicaPath := openReplicatedDB(t, Config{})
+	putKeys(t, db, "widgets", 2)
+	requireModes(t, replicaPath, 0o755, 0o644)
+}
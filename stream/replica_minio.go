@@ -3,22 +3,41 @@ package stream
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"path"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
 )
 
 var errS3ObjectNotFound = errors.New("s3 object not found")
 
+// ArchivedObjectError is returned when an artefact lives in an archive storage
+// class (such as GLACIER or DEEP_ARCHIVE) and has not been restored, so it
+// cannot be read until a restore-from-archive completes.
+type ArchivedObjectError struct {
+	Key          string
+	StorageClass string
+}
+
+func (e *ArchivedObjectError) Error() string {
+	if e.StorageClass == "" {
+		return fmt.Sprintf("s3 object %s is archived and must be restored before it can be read", e.Key)
+	}
+	return fmt.Sprintf("s3 object %s is archived in %s and must be restored before it can be read", e.Key, e.StorageClass)
+}
+
 // S3CompatibleConfig configures a generic S3-compatible backend.
 type S3CompatibleConfig struct {
+	ReplicaOptions
 	Endpoint       string `json:"endpoint"`
 	Region         string `json:"region"`
 	Bucket         string `json:"bucket"`
@@ -28,6 +47,71 @@ type S3CompatibleConfig struct {
 	SessionToken   string `json:"sessionToken"`
 	Insecure       bool   `json:"insecure"`
 	ForcePathStyle bool   `json:"forcePathStyle"`
+	// StorageClass is applied to uploaded snapshots and segments, for example
+	// STANDARD_IA or GLACIER. The state manifest always uses the bucket default.
+	StorageClass string `json:"storageClass"`
+	// PartSize is the multipart chunk size in bytes. Objects larger than one
+	// part are uploaded in parts; zero lets the client choose. Must be at
+	// least 5 MiB when set.
+	PartSize uint64 `json:"partSize"`
+	// SSE selects server-side encryption for uploaded objects.
+	SSE S3SSEConfig `json:"sse"`
+}
+
+// S3SSEType names a server-side encryption mode.
+type S3SSEType string
+
+const (
+	// S3SSENone uploads objects without requesting server-side encryption.
+	S3SSENone S3SSEType = ""
+	// S3SSES3 requests encryption with keys managed by the storage service.
+	S3SSES3 S3SSEType = "SSE-S3"
+	// S3SSEKMS requests encryption with a KMS-managed key.
+	S3SSEKMS S3SSEType = "SSE-KMS"
+	// S3SSEC encrypts with a customer-provided key that must accompany every read.
+	S3SSEC S3SSEType = "SSE-C"
+)
+
+// S3SSEConfig configures server-side encryption for an S3-compatible replica.
+type S3SSEConfig struct {
+	Type S3SSEType `json:"type"`
+	// KMSKeyID selects the KMS key for SSE-KMS. Empty uses the bucket default.
+	KMSKeyID string `json:"kmsKeyId"`
+	// CustomerKey is the base64-encoded 256-bit key used with SSE-C.
+	CustomerKey string `json:"customerKey"`
+}
+
+// serverSide validates the configuration and returns the matching minio
+// encryption settings, or nil when SSE is disabled.
+func (cfg S3SSEConfig) serverSide() (encrypt.ServerSide, error) {
+	switch cfg.Type {
+	case S3SSENone:
+		if cfg.KMSKeyID != "" || cfg.CustomerKey != "" {
+			return nil, fmt.Errorf("sse key supplied without sse type")
+		}
+		return nil, nil
+	case S3SSES3:
+		if cfg.KMSKeyID != "" || cfg.CustomerKey != "" {
+			return nil, fmt.Errorf("%s does not accept a key", cfg.Type)
+		}
+		return encrypt.NewSSE(), nil
+	case S3SSEKMS:
+		if cfg.CustomerKey != "" {
+			return nil, fmt.Errorf("%s does not accept a customer key", cfg.Type)
+		}
+		return encrypt.NewSSEKMS(cfg.KMSKeyID, nil)
+	case S3SSEC:
+		if cfg.KMSKeyID != "" {
+			return nil, fmt.Errorf("%s does not accept a kms key id", cfg.Type)
+		}
+		key, err := base64.StdEncoding.DecodeString(cfg.CustomerKey)
+		if err != nil {
+			return nil, fmt.Errorf("decode %s customer key: %w", cfg.Type, err)
+		}
+		return encrypt.NewSSEC(key)
+	default:
+		return nil, fmt.Errorf("unknown sse type %q", cfg.Type)
+	}
 }
 
 func (cfg *S3CompatibleConfig) buildReplica(ctx context.Context) (Replica, error) {
@@ -37,21 +121,60 @@ func (cfg *S3CompatibleConfig) buildReplica(ctx context.Context) (Replica, error
 	return NewS3CompatibleReplica(ctx, cfg)
 }
 
+func (cfg *S3CompatibleConfig) validate() error {
+	_, err := cfg.validatedSSE()
+	return err
+}
+
+// validatedSSE validates cfg and returns its server-side encryption
+// settings, or nil when SSE is disabled.
+func (cfg *S3CompatibleConfig) validatedSSE() (encrypt.ServerSide, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("s3 replica config is nil")
+	}
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("bucket is required")
+	}
+	if cfg.PartSize != 0 && cfg.PartSize < 5<<20 {
+		return nil, fmt.Errorf("partSize must be at least 5 MiB")
+	}
+	sse, err := cfg.SSE.serverSide()
+	if err != nil {
+		return nil, fmt.Errorf("invalid sse config: %w", err)
+	}
+	if sse != nil && sse.Type() == encrypt.SSEC && cfg.Insecure {
+		return nil, fmt.Errorf("invalid sse config: %s requires TLS", S3SSEC)
+	}
+	return sse, nil
+}
+
 // S3CompatibleReplica stores artefacts in any S3-compatible object storage.
 type S3CompatibleReplica struct {
 	name   string
 	client *minio.Client
 	cfg    S3CompatibleConfig
+	sse    encrypt.ServerSide
 	mu     sync.Mutex
+
+	pruneMu     sync.Mutex
+	pruneCursor s3PruneCursor
+}
+
+// s3PruneCursor records how far an interrupted Prune got. The next Prune of
+// the same generation lists from after the last handled key instead of
+// walking the whole prefix again.
+type s3PruneCursor struct {
+	generation string
+	prefix     string
+	after      string
+	keepTxID   uint64
 }
 
 // NewS3CompatibleReplica constructs an S3-compatible replica backed by MinIO client.
 func NewS3CompatibleReplica(ctx context.Context, cfg *S3CompatibleConfig) (*S3CompatibleReplica, error) {
-	if cfg == nil {
-		return nil, fmt.Errorf("s3 replica config is nil")
-	}
-	if cfg.Bucket == "" {
-		return nil, fmt.Errorf("bucket is required")
+	sse, err := cfg.validatedSSE()
+	if err != nil {
+		return nil, err
 	}
 	endpoint := cfg.Endpoint
 	if endpoint == "" {
@@ -77,7 +200,7 @@ func NewS3CompatibleReplica(ctx context.Context, cfg *S3CompatibleConfig) (*S3Co
 	if cfg.Prefix != "" {
 		replicaName = fmt.Sprintf("s3://%s/%s", cfg.Bucket, cfg.Prefix)
 	}
-	return &S3CompatibleReplica{name: replicaName, client: client, cfg: *cfg}, nil
+	return &S3CompatibleReplica{name: replicaName, client: client, cfg: *cfg, sse: sse}, nil
 }
 
 func bucketLookupStyle(forcePath bool) minio.BucketLookupType {
@@ -90,6 +213,8 @@ func bucketLookupStyle(forcePath bool) minio.BucketLookupType {
 // Name implements Replica.
 func (r *S3CompatibleReplica) Name() string { return r.name }
 
+func (r *S3CompatibleReplica) replicaOptions() ReplicaOptions { return r.cfg.ReplicaOptions }
+
 // Close satisfies the Replica interface. MinIO client does not hold open resources.
 func (r *S3CompatibleReplica) Close(context.Context) error { return nil }
 
@@ -98,11 +223,15 @@ func (r *S3CompatibleReplica) PutSnapshot(ctx context.Context, generation string
 	if err := ctx.Err(); err != nil {
 		return err
 	}
-	objectName := prefixedKey(r.cfg.Prefix, snapshotObjectName(generation, snapshot.Header.CreatedAt, snapshot.Header.TxID))
-	if err := r.putObject(ctx, objectName, snapshot.Data); err != nil {
+	objectName := prefixedKey(r.cfg.Prefix, snapshotObjectName(generation, snapshot.createdAt(), snapshot.Header.TxID))
+	body, size, err := snapshotReader(snapshot)
+	if err != nil {
+		return err
+	}
+	if err := r.putObject(ctx, objectName, body, size, r.cfg.StorageClass); err != nil {
 		return err
 	}
-	desc := SnapshotDescriptor{Name: objectName, Timestamp: snapshot.Header.CreatedAt, Size: int64(len(snapshot.Data))}
+	desc := SnapshotDescriptor{Name: objectName, Timestamp: snapshot.createdAt(), Size: int64(len(snapshot.Data))}
 	return r.updateState(ctx, generation, &desc, nil)
 }
 
@@ -112,7 +241,11 @@ func (r *S3CompatibleReplica) PutSegment(ctx context.Context, generation string,
 		return err
 	}
 	objectName := prefixedKey(r.cfg.Prefix, segmentObjectName(generation, segment.Header.TxID))
-	if err := r.putObject(ctx, objectName, segment.Data); err != nil {
+	encoded, err := marshalSegment(segment)
+	if err != nil {
+		return err
+	}
+	if err := r.putObject(ctx, objectName, bytes.NewReader(encoded), int64(len(encoded)), r.cfg.StorageClass); err != nil {
 		return err
 	}
 	desc := SegmentDescriptor{
@@ -125,7 +258,46 @@ func (r *S3CompatibleReplica) PutSegment(ctx context.Context, generation string,
 	return r.updateState(ctx, generation, nil, &desc)
 }
 
-// Prune applies the retention policy to snapshots and segments.
+// ReplaceSegments uploads merged, swaps it into the state manifest in place of
+// replaced and deletes the original objects.
+func (r *S3CompatibleReplica) ReplaceSegments(ctx context.Context, generation string, merged *Segment, replaced []SegmentDescriptor) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	objectName := prefixedKey(r.cfg.Prefix, segmentObjectName(generation, merged.Header.TxID))
+	encoded, err := marshalSegment(merged)
+	if err != nil {
+		return err
+	}
+	if err := r.putObject(ctx, objectName, bytes.NewReader(encoded), int64(len(encoded)), r.cfg.StorageClass); err != nil {
+		return err
+	}
+	desc := SegmentDescriptor{
+		Name:      objectName,
+		FirstTxID: merged.Header.ParentTxID + 1,
+		LastTxID:  merged.Header.TxID,
+		Timestamp: merged.Header.CreatedAt,
+		Size:      int64(len(merged.Data)),
+	}
+	if err := r.replaceState(ctx, generation, desc, replaced); err != nil {
+		return err
+	}
+	for _, old := range replaced {
+		if old.Name == objectName {
+			continue
+		}
+		if err := r.removeObject(ctx, old.Name); err != nil && !isS3NotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Prune applies the retention policy to snapshots and segments. Object names
+// carry the timestamps and txids it needs, so archived objects are listed and
+// deleted without being restored first. A Prune that fails part way, for
+// example because ctx was cancelled, resumes after the last handled key on
+// the next call for the same generation.
 func (r *S3CompatibleReplica) Prune(ctx context.Context, generation string, retention RetentionConfig) error {
 	if err := ctx.Err(); err != nil {
 		return err
@@ -133,38 +305,59 @@ func (r *S3CompatibleReplica) Prune(ctx context.Context, generation string, rete
 	if retention.SnapshotRetention <= 0 {
 		return nil
 	}
+	r.pruneMu.Lock()
+	defer r.pruneMu.Unlock()
+	cursor := r.pruneCursor
+	if cursor.generation != generation {
+		cursor = s3PruneCursor{generation: generation}
+	}
 	cutoff := time.Now().Add(-retention.SnapshotRetention)
 	snapshotsPrefix := prefixedKey(r.cfg.Prefix, path.Join(generation, "snapshots"))
-	var keepTxID uint64
-	if err := r.walkObjects(ctx, snapshotsPrefix, func(obj minio.ObjectInfo) error {
-		created, txid, err := parseSnapshotObject(path.Base(obj.Key))
-		if err != nil {
-			return nil
+	segmentsPrefix := prefixedKey(r.cfg.Prefix, path.Join(generation, "segments"))
+	if cursor.prefix != segmentsPrefix {
+		if cursor.prefix != snapshotsPrefix {
+			cursor.prefix, cursor.after = snapshotsPrefix, ""
 		}
-		if created.After(cutoff) || keepTxID == 0 {
-			if txid > keepTxID {
-				keepTxID = txid
+		if err := r.walkObjects(ctx, snapshotsPrefix, cursor.after, func(obj minio.ObjectInfo) error {
+			created, txid, err := parseSnapshotObject(path.Base(obj.Key))
+			if err != nil {
+				cursor.after = obj.Key
+				return nil
 			}
+			if created.After(cutoff) || cursor.keepTxID == 0 {
+				if txid > cursor.keepTxID {
+					cursor.keepTxID = txid
+				}
+			} else if err := r.removeObject(ctx, obj.Key); err != nil {
+				return err
+			}
+			cursor.after = obj.Key
 			return nil
+		}); err != nil {
+			r.pruneCursor = cursor
+			return err
 		}
-		return r.removeObject(ctx, obj.Key)
-	}); err != nil {
-		return err
+		cursor.prefix, cursor.after = segmentsPrefix, ""
 	}
-	if keepTxID == 0 {
+	if cursor.keepTxID == 0 {
+		r.pruneCursor = s3PruneCursor{}
 		return nil
 	}
-	segmentsPrefix := prefixedKey(r.cfg.Prefix, path.Join(generation, "segments"))
-	return r.walkObjects(ctx, segmentsPrefix, func(obj minio.ObjectInfo) error {
+	if err := r.walkObjects(ctx, segmentsPrefix, cursor.after, func(obj minio.ObjectInfo) error {
 		txid, err := parseSegmentObject(path.Base(obj.Key))
-		if err != nil {
-			return nil
-		}
-		if txid <= keepTxID {
-			return r.removeObject(ctx, obj.Key)
+		if err == nil && txid <= cursor.keepTxID {
+			if err := r.removeObject(ctx, obj.Key); err != nil {
+				return err
+			}
 		}
+		cursor.after = obj.Key
 		return nil
-	})
+	}); err != nil {
+		r.pruneCursor = cursor
+		return err
+	}
+	r.pruneCursor = s3PruneCursor{}
+	return nil
 }
 
 // FetchSnapshot downloads and decodes a snapshot artefact.
@@ -201,6 +394,65 @@ func (r *S3CompatibleReplica) LatestState(ctx context.Context) (*RestoreState, e
 	return &state, nil
 }
 
+// ListGenerations summarises the generations stored below the replica prefix.
+func (r *S3CompatibleReplica) ListGenerations(ctx context.Context) ([]GenerationInfo, error) {
+	prefix := ""
+	if r.cfg.Prefix != "" {
+		prefix = strings.TrimSuffix(r.cfg.Prefix, "/") + "/"
+	}
+	lister := generationLister{}
+	if err := r.walkObjects(ctx, prefix, "", func(obj minio.ObjectInfo) error {
+		lister.add(strings.TrimPrefix(obj.Key, prefix))
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return lister.list(), nil
+}
+
+// DeleteGeneration removes every object below the generation prefix, clearing
+// the state manifest first if it references generation.
+func (r *S3CompatibleReplica) DeleteGeneration(ctx context.Context, generation string) error {
+	if err := checkGenerationID(generation); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	state, err := r.LatestState(ctx)
+	if err == nil && state.Generation == generation {
+		err = r.removeObject(ctx, r.stateKey())
+	}
+	r.mu.Unlock()
+	if err != nil && !isS3NotFound(err) {
+		return err
+	}
+	return r.walkObjects(ctx, prefixedKey(r.cfg.Prefix, generation)+"/", "", func(obj minio.ObjectInfo) error {
+		if err := r.removeObject(ctx, obj.Key); err != nil && !isS3NotFound(err) {
+			return err
+		}
+		return nil
+	})
+}
+
+// HealthCheck confirms the bucket exists and the state manifest can be
+// inspected with the configured credentials.
+func (r *S3CompatibleReplica) HealthCheck(ctx context.Context) error {
+	exists, err := r.client.BucketExists(ctx, r.cfg.Bucket)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("bucket %q does not exist", r.cfg.Bucket)
+	}
+	var opts minio.StatObjectOptions
+	if r.sse != nil && r.sse.Type() == encrypt.SSEC {
+		opts.ServerSideEncryption = r.sse
+	}
+	if _, err := r.client.StatObject(ctx, r.cfg.Bucket, r.stateKey(), opts); err != nil && !isS3NotFound(err) {
+		return err
+	}
+	return nil
+}
+
 func (r *S3CompatibleReplica) updateState(ctx context.Context, generation string, snapshot *SnapshotDescriptor, segment *SegmentDescriptor) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -223,17 +475,54 @@ func (r *S3CompatibleReplica) updateState(ctx context.Context, generation string
 	if err != nil {
 		return err
 	}
-	return r.putObject(ctx, r.stateKey(), data)
+	return r.putObject(ctx, r.stateKey(), bytes.NewReader(data), int64(len(data)), "")
 }
 
-func (r *S3CompatibleReplica) putObject(ctx context.Context, key string, body []byte) error {
-	reader := bytes.NewReader(body)
-	_, err := r.client.PutObject(ctx, r.cfg.Bucket, key, reader, int64(len(body)), minio.PutObjectOptions{ContentType: "application/octet-stream"})
+func (r *S3CompatibleReplica) replaceState(ctx context.Context, generation string, merged SegmentDescriptor, replaced []SegmentDescriptor) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	state, err := r.LatestState(ctx)
+	if err != nil {
+		return err
+	}
+	if !replaceSegmentDescriptors(state, generation, merged, replaced) {
+		return nil
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return r.putObject(ctx, r.stateKey(), bytes.NewReader(data), int64(len(data)), "")
+}
+
+// putObject uploads the size bytes of body under key. Uploads larger than one
+// part use multipart, reading body one part at a time; if they fail, any
+// parts already stored are aborted so they are not billed as orphans.
+func (r *S3CompatibleReplica) putObject(ctx context.Context, key string, body io.Reader, size int64, storageClass string) error {
+	opts := minio.PutObjectOptions{
+		ContentType:          "application/octet-stream",
+		ServerSideEncryption: r.sse,
+		StorageClass:         storageClass,
+		PartSize:             r.cfg.PartSize,
+	}
+	_, err := r.client.PutObject(ctx, r.cfg.Bucket, key, body, size, opts)
+	if err != nil {
+		// ctx may be the reason the upload failed, so clean up with a fresh one.
+		abortCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		_ = r.client.RemoveIncompleteUpload(abortCtx, r.cfg.Bucket, key)
+	}
 	return err
 }
 
 func (r *S3CompatibleReplica) getObject(ctx context.Context, key string) ([]byte, error) {
-	obj, err := r.client.GetObject(ctx, r.cfg.Bucket, key, minio.GetObjectOptions{})
+	var opts minio.GetObjectOptions
+	// Only SSE-C needs the key on reads; SSE-S3 and SSE-KMS headers are
+	// rejected by S3 on GET requests.
+	if r.sse != nil && r.sse.Type() == encrypt.SSEC {
+		opts.ServerSideEncryption = r.sse
+	}
+	obj, err := r.client.GetObject(ctx, r.cfg.Bucket, key, opts)
 	if err != nil {
 		if isS3NotFound(err) {
 			return nil, errS3ObjectNotFound
@@ -241,17 +530,24 @@ func (r *S3CompatibleReplica) getObject(ctx context.Context, key string) ([]byte
 		return nil, err
 	}
 	defer obj.Close()
-	if _, statErr := obj.Stat(); statErr != nil {
+	info, statErr := obj.Stat()
+	if statErr != nil {
 		if isS3NotFound(statErr) {
 			return nil, errS3ObjectNotFound
 		}
 		return nil, statErr
 	}
+	if isS3Archived(info) {
+		return nil, &ArchivedObjectError{Key: key, StorageClass: info.StorageClass}
+	}
 	data, readErr := io.ReadAll(obj)
 	if readErr != nil {
 		if isS3NotFound(readErr) {
 			return nil, errS3ObjectNotFound
 		}
+		if minio.ToErrorResponse(readErr).Code == "InvalidObjectState" {
+			return nil, &ArchivedObjectError{Key: key, StorageClass: info.StorageClass}
+		}
 		return nil, readErr
 	}
 	return data, nil
@@ -261,8 +557,10 @@ func (r *S3CompatibleReplica) removeObject(ctx context.Context, key string) erro
 	return r.client.RemoveObject(ctx, r.cfg.Bucket, key, minio.RemoveObjectOptions{})
 }
 
-func (r *S3CompatibleReplica) walkObjects(ctx context.Context, prefix string, fn func(minio.ObjectInfo) error) error {
-	opts := minio.ListObjectsOptions{Prefix: prefix, Recursive: true}
+// walkObjects lists keys below prefix in lexical order, starting after
+// startAfter when it is non-empty.
+func (r *S3CompatibleReplica) walkObjects(ctx context.Context, prefix, startAfter string, fn func(minio.ObjectInfo) error) error {
+	opts := minio.ListObjectsOptions{Prefix: prefix, Recursive: true, StartAfter: startAfter}
 	for object := range r.client.ListObjects(ctx, r.cfg.Bucket, opts) {
 		if err := ctx.Err(); err != nil {
 			return err
@@ -291,6 +589,16 @@ func prefixedKey(prefix, key string) string {
 	return path.Join(prefix, key)
 }
 
+// isS3Archived reports whether the object sits in an archive tier without a
+// completed restore. GLACIER_IR is readable immediately and is not included.
+func isS3Archived(info minio.ObjectInfo) bool {
+	switch info.StorageClass {
+	case "GLACIER", "DEEP_ARCHIVE":
+		return info.Restore == nil || info.Restore.OngoingRestore
+	}
+	return false
+}
+
 func isS3NotFound(err error) bool {
 	resp := minio.ToErrorResponse(err)
 	if resp.StatusCode == 404 {
@@ -0,0 +1,196 @@
+package stream
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewS3CompatibleReplicaSSE(t *testing.T) {
+	customerKey := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32)))
+	tests := []struct {
+		name     string
+		sse      S3SSEConfig
+		insecure bool
+		wantType encrypt.Type
+		wantErr  string
+	}{
+		{name: "none"},
+		{name: "sse-s3", sse: S3SSEConfig{Type: S3SSES3}, wantType: encrypt.S3},
+		{name: "sse-kms", sse: S3SSEConfig{Type: S3SSEKMS, KMSKeyID: "alias/witchbolt"}, wantType: encrypt.KMS},
+		{name: "sse-kms default key", sse: S3SSEConfig{Type: S3SSEKMS}, wantType: encrypt.KMS},
+		{name: "sse-c", sse: S3SSEConfig{Type: S3SSEC, CustomerKey: customerKey}, wantType: encrypt.SSEC},
+		{name: "sse-c without tls", sse: S3SSEConfig{Type: S3SSEC, CustomerKey: customerKey}, insecure: true, wantErr: "requires TLS"},
+		{name: "sse-c short key", sse: S3SSEConfig{Type: S3SSEC, CustomerKey: base64.StdEncoding.EncodeToString([]byte("short"))}, wantErr: "invalid sse config"},
+		{name: "sse-c bad base64", sse: S3SSEConfig{Type: S3SSEC, CustomerKey: "not base64!"}, wantErr: "decode SSE-C customer key"},
+		{name: "sse-s3 with key", sse: S3SSEConfig{Type: S3SSES3, KMSKeyID: "alias/witchbolt"}, wantErr: "does not accept a key"},
+		{name: "key without type", sse: S3SSEConfig{CustomerKey: customerKey}, wantErr: "without sse type"},
+		{name: "unknown type", sse: S3SSEConfig{Type: "SSE-X"}, wantErr: `unknown sse type "SSE-X"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			replica, err := NewS3CompatibleReplica(context.Background(), &S3CompatibleConfig{
+				Endpoint: "localhost:9000",
+				Bucket:   "example",
+				Insecure: tt.insecure,
+				SSE:      tt.sse,
+			})
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			if tt.wantType == "" {
+				require.Nil(t, replica.sse)
+				return
+			}
+			require.NotNil(t, replica.sse)
+			require.Equal(t, tt.wantType, replica.sse.Type())
+		})
+	}
+}
+
+func TestIsS3Archived(t *testing.T) {
+	tests := []struct {
+		name string
+		info minio.ObjectInfo
+		want bool
+	}{
+		{name: "standard", info: minio.ObjectInfo{StorageClass: "STANDARD"}},
+		{name: "infrequent access", info: minio.ObjectInfo{StorageClass: "STANDARD_IA"}},
+		{name: "glacier instant retrieval", info: minio.ObjectInfo{StorageClass: "GLACIER_IR"}},
+		{name: "glacier", info: minio.ObjectInfo{StorageClass: "GLACIER"}, want: true},
+		{name: "deep archive", info: minio.ObjectInfo{StorageClass: "DEEP_ARCHIVE"}, want: true},
+		{name: "restore in progress", info: minio.ObjectInfo{StorageClass: "GLACIER", Restore: &minio.RestoreInfo{OngoingRestore: true}}, want: true},
+		{name: "restored", info: minio.ObjectInfo{StorageClass: "GLACIER", Restore: &minio.RestoreInfo{ExpiryTime: time.Now().Add(time.Hour)}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, isS3Archived(tt.info))
+		})
+	}
+}
+
+func TestArchivedObjectError(t *testing.T) {
+	var err error = &ArchivedObjectError{Key: "gen/snapshots/a.snapshot", StorageClass: "GLACIER"}
+	var archived *ArchivedObjectError
+	require.ErrorAs(t, fmt.Errorf("fetch snapshot: %w", err), &archived)
+	require.Equal(t, "GLACIER", archived.StorageClass)
+	require.EqualError(t, err, "s3 object gen/snapshots/a.snapshot is archived in GLACIER and must be restored before it can be read")
+}
+
+func TestNewS3CompatibleReplicaPartSize(t *testing.T) {
+	_, err := NewS3CompatibleReplica(context.Background(), &S3CompatibleConfig{Bucket: "example", PartSize: 1 << 20})
+	require.ErrorContains(t, err, "partSize must be at least 5 MiB")
+
+	replica, err := NewS3CompatibleReplica(context.Background(), &S3CompatibleConfig{Bucket: "example", PartSize: 64 << 20})
+	require.NoError(t, err)
+	require.Equal(t, uint64(64<<20), replica.cfg.PartSize)
+}
+
+// fakeS3Bucket serves the ListObjectsV2 and DeleteObject calls Prune makes.
+type fakeS3Bucket struct {
+	mu       sync.Mutex
+	keys     map[string]bool
+	listed   int
+	starts   []string
+	deletes  int
+	onDelete func(n int)
+}
+
+func (b *fakeS3Bucket) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	key := strings.TrimPrefix(req.URL.Path, "/bucket/")
+	switch {
+	case req.Method == http.MethodGet && req.URL.Query().Get("list-type") == "2":
+		q := req.URL.Query()
+		b.starts = append(b.starts, q.Get("start-after"))
+		var keys []string
+		for k := range b.keys {
+			if strings.HasPrefix(k, q.Get("prefix")) && k > q.Get("start-after") {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		b.listed += len(keys)
+		var body strings.Builder
+		body.WriteString(`<?xml version="1.0" encoding="UTF-8"?><ListBucketResult><Name>bucket</Name><IsTruncated>false</IsTruncated>`)
+		for _, k := range keys {
+			fmt.Fprintf(&body, `<Contents><Key>%s</Key><Size>1</Size><StorageClass>STANDARD</StorageClass></Contents>`, k)
+		}
+		body.WriteString(`</ListBucketResult>`)
+		w.Header().Set("Content-Type", "application/xml")
+		_, _ = io.WriteString(w, body.String())
+	case req.Method == http.MethodDelete:
+		delete(b.keys, key)
+		b.deletes++
+		if b.onDelete != nil {
+			b.onDelete(b.deletes)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+func TestS3CompatibleReplicaPruneResumes(t *testing.T) {
+	const generation = "0123456789abcdef"
+	bucket := &fakeS3Bucket{keys: map[string]bool{}}
+	old := time.Now().Add(-72 * time.Hour).UTC()
+	for i := 0; i < 10; i++ {
+		txid := uint64(10 * (i + 1))
+		bucket.keys[snapshotObjectName(generation, old.Add(time.Duration(i)*time.Minute), txid)] = true
+		bucket.keys[segmentObjectName(generation, txid+1)] = true
+	}
+	latest := snapshotObjectName(generation, time.Now().UTC(), 200)
+	bucket.keys[latest] = true
+	srv := httptest.NewServer(bucket)
+	t.Cleanup(srv.Close)
+
+	replica, err := NewS3CompatibleReplica(context.Background(), &S3CompatibleConfig{
+		Endpoint:       strings.TrimPrefix(srv.URL, "http://"),
+		Region:         "us-east-1",
+		Bucket:         "bucket",
+		Insecure:       true,
+		ForcePathStyle: true,
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	bucket.onDelete = func(n int) {
+		if n == 4 {
+			cancel()
+		}
+	}
+	retention := RetentionConfig{SnapshotRetention: 24 * time.Hour}
+	require.ErrorIs(t, replica.Prune(ctx, generation, retention), context.Canceled)
+	resumeAfter := replica.pruneCursor.after
+	require.NotEmpty(t, resumeAfter)
+	firstListed := bucket.listed
+
+	bucket.onDelete = nil
+	require.NoError(t, replica.Prune(context.Background(), generation, retention))
+	require.Equal(t, resumeAfter, bucket.starts[1], "second prune resumes after the last handled key")
+	require.Less(t, bucket.listed-firstListed, 21, "resumed prune must not re-list every object")
+
+	var remaining []string
+	for k := range bucket.keys {
+		remaining = append(remaining, k)
+	}
+	sort.Strings(remaining)
+	require.Equal(t, []string{snapshotObjectName(generation, old, 10), latest}, remaining)
+	require.Equal(t, s3PruneCursor{}, replica.pruneCursor, "a completed prune clears the cursor")
+}
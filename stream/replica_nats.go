@@ -20,6 +20,7 @@ import (
 
 // NATSReplicaConfig configures the NATS JetStream replica backend.
 type NATSReplicaConfig struct {
+	ReplicaOptions
 	URL     string   `json:"url"`
 	Bucket  string   `json:"bucket"`
 	Prefix  string   `json:"prefix"`
@@ -35,6 +36,16 @@ func (cfg *NATSReplicaConfig) buildReplica(ctx context.Context) (Replica, error)
 	return NewNATSReplica(ctx, cfg)
 }
 
+func (cfg *NATSReplicaConfig) validate() error {
+	if cfg == nil {
+		return fmt.Errorf("nats replica config is nil")
+	}
+	if cfg.Bucket == "" {
+		return fmt.Errorf("nats bucket is required")
+	}
+	return nil
+}
+
 // NATSReplica persists artefacts via NATS JetStream object storage.
 type NATSReplica struct {
 	name    string
@@ -48,11 +59,8 @@ type NATSReplica struct {
 
 // NewNATSReplica constructs a JetStream-backed replica using the provided configuration.
 func NewNATSReplica(_ context.Context, cfg *NATSReplicaConfig) (*NATSReplica, error) {
-	if cfg == nil {
-		return nil, fmt.Errorf("nats replica config is nil")
-	}
-	if cfg.Bucket == "" {
-		return nil, fmt.Errorf("nats bucket is required")
+	if err := cfg.validate(); err != nil {
+		return nil, err
 	}
 	clean := *cfg
 	clean.Prefix = strings.Trim(clean.Prefix, "/")
@@ -62,6 +70,8 @@ func NewNATSReplica(_ context.Context, cfg *NATSReplicaConfig) (*NATSReplica, er
 // Name implements Replica.
 func (r *NATSReplica) Name() string { return r.name }
 
+func (r *NATSReplica) replicaOptions() ReplicaOptions { return r.cfg.ReplicaOptions }
+
 // Close terminates the JetStream connection.
 func (r *NATSReplica) Close(context.Context) error {
 	r.connMu.Lock()
@@ -84,13 +94,17 @@ func (r *NATSReplica) PutSnapshot(ctx context.Context, generation string, snapsh
 	if err != nil {
 		return err
 	}
-	objectName := prefixedKey(r.cfg.Prefix, snapshotObjectName(generation, snapshot.Header.CreatedAt, snapshot.Header.TxID))
-	if _, err := store.PutBytes(ctx, objectName, snapshot.Data); err != nil {
+	objectName := prefixedKey(r.cfg.Prefix, snapshotObjectName(generation, snapshot.createdAt(), snapshot.Header.TxID))
+	encoded, err := marshalSnapshot(snapshot)
+	if err != nil {
+		return err
+	}
+	if _, err := store.PutBytes(ctx, objectName, encoded); err != nil {
 		return err
 	}
 	desc := &SnapshotDescriptor{
 		Name:      objectName,
-		Timestamp: snapshot.Header.CreatedAt,
+		Timestamp: snapshot.createdAt(),
 		Size:      int64(len(snapshot.Data)),
 	}
 	return r.updateState(ctx, store, generation, desc, nil)
@@ -106,7 +120,11 @@ func (r *NATSReplica) PutSegment(ctx context.Context, generation string, segment
 		return err
 	}
 	objectName := prefixedKey(r.cfg.Prefix, segmentObjectName(generation, segment.Header.TxID))
-	if _, err := store.PutBytes(ctx, objectName, segment.Data); err != nil {
+	encoded, err := marshalSegment(segment)
+	if err != nil {
+		return err
+	}
+	if _, err := store.PutBytes(ctx, objectName, encoded); err != nil {
 		return err
 	}
 	desc := &SegmentDescriptor{
@@ -119,6 +137,45 @@ func (r *NATSReplica) PutSegment(ctx context.Context, generation string, segment
 	return r.updateState(ctx, store, generation, nil, desc)
 }
 
+// ReplaceSegments stores merged, swaps it into the state manifest in place of
+// replaced and deletes the original objects.
+func (r *NATSReplica) ReplaceSegments(ctx context.Context, generation string, merged *Segment, replaced []SegmentDescriptor) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	store, err := r.connect(ctx)
+	if err != nil {
+		return err
+	}
+	objectName := prefixedKey(r.cfg.Prefix, segmentObjectName(generation, merged.Header.TxID))
+	encoded, err := marshalSegment(merged)
+	if err != nil {
+		return err
+	}
+	if _, err := store.PutBytes(ctx, objectName, encoded); err != nil {
+		return err
+	}
+	desc := SegmentDescriptor{
+		Name:      objectName,
+		FirstTxID: merged.Header.ParentTxID + 1,
+		LastTxID:  merged.Header.TxID,
+		Timestamp: merged.Header.CreatedAt,
+		Size:      int64(len(merged.Data)),
+	}
+	if err := r.replaceState(ctx, store, generation, desc, replaced); err != nil {
+		return err
+	}
+	for _, old := range replaced {
+		if old.Name == objectName {
+			continue
+		}
+		if err := deleteObjectIfExists(ctx, store, old.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Prune removes stale artefacts according to retention rules.
 func (r *NATSReplica) Prune(ctx context.Context, generation string, retention RetentionConfig) error {
 	if err := ctx.Err(); err != nil {
@@ -182,6 +239,91 @@ func (r *NATSReplica) LatestState(ctx context.Context) (*RestoreState, error) {
 	return state, nil
 }
 
+// ListGenerations summarises the generations stored below the replica prefix
+// in the object store.
+func (r *NATSReplica) ListGenerations(ctx context.Context) ([]GenerationInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	store, err := r.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	infos, err := store.List(ctx)
+	if err != nil {
+		if errors.Is(err, jetstream.ErrNoObjectsFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	prefix := ""
+	if r.cfg.Prefix != "" {
+		prefix = strings.TrimSuffix(r.cfg.Prefix, "/") + "/"
+	}
+	lister := generationLister{}
+	for _, info := range infos {
+		if info.Deleted || !strings.HasPrefix(info.Name, prefix) {
+			continue
+		}
+		lister.add(strings.TrimPrefix(info.Name, prefix))
+	}
+	return lister.list(), nil
+}
+
+// DeleteGeneration removes every object below the generation prefix, clearing
+// the state manifest first if it references generation.
+func (r *NATSReplica) DeleteGeneration(ctx context.Context, generation string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := checkGenerationID(generation); err != nil {
+		return err
+	}
+	store, err := r.connect(ctx)
+	if err != nil {
+		return err
+	}
+	r.stateMu.Lock()
+	state, err := r.loadState(ctx, store)
+	if err == nil && state.Generation == generation {
+		err = deleteObjectIfExists(ctx, store, prefixedKey(r.cfg.Prefix, stateFileName))
+	}
+	r.stateMu.Unlock()
+	if err != nil {
+		return err
+	}
+	infos, err := store.List(ctx)
+	if err != nil {
+		if errors.Is(err, jetstream.ErrNoObjectsFound) {
+			return nil
+		}
+		return err
+	}
+	genPrefix := prefixedKey(r.cfg.Prefix, generation) + "/"
+	for _, info := range infos {
+		if info.Deleted || !strings.HasPrefix(info.Name, genPrefix) {
+			continue
+		}
+		if err := deleteObjectIfExists(ctx, store, info.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HealthCheck connects to JetStream and queries the object store status.
+func (r *NATSReplica) HealthCheck(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	store, err := r.connect(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = store.Status(ctx)
+	return err
+}
+
 func (r *NATSReplica) updateState(ctx context.Context, store jetstream.ObjectStore, generation string, snapshot *SnapshotDescriptor, segment *SegmentDescriptor) error {
 	if err := ctx.Err(); err != nil {
 		return err
@@ -215,6 +357,31 @@ func (r *NATSReplica) updateState(ctx context.Context, store jetstream.ObjectSto
 	return err
 }
 
+func (r *NATSReplica) replaceState(ctx context.Context, store jetstream.ObjectStore, generation string, merged SegmentDescriptor, replaced []SegmentDescriptor) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.stateMu.Lock()
+	defer r.stateMu.Unlock()
+	state, err := r.loadState(ctx, store)
+	if err != nil {
+		return err
+	}
+	if !replaceSegmentDescriptors(state, generation, merged, replaced) {
+		return nil
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	stateKey := prefixedKey(r.cfg.Prefix, stateFileName)
+	if err := deleteObjectIfExists(ctx, store, stateKey); err != nil {
+		return err
+	}
+	_, err = store.PutBytes(ctx, stateKey, data)
+	return err
+}
+
 func (r *NATSReplica) loadState(ctx context.Context, store jetstream.ObjectStore) (*RestoreState, error) {
 	stateKey := prefixedKey(r.cfg.Prefix, stateFileName)
 	data, err := store.GetBytes(ctx, stateKey)
@@ -10,24 +10,67 @@ import (
 	"net"
 	"os"
 	"path"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/pkg/sftp"
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
 )
 
 // SFTPReplicaConfig configures the SFTP replica backend.
 type SFTPReplicaConfig struct {
+	ReplicaOptions
 	Host     string `json:"host"`
 	Port     int    `json:"port"`
 	User     string `json:"user"`
 	Password string `json:"password"`
 	KeyPath  string `json:"keyPath"`
 	Path     string `json:"path"`
+
+	// KnownHostsPath points at an OpenSSH known_hosts file used to verify the
+	// server host key. Defaults to ~/.ssh/known_hosts when neither it nor
+	// HostKeyFingerprint is set.
+	KnownHostsPath string `json:"knownHostsPath"`
+
+	// HostKeyFingerprint pins the server host key by its SHA256 fingerprint
+	// as printed by ssh-keygen -l (e.g. "SHA256:...").
+	HostKeyFingerprint string `json:"hostKeyFingerprint"`
+
+	// Insecure disables host key verification entirely. Only use it for
+	// testing; it leaves the connection open to man-in-the-middle attacks.
+	Insecure bool `json:"insecure"`
+
+	// DirMode and FileMode are applied with chmod to directories and files
+	// the replica creates. Zero keeps the server's default permissions.
+	DirMode  os.FileMode `json:"dirMode"`
+	FileMode os.FileMode `json:"fileMode"`
+}
+
+// UnmarshalJSON decodes the config, accepting octal strings such as "0775"
+// for the permission fields.
+func (cfg *SFTPReplicaConfig) UnmarshalJSON(data []byte) error {
+	type alias SFTPReplicaConfig
+	aux := struct {
+		*alias
+		DirMode  jsonFileMode `json:"dirMode"`
+		FileMode jsonFileMode `json:"fileMode"`
+	}{
+		alias:    (*alias)(cfg),
+		DirMode:  jsonFileMode(cfg.DirMode),
+		FileMode: jsonFileMode(cfg.FileMode),
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	cfg.DirMode = os.FileMode(aux.DirMode)
+	cfg.FileMode = os.FileMode(aux.FileMode)
+	return nil
 }
 
 func (cfg *SFTPReplicaConfig) buildReplica(ctx context.Context) (Replica, error) {
@@ -37,6 +80,22 @@ func (cfg *SFTPReplicaConfig) buildReplica(ctx context.Context) (Replica, error)
 	return NewSFTPReplica(ctx, cfg)
 }
 
+func (cfg *SFTPReplicaConfig) validate() error {
+	if cfg == nil {
+		return fmt.Errorf("sftp replica config is nil")
+	}
+	if cfg.Host == "" {
+		return fmt.Errorf("sftp host is required")
+	}
+	if cfg.User == "" {
+		return fmt.Errorf("sftp user is required")
+	}
+	if cfg.Password == "" && cfg.KeyPath == "" {
+		return fmt.Errorf("sftp password or keyPath is required")
+	}
+	return nil
+}
+
 // SFTPReplica persists artefacts over SFTP.
 type SFTPReplica struct {
 	name      string
@@ -49,17 +108,8 @@ type SFTPReplica struct {
 
 // NewSFTPReplica constructs an SFTP replica backed by the provided configuration.
 func NewSFTPReplica(_ context.Context, cfg *SFTPReplicaConfig) (*SFTPReplica, error) {
-	if cfg == nil {
-		return nil, fmt.Errorf("sftp replica config is nil")
-	}
-	if cfg.Host == "" {
-		return nil, fmt.Errorf("sftp host is required")
-	}
-	if cfg.User == "" {
-		return nil, fmt.Errorf("sftp user is required")
-	}
-	if cfg.Password == "" && cfg.KeyPath == "" {
-		return nil, fmt.Errorf("sftp password or keyPath is required")
+	if err := cfg.validate(); err != nil {
+		return nil, err
 	}
 	clean := *cfg
 	clean.Path = path.Clean(clean.Path)
@@ -73,6 +123,8 @@ func NewSFTPReplica(_ context.Context, cfg *SFTPReplicaConfig) (*SFTPReplica, er
 // Name implements Replica.
 func (r *SFTPReplica) Name() string { return r.name }
 
+func (r *SFTPReplica) replicaOptions() ReplicaOptions { return r.cfg.ReplicaOptions }
+
 // Close releases any open SFTP/SSH connections.
 func (r *SFTPReplica) Close(context.Context) error {
 	r.connMu.Lock()
@@ -93,25 +145,26 @@ func (r *SFTPReplica) PutSnapshot(ctx context.Context, generation string, snapsh
 	if err := ctx.Err(); err != nil {
 		return err
 	}
-	client, err := r.connect()
+	encoded, err := marshalSnapshot(snapshot)
 	if err != nil {
 		return err
 	}
-	remoteDir := r.remotePath(path.Join(generation, "snapshots"))
-	if err := ensureRemoteDir(client, remoteDir); err != nil {
-		return err
-	}
-	filename := fmt.Sprintf("%s-%016x.snapshot.cbor", snapshot.Header.CreatedAt.Format(time.RFC3339Nano), snapshot.Header.TxID)
-	remotePath := path.Join(remoteDir, filename)
-	if err := writeRemoteFile(client, remotePath, snapshot.Data); err != nil {
-		return err
-	}
+	filename := fmt.Sprintf("%s-%016x.snapshot.cbor", snapshot.createdAt().Format(time.RFC3339Nano), snapshot.Header.TxID)
 	desc := &SnapshotDescriptor{
 		Name:      path.Join(generation, "snapshots", filename),
-		Timestamp: snapshot.Header.CreatedAt,
+		Timestamp: snapshot.createdAt(),
 		Size:      int64(len(snapshot.Data)),
 	}
-	return r.updateState(ctx, client, generation, desc, nil)
+	return r.withClient(func(client *sftp.Client) error {
+		remoteDir := r.remotePath(path.Join(generation, "snapshots"))
+		if err := r.ensureRemoteDir(client, remoteDir); err != nil {
+			return err
+		}
+		if err := r.writeRemoteFile(client, path.Join(remoteDir, filename), encoded); err != nil {
+			return err
+		}
+		return r.updateState(ctx, client, generation, desc, nil)
+	})
 }
 
 // PutSegment uploads a segment artefact and records metadata in replica state.
@@ -119,19 +172,11 @@ func (r *SFTPReplica) PutSegment(ctx context.Context, generation string, segment
 	if err := ctx.Err(); err != nil {
 		return err
 	}
-	client, err := r.connect()
+	encoded, err := marshalSegment(segment)
 	if err != nil {
 		return err
 	}
-	remoteDir := r.remotePath(path.Join(generation, "segments"))
-	if err := ensureRemoteDir(client, remoteDir); err != nil {
-		return err
-	}
 	filename := fmt.Sprintf("%016x.segment.cbor", segment.Header.TxID)
-	remotePath := path.Join(remoteDir, filename)
-	if err := writeRemoteFile(client, remotePath, segment.Data); err != nil {
-		return err
-	}
 	desc := &SegmentDescriptor{
 		Name:      path.Join(generation, "segments", filename),
 		FirstTxID: segment.Header.ParentTxID + 1,
@@ -139,7 +184,57 @@ func (r *SFTPReplica) PutSegment(ctx context.Context, generation string, segment
 		Timestamp: segment.Header.CreatedAt,
 		Size:      int64(len(segment.Data)),
 	}
-	return r.updateState(ctx, client, generation, nil, desc)
+	return r.withClient(func(client *sftp.Client) error {
+		remoteDir := r.remotePath(path.Join(generation, "segments"))
+		if err := r.ensureRemoteDir(client, remoteDir); err != nil {
+			return err
+		}
+		if err := r.writeRemoteFile(client, path.Join(remoteDir, filename), encoded); err != nil {
+			return err
+		}
+		return r.updateState(ctx, client, generation, nil, desc)
+	})
+}
+
+// ReplaceSegments uploads merged, swaps it into the state manifest in place of
+// replaced and removes the original segment files.
+func (r *SFTPReplica) ReplaceSegments(ctx context.Context, generation string, merged *Segment, replaced []SegmentDescriptor) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	encoded, err := marshalSegment(merged)
+	if err != nil {
+		return err
+	}
+	filename := fmt.Sprintf("%016x.segment.cbor", merged.Header.TxID)
+	desc := SegmentDescriptor{
+		Name:      path.Join(generation, "segments", filename),
+		FirstTxID: merged.Header.ParentTxID + 1,
+		LastTxID:  merged.Header.TxID,
+		Timestamp: merged.Header.CreatedAt,
+		Size:      int64(len(merged.Data)),
+	}
+	return r.withClient(func(client *sftp.Client) error {
+		remoteDir := r.remotePath(path.Join(generation, "segments"))
+		if err := r.ensureRemoteDir(client, remoteDir); err != nil {
+			return err
+		}
+		if err := r.writeRemoteFile(client, path.Join(remoteDir, filename), encoded); err != nil {
+			return err
+		}
+		if err := r.replaceState(ctx, client, generation, desc, replaced); err != nil {
+			return err
+		}
+		for _, old := range replaced {
+			if old.Name == desc.Name {
+				continue
+			}
+			if err := client.Remove(r.remotePath(old.Name)); err != nil && !isSFTPNotExist(err) {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
 // Prune removes expired artefacts as dictated by the retention policy.
@@ -150,12 +245,10 @@ func (r *SFTPReplica) Prune(ctx context.Context, generation string, retention Re
 	if retention.SnapshotRetention <= 0 {
 		return nil
 	}
-	client, err := r.connect()
-	if err != nil {
-		return err
-	}
 	baseDir := r.remotePath(generation)
-	return pruneSFTPGeneration(client, baseDir, retention.SnapshotRetention)
+	return r.withClient(func(client *sftp.Client) error {
+		return pruneSFTPGeneration(client, baseDir, retention.SnapshotRetention)
+	})
 }
 
 // FetchSnapshot downloads and decodes the referenced snapshot blob.
@@ -163,12 +256,11 @@ func (r *SFTPReplica) FetchSnapshot(ctx context.Context, generation string, desc
 	if err := ctx.Err(); err != nil {
 		return nil, err
 	}
-	client, err := r.connect()
-	if err != nil {
-		return nil, err
-	}
-	data, err := readRemoteFile(client, r.remotePath(desc.Name))
-	if err != nil {
+	var data []byte
+	if err := r.withClient(func(client *sftp.Client) (err error) {
+		data, err = readRemoteFile(client, r.remotePath(desc.Name))
+		return err
+	}); err != nil {
 		return nil, err
 	}
 	return decodeSnapshotFile(data)
@@ -179,12 +271,11 @@ func (r *SFTPReplica) FetchSegment(ctx context.Context, generation string, desc
 	if err := ctx.Err(); err != nil {
 		return nil, err
 	}
-	client, err := r.connect()
-	if err != nil {
-		return nil, err
-	}
-	data, err := readRemoteFile(client, r.remotePath(desc.Name))
-	if err != nil {
+	var data []byte
+	if err := r.withClient(func(client *sftp.Client) (err error) {
+		data, err = readRemoteFile(client, r.remotePath(desc.Name))
+		return err
+	}); err != nil {
 		return nil, err
 	}
 	return decodeSegmentFile(data)
@@ -195,15 +286,110 @@ func (r *SFTPReplica) LatestState(ctx context.Context) (*RestoreState, error) {
 	if err := ctx.Err(); err != nil {
 		return nil, err
 	}
-	client, err := r.connect()
-	if err != nil {
+	var state *RestoreState
+	if err := r.withClient(func(client *sftp.Client) (err error) {
+		state, err = r.loadState(client)
+		return err
+	}); err != nil {
 		return nil, err
 	}
-	state, err := r.loadState(client)
-	if err != nil {
+	return state, nil
+}
+
+// ListGenerations summarises the generation directories below the remote
+// path.
+func (r *SFTPReplica) ListGenerations(ctx context.Context) ([]GenerationInfo, error) {
+	if err := ctx.Err(); err != nil {
 		return nil, err
 	}
-	return state, nil
+	lister := generationLister{}
+	if err := r.withClient(func(client *sftp.Client) error {
+		base := r.remotePath("")
+		if base == "" {
+			base = "."
+		}
+		entries, err := client.ReadDir(base)
+		if err != nil {
+			if isSFTPNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				continue
+			}
+			for _, kind := range []string{"snapshots", "segments"} {
+				names, err := client.ReadDir(r.remotePath(path.Join(entry.Name(), kind)))
+				if err != nil {
+					if isSFTPNotExist(err) {
+						continue
+					}
+					return err
+				}
+				for _, name := range names {
+					if !name.IsDir() {
+						lister.add(path.Join(entry.Name(), kind, name.Name()))
+					}
+				}
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return lister.list(), nil
+}
+
+// DeleteGeneration removes the generation directory, clearing the state
+// manifest first if it references generation.
+func (r *SFTPReplica) DeleteGeneration(ctx context.Context, generation string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := checkGenerationID(generation); err != nil {
+		return err
+	}
+	return r.withClient(func(client *sftp.Client) error {
+		r.stateMu.Lock()
+		state, err := r.loadState(client)
+		if err == nil && state.Generation == generation {
+			err = client.Remove(r.remotePath(stateFileName))
+		}
+		r.stateMu.Unlock()
+		if err != nil && !isSFTPNotExist(err) {
+			return err
+		}
+		if err := client.RemoveAll(r.remotePath(generation)); err != nil && !isSFTPNotExist(err) {
+			return err
+		}
+		return nil
+	})
+}
+
+// HealthCheck connects to the server and stats the base directory. A missing
+// directory is healthy because it is created on first write.
+func (r *SFTPReplica) HealthCheck(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	base := r.remotePath("")
+	if base == "" {
+		base = "."
+	}
+	return r.withClient(func(client *sftp.Client) error {
+		info, err := client.Stat(base)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("sftp path %s is not a directory", base)
+		}
+		return nil
+	})
 }
 
 func (r *SFTPReplica) updateState(ctx context.Context, client *sftp.Client, generation string, snapshot *SnapshotDescriptor, segment *SegmentDescriptor) error {
@@ -231,7 +417,27 @@ func (r *SFTPReplica) updateState(ctx context.Context, client *sftp.Client, gene
 	if err != nil {
 		return err
 	}
-	return writeRemoteFile(client, r.remotePath(stateFileName), data)
+	return r.writeRemoteFile(client, r.remotePath(stateFileName), data)
+}
+
+func (r *SFTPReplica) replaceState(ctx context.Context, client *sftp.Client, generation string, merged SegmentDescriptor, replaced []SegmentDescriptor) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.stateMu.Lock()
+	defer r.stateMu.Unlock()
+	state, err := r.loadState(client)
+	if err != nil {
+		return err
+	}
+	if !replaceSegmentDescriptors(state, generation, merged, replaced) {
+		return nil
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return r.writeRemoteFile(client, r.remotePath(stateFileName), data)
 }
 
 func (r *SFTPReplica) loadState(client *sftp.Client) (*RestoreState, error) {
@@ -249,6 +455,42 @@ func (r *SFTPReplica) loadState(client *sftp.Client) (*RestoreState, error) {
 	return &state, nil
 }
 
+// withClient runs fn against the cached SFTP client. If fn fails because the
+// connection died (for example the server dropped an idle session), the
+// client is discarded and fn is retried once on a fresh connection.
+func (r *SFTPReplica) withClient(fn func(*sftp.Client) error) error {
+	client, err := r.connect()
+	if err != nil {
+		return err
+	}
+	err = fn(client)
+	if !isSFTPConnectionLost(err) {
+		return err
+	}
+	r.discardClient(client)
+	client, reconnectErr := r.connect()
+	if reconnectErr != nil {
+		return fmt.Errorf("sftp: reconnect after %v: %w", err, reconnectErr)
+	}
+	return fn(client)
+}
+
+// discardClient closes client and its SSH transport if it is still the cached
+// connection. A client already replaced by another goroutine is left alone.
+func (r *SFTPReplica) discardClient(client *sftp.Client) {
+	r.connMu.Lock()
+	defer r.connMu.Unlock()
+	if r.client != client {
+		return
+	}
+	_ = r.client.Close()
+	r.client = nil
+	if r.sshClient != nil {
+		_ = r.sshClient.Close()
+		r.sshClient = nil
+	}
+}
+
 func (r *SFTPReplica) connect() (*sftp.Client, error) {
 	r.connMu.Lock()
 	defer r.connMu.Unlock()
@@ -273,10 +515,14 @@ func (r *SFTPReplica) connect() (*sftp.Client, error) {
 		}
 		auth = append(auth, ssh.PublicKeys(signer))
 	}
+	hostKeyCallback, err := sftpHostKeyCallback(r.cfg)
+	if err != nil {
+		return nil, err
+	}
 	config := &ssh.ClientConfig{
 		User:            r.cfg.User,
 		Auth:            auth,
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		HostKeyCallback: hostKeyCallback,
 		Timeout:         30 * time.Second,
 	}
 	port := r.cfg.Port
@@ -295,9 +541,57 @@ func (r *SFTPReplica) connect() (*sftp.Client, error) {
 	}
 	r.sshClient = sshClient
 	r.client = client
+	go func() {
+		// Drop the cached connection as soon as the transport dies so the
+		// next operation reconnects instead of failing on a dead client.
+		_ = sshClient.Wait()
+		r.discardClient(client)
+	}()
 	return client, nil
 }
 
+// sftpHostKeyCallback builds the host key verifier for cfg. When both a
+// fingerprint and a known_hosts file are configured the key must satisfy both.
+func sftpHostKeyCallback(cfg SFTPReplicaConfig) (ssh.HostKeyCallback, error) {
+	if cfg.Insecure {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+	knownHostsPath := cfg.KnownHostsPath
+	if knownHostsPath == "" && cfg.HostKeyFingerprint == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("sftp: no knownHostsPath or hostKeyFingerprint configured and home directory is unknown: %w", err)
+		}
+		knownHostsPath = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	var knownHostsCallback ssh.HostKeyCallback
+	if knownHostsPath != "" {
+		cb, err := knownhosts.New(knownHostsPath)
+		if err != nil {
+			return nil, fmt.Errorf("sftp: load known hosts %q: %w", knownHostsPath, err)
+		}
+		knownHostsCallback = cb
+	}
+	want := cfg.HostKeyFingerprint
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if want != "" {
+			if got := ssh.FingerprintSHA256(key); got != want {
+				return fmt.Errorf("sftp: host key fingerprint mismatch for %s: got %s, want %s", hostname, got, want)
+			}
+		}
+		if knownHostsCallback != nil {
+			if err := knownHostsCallback(hostname, remote, key); err != nil {
+				var keyErr *knownhosts.KeyError
+				if errors.As(err, &keyErr) && len(keyErr.Want) == 0 {
+					return fmt.Errorf("sftp: host %s (%s %s) is not listed in %s: %w", hostname, key.Type(), ssh.FingerprintSHA256(key), knownHostsPath, err)
+				}
+				return fmt.Errorf("sftp: host key for %s (%s %s) does not match %s: %w", hostname, key.Type(), ssh.FingerprintSHA256(key), knownHostsPath, err)
+			}
+		}
+		return nil
+	}, nil
+}
+
 func (r *SFTPReplica) remotePath(rel string) string {
 	rel = path.Clean(rel)
 	if rel == "." {
@@ -335,8 +629,8 @@ func formatSFTPReplicaName(cfg SFTPReplicaConfig) string {
 	return "sftp://" + host + pathPart
 }
 
-func writeRemoteFile(client *sftp.Client, filename string, data []byte) error {
-	if err := ensureRemoteDir(client, path.Dir(filename)); err != nil {
+func (r *SFTPReplica) writeRemoteFile(client *sftp.Client, filename string, data []byte) error {
+	if err := r.ensureRemoteDir(client, path.Dir(filename)); err != nil {
 		return err
 	}
 	f, err := client.Create(filename)
@@ -344,6 +638,11 @@ func writeRemoteFile(client *sftp.Client, filename string, data []byte) error {
 		return err
 	}
 	defer f.Close()
+	if mode := r.cfg.FileMode.Perm(); mode != 0 {
+		if err := f.Chmod(mode); err != nil {
+			return err
+		}
+	}
 	if _, err := f.Write(data); err != nil {
 		return err
 	}
@@ -359,11 +658,34 @@ func readRemoteFile(client *sftp.Client, filename string) ([]byte, error) {
 	return io.ReadAll(f)
 }
 
-func ensureRemoteDir(client *sftp.Client, dir string) error {
+// ensureRemoteDir creates dir and its parents, applying DirMode to every
+// directory it had to create.
+func (r *SFTPReplica) ensureRemoteDir(client *sftp.Client, dir string) error {
 	if dir == "" || dir == "." || dir == "/" {
 		return nil
 	}
-	return client.MkdirAll(dir)
+	mode := r.cfg.DirMode.Perm()
+	if mode == 0 {
+		return client.MkdirAll(dir)
+	}
+	var missing []string
+	for d := path.Clean(dir); d != "." && d != "/"; d = path.Dir(d) {
+		if _, err := client.Stat(d); err == nil {
+			break
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		missing = append(missing, d)
+	}
+	if err := client.MkdirAll(dir); err != nil {
+		return err
+	}
+	for _, d := range missing {
+		if err := client.Chmod(d, mode); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func pruneSFTPGeneration(client *sftp.Client, base string, retention time.Duration) error {
@@ -436,6 +758,24 @@ func pruneSFTPGeneration(client *sftp.Client, base string, retention time.Durati
 	return nil
 }
 
+// isSFTPConnectionLost reports whether err indicates the underlying SSH
+// connection is no longer usable. io.EOF and io.ErrUnexpectedEOF are not
+// counted: they also end reads and writes that reached the server, and
+// retrying those could apply a state update twice.
+func isSFTPConnectionLost(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, sftp.ErrSSHFxConnectionLost) || errors.Is(err, sftp.ErrSSHFxNoConnection) ||
+		errors.Is(err, net.ErrClosed) || errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "use of closed network connection") ||
+		strings.Contains(msg, "broken pipe") ||
+		strings.Contains(msg, "connection lost")
+}
+
 func isSFTPNotExist(err error) bool {
 	if err == nil {
 		return false
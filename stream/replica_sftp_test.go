@@ -0,0 +1,190 @@
+package stream
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func TestSFTPReplicaHostKeyVerification(t *testing.T) {
+	srv := newTestSFTPServer(t)
+
+	_, otherPriv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	otherSigner, err := ssh.NewSignerFromKey(otherPriv)
+	require.NoError(t, err)
+
+	writeKnownHosts := func(key ssh.PublicKey) string {
+		path := filepath.Join(t.TempDir(), "known_hosts")
+		line := knownhosts.Line([]string{knownhosts.Normalize(srv.addr())}, key)
+		require.NoError(t, os.WriteFile(path, []byte(line+"\n"), 0o600))
+		return path
+	}
+
+	testCases := []struct {
+		name   string
+		cfg    func(*SFTPReplicaConfig)
+		expErr string
+	}{
+		{
+			name: "fingerprint match",
+			cfg: func(cfg *SFTPReplicaConfig) {
+				cfg.HostKeyFingerprint = ssh.FingerprintSHA256(srv.hostKey.PublicKey())
+			},
+		},
+		{
+			name: "fingerprint mismatch",
+			cfg: func(cfg *SFTPReplicaConfig) {
+				cfg.HostKeyFingerprint = ssh.FingerprintSHA256(otherSigner.PublicKey())
+			},
+			expErr: "host key fingerprint mismatch",
+		},
+		{
+			name: "known hosts match",
+			cfg: func(cfg *SFTPReplicaConfig) {
+				cfg.KnownHostsPath = writeKnownHosts(srv.hostKey.PublicKey())
+			},
+		},
+		{
+			name: "known hosts mismatch",
+			cfg: func(cfg *SFTPReplicaConfig) {
+				cfg.KnownHostsPath = writeKnownHosts(otherSigner.PublicKey())
+			},
+			expErr: "does not match",
+		},
+		{
+			name: "insecure",
+			cfg: func(cfg *SFTPReplicaConfig) {
+				cfg.Insecure = true
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			replica, err := NewSFTPReplica(context.Background(), srv.config(tc.cfg))
+			require.NoError(t, err)
+			defer replica.Close(context.Background())
+
+			_, err = replica.LatestState(context.Background())
+			if tc.expErr != "" {
+				require.ErrorContains(t, err, tc.expErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestSFTPReplicaReconnectsAfterDrop(t *testing.T) {
+	srv := newTestSFTPServer(t)
+	replica, err := NewSFTPReplica(context.Background(), srv.config(func(cfg *SFTPReplicaConfig) {
+		cfg.Insecure = true
+	}))
+	require.NoError(t, err)
+	defer replica.Close(context.Background())
+
+	segment := func(txid uint64) *Segment {
+		return &Segment{
+			Header: SegmentHeader{Magic: segmentMagic, Version: segmentVersion, TxID: txid, ParentTxID: txid - 1, Compression: CompressionNone},
+			Data:   []byte("payload"),
+		}
+	}
+	require.NoError(t, replica.PutSegment(context.Background(), "gen", segment(2)))
+
+	t.Log("Dropping server connections")
+	srv.dropConnections()
+
+	require.NoError(t, replica.PutSegment(context.Background(), "gen", segment(3)))
+	state, err := replica.LatestState(context.Background())
+	require.NoError(t, err)
+	require.Len(t, state.Segments, 2)
+}
+
+func TestSFTPReplicaHealthCheck(t *testing.T) {
+	srv := newTestSFTPServer(t)
+	missing := filepath.Join(srv.root, "not-yet-created")
+	replica, err := NewSFTPReplica(context.Background(), srv.config(func(cfg *SFTPReplicaConfig) {
+		cfg.Insecure = true
+		cfg.Path = missing
+	}))
+	require.NoError(t, err)
+	defer replica.Close(context.Background())
+	require.NoError(t, replica.HealthCheck(context.Background()), "a base dir created on first write is healthy")
+
+	require.NoError(t, os.WriteFile(missing, nil, 0o600))
+	require.ErrorContains(t, replica.HealthCheck(context.Background()), "is not a directory")
+
+	replica, err = NewSFTPReplica(context.Background(), srv.config(func(cfg *SFTPReplicaConfig) {
+		cfg.Insecure = true
+		cfg.Password = "wrong"
+	}))
+	require.NoError(t, err)
+	defer replica.Close(context.Background())
+	require.Error(t, replica.HealthCheck(context.Background()))
+}
+
+func TestSFTPReplicaModes(t *testing.T) {
+	srv := newTestSFTPServer(t)
+	base := filepath.Join(srv.root, "shared")
+	replica, err := NewSFTPReplica(context.Background(), srv.config(func(cfg *SFTPReplicaConfig) {
+		cfg.Insecure = true
+		cfg.Path = base
+		cfg.DirMode = 0o770
+		cfg.FileMode = 0o660
+	}))
+	require.NoError(t, err)
+	defer replica.Close(context.Background())
+
+	require.NoError(t, replica.PutSegment(context.Background(), "gen", &Segment{
+		Header: SegmentHeader{Magic: segmentMagic, Version: segmentVersion, TxID: 2, ParentTxID: 1, Compression: CompressionNone},
+		Data:   []byte("payload"),
+	}))
+	info, err := os.Stat(base)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o770), info.Mode().Perm())
+	requireModes(t, base, 0o770, 0o660)
+}
+
+func TestSFTPReplicaWithClientRetriesOnce(t *testing.T) {
+	srv := newTestSFTPServer(t)
+	replica, err := NewSFTPReplica(context.Background(), srv.config(func(cfg *SFTPReplicaConfig) {
+		cfg.Insecure = true
+	}))
+	require.NoError(t, err)
+	defer replica.Close(context.Background())
+
+	var clients []*sftp.Client
+	err = replica.withClient(func(client *sftp.Client) error {
+		clients = append(clients, client)
+		return sftp.ErrSSHFxConnectionLost
+	})
+	require.ErrorIs(t, err, sftp.ErrSSHFxConnectionLost)
+	require.Len(t, clients, 2, "expected exactly one reconnect attempt")
+	require.NotSame(t, clients[0], clients[1])
+}
+
+func TestIsSFTPConnectionLost(t *testing.T) {
+	require.False(t, isSFTPConnectionLost(nil))
+	require.False(t, isSFTPConnectionLost(os.ErrNotExist))
+	require.False(t, isSFTPConnectionLost(io.EOF))
+	require.False(t, isSFTPConnectionLost(fmt.Errorf("read: %w", io.ErrUnexpectedEOF)))
+	require.True(t, isSFTPConnectionLost(sftp.ErrSSHFxConnectionLost))
+	require.True(t, isSFTPConnectionLost(sftp.ErrSSHFxNoConnection))
+	require.True(t, isSFTPConnectionLost(fmt.Errorf("write: %w", syscall.ECONNRESET)))
+	require.True(t, isSFTPConnectionLost(fmt.Errorf("write: %w", net.ErrClosed)))
+	require.True(t, isSFTPConnectionLost(errors.New("write tcp 127.0.0.1:22: use of closed network connection")))
+}
@@ -0,0 +1,159 @@
+package stream
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestGenerationLister(t *testing.T) {
+	lister := generationLister{}
+	for _, name := range []string{
+		"bbbb/snapshots/2024-01-02T00:00:00Z-0000000000000010.snapshot.cbor",
+		"bbbb/segments/0000000000000011.segment.cbor",
+		"bbbb/segments/0000000000000014.segment.cbor",
+		"aaaa/snapshots/2024-01-01T00:00:00Z-0000000000000002.snapshot.cbor",
+		"aaaa/snapshots/2024-01-01T01:00:00Z-0000000000000005.snapshot.cbor",
+		"aaaa/segments/0000000000000003.segment.cbor",
+		"aaaa/segments/garbage.segment.cbor",
+		"aaaa/segments/0000000000000004.tmp",
+		"aaaa/other/0000000000000006.segment.cbor",
+		"_state.json",
+	} {
+		lister.add(name)
+	}
+	require.Equal(t, []GenerationInfo{
+		{ID: "aaaa", Snapshots: 2, Segments: 1, MinTxID: 2, MaxTxID: 5},
+		{ID: "bbbb", Snapshots: 1, Segments: 2, MinTxID: 0x10, MaxTxID: 0x14},
+	}, lister.list())
+}
+
+// replicaTestConfigs lists the replica backends that run against in-process
+// servers.
+func replicaTestConfigs() []struct {
+	name string
+	cfg  func(t *testing.T) ReplicaConfig
+} {
+	return []struct {
+		name string
+		cfg  func(t *testing.T) ReplicaConfig
+	}{
+		{
+			name: "file",
+			cfg: func(t *testing.T) ReplicaConfig {
+				return &FileReplicaConfig{Path: t.TempDir()}
+			},
+		},
+		{
+			name: "sftp",
+			cfg: func(t *testing.T) ReplicaConfig {
+				srv := newTestSFTPServer(t)
+				return srv.config(func(cfg *SFTPReplicaConfig) {
+					cfg.HostKeyFingerprint = ssh.FingerprintSHA256(srv.hostKey.PublicKey())
+				})
+			},
+		},
+		{
+			name: "webdav",
+			cfg: func(t *testing.T) ReplicaConfig {
+				srv, _ := newTestWebDAVServer(t)
+				return &WebDAVReplicaConfig{URL: srv.URL, User: "replicator", Password: "secret"}
+			},
+		},
+		{
+			name: "blob",
+			cfg: func(t *testing.T) ReplicaConfig {
+				return &blobReplicaConfig{store: newMemBlobStore()}
+			},
+		},
+	}
+}
+
+func TestReplicaListGenerations(t *testing.T) {
+	for _, tc := range replicaTestConfigs() {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := tc.cfg(t)
+			replica, err := cfg.buildReplica(context.Background())
+			require.NoError(t, err)
+			defer replica.Close(context.Background())
+
+			generations, err := replica.ListGenerations(context.Background())
+			require.NoError(t, err)
+			require.Empty(t, generations)
+
+			db, ctrl, _ := openReplicatedDB(t, Config{Replicas: []ReplicaConfig{cfg}})
+			putKeys(t, db, "widgets", 3)
+			// The first snapshot supersedes the segment written with it, which
+			// the retention loop prunes in the background; prune now so the
+			// listing matches the state.
+			ctrl.enforceRetention(context.Background())
+
+			state, err := replica.LatestState(context.Background())
+			require.NoError(t, err)
+			require.NotEmpty(t, state.Segments)
+			generations, err = replica.ListGenerations(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, []GenerationInfo{{
+				ID:        state.Generation,
+				Snapshots: 1,
+				Segments:  len(state.Segments),
+				MinTxID:   state.Segments[0].FirstTxID - 1,
+				MaxTxID:   state.Segments[len(state.Segments)-1].LastTxID,
+			}}, generations)
+		})
+	}
+}
+
+func TestDeleteReplicaGeneration(t *testing.T) {
+	ctx := context.Background()
+	for _, tc := range replicaTestConfigs() {
+		t.Run(tc.name, func(t *testing.T) {
+			replica, err := tc.cfg(t).buildReplica(ctx)
+			require.NoError(t, err)
+			defer replica.Close(ctx)
+
+			created := time.Now().UTC()
+			for i, generation := range []string{"stale", "current"} {
+				txid := uint64(10 * (i + 1))
+				require.NoError(t, replica.PutSnapshot(ctx, generation, &Snapshot{
+					Header: SnapshotHeader{TxID: txid, CreatedAt: created.Add(time.Duration(i) * time.Second)},
+					Data:   []byte("snapshot"),
+				}))
+				require.NoError(t, replica.PutSegment(ctx, generation, &Segment{
+					Header: SegmentHeader{TxID: txid + 1, ParentTxID: txid, CreatedAt: created},
+					Data:   []byte("segment"),
+				}))
+			}
+			ids := func() []string {
+				generations, err := replica.ListGenerations(ctx)
+				require.NoError(t, err)
+				var ids []string
+				for _, gen := range generations {
+					ids = append(ids, gen.ID)
+				}
+				return ids
+			}
+			require.Equal(t, []string{"stale", "current"}, ids())
+
+			require.ErrorIs(t, DeleteReplicaGeneration(ctx, []Replica{replica}, "current", false), ErrGenerationInUse)
+			require.Error(t, DeleteReplicaGeneration(ctx, []Replica{replica}, "../current", true))
+			require.Equal(t, []string{"stale", "current"}, ids())
+
+			require.NoError(t, DeleteReplicaGeneration(ctx, []Replica{replica}, "stale", false))
+			require.Equal(t, []string{"current"}, ids())
+			state, err := replica.LatestState(ctx)
+			require.NoError(t, err)
+			require.Equal(t, "current", state.Generation)
+			require.Len(t, state.Segments, 1)
+
+			require.NoError(t, DeleteReplicaGeneration(ctx, []Replica{replica}, "current", true))
+			require.Empty(t, ids())
+			state, err = replica.LatestState(ctx)
+			require.NoError(t, err)
+			require.Empty(t, state.Generation)
+		})
+	}
+}
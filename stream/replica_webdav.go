@@ -0,0 +1,541 @@
+package stream
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var errWebDAVNotFound = errors.New("webdav resource not found")
+
+// WebDAVReplicaConfig configures a WebDAV-backed replica, such as a NAS share.
+type WebDAVReplicaConfig struct {
+	ReplicaOptions
+	URL      string `json:"url"`
+	User     string `json:"user"`
+	Password string `json:"password"`
+	Path     string `json:"path"`
+}
+
+func (cfg *WebDAVReplicaConfig) buildReplica(_ context.Context) (Replica, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("webdav replica config is nil")
+	}
+	return NewWebDAVReplica(cfg)
+}
+
+func (cfg *WebDAVReplicaConfig) validate() error {
+	_, err := cfg.parseURL()
+	return err
+}
+
+// parseURL validates cfg and returns its parsed URL.
+func (cfg *WebDAVReplicaConfig) parseURL() (*url.URL, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("webdav replica config is nil")
+	}
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("webdav url is required")
+	}
+	base, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("parse webdav url: %w", err)
+	}
+	if base.Scheme != "http" && base.Scheme != "https" {
+		return nil, fmt.Errorf("webdav url must use http or https, got %q", base.Scheme)
+	}
+	return base, nil
+}
+
+// WebDAVReplica stores artefacts on a WebDAV server using PUT, GET, PROPFIND
+// and DELETE, with the same layout as the other replicas.
+type WebDAVReplica struct {
+	name    string
+	baseURL *url.URL
+	prefix  string
+	cfg     WebDAVReplicaConfig
+	client  *http.Client
+	mu      sync.Mutex
+
+	dirMu sync.Mutex
+	dirs  map[string]struct{}
+}
+
+// NewWebDAVReplica constructs a WebDAV replica. No request is made until the
+// first artefact is written or read.
+func NewWebDAVReplica(cfg *WebDAVReplicaConfig) (*WebDAVReplica, error) {
+	base, err := cfg.parseURL()
+	if err != nil {
+		return nil, err
+	}
+	prefix := strings.Trim(cfg.Path, "/")
+	return &WebDAVReplica{
+		name:    strings.TrimSuffix(base.JoinPath(prefix).Redacted(), "/"),
+		baseURL: base,
+		prefix:  prefix,
+		cfg:     *cfg,
+		client:  &http.Client{},
+		dirs:    make(map[string]struct{}),
+	}, nil
+}
+
+// Name implements Replica.
+func (r *WebDAVReplica) Name() string { return r.name }
+
+func (r *WebDAVReplica) replicaOptions() ReplicaOptions { return r.cfg.ReplicaOptions }
+
+// Close releases idle HTTP connections.
+func (r *WebDAVReplica) Close(context.Context) error {
+	r.client.CloseIdleConnections()
+	return nil
+}
+
+// PutSnapshot uploads the snapshot artefact and updates replica state.
+func (r *WebDAVReplica) PutSnapshot(ctx context.Context, generation string, snapshot *Snapshot) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	name := snapshotObjectName(generation, snapshot.createdAt(), snapshot.Header.TxID)
+	body, size, err := snapshotReader(snapshot)
+	if err != nil {
+		return err
+	}
+	if err := r.put(ctx, name, body, size); err != nil {
+		return err
+	}
+	desc := SnapshotDescriptor{Name: name, Timestamp: snapshot.createdAt(), Size: int64(len(snapshot.Data))}
+	return r.updateState(ctx, generation, &desc, nil)
+}
+
+// PutSegment uploads the segment artefact and appends it to replica state.
+func (r *WebDAVReplica) PutSegment(ctx context.Context, generation string, segment *Segment) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	name := segmentObjectName(generation, segment.Header.TxID)
+	encoded, err := marshalSegment(segment)
+	if err != nil {
+		return err
+	}
+	if err := r.put(ctx, name, bytes.NewReader(encoded), int64(len(encoded))); err != nil {
+		return err
+	}
+	desc := SegmentDescriptor{
+		Name:      name,
+		FirstTxID: segment.Header.ParentTxID + 1,
+		LastTxID:  segment.Header.TxID,
+		Timestamp: segment.Header.CreatedAt,
+		Size:      int64(len(segment.Data)),
+	}
+	return r.updateState(ctx, generation, nil, &desc)
+}
+
+// ReplaceSegments uploads merged, swaps it into the state manifest in place of
+// replaced and deletes the original segments.
+func (r *WebDAVReplica) ReplaceSegments(ctx context.Context, generation string, merged *Segment, replaced []SegmentDescriptor) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	name := segmentObjectName(generation, merged.Header.TxID)
+	encoded, err := marshalSegment(merged)
+	if err != nil {
+		return err
+	}
+	if err := r.put(ctx, name, bytes.NewReader(encoded), int64(len(encoded))); err != nil {
+		return err
+	}
+	desc := SegmentDescriptor{
+		Name:      name,
+		FirstTxID: merged.Header.ParentTxID + 1,
+		LastTxID:  merged.Header.TxID,
+		Timestamp: merged.Header.CreatedAt,
+		Size:      int64(len(merged.Data)),
+	}
+	if err := r.replaceState(ctx, generation, desc, replaced); err != nil {
+		return err
+	}
+	for _, old := range replaced {
+		if old.Name == name {
+			continue
+		}
+		if err := r.delete(ctx, old.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Prune deletes snapshots older than the retention window, always keeping the
+// newest, along with the segments covered by the oldest retained snapshot.
+func (r *WebDAVReplica) Prune(ctx context.Context, generation string, retention RetentionConfig) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if retention.SnapshotRetention <= 0 {
+		return nil
+	}
+	cutoff := time.Now().Add(-retention.SnapshotRetention)
+	snapshotsDir := path.Join(generation, "snapshots")
+	names, err := r.list(ctx, snapshotsDir)
+	if err != nil {
+		return err
+	}
+	type snapInfo struct {
+		name    string
+		created time.Time
+		txid    uint64
+	}
+	var snaps []snapInfo
+	for _, name := range names {
+		created, txid, err := parseSnapshotObject(name)
+		if err != nil {
+			continue
+		}
+		snaps = append(snaps, snapInfo{name: name, created: created, txid: txid})
+	}
+	if len(snaps) == 0 {
+		return nil
+	}
+	sort.Slice(snaps, func(i, j int) bool { return snaps[i].created.After(snaps[j].created) })
+
+	keepTxID := snaps[0].txid
+	for i, snap := range snaps {
+		if i == 0 || snap.created.After(cutoff) {
+			keepTxID = snap.txid
+			continue
+		}
+		if err := r.delete(ctx, path.Join(snapshotsDir, snap.name)); err != nil {
+			return err
+		}
+	}
+
+	segmentsDir := path.Join(generation, "segments")
+	names, err = r.list(ctx, segmentsDir)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		txid, err := parseSegmentObject(name)
+		if err != nil {
+			continue
+		}
+		if txid <= keepTxID {
+			if err := r.delete(ctx, path.Join(segmentsDir, name)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// FetchSnapshot downloads and decodes a snapshot artefact.
+func (r *WebDAVReplica) FetchSnapshot(ctx context.Context, generation string, desc *SnapshotDescriptor) (*Snapshot, error) {
+	data, err := r.get(ctx, desc.Name)
+	if err != nil {
+		return nil, err
+	}
+	return decodeSnapshotFile(data)
+}
+
+// FetchSegment downloads and decodes a segment artefact.
+func (r *WebDAVReplica) FetchSegment(ctx context.Context, generation string, desc SegmentDescriptor) (*Segment, error) {
+	data, err := r.get(ctx, desc.Name)
+	if err != nil {
+		return nil, err
+	}
+	return decodeSegmentFile(data)
+}
+
+// LatestState retrieves the replica state manifest.
+func (r *WebDAVReplica) LatestState(ctx context.Context) (*RestoreState, error) {
+	data, err := r.get(ctx, stateFileName)
+	if err != nil {
+		if errors.Is(err, errWebDAVNotFound) {
+			return &RestoreState{}, nil
+		}
+		return nil, err
+	}
+	var state RestoreState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// ListGenerations summarises the generation collections below the replica
+// path.
+func (r *WebDAVReplica) ListGenerations(ctx context.Context) ([]GenerationInfo, error) {
+	generations, err := r.list(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	lister := generationLister{}
+	for _, generation := range generations {
+		if generation == stateFileName {
+			continue
+		}
+		for _, kind := range []string{"snapshots", "segments"} {
+			names, err := r.list(ctx, path.Join(generation, kind))
+			if err != nil {
+				return nil, err
+			}
+			for _, name := range names {
+				lister.add(path.Join(generation, kind, name))
+			}
+		}
+	}
+	return lister.list(), nil
+}
+
+// DeleteGeneration removes the generation collection, clearing the state
+// manifest first if it references generation.
+func (r *WebDAVReplica) DeleteGeneration(ctx context.Context, generation string) error {
+	if err := checkGenerationID(generation); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	state, err := r.LatestState(ctx)
+	if err == nil && state.Generation == generation {
+		err = r.delete(ctx, stateFileName)
+	}
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return r.delete(ctx, generation+"/")
+}
+
+// HealthCheck issues a PROPFIND on the replica path. A path that does not
+// exist yet is healthy because collections are created on first write.
+func (r *WebDAVReplica) HealthCheck(ctx context.Context) error {
+	req, err := r.newRequest(ctx, "PROPFIND", "/", strings.NewReader(propfindBody))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Depth", "0")
+	req.Header.Set("Content-Type", "application/xml")
+	resp, err := r.do(req, http.StatusMultiStatus)
+	if err != nil {
+		if errors.Is(err, errWebDAVNotFound) {
+			return nil
+		}
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (r *WebDAVReplica) updateState(ctx context.Context, generation string, snapshot *SnapshotDescriptor, segment *SegmentDescriptor) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	state, err := r.LatestState(ctx)
+	if err != nil {
+		return err
+	}
+	if state.Generation != generation {
+		state = &RestoreState{Generation: generation}
+	}
+	if snapshot != nil {
+		state.Snapshot = snapshot
+		state.Segments = nil
+	}
+	if segment != nil {
+		state.Segments = append(state.Segments, *segment)
+	}
+	state.LastUploaded = time.Now().UTC()
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return r.put(ctx, stateFileName, bytes.NewReader(data), int64(len(data)))
+}
+
+func (r *WebDAVReplica) replaceState(ctx context.Context, generation string, merged SegmentDescriptor, replaced []SegmentDescriptor) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	state, err := r.LatestState(ctx)
+	if err != nil {
+		return err
+	}
+	if !replaceSegmentDescriptors(state, generation, merged, replaced) {
+		return nil
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return r.put(ctx, stateFileName, bytes.NewReader(data), int64(len(data)))
+}
+
+func (r *WebDAVReplica) put(ctx context.Context, rel string, body io.Reader, size int64) error {
+	if err := r.mkdirAll(ctx, path.Dir(path.Join(r.prefix, rel))); err != nil {
+		return err
+	}
+	req, err := r.newRequest(ctx, http.MethodPut, rel, body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := r.do(req, http.StatusOK, http.StatusCreated, http.StatusNoContent)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (r *WebDAVReplica) get(ctx context.Context, rel string) ([]byte, error) {
+	req, err := r.newRequest(ctx, http.MethodGet, rel, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.do(req, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+func (r *WebDAVReplica) delete(ctx context.Context, rel string) error {
+	req, err := r.newRequest(ctx, http.MethodDelete, rel, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := r.do(req, http.StatusOK, http.StatusNoContent)
+	if err != nil {
+		if errors.Is(err, errWebDAVNotFound) {
+			return nil
+		}
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// mkdirAll creates every collection leading to dir, which is relative to the
+// configured URL and includes Path. Collections known to exist are remembered
+// so steady-state uploads issue a single PUT.
+func (r *WebDAVReplica) mkdirAll(ctx context.Context, dir string) error {
+	if dir == "." || dir == "" {
+		return nil
+	}
+	r.dirMu.Lock()
+	defer r.dirMu.Unlock()
+	var current string
+	for _, part := range strings.Split(dir, "/") {
+		current = path.Join(current, part)
+		if _, ok := r.dirs[current]; ok {
+			continue
+		}
+		req, err := r.newURLRequest(ctx, "MKCOL", r.baseURL.JoinPath(current).String()+"/", nil)
+		if err != nil {
+			return err
+		}
+		// 405 Method Not Allowed is returned when the collection exists.
+		resp, err := r.do(req, http.StatusCreated, http.StatusMethodNotAllowed)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		r.dirs[current] = struct{}{}
+	}
+	return nil
+}
+
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?><D:propfind xmlns:D="DAV:"><D:prop><D:resourcetype/></D:prop></D:propfind>`
+
+type webdavMultistatus struct {
+	Responses []struct {
+		Href string `xml:"href"`
+	} `xml:"DAV: response"`
+}
+
+// list returns the names of the direct members of the collection dir. A
+// missing collection has no members.
+func (r *WebDAVReplica) list(ctx context.Context, dir string) ([]string, error) {
+	req, err := r.newRequest(ctx, "PROPFIND", dir+"/", strings.NewReader(propfindBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Depth", "1")
+	req.Header.Set("Content-Type", "application/xml")
+	resp, err := r.do(req, http.StatusMultiStatus)
+	if err != nil {
+		if errors.Is(err, errWebDAVNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var ms webdavMultistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, fmt.Errorf("decode webdav PROPFIND %s: %w", dir, err)
+	}
+	self := path.Clean("/" + r.resolve(dir).Path)
+	var names []string
+	for _, entry := range ms.Responses {
+		href, err := url.Parse(entry.Href)
+		if err != nil {
+			continue
+		}
+		if path.Clean("/"+href.Path) == self {
+			continue
+		}
+		names = append(names, path.Base(strings.TrimSuffix(href.Path, "/")))
+	}
+	return names, nil
+}
+
+// resolve maps a replica-relative name to its URL below Path.
+func (r *WebDAVReplica) resolve(rel string) *url.URL {
+	u := r.baseURL.JoinPath(r.prefix, rel)
+	if strings.HasSuffix(rel, "/") && !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+	return u
+}
+
+func (r *WebDAVReplica) newRequest(ctx context.Context, method, rel string, body io.Reader) (*http.Request, error) {
+	return r.newURLRequest(ctx, method, r.resolve(rel).String(), body)
+}
+
+func (r *WebDAVReplica) newURLRequest(ctx context.Context, method, target string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	if r.cfg.User != "" || r.cfg.Password != "" {
+		req.SetBasicAuth(r.cfg.User, r.cfg.Password)
+	}
+	return req, nil
+}
+
+// do sends req and returns the response when its status is one of want. Any
+// other status is turned into an error and the body is discarded.
+func (r *WebDAVReplica) do(req *http.Request, want ...int) (*http.Response, error) {
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	for _, code := range want {
+		if resp.StatusCode == code {
+			return resp, nil
+		}
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errWebDAVNotFound
+	}
+	return nil, fmt.Errorf("webdav %s %s: %s", req.Method, req.URL.Redacted(), resp.Status)
+}
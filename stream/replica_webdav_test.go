@@ -0,0 +1,101 @@
+package stream
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/webdav"
+)
+
+// newTestWebDAVServer serves an in-memory WebDAV tree requiring basic auth
+// with user "replicator" and password "secret".
+func newTestWebDAVServer(t *testing.T) (*httptest.Server, webdav.FileSystem) {
+	t.Helper()
+	fs := webdav.NewMemFS()
+	handler := &webdav.Handler{FileSystem: fs, LockSystem: webdav.NewMemLS()}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if user, pass, ok := req.BasicAuth(); !ok || user != "replicator" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, req)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, fs
+}
+
+func TestWebDAVReplicaReplicateAndRestore(t *testing.T) {
+	srv, fs := newTestWebDAVServer(t)
+	cfg := &WebDAVReplicaConfig{URL: srv.URL, User: "replicator", Password: "secret", Path: "nas/backups/db"}
+	db, _, _ := openReplicatedDB(t, Config{Replicas: []ReplicaConfig{cfg}})
+	putKeys(t, db, "widgets", 3)
+
+	_, err := fs.Stat(context.Background(), "/nas/backups/db/"+stateFileName)
+	require.NoError(t, err, "state manifest is stored below the configured path")
+
+	target := filepath.Join(t.TempDir(), "restored.db")
+	require.NoError(t, RestoreStandalone(context.Background(), Config{
+		Replicas: []ReplicaConfig{&WebDAVReplicaConfig{URL: srv.URL, User: "replicator", Password: "secret", Path: "nas/backups/db"}},
+		Restore:  RestoreConfig{TargetPath: target},
+	}))
+	requireKeys(t, target, "widgets", 3)
+}
+
+func TestWebDAVReplicaPrune(t *testing.T) {
+	srv, _ := newTestWebDAVServer(t)
+	replica, err := NewWebDAVReplica(&WebDAVReplicaConfig{URL: srv.URL, User: "replicator", Password: "secret"})
+	require.NoError(t, err)
+	ctx := context.Background()
+	const generation = "0123456789abcdef"
+
+	now := time.Now().UTC()
+	for i, created := range []time.Time{now.Add(-72 * time.Hour), now.Add(-48 * time.Hour), now.Add(-time.Hour)} {
+		txid := uint64(10 * (i + 1))
+		require.NoError(t, replica.PutSnapshot(ctx, generation, &Snapshot{
+			Header: SnapshotHeader{TxID: txid, CreatedAt: created},
+			Data:   []byte("snapshot"),
+		}))
+		require.NoError(t, replica.PutSegment(ctx, generation, &Segment{
+			Header: SegmentHeader{TxID: txid + 1, ParentTxID: txid, CreatedAt: created},
+			Data:   []byte("segment"),
+		}))
+	}
+
+	require.NoError(t, replica.Prune(ctx, generation, RetentionConfig{SnapshotRetention: 24 * time.Hour}))
+
+	snapshots, err := replica.list(ctx, generation+"/snapshots")
+	require.NoError(t, err)
+	require.Len(t, snapshots, 1)
+	_, txid, err := parseSnapshotObject(snapshots[0])
+	require.NoError(t, err)
+	require.Equal(t, uint64(30), txid)
+
+	segments, err := replica.list(ctx, generation+"/segments")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"000000000000001f.segment.cbor"}, segments)
+}
+
+func TestWebDAVReplicaErrors(t *testing.T) {
+	srv, _ := newTestWebDAVServer(t)
+
+	_, err := NewWebDAVReplica(&WebDAVReplicaConfig{URL: "ftp://nas.local"})
+	require.ErrorContains(t, err, "http or https")
+
+	replica, err := NewWebDAVReplica(&WebDAVReplicaConfig{URL: srv.URL, User: "replicator", Password: "wrong"})
+	require.NoError(t, err)
+	_, err = replica.LatestState(context.Background())
+	require.ErrorContains(t, err, "401 Unauthorized")
+	require.ErrorContains(t, replica.HealthCheck(context.Background()), "401 Unauthorized")
+
+	replica, err = NewWebDAVReplica(&WebDAVReplicaConfig{URL: srv.URL, User: "replicator", Password: "secret"})
+	require.NoError(t, err)
+	state, err := replica.LatestState(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, state.Generation, "a missing manifest is an empty state")
+	require.NoError(t, replica.HealthCheck(context.Background()))
+}
@@ -1,14 +1,19 @@
 package stream
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
 	"time"
+
+	"golang.org/x/sync/errgroup"
 )
 
 func (c *Controller) ensureRestored(ctx context.Context) error {
@@ -25,13 +30,17 @@ func (c *Controller) ensureRestored(ctx context.Context) error {
 		return err
 	}
 
-	snapshot, segments, err := c.localRestoreState()
-	if err != nil {
-		return err
+	var snapshot *Snapshot
+	var segments []*Segment
+	if !c.config.DisableShadow {
+		snapshot, segments, err = c.localRestoreState()
+		if err != nil {
+			return err
+		}
 	}
 
 	if snapshot == nil {
-		snapshot, segments, err = replicaRestoreState(ctx, c.replicas)
+		snapshot, segments, err = replicaRestoreState(ctx, c.replicas, c.config.Restore.FetchConcurrency)
 		if err != nil {
 			return err
 		}
@@ -41,17 +50,32 @@ func (c *Controller) ensureRestored(ctx context.Context) error {
 		return fmt.Errorf("stream: no snapshots available for restore")
 	}
 
-	tempDir := c.config.Restore.TempDir
-	if tempDir == "" {
-		tempDir = filepath.Dir(target)
+	if err := checkSegmentChain(snapshot, segments); err != nil {
+		if !c.config.Restore.AllowGaps {
+			return err
+		}
+		c.db.Logger().Warningf("stream: restoring %s despite %v", target, err)
 	}
 
-	if err := restoreToTarget(snapshot, segments, target, tempDir); err != nil {
+	if err := restoreToTarget(snapshot, segments, target, c.config.Restore); err != nil {
 		return fmt.Errorf("restore to target: %w", err)
 	}
+	c.events.OnRestore(target, restoredTxID(snapshot, segments))
 	return nil
 }
 
+// restoredTxID reports the transaction a restore of snapshot plus segments
+// ends at.
+func restoredTxID(snapshot *Snapshot, segments []*Segment) uint64 {
+	txid := snapshot.Header.TxID
+	for _, segment := range segments {
+		if segment.Header.TxID > txid {
+			txid = segment.Header.TxID
+		}
+	}
+	return txid
+}
+
 func (c *Controller) localRestoreState() (*Snapshot, []*Segment, error) {
 	entries, err := os.ReadDir(c.shadowDir)
 	if err != nil {
@@ -83,10 +107,16 @@ func (c *Controller) localRestoreState() (*Snapshot, []*Segment, error) {
 				continue
 			}
 			snapshot, err := decodeSnapshotFile(data)
-			if err != nil {
+			if err != nil || verifySnapshotChecksum(snapshot) != nil {
 				continue
 			}
-			if bestSnapshot != nil && !snapshot.Header.CreatedAt.After(bestCreated) {
+			// Deterministic snapshots zero the header timestamp, so prefer
+			// the one in the file name.
+			created := snapshot.Header.CreatedAt
+			if ts, _, err := parseSnapshotObject(snapEntry.Name()); err == nil {
+				created = ts
+			}
+			if bestSnapshot != nil && !created.After(bestCreated) {
 				continue
 			}
 			segments, err := loadSegmentsFromDir(filepath.Join(genDir, "segments"), snapshot.Header.TxID)
@@ -95,68 +125,228 @@ func (c *Controller) localRestoreState() (*Snapshot, []*Segment, error) {
 			}
 			bestSnapshot = snapshot
 			bestSegments = segments
-			bestCreated = snapshot.Header.CreatedAt
+			bestCreated = created
 		}
 	}
 
 	return bestSnapshot, bestSegments, nil
 }
 
-func replicaRestoreState(ctx context.Context, replicas []Replica) (*Snapshot, []*Segment, error) {
+// defaultFetchConcurrency is the number of segments fetched in parallel when
+// RestoreConfig.FetchConcurrency is unset.
+const defaultFetchConcurrency = 8
+
+func replicaRestoreState(ctx context.Context, replicas []Replica, concurrency int) (*Snapshot, []*Segment, error) {
 	for _, replica := range replicas {
 		state, err := replica.LatestState(ctx)
 		if err != nil || state == nil || state.Snapshot == nil {
 			continue
 		}
 		snapshot, err := replica.FetchSnapshot(ctx, state.Generation, state.Snapshot)
+		if err == nil {
+			err = verifySnapshotChecksum(snapshot)
+		}
 		if err != nil {
 			return nil, nil, fmt.Errorf("fetch snapshot from %s: %w", replica.Name(), err)
 		}
-		var segments []*Segment
-		for _, desc := range state.Segments {
-			segment, err := replica.FetchSegment(ctx, state.Generation, desc)
-			if err != nil {
-				return nil, nil, fmt.Errorf("fetch segment from %s: %w", replica.Name(), err)
-			}
-			segments = append(segments, segment)
+		segments, err := fetchSegments(ctx, replica, state.Generation, state.Segments, concurrency)
+		if err != nil {
+			return nil, nil, fmt.Errorf("fetch segment from %s: %w", replica.Name(), err)
 		}
 		return snapshot, segments, nil
 	}
 	return nil, nil, nil
 }
 
-func restoreToTarget(snapshot *Snapshot, segments []*Segment, targetPath, tempDir string) error {
-	if err := os.MkdirAll(filepath.Dir(targetPath), 0o755); err != nil {
-		return err
+// fetchSegments fetches descs from replica with up to concurrency requests in
+// flight, returning the segments ordered by TxID. The first failure cancels
+// the remaining fetches.
+func fetchSegments(ctx context.Context, replica Replica, generation string, descs []SegmentDescriptor, concurrency int) ([]*Segment, error) {
+	if concurrency <= 0 {
+		concurrency = defaultFetchConcurrency
+	}
+	segments := make([]*Segment, len(descs))
+	g, fetchCtx := errgroup.WithContext(ctx)
+	g.SetLimit(concurrency)
+	for i, desc := range descs {
+		if fetchCtx.Err() != nil {
+			break
+		}
+		g.Go(func() error {
+			segment, err := replica.FetchSegment(fetchCtx, generation, desc)
+			if err != nil {
+				return fmt.Errorf("%s: %w", desc.Name, err)
+			}
+			segments[i] = segment
+			return nil
+		})
 	}
-
-	rawSnapshot, err := decompressBuffer(snapshot.Header.Compression, snapshot.Data)
-	if err != nil {
-		return fmt.Errorf("decompress snapshot: %w", err)
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
+	sort.SliceStable(segments, func(i, j int) bool {
+		return segments[i].Header.TxID < segments[j].Header.TxID
+	})
+	return segments, nil
+}
+
+// restoreProgress is persisted in a sidecar next to a restore's temp file
+// after each applied segment, so a restore interrupted mid-apply resumes
+// instead of starting over.
+type restoreProgress struct {
+	TargetPath       string `json:"targetPath"`
+	SnapshotTxID     uint64 `json:"snapshotTxId"`
+	SnapshotChecksum uint64 `json:"snapshotChecksum"`
+	AppliedTxID      uint64 `json:"appliedTxId"`
+}
 
-	tmp, err := os.CreateTemp(tempDir, "stream-restore-*.db")
+// resumes reports whether the progress belongs to a restore of targetPath
+// from snapshot.
+func (p *restoreProgress) resumes(targetPath string, snapshot *Snapshot) bool {
+	return p.TargetPath == targetPath &&
+		p.SnapshotTxID == snapshot.Header.TxID && p.SnapshotChecksum == snapshot.Header.Checksum
+}
+
+// restoreTempPrefix starts the name of every temp file a restore of
+// targetPath assembles the database in.
+func restoreTempPrefix(targetPath string) string {
+	return "." + filepath.Base(targetPath) + ".stream-restore-"
+}
+
+func restoreProgressPath(tmpName string) string {
+	return tmpName + ".progress"
+}
+
+// createRestoreTemp creates a temp file in tempDir for a restore of
+// targetPath. If an earlier restore of targetPath from snapshot was
+// interrupted, its partial file is taken over by renaming it to the new name
+// and returned with its progress; the rename fails for all but one of
+// several restores racing for it. Partial files of targetPath from other
+// snapshots can never be resumed and are removed.
+func createRestoreTemp(targetPath, tempDir string, snapshot *Snapshot) (string, *restoreProgress, error) {
+	prefix := restoreTempPrefix(targetPath)
+	tmp, err := os.CreateTemp(tempDir, prefix+"*")
 	if err != nil {
-		return err
+		return "", nil, err
 	}
 	tmpName := tmp.Name()
-	if _, err := tmp.Write(rawSnapshot); err != nil {
-		tmp.Close()
+	if err := tmp.Close(); err != nil {
 		os.Remove(tmpName)
-		return fmt.Errorf("write snapshot: %w", err)
+		return "", nil, err
 	}
-	if err := tmp.Sync(); err != nil {
-		tmp.Close()
+
+	entries, err := os.ReadDir(tempDir)
+	if err != nil {
 		os.Remove(tmpName)
-		return fmt.Errorf("sync snapshot: %w", err)
+		return "", nil, err
 	}
-	if err := tmp.Close(); err != nil {
-		os.Remove(tmpName)
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".progress")
+		if !ok || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		partial := filepath.Join(tempDir, name)
+		progress, ok := readRestoreProgress(partial)
+		if !ok || progress.TargetPath != targetPath {
+			continue
+		}
+		if !progress.resumes(targetPath, snapshot) {
+			os.Remove(partial)
+			os.Remove(restoreProgressPath(partial))
+			continue
+		}
+		if err := os.Rename(partial, tmpName); err != nil {
+			continue
+		}
+		os.Remove(restoreProgressPath(partial))
+		if err := writeRestoreProgress(tmpName, progress); err != nil {
+			os.Remove(tmpName)
+			return "", nil, err
+		}
+		return tmpName, progress, nil
+	}
+	return tmpName, nil, nil
+}
+
+// readRestoreProgress returns the progress recorded for tmpName if both the
+// sidecar and the temp file exist.
+func readRestoreProgress(tmpName string) (*restoreProgress, bool) {
+	data, err := os.ReadFile(restoreProgressPath(tmpName))
+	if err != nil {
+		return nil, false
+	}
+	var progress restoreProgress
+	if err := json.Unmarshal(data, &progress); err != nil {
+		return nil, false
+	}
+	if _, err := os.Stat(tmpName); err != nil {
+		return nil, false
+	}
+	return &progress, true
+}
+
+// writeRestoreProgress replaces the sidecar of tmpName via a rename, so a
+// crash leaves either the old or the new progress.
+func writeRestoreProgress(tmpName string, progress *restoreProgress) error {
+	data, err := json.Marshal(progress)
+	if err != nil {
 		return err
 	}
+	path := restoreProgressPath(tmpName)
+	if err := os.WriteFile(path+".tmp", data, 0o600); err != nil {
+		return fmt.Errorf("write restore progress: %w", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("write restore progress: %w", err)
+	}
+	return nil
+}
 
-	if err := applySegments(tmpName, snapshot.Header.PageSize, segments); err != nil {
-		os.Remove(tmpName)
+// restoreToTarget assembles snapshot and segments into targetPath, staging
+// the file in cfg.TempDir (default: the target's directory) and reporting
+// to cfg's progress callbacks.
+func restoreToTarget(snapshot *Snapshot, segments []*Segment, targetPath string, cfg RestoreConfig) error {
+	if err := checkDatabaseIdentity(snapshot, segments); err != nil {
+		return err
+	}
+	if cfg.Plan != nil {
+		cfg.Plan(snapshot.Header.PageCount, len(segments))
+	}
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0o755); err != nil {
+		return err
+	}
+	tempDir := cfg.TempDir
+	if tempDir == "" {
+		tempDir = filepath.Dir(targetPath)
+	}
+
+	tmpName, progress, err := createRestoreTemp(targetPath, tempDir, snapshot)
+	if err != nil {
+		return err
+	}
+	if progress == nil {
+		if err := writeRestoreSnapshot(tmpName, snapshot, cfg.SnapshotProgress); err != nil {
+			os.Remove(tmpName)
+			return err
+		}
+		progress = &restoreProgress{
+			TargetPath:       targetPath,
+			SnapshotTxID:     snapshot.Header.TxID,
+			SnapshotChecksum: snapshot.Header.Checksum,
+			AppliedTxID:      snapshot.Header.TxID,
+		}
+		if err := writeRestoreProgress(tmpName, progress); err != nil {
+			os.Remove(tmpName)
+			return err
+		}
+	}
+
+	// A failure while applying keeps the temp file and its sidecar so the
+	// next attempt resumes after the last applied segment.
+	if err := applySegments(tmpName, snapshot.Header.PageSize, segments, progress, cfg.Progress); err != nil {
 		return err
 	}
 
@@ -165,6 +355,9 @@ func restoreToTarget(snapshot *Snapshot, segments []*Segment, targetPath, tempDi
 		return err
 	}
 
+	if err := os.Remove(restoreProgressPath(tmpName)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
 	if err := os.Rename(tmpName, targetPath); err != nil {
 		os.Remove(tmpName)
 		return err
@@ -172,7 +365,88 @@ func restoreToTarget(snapshot *Snapshot, segments []*Segment, targetPath, tempDi
 	return nil
 }
 
-func applySegments(path string, pageSize int, segments []*Segment) error {
+// writeRestoreSnapshot decompresses snapshot into path, truncating it.
+// report, if set, receives the compressed bytes consumed.
+func writeRestoreSnapshot(path string, snapshot *Snapshot, report func(read, total int64)) error {
+	var src io.Reader = bytes.NewReader(snapshot.Data)
+	if report != nil {
+		src = &progressReader{r: src, total: int64(len(snapshot.Data)), report: report}
+	}
+	decoder, err := decompressReader(snapshot.Header.Compression, snapshot.Header.CompressionWindow, src)
+	if err != nil {
+		return fmt.Errorf("decompress snapshot: %w", err)
+	}
+	defer decoder.Close()
+
+	tmp, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, decoder); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("sync snapshot: %w", err)
+	}
+	return tmp.Close()
+}
+
+// progressReader reports how much of r has been read.
+type progressReader struct {
+	r      io.Reader
+	read   int64
+	total  int64
+	report func(read, total int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.read += int64(n)
+		p.report(p.read, p.total)
+	}
+	return n, err
+}
+
+// SegmentGapError reports a break in the TxID chain from a snapshot through
+// its segments. Applying segments across a gap silently loses the missing
+// transactions' pages and yields a corrupt database.
+type SegmentGapError struct {
+	// ParentTxID is the TxID the segment should have chained onto.
+	ParentTxID uint64
+	// Segment is the TxID of the first segment that does not chain on.
+	Segment uint64
+	// SegmentParentTxID is the parent recorded in that segment's header.
+	SegmentParentTxID uint64
+}
+
+func (e *SegmentGapError) Error() string {
+	return fmt.Sprintf("stream: segment gap: segment %016x follows txid %d, expected %d", e.Segment, e.SegmentParentTxID, e.ParentTxID)
+}
+
+// checkSegmentChain sorts segments by TxID and confirms the first chains onto
+// the snapshot and each later one onto its predecessor.
+func checkSegmentChain(snapshot *Snapshot, segments []*Segment) error {
+	sort.Slice(segments, func(i, j int) bool {
+		return segments[i].Header.TxID < segments[j].Header.TxID
+	})
+	parent := snapshot.Header.TxID
+	for _, segment := range segments {
+		if segment.Header.ParentTxID != parent {
+			return &SegmentGapError{ParentTxID: parent, Segment: segment.Header.TxID, SegmentParentTxID: segment.Header.ParentTxID}
+		}
+		parent = segment.Header.TxID
+	}
+	return nil
+}
+
+// applySegments writes the frames of segments newer than
+// progress.AppliedTxID to path in TxID order, zeroing the pages each one
+// freed first. After each segment the file is synced and the sidecar
+// advanced, so a resumed restore skips it.
+func applySegments(path string, pageSize int, segments []*Segment, progress *restoreProgress, report func(applied, total int, txid uint64)) error {
 	if len(segments) == 0 {
 		return nil
 	}
@@ -186,19 +460,49 @@ func applySegments(path string, pageSize int, segments []*Segment) error {
 		return segments[i].Header.TxID < segments[j].Header.TxID
 	})
 
-	for _, segment := range segments {
-		if err := populateSegmentPages(segment); err != nil {
+	for i, segment := range segments {
+		if segment.Header.TxID <= progress.AppliedTxID {
+			continue
+		}
+		if err := applySegment(f, pageSize, segment); err != nil {
 			return err
 		}
-		for _, frame := range segment.Pages {
-			offset := int64(frame.ID) * int64(pageSize)
-			if _, err := f.WriteAt(frame.Data, offset); err != nil {
-				return fmt.Errorf("write segment frame: %w", err)
-			}
+		if err := f.Sync(); err != nil {
+			return err
+		}
+		progress.AppliedTxID = segment.Header.TxID
+		if err := writeRestoreProgress(path, progress); err != nil {
+			return err
+		}
+		if report != nil {
+			report(i+1, len(segments), segment.Header.TxID)
 		}
 	}
 
-	return f.Sync()
+	return nil
+}
+
+// applySegment zeroes the pages segment freed and writes its frames to f.
+func applySegment(f *os.File, pageSize int, segment *Segment) error {
+	if err := populateSegmentPages(segment); err != nil {
+		return err
+	}
+	var zeroPage []byte
+	for _, id := range segment.Header.FreedPages {
+		if zeroPage == nil {
+			zeroPage = make([]byte, pageSize)
+		}
+		if _, err := f.WriteAt(zeroPage, int64(id)*int64(pageSize)); err != nil {
+			return fmt.Errorf("zero freed page %d: %w", id, err)
+		}
+	}
+	for _, frame := range segment.Pages {
+		offset := int64(frame.ID) * int64(pageSize)
+		if _, err := f.WriteAt(frame.Data, offset); err != nil {
+			return fmt.Errorf("write segment frame: %w", err)
+		}
+	}
+	return nil
 }
 
 func loadSegmentsFromDir(dir string, afterTxID uint64) ([]*Segment, error) {
@@ -243,10 +547,14 @@ func decodeSnapshotFile(data []byte) (*Snapshot, error) {
 	if err := cborDecMode.Unmarshal(data, &payload); err != nil {
 		return nil, fmt.Errorf("decode snapshot file: %w", err)
 	}
-	return &Snapshot{
+	snapshot := &Snapshot{
 		Header: payload.Header,
 		Data:   payload.Data,
-	}, nil
+	}
+	if err := upgradeSnapshot(snapshot); err != nil {
+		return nil, fmt.Errorf("decode snapshot file: %w", err)
+	}
+	return snapshot, nil
 }
 
 func decodeSegmentFile(data []byte) (*Segment, error) {
@@ -261,6 +569,14 @@ func decodeSegmentFile(data []byte) (*Segment, error) {
 		Header: payload.Header,
 		Data:   payload.Data,
 	}
+	// Check the payload before decompressing it, so a corrupt segment is
+	// reported as such rather than as a codec error.
+	if err := verifySegmentChecksum(segment); err != nil {
+		return nil, err
+	}
+	if err := upgradeSegment(segment); err != nil {
+		return nil, fmt.Errorf("decode segment file: %w", err)
+	}
 	if err := populateSegmentPages(segment); err != nil {
 		return nil, err
 	}
@@ -271,7 +587,7 @@ func populateSegmentPages(segment *Segment) error {
 	if len(segment.Pages) > 0 {
 		return nil
 	}
-	raw, err := decompressBuffer(segment.Header.Compression, segment.Data)
+	raw, err := decompressBuffer(segment.Header.Compression, segment.Header.CompressionWindow, segment.Data)
 	if err != nil {
 		return fmt.Errorf("decompress segment: %w", err)
 	}
@@ -288,13 +604,22 @@ func populateSegmentPages(segment *Segment) error {
 
 // RestoreStandalone builds replicas from configuration and restores the database to disk.
 func RestoreStandalone(ctx context.Context, cfg Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
 	replicas, err := BuildReplicas(ctx, cfg)
 	if err != nil {
 		return err
 	}
 	defer closeReplicas(ctx, replicas)
+	return RestoreFromReplicas(ctx, replicas, cfg)
+}
 
-	snapshot, segments, err := replicaRestoreState(ctx, replicas)
+// RestoreFromReplicas restores the database to cfg.Restore.TargetPath from
+// the first of replicas with a snapshot, for replicas not built from cfg such
+// as a BlobStoreReplica. The replicas are left open.
+func RestoreFromReplicas(ctx context.Context, replicas []Replica, cfg Config) error {
+	snapshot, segments, err := replicaRestoreState(ctx, replicas, cfg.Restore.FetchConcurrency)
 	if err != nil {
 		return err
 	}
@@ -306,11 +631,16 @@ func RestoreStandalone(ctx context.Context, cfg Config) error {
 	if target == "" {
 		return fmt.Errorf("stream: restore target path is required")
 	}
-	tempDir := cfg.Restore.TempDir
-	if tempDir == "" {
-		tempDir = filepath.Dir(target)
+	if err := checkSegmentChain(snapshot, segments); err != nil && !cfg.Restore.AllowGaps {
+		return err
+	}
+	if err := restoreToTarget(snapshot, segments, target, cfg.Restore); err != nil {
+		return err
 	}
-	return restoreToTarget(snapshot, segments, target, tempDir)
+	if cfg.Events != nil {
+		cfg.Events.OnRestore(target, restoredTxID(snapshot, segments))
+	}
+	return nil
 }
 
 func closeReplicas(ctx context.Context, replicas []Replica) {
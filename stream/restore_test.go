@@ -0,0 +1,422 @@
+package stream
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/delaneyj/witchbolt"
+)
+
+func TestCheckSegmentChain(t *testing.T) {
+	snapshot := &Snapshot{Header: SnapshotHeader{TxID: 4}}
+	segment := func(parent, txid uint64) *Segment {
+		return &Segment{Header: SegmentHeader{ParentTxID: parent, TxID: txid}}
+	}
+	require.NoError(t, checkSegmentChain(snapshot, nil))
+	require.NoError(t, checkSegmentChain(snapshot, []*Segment{segment(6, 9), segment(4, 5), segment(5, 6)}),
+		"segments are ordered by TxID before checking")
+
+	var gap *SegmentGapError
+	require.ErrorAs(t, checkSegmentChain(snapshot, []*Segment{segment(3, 5)}), &gap,
+		"the first segment must chain onto the snapshot")
+	require.Equal(t, SegmentGapError{ParentTxID: 4, Segment: 5, SegmentParentTxID: 3}, *gap)
+
+	require.ErrorAs(t, checkSegmentChain(snapshot, []*Segment{segment(4, 5), segment(6, 7)}), &gap)
+	require.Equal(t, SegmentGapError{ParentTxID: 5, Segment: 7, SegmentParentTxID: 6}, *gap)
+	require.EqualError(t, gap, "stream: segment gap: segment 0000000000000007 follows txid 6, expected 5")
+}
+
+func TestRestoreStandaloneDetectsGaps(t *testing.T) {
+	db, _, replicaPath := openReplicatedDB(t, Config{})
+	putKeys(t, db, "widgets", 4)
+
+	replica, err := NewFileReplica(&FileReplicaConfig{Path: replicaPath})
+	require.NoError(t, err)
+	state, err := replica.LatestState(context.Background())
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, len(state.Segments), 3)
+
+	t.Log("Dropping a middle segment from the manifest")
+	missing := state.Segments[1]
+	gapped := *state
+	gapped.Segments = slices.Delete(slices.Clone(state.Segments), 1, 2)
+	require.NoError(t, replica.writeState(&gapped))
+
+	target := filepath.Join(t.TempDir(), "restored.db")
+	cfg := Config{
+		Replicas: []ReplicaConfig{&FileReplicaConfig{Path: replicaPath}},
+		Restore:  RestoreConfig{TargetPath: target},
+	}
+	err = RestoreStandalone(context.Background(), cfg)
+	var gap *SegmentGapError
+	require.ErrorAs(t, err, &gap)
+	require.Equal(t, missing.FirstTxID-1, gap.ParentTxID)
+	require.NoFileExists(t, target, "a failed restore leaves nothing behind")
+
+	t.Log("Restoring anyway with AllowGaps")
+	cfg.Restore.AllowGaps = true
+	require.NoError(t, RestoreStandalone(context.Background(), cfg))
+	// Every commit rewrites the bucket's only page, so the segments after the
+	// gap still carry the keys written by the missing one.
+	requireRestoredMatches(t, db, target)
+}
+
+func TestRestoreStandaloneVerifiesChecksums(t *testing.T) {
+	db, _, replicaPath := openReplicatedDB(t, Config{})
+	putKeys(t, db, "widgets", 3)
+
+	replica, err := NewFileReplica(&FileReplicaConfig{Path: replicaPath})
+	require.NoError(t, err)
+	state, err := replica.LatestState(context.Background())
+	require.NoError(t, err)
+	require.NotEmpty(t, state.Segments)
+
+	t.Log("Flipping a payload byte of a segment the restore reads")
+	corrupted := state.Segments[len(state.Segments)-1]
+	segmentPath := filepath.Join(replicaPath, filepath.FromSlash(corrupted.Name))
+	data, err := os.ReadFile(segmentPath)
+	require.NoError(t, err)
+	segment, err := decodeSegmentFile(data)
+	require.NoError(t, err)
+	segment.Data[len(segment.Data)/2] ^= 0xff
+	require.NoError(t, writeSegmentFile(segmentPath, segment, 0o644))
+
+	target := filepath.Join(t.TempDir(), "restored.db")
+	err = RestoreStandalone(context.Background(), Config{
+		Replicas: []ReplicaConfig{&FileReplicaConfig{Path: replicaPath}},
+		Restore:  RestoreConfig{TargetPath: target},
+	})
+	require.ErrorContains(t, err, corrupted.Name)
+	require.ErrorContains(t, err, "checksum mismatch")
+	require.NoFileExists(t, target)
+
+	t.Log("Flipping a payload byte of the snapshot")
+	require.NoError(t, os.WriteFile(segmentPath, data, 0o644))
+	snapshotPath := filepath.Join(replicaPath, filepath.FromSlash(state.Snapshot.Name))
+	data, err = os.ReadFile(snapshotPath)
+	require.NoError(t, err)
+	snapshot, err := decodeSnapshotFile(data)
+	require.NoError(t, err)
+	require.NotZero(t, snapshot.Header.Checksum)
+	snapshot.Data[len(snapshot.Data)/2] ^= 0xff
+	encoded, err := marshalSnapshot(snapshot)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(snapshotPath, encoded, 0o644))
+
+	err = RestoreStandalone(context.Background(), Config{
+		Replicas: []ReplicaConfig{&FileReplicaConfig{Path: replicaPath}},
+		Restore:  RestoreConfig{TargetPath: target},
+	})
+	require.ErrorContains(t, err, "snapshot")
+	require.ErrorContains(t, err, "checksum mismatch")
+	require.NoFileExists(t, target)
+}
+
+func TestRestoreResumesAfterInterruption(t *testing.T) {
+	db, _, replicaPath := openReplicatedDB(t, Config{})
+	putKeys(t, db, "widgets", 4)
+	want := restoreBytes(t, &FileReplicaConfig{Path: replicaPath})
+
+	replica, err := NewFileReplica(&FileReplicaConfig{Path: replicaPath})
+	require.NoError(t, err)
+	snapshot, segments, err := replicaRestoreState(context.Background(), []Replica{replica}, 0)
+	require.NoError(t, err)
+	require.NotNil(t, snapshot)
+	require.GreaterOrEqual(t, len(segments), 3)
+	require.NoError(t, checkSegmentChain(snapshot, segments))
+
+	corrupt := func(segment *Segment) *Segment {
+		broken := *segment
+		broken.Pages = nil
+		broken.Data = []byte("corrupt")
+		return &broken
+	}
+	dir := t.TempDir()
+	target := filepath.Join(dir, "restored.db")
+
+	t.Log("Interrupting the restore at the third segment")
+	interrupted := slices.Clone(segments)
+	interrupted[2] = corrupt(segments[2])
+	require.Error(t, restoreToTarget(snapshot, interrupted, target, RestoreConfig{}))
+	require.NoFileExists(t, target)
+	sidecars, err := filepath.Glob(filepath.Join(dir, ".restored.db.stream-restore-*.progress"))
+	require.NoError(t, err)
+	require.Len(t, sidecars, 1, "the partial restore is kept for the next attempt")
+	progress, ok := readRestoreProgress(strings.TrimSuffix(sidecars[0], ".progress"))
+	require.True(t, ok)
+	require.True(t, progress.resumes(target, snapshot))
+	require.Equal(t, segments[1].Header.TxID, progress.AppliedTxID)
+
+	t.Log("Resuming skips the segments already applied")
+	resumed := slices.Clone(segments)
+	resumed[0] = corrupt(segments[0])
+	resumed[1] = corrupt(segments[1])
+	require.NoError(t, restoreToTarget(snapshot, resumed, target, RestoreConfig{}))
+	got, err := os.ReadFile(target)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "the temp file and its sidecar are gone")
+	require.Equal(t, "restored.db", entries[0].Name())
+}
+
+func TestRestoreConcurrentTargets(t *testing.T) {
+	db, _, replicaPath := openReplicatedDB(t, Config{})
+	putKeys(t, db, "widgets", 4)
+
+	t.Log("Restoring two targets with the same name through one temp dir")
+	tempDir := t.TempDir()
+	targets := []string{
+		filepath.Join(t.TempDir(), "restored.db"),
+		filepath.Join(t.TempDir(), "restored.db"),
+	}
+	var g errgroup.Group
+	for _, target := range targets {
+		g.Go(func() error {
+			return RestoreStandalone(context.Background(), Config{
+				Replicas: []ReplicaConfig{&FileReplicaConfig{Path: replicaPath}},
+				Restore:  RestoreConfig{TargetPath: target, TempDir: tempDir},
+			})
+		})
+	}
+	require.NoError(t, g.Wait())
+	for _, target := range targets {
+		requireRestoredMatches(t, db, target)
+	}
+	entries, err := os.ReadDir(tempDir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func TestRestoreStandaloneProgress(t *testing.T) {
+	db, _, replicaPath := openReplicatedDB(t, Config{})
+	putKeys(t, db, "widgets", 3)
+
+	var planPages uint64
+	planSegments := -1
+	var snapshotRead, snapshotTotal int64
+	var applied []int
+	var total int
+	var lastTxID uint64
+	target := filepath.Join(t.TempDir(), "restored.db")
+	err := RestoreStandalone(context.Background(), Config{
+		Replicas: []ReplicaConfig{&FileReplicaConfig{Path: replicaPath}},
+		Restore: RestoreConfig{
+			TargetPath: target,
+			Plan: func(pages uint64, segments int) {
+				require.Zero(t, snapshotRead, "the plan is reported first")
+				planPages, planSegments = pages, segments
+			},
+			SnapshotProgress: func(read, size int64) {
+				require.GreaterOrEqual(t, read, snapshotRead)
+				snapshotRead, snapshotTotal = read, size
+			},
+			Progress: func(n, segments int, txid uint64) {
+				applied = append(applied, n)
+				total = segments
+				require.Greater(t, txid, lastTxID)
+				lastTxID = txid
+			},
+		},
+	})
+	require.NoError(t, err)
+	require.NotZero(t, snapshotTotal)
+	require.Equal(t, snapshotTotal, snapshotRead, "the whole snapshot is consumed")
+	require.NotEmpty(t, applied)
+	require.Equal(t, total, applied[len(applied)-1])
+	require.NotZero(t, planPages)
+	require.Equal(t, total, planSegments)
+	require.NoError(t, db.View(func(tx *witchbolt.Tx) error {
+		require.Equal(t, uint64(tx.ID()), lastTxID, "the last segment reported is the source's TxID")
+		return nil
+	}))
+	requireRestoredMatches(t, db, target)
+}
+
+// slowFetchReplica delays every segment fetch and records the peak number of
+// fetches in flight, optionally failing the fetch of one segment.
+type slowFetchReplica struct {
+	Replica
+	failTxID uint64
+	inFlight atomic.Int32
+	peak     atomic.Int32
+	fetched  atomic.Int32
+}
+
+func (r *slowFetchReplica) FetchSegment(ctx context.Context, generation string, desc SegmentDescriptor) (*Segment, error) {
+	n := r.inFlight.Add(1)
+	defer r.inFlight.Add(-1)
+	for {
+		peak := r.peak.Load()
+		if n <= peak || r.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	if desc.FirstTxID == r.failTxID {
+		return nil, errors.New("fetch failed")
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(20 * time.Millisecond):
+	}
+	r.fetched.Add(1)
+	return r.Replica.FetchSegment(ctx, generation, desc)
+}
+
+func TestReplicaRestoreStateFetchesConcurrently(t *testing.T) {
+	db, _, replicaPath := openReplicatedDB(t, Config{})
+	putKeys(t, db, "widgets", 8)
+
+	file, err := NewFileReplica(&FileReplicaConfig{Path: replicaPath})
+	require.NoError(t, err)
+	state, err := file.LatestState(context.Background())
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, len(state.Segments), 6)
+
+	replica := &slowFetchReplica{Replica: file}
+	snapshot, segments, err := replicaRestoreState(context.Background(), []Replica{replica}, 3)
+	require.NoError(t, err)
+	require.NotNil(t, snapshot)
+	require.Len(t, segments, len(state.Segments))
+	require.True(t, slices.IsSortedFunc(segments, func(a, b *Segment) int {
+		return cmp.Compare(a.Header.TxID, b.Header.TxID)
+	}), "segments are returned in TxID order")
+	require.NoError(t, checkSegmentChain(snapshot, segments))
+	require.EqualValues(t, 3, replica.peak.Load(), "fetches are bounded by the concurrency")
+
+	t.Log("A failed fetch cancels the remaining fetches")
+	replica = &slowFetchReplica{Replica: file, failTxID: state.Segments[0].FirstTxID}
+	_, _, err = replicaRestoreState(context.Background(), []Replica{replica}, 2)
+	require.ErrorContains(t, err, "fetch failed")
+	require.Less(t, int(replica.fetched.Load()), len(state.Segments)-1)
+
+	t.Log("A cancelled context stops the restore")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = fetchSegments(ctx, file, state.Generation, state.Segments, 0)
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+// TestRestoreReachesSourceTxID guards against segments that leave the meta
+// page out: the restored file would then keep the snapshot's root and
+// TxID however many segments were applied.
+func TestRestoreReachesSourceTxID(t *testing.T) {
+	db, _, replicaPath := openReplicatedDB(t, Config{})
+	putKeys(t, db, "widgets", 4)
+	putKeys(t, db, "gadgets", 2)
+
+	target := filepath.Join(t.TempDir(), "restored.db")
+	require.NoError(t, RestoreStandalone(context.Background(), Config{
+		Replicas: []ReplicaConfig{&FileReplicaConfig{Path: replicaPath}},
+		Restore:  RestoreConfig{TargetPath: target},
+	}))
+	requireRestoredMatches(t, db, target)
+}
+
+func TestRestoreZeroesFreedPages(t *testing.T) {
+	db, _, replicaPath := openReplicatedDB(t, Config{})
+	putKeys(t, db, "widgets", 2)
+	require.NoError(t, db.Update(func(tx *witchbolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("doomed"))
+		if err != nil {
+			return err
+		}
+		for i := 0; i < 500; i++ {
+			if err := b.Put([]byte(fmt.Sprintf("key-%04d", i)), bytes.Repeat([]byte{0xaa}, 100)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	require.NoError(t, db.Update(func(tx *witchbolt.Tx) error {
+		return tx.DeleteBucket([]byte("doomed"))
+	}))
+
+	replica, err := NewFileReplica(&FileReplicaConfig{Path: replicaPath})
+	require.NoError(t, err)
+	state, err := replica.LatestState(context.Background())
+	require.NoError(t, err)
+	last, err := replica.FetchSegment(context.Background(), state.Generation, state.Segments[len(state.Segments)-1])
+	require.NoError(t, err)
+	require.NotEmpty(t, last.Header.FreedPages, "the delete records the pages it freed")
+
+	target := filepath.Join(t.TempDir(), "restored.db")
+	require.NoError(t, RestoreStandalone(context.Background(), Config{
+		Replicas: []ReplicaConfig{&FileReplicaConfig{Path: replicaPath}},
+		Restore:  RestoreConfig{TargetPath: target},
+	}))
+	requireKeys(t, target, "widgets", 2)
+	restored, err := witchbolt.Open(target, 0o600, &witchbolt.Options{ReadOnly: true})
+	require.NoError(t, err)
+	require.NoError(t, restored.View(func(tx *witchbolt.Tx) error {
+		require.Nil(t, tx.Bucket([]byte("doomed")))
+		require.Equal(t, int(last.Header.TxID), tx.ID(), "the restore reaches the last commit")
+		return nil
+	}))
+	require.NoError(t, restored.Close())
+
+	data, err := os.ReadFile(target)
+	require.NoError(t, err)
+	pageSize := int(last.Header.PageSize)
+	for _, id := range last.Header.FreedPages {
+		page := data[int(id)*pageSize : (int(id)+1)*pageSize]
+		require.Equal(t, make([]byte, pageSize), page, "freed page %d is zeroed", id)
+	}
+	require.False(t, bytes.Contains(data, bytes.Repeat([]byte{0xaa}, 100)), "deleted values do not survive the restore")
+}
+
+func TestRestoreRejectsSegmentsOfAnotherDatabase(t *testing.T) {
+	dbA, ctrlA, replicaPathA := openReplicatedDB(t, Config{})
+	putKeys(t, dbA, "widgets", 3)
+	dbB, ctrlB, replicaPathB := openReplicatedDB(t, Config{})
+	putKeys(t, dbB, "widgets", 3)
+	require.NotEmpty(t, ctrlA.databaseID)
+	require.NotEqual(t, ctrlA.databaseID, ctrlB.databaseID)
+
+	ctx := context.Background()
+	replicaA, err := NewFileReplica(&FileReplicaConfig{Path: replicaPathA})
+	require.NoError(t, err)
+	replicaB, err := NewFileReplica(&FileReplicaConfig{Path: replicaPathB})
+	require.NoError(t, err)
+	stateA, err := replicaA.LatestState(ctx)
+	require.NoError(t, err)
+	stateB, err := replicaB.LatestState(ctx)
+	require.NoError(t, err)
+
+	t.Log("Swapping database A's last segment for database B's")
+	lastA := stateA.Segments[len(stateA.Segments)-1]
+	foreign, err := replicaB.FetchSegment(ctx, stateB.Generation, stateB.Segments[len(stateB.Segments)-1])
+	require.NoError(t, err)
+	require.Equal(t, lastA.LastTxID, foreign.Header.TxID, "both databases committed the same transactions")
+	require.NoError(t, replicaA.ReplaceSegments(ctx, stateA.Generation, foreign, []SegmentDescriptor{lastA}))
+
+	target := filepath.Join(t.TempDir(), "restored.db")
+	cfg := Config{
+		Replicas: []ReplicaConfig{&FileReplicaConfig{Path: replicaPathA}},
+		Restore:  RestoreConfig{TargetPath: target, AllowGaps: true},
+	}
+	err = RestoreStandalone(ctx, cfg)
+	var mismatch *DatabaseMismatchError
+	require.ErrorAs(t, err, &mismatch, "AllowGaps does not relax the identity check")
+	require.Equal(t, DatabaseMismatchError{
+		DatabaseID:        ctrlA.databaseID,
+		Segment:           foreign.Header.TxID,
+		SegmentDatabaseID: ctrlB.databaseID,
+	}, *mismatch)
+	require.NoFileExists(t, target)
+}
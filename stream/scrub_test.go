@@ -0,0 +1,59 @@
+package stream
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestScrubReportsCorruptSegment(t *testing.T) {
+	db, ctrl, replicaPath := openReplicatedDB(t, Config{})
+	putKeys(t, db, "widgets", 3)
+
+	scrubErrs, err := ctrl.Scrub(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, scrubErrs)
+
+	replica, err := NewFileReplica(&FileReplicaConfig{Path: replicaPath})
+	require.NoError(t, err)
+	state, err := replica.LatestState(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, state.Snapshot)
+	require.NotEmpty(t, state.Segments)
+
+	target := state.Segments[len(state.Segments)-1]
+	targetPath := filepath.Join(replicaPath, filepath.FromSlash(target.Name))
+	data, err := os.ReadFile(targetPath)
+	require.NoError(t, err)
+	segment, err := decodeSegmentFile(data)
+	require.NoError(t, err)
+	segment.Data[len(segment.Data)/2] ^= 0xff
+	require.NoError(t, writeSegmentFile(targetPath, segment, 0o644))
+	before, err := os.ReadFile(targetPath)
+	require.NoError(t, err)
+
+	scrubErrs, err = ctrl.Scrub(context.Background())
+	require.NoError(t, err)
+	require.Len(t, scrubErrs, 1)
+	require.Equal(t, target.Name, scrubErrs[0].Object)
+	require.Equal(t, state.Generation, scrubErrs[0].Generation)
+	require.Equal(t, replica.Name(), scrubErrs[0].Replica)
+
+	after, err := os.ReadFile(targetPath)
+	require.NoError(t, err)
+	require.Equal(t, before, after, "scrub must not modify artefacts")
+}
+
+func TestScrubDetectsSnapshotChecksumMismatch(t *testing.T) {
+	snapshot := &Snapshot{
+		Header: SnapshotHeader{TxID: 7},
+		Data:   []byte("snapshot-payload"),
+	}
+	require.NoError(t, verifySnapshotChecksum(snapshot), "snapshots without checksum are skipped")
+
+	snapshot.Header.Checksum = 1
+	require.Error(t, verifySnapshotChecksum(snapshot))
+}
@@ -1,7 +1,10 @@
 package stream
 
 import (
+	"bytes"
+	"encoding/binary"
 	"fmt"
+	"io"
 	"time"
 )
 
@@ -10,47 +13,88 @@ const (
 	segmentVersion = 1
 )
 
+// segmentUpgrades converts a segment stored in the keyed version to the next
+// version, adjusting Data and the checksum as needed; upgradeSegment then
+// bumps Header.Version. Bumping segmentVersion requires an entry for the
+// previous version so artefacts already on replicas keep decoding.
+var segmentUpgrades = map[int]func(*Segment) error{}
+
+// snapshotUpgrades is the snapshot counterpart of segmentUpgrades.
+var snapshotUpgrades = map[int]func(*Snapshot) error{}
+
 // Segment is the binary unit representing a set of page writes.
 type Segment struct {
 	Header SegmentHeader
 	Pages  []PageFrame
 	Data   []byte
+
+	// upgraded reports that the segment was stored in an older version and
+	// decoding upgraded it to segmentVersion.
+	upgraded bool
 }
 
 // SegmentHeader stores metadata written alongside a segment.
 type SegmentHeader struct {
-	Magic             string            `json:"magic" cbor:"magic"`
-	Version           int               `json:"version" cbor:"version"`
-	TxID              uint64            `json:"txId" cbor:"txId"`
-	ParentTxID        uint64            `json:"parentTxId" cbor:"parentTxId"`
-	PageCount         int               `json:"pageCount" cbor:"pageCount"`
-	PageSize          int               `json:"pageSize" cbor:"pageSize"`
-	Checksum          uint64            `json:"checksum" cbor:"checksum"`
+	Magic      string `json:"magic" cbor:"magic"`
+	Version    int    `json:"version" cbor:"version"`
+	TxID       uint64 `json:"txId" cbor:"txId"`
+	ParentTxID uint64 `json:"parentTxId" cbor:"parentTxId"`
+	PageCount  int    `json:"pageCount" cbor:"pageCount"`
+	PageSize   int    `json:"pageSize" cbor:"pageSize"`
+	Checksum   uint64 `json:"checksum" cbor:"checksum"`
+	// ChecksumAlgorithm names the algorithm of Checksum; empty means
+	// ChecksumCRC64ISO.
+	ChecksumAlgorithm ChecksumAlgorithm `json:"checksumAlgorithm,omitempty" cbor:"checksumAlgorithm,omitempty"`
 	Compression       CompressionType   `json:"compression" cbor:"compression"`
 	CompressionLevel  int               `json:"compressionLevel,omitempty" cbor:"compressionLevel,omitempty"`
 	CompressionWindow int               `json:"compressionWindow,omitempty" cbor:"compressionWindow,omitempty"`
 	CreatedAt         time.Time         `json:"createdAt" cbor:"createdAt"`
 	HighWaterMark     uint64            `json:"highWaterMark" cbor:"highWaterMark"`
-	AdditionalAttrs   map[string]string `json:"additionalAttrs,omitempty" cbor:"additionalAttrs,omitempty"`
+	// DatabaseID identifies the database the segment was recorded for.
+	DatabaseID string `json:"databaseId,omitempty" cbor:"databaseId,omitempty"`
+	// FreedPages lists the pages the transaction returned to the freelist.
+	// Restores zero them so stale data does not outlive a delete.
+	FreedPages      []uint64          `json:"freedPages,omitempty" cbor:"freedPages,omitempty"`
+	AdditionalAttrs map[string]string `json:"additionalAttrs,omitempty" cbor:"additionalAttrs,omitempty"`
 }
 
 // Snapshot represents a complete copy of the database file.
 type Snapshot struct {
 	Header SnapshotHeader
 	Data   []byte
+	// Timestamp records when a deterministic snapshot was taken, because its
+	// Header.CreatedAt is zeroed. It is not encoded in the artefact; the
+	// object name and the state manifest carry it instead.
+	Timestamp time.Time
+
+	// upgraded is as for Segment.
+	upgraded bool
+}
+
+// createdAt reports when the snapshot was taken.
+func (s *Snapshot) createdAt() time.Time {
+	if !s.Timestamp.IsZero() {
+		return s.Timestamp
+	}
+	return s.Header.CreatedAt
 }
 
 // SnapshotHeader describes a snapshot artefact.
 type SnapshotHeader struct {
-	Magic             string          `json:"magic" cbor:"magic"`
-	Version           int             `json:"version" cbor:"version"`
-	TxID              uint64          `json:"txId" cbor:"txId"`
-	PageCount         uint64          `json:"pageCount" cbor:"pageCount"`
-	PageSize          int             `json:"pageSize" cbor:"pageSize"`
-	Compression       CompressionType `json:"compression" cbor:"compression"`
-	CompressionLevel  int             `json:"compressionLevel,omitempty" cbor:"compressionLevel,omitempty"`
-	CompressionWindow int             `json:"compressionWindow,omitempty" cbor:"compressionWindow,omitempty"`
-	CreatedAt         time.Time       `json:"createdAt" cbor:"createdAt"`
+	Magic     string `json:"magic" cbor:"magic"`
+	Version   int    `json:"version" cbor:"version"`
+	TxID      uint64 `json:"txId" cbor:"txId"`
+	PageCount uint64 `json:"pageCount" cbor:"pageCount"`
+	PageSize  int    `json:"pageSize" cbor:"pageSize"`
+	Checksum  uint64 `json:"checksum,omitempty" cbor:"checksum,omitempty"`
+	// ChecksumAlgorithm is as for SegmentHeader.
+	ChecksumAlgorithm ChecksumAlgorithm `json:"checksumAlgorithm,omitempty" cbor:"checksumAlgorithm,omitempty"`
+	Compression       CompressionType   `json:"compression" cbor:"compression"`
+	CompressionLevel  int               `json:"compressionLevel,omitempty" cbor:"compressionLevel,omitempty"`
+	CompressionWindow int               `json:"compressionWindow,omitempty" cbor:"compressionWindow,omitempty"`
+	CreatedAt         time.Time         `json:"createdAt" cbor:"createdAt"`
+	// DatabaseID identifies the database the snapshot was taken of.
+	DatabaseID string `json:"databaseId,omitempty" cbor:"databaseId,omitempty"`
 }
 
 // PageFrame captures a single page and its payload.
@@ -80,8 +124,8 @@ func DecodeSegmentHeader(buf []byte) (SegmentHeader, error) {
 	if header.Magic != segmentMagic {
 		return SegmentHeader{}, fmt.Errorf("invalid segment magic: %s", header.Magic)
 	}
-	if header.Version != segmentVersion {
-		return SegmentHeader{}, fmt.Errorf("unsupported segment version: %d", header.Version)
+	if err := checkArtefactVersion("segment", header.Version, segmentUpgrades); err != nil {
+		return SegmentHeader{}, err
 	}
 	return header, nil
 }
@@ -106,12 +150,53 @@ func DecodeSnapshotHeader(buf []byte) (SnapshotHeader, error) {
 	if header.Magic != segmentMagic {
 		return SnapshotHeader{}, fmt.Errorf("invalid snapshot magic: %s", header.Magic)
 	}
-	if header.Version != segmentVersion {
-		return SnapshotHeader{}, fmt.Errorf("unsupported snapshot version: %d", header.Version)
+	if err := checkArtefactVersion("snapshot", header.Version, snapshotUpgrades); err != nil {
+		return SnapshotHeader{}, err
 	}
 	return header, nil
 }
 
+// checkArtefactVersion reports whether an artefact stored in version is the
+// current version or can be upgraded to it.
+func checkArtefactVersion[T any](kind string, version int, upgrades map[int]func(T) error) error {
+	for v := version; v != segmentVersion; v++ {
+		if v > segmentVersion || upgrades[v] == nil {
+			return fmt.Errorf("unsupported %s version: %d", kind, version)
+		}
+	}
+	return nil
+}
+
+// upgradeSegment brings a decoded segment up to segmentVersion in memory.
+func upgradeSegment(segment *Segment) error {
+	if err := checkArtefactVersion("segment", segment.Header.Version, segmentUpgrades); err != nil {
+		return err
+	}
+	for segment.Header.Version != segmentVersion {
+		if err := segmentUpgrades[segment.Header.Version](segment); err != nil {
+			return fmt.Errorf("upgrade segment %016x from version %d: %w", segment.Header.TxID, segment.Header.Version, err)
+		}
+		segment.Header.Version++
+		segment.upgraded = true
+	}
+	return nil
+}
+
+// upgradeSnapshot brings a decoded snapshot up to segmentVersion in memory.
+func upgradeSnapshot(snapshot *Snapshot) error {
+	if err := checkArtefactVersion("snapshot", snapshot.Header.Version, snapshotUpgrades); err != nil {
+		return err
+	}
+	for snapshot.Header.Version != segmentVersion {
+		if err := snapshotUpgrades[snapshot.Header.Version](snapshot); err != nil {
+			return fmt.Errorf("upgrade snapshot %016x from version %d: %w", snapshot.Header.TxID, snapshot.Header.Version, err)
+		}
+		snapshot.Header.Version++
+		snapshot.upgraded = true
+	}
+	return nil
+}
+
 func marshalSnapshot(snapshot *Snapshot) ([]byte, error) {
 	payload := struct {
 		Header SnapshotHeader `json:"header" cbor:"header"`
@@ -123,6 +208,61 @@ func marshalSnapshot(snapshot *Snapshot) ([]byte, error) {
 	return cborEncMode.Marshal(payload)
 }
 
+// snapshotReader streams the same bytes as marshalSnapshot without copying the
+// payload into a second buffer. Canonical CBOR sorts the "data" key before
+// "header", so the envelope is a short prefix, the payload and the header.
+func snapshotReader(snapshot *Snapshot) (io.Reader, int64, error) {
+	if snapshot.Data == nil {
+		// A nil payload encodes as CBOR null rather than a byte string.
+		encoded, err := marshalSnapshot(snapshot)
+		if err != nil {
+			return nil, 0, err
+		}
+		return bytes.NewReader(encoded), int64(len(encoded)), nil
+	}
+	header, err := cborEncMode.Marshal(snapshot.Header)
+	if err != nil {
+		return nil, 0, err
+	}
+	var prefix, suffix bytes.Buffer
+	prefix.WriteByte(0xa2) // map with two entries
+	writeCBORString(&prefix, "data")
+	writeCBORHead(&prefix, cborMajorBytes, uint64(len(snapshot.Data)))
+	writeCBORString(&suffix, "header")
+	suffix.Write(header)
+	size := int64(prefix.Len() + len(snapshot.Data) + suffix.Len())
+	return io.MultiReader(&prefix, bytes.NewReader(snapshot.Data), &suffix), size, nil
+}
+
+const (
+	cborMajorBytes = 2
+	cborMajorText  = 3
+)
+
+func writeCBORString(buf *bytes.Buffer, s string) {
+	writeCBORHead(buf, cborMajorText, uint64(len(s)))
+	buf.WriteString(s)
+}
+
+func writeCBORHead(buf *bytes.Buffer, major byte, n uint64) {
+	major <<= 5
+	switch {
+	case n < 24:
+		buf.WriteByte(major | byte(n))
+	case n <= 0xff:
+		buf.Write([]byte{major | 24, byte(n)})
+	case n <= 0xffff:
+		buf.WriteByte(major | 25)
+		buf.Write(binary.BigEndian.AppendUint16(nil, uint16(n)))
+	case n <= 0xffffffff:
+		buf.WriteByte(major | 26)
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(n)))
+	default:
+		buf.WriteByte(major | 27)
+		buf.Write(binary.BigEndian.AppendUint64(nil, n))
+	}
+}
+
 func marshalSegment(segment *Segment) ([]byte, error) {
 	payload := struct {
 		Header SegmentHeader `json:"header" cbor:"header"`
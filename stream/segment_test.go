@@ -0,0 +1,40 @@
+package stream
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotReaderMatchesMarshal(t *testing.T) {
+	for _, size := range []int{-1, 0, 5, 23, 24, 255, 256, 65535, 65536, 1 << 20} {
+		snapshot := &Snapshot{Header: SnapshotHeader{
+			Magic:       segmentMagic,
+			Version:     segmentVersion,
+			TxID:        42,
+			PageSize:    4096,
+			Compression: CompressionZSTD,
+			CreatedAt:   time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		}}
+		if size >= 0 {
+			snapshot.Data = bytes.Repeat([]byte{0xab}, size)
+		}
+		want, err := marshalSnapshot(snapshot)
+		require.NoError(t, err)
+
+		r, n, err := snapshotReader(snapshot)
+		require.NoError(t, err)
+		got, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.Equal(t, want, got, "size %d", size)
+		require.Equal(t, int64(len(want)), n, "size %d", size)
+
+		decoded, err := decodeSnapshotFile(got)
+		require.NoError(t, err)
+		require.Equal(t, snapshot.Header.TxID, decoded.Header.TxID)
+		require.Len(t, decoded.Data, max(size, 0))
+	}
+}
@@ -0,0 +1,149 @@
+package stream
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	testSFTPUser     = "replicator"
+	testSFTPPassword = "secret"
+)
+
+// testSFTPServer is a minimal in-process SSH server exposing the sftp
+// subsystem rooted at a temporary directory.
+type testSFTPServer struct {
+	t        *testing.T
+	listener net.Listener
+	hostKey  ssh.Signer
+	root     string
+
+	mu    sync.Mutex
+	conns []net.Conn
+	wg    sync.WaitGroup
+}
+
+func newTestSFTPServer(t *testing.T) *testSFTPServer {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(priv)
+	require.NoError(t, err)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	srv := &testSFTPServer{t: t, listener: listener, hostKey: signer, root: t.TempDir()}
+	srv.wg.Add(1)
+	go srv.serve()
+	t.Cleanup(srv.close)
+	return srv
+}
+
+func (s *testSFTPServer) config(extra func(*SFTPReplicaConfig)) *SFTPReplicaConfig {
+	addr := s.listener.Addr().(*net.TCPAddr)
+	cfg := &SFTPReplicaConfig{
+		Host:     addr.IP.String(),
+		Port:     addr.Port,
+		User:     testSFTPUser,
+		Password: testSFTPPassword,
+		Path:     s.root,
+	}
+	if extra != nil {
+		extra(cfg)
+	}
+	return cfg
+}
+
+func (s *testSFTPServer) addr() string {
+	addr := s.listener.Addr().(*net.TCPAddr)
+	return net.JoinHostPort(addr.IP.String(), strconv.Itoa(addr.Port))
+}
+
+// dropConnections closes every accepted connection, simulating a server
+// that drops idle clients.
+func (s *testSFTPServer) dropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.conns = nil
+}
+
+func (s *testSFTPServer) close() {
+	_ = s.listener.Close()
+	s.dropConnections()
+	s.wg.Wait()
+}
+
+func (s *testSFTPServer) serve() {
+	defer s.wg.Done()
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(meta ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if meta.User() == testSFTPUser && string(password) == testSFTPPassword {
+				return nil, nil
+			}
+			return nil, io.EOF
+		},
+	}
+	config.AddHostKey(s.hostKey)
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns = append(s.conns, conn)
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn, config)
+		}()
+	}
+}
+
+func (s *testSFTPServer) handle(conn net.Conn, config *ssh.ServerConfig) {
+	defer conn.Close()
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "unsupported channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			for req := range requests {
+				ok := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp"
+				_ = req.Reply(ok, nil)
+				if !ok {
+					continue
+				}
+				server, err := sftp.NewServer(channel)
+				if err != nil {
+					_ = channel.Close()
+					return
+				}
+				_ = server.Serve()
+				_ = server.Close()
+				return
+			}
+		}()
+	}
+}
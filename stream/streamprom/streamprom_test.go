@@ -0,0 +1,94 @@
+package streamprom_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/delaneyj/witchbolt"
+	"github.com/delaneyj/witchbolt/stream"
+	"github.com/delaneyj/witchbolt/stream/streamprom"
+)
+
+func TestMetrics(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	m, err := streamprom.New(reg)
+	require.NoError(t, err)
+
+	m.SegmentUploaded("r1", 100)
+	m.SegmentUploaded("r1", 50)
+	m.SnapshotUploaded("r1", 1000)
+	m.UploadFailed("r2", "segment")
+	m.PruneCompleted("r1", nil)
+	m.PruneCompleted("r2", errors.New("boom"))
+	m.DataLossWindow(1500 * time.Millisecond)
+
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP witchbolt_stream_data_loss_window_seconds Worst-case replication lag across replicas.
+# TYPE witchbolt_stream_data_loss_window_seconds gauge
+witchbolt_stream_data_loss_window_seconds 1.5
+# HELP witchbolt_stream_prune_runs_total Retention passes per replica by result.
+# TYPE witchbolt_stream_prune_runs_total counter
+witchbolt_stream_prune_runs_total{replica="r1",result="success"} 1
+witchbolt_stream_prune_runs_total{replica="r2",result="error"} 1
+# HELP witchbolt_stream_segments_uploaded_total Segments accepted by each replica.
+# TYPE witchbolt_stream_segments_uploaded_total counter
+witchbolt_stream_segments_uploaded_total{replica="r1"} 2
+# HELP witchbolt_stream_snapshots_uploaded_total Snapshots accepted by each replica.
+# TYPE witchbolt_stream_snapshots_uploaded_total counter
+witchbolt_stream_snapshots_uploaded_total{replica="r1"} 1
+# HELP witchbolt_stream_upload_errors_total Failed artefact uploads per replica.
+# TYPE witchbolt_stream_upload_errors_total counter
+witchbolt_stream_upload_errors_total{kind="segment",replica="r2"} 1
+# HELP witchbolt_stream_uploaded_bytes_total Compressed artefact bytes accepted by each replica.
+# TYPE witchbolt_stream_uploaded_bytes_total counter
+witchbolt_stream_uploaded_bytes_total{kind="segment",replica="r1"} 150
+witchbolt_stream_uploaded_bytes_total{kind="snapshot",replica="r1"} 1000
+`)))
+
+	_, err = streamprom.New(reg)
+	require.Error(t, err, "registering twice must fail")
+}
+
+func TestMetricsFromController(t *testing.T) {
+	dir := t.TempDir()
+	db, err := witchbolt.Open(filepath.Join(dir, "db"), 0o600, nil)
+	require.NoError(t, err)
+	defer db.Close()
+
+	reg := prometheus.NewRegistry()
+	m, err := streamprom.New(reg)
+	require.NoError(t, err)
+	replicaPath := filepath.Join(dir, "replica")
+	ctrl, err := stream.Enable(context.Background(), db, stream.Config{
+		ShadowDir: filepath.Join(dir, "shadow"),
+		Replicas:  []stream.ReplicaConfig{&stream.FileReplicaConfig{Path: replicaPath}},
+		Metrics:   m,
+	})
+	require.NoError(t, err)
+	defer ctrl.Stop(context.Background())
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, db.Update(func(tx *witchbolt.Tx) error {
+			b, err := tx.CreateBucketIfNotExists([]byte("widgets"))
+			if err != nil {
+				return err
+			}
+			return b.Put([]byte{byte(i)}, []byte("value"))
+		}))
+	}
+
+	count, err := testutil.GatherAndCount(reg, "witchbolt_stream_segments_uploaded_total")
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+	snapshots, err := testutil.GatherAndCount(reg, "witchbolt_stream_snapshots_uploaded_total")
+	require.NoError(t, err)
+	require.Equal(t, 1, snapshots)
+}
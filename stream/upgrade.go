@@ -0,0 +1,91 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+)
+
+// ArtefactUpgrade reports the artefacts rewritten in the current version on
+// one replica.
+type ArtefactUpgrade struct {
+	Replica    string
+	Generation string
+	// Snapshots and Segments count the artefacts rewritten. Both are zero
+	// when the replica was already current.
+	Snapshots int
+	Segments  int
+}
+
+// UpgradeReplicaArtefacts rewrites the head snapshot and segments referenced
+// by each replica's state manifest in the current artefact version, so
+// restore tooling only meets the latest format. Artefacts are decoded through
+// the version upgrades registered for older versions. Replacing an outdated
+// snapshot resets the manifest, so its segments are written again after it.
+func UpgradeReplicaArtefacts(ctx context.Context, replicas []Replica) ([]ArtefactUpgrade, error) {
+	var upgrades []ArtefactUpgrade
+	var errs []error
+	for _, replica := range replicas {
+		if err := ctx.Err(); err != nil {
+			return upgrades, err
+		}
+		upgrade, err := upgradeReplica(ctx, replica)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", replica.Name(), err))
+			continue
+		}
+		upgrades = append(upgrades, upgrade)
+	}
+	return upgrades, aggregateErrors("upgrade artefacts", errs)
+}
+
+func upgradeReplica(ctx context.Context, replica Replica) (ArtefactUpgrade, error) {
+	upgrade := ArtefactUpgrade{Replica: replica.Name()}
+	state, err := replica.LatestState(ctx)
+	if err != nil {
+		return upgrade, err
+	}
+	if state == nil || state.Snapshot == nil {
+		return upgrade, nil
+	}
+	upgrade.Generation = state.Generation
+
+	snapshot, err := replica.FetchSnapshot(ctx, state.Generation, state.Snapshot)
+	if err != nil {
+		return upgrade, fmt.Errorf("fetch snapshot %s: %w", state.Snapshot.Name, err)
+	}
+	// Keep the object name: the header timestamp is encoded with second
+	// precision, and zeroed for deterministic snapshots.
+	snapshot.Timestamp = state.Snapshot.Timestamp
+	segments := make([]*Segment, 0, len(state.Segments))
+	for _, desc := range state.Segments {
+		segment, err := replica.FetchSegment(ctx, state.Generation, desc)
+		if err != nil {
+			return upgrade, fmt.Errorf("fetch segment %s: %w", desc.Name, err)
+		}
+		segments = append(segments, segment)
+	}
+
+	if snapshot.upgraded {
+		if err := replica.PutSnapshot(ctx, state.Generation, snapshot); err != nil {
+			return upgrade, fmt.Errorf("put snapshot: %w", err)
+		}
+		upgrade.Snapshots++
+		for _, segment := range segments {
+			if err := replica.PutSegment(ctx, state.Generation, segment); err != nil {
+				return upgrade, fmt.Errorf("put segment %016x: %w", segment.Header.TxID, err)
+			}
+			upgrade.Segments++
+		}
+		return upgrade, nil
+	}
+	for i, segment := range segments {
+		if !segment.upgraded {
+			continue
+		}
+		if err := replica.ReplaceSegments(ctx, state.Generation, segment, state.Segments[i:i+1]); err != nil {
+			return upgrade, fmt.Errorf("replace segment %s: %w", state.Segments[i].Name, err)
+		}
+		upgrade.Segments++
+	}
+	return upgrade, nil
+}
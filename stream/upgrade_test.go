@@ -0,0 +1,89 @@
+package stream
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpgradeReplicaArtefacts(t *testing.T) {
+	db, _, replicaPath := openReplicatedDB(t, Config{})
+	putKeys(t, db, "widgets", 4)
+	want := restoreBytes(t, &FileReplicaConfig{Path: replicaPath})
+
+	replica, err := NewFileReplica(&FileReplicaConfig{Path: replicaPath})
+	require.NoError(t, err)
+	upgrades, err := UpgradeReplicaArtefacts(context.Background(), []Replica{replica})
+	require.NoError(t, err)
+	require.Len(t, upgrades, 1)
+	require.Zero(t, upgrades[0].Snapshots)
+	require.Zero(t, upgrades[0].Segments, "current artefacts are left alone")
+
+	state, err := replica.LatestState(context.Background())
+	require.NoError(t, err)
+	require.NotEmpty(t, state.Segments)
+
+	t.Log("Rewriting the last segment in the previous version")
+	const oldVersion = segmentVersion - 1
+	segmentPath := filepath.Join(replicaPath, filepath.FromSlash(state.Segments[len(state.Segments)-1].Name))
+	data, err := os.ReadFile(segmentPath)
+	require.NoError(t, err)
+	segment, err := decodeSegmentFile(data)
+	require.NoError(t, err)
+	segment.Header.Version = oldVersion
+	require.NoError(t, writeSegmentFile(segmentPath, segment, 0o644))
+
+	_, err = UpgradeReplicaArtefacts(context.Background(), []Replica{replica})
+	require.ErrorContains(t, err, "unsupported segment version")
+
+	var upgraded int
+	segmentUpgrades[oldVersion] = func(*Segment) error { upgraded++; return nil }
+	snapshotUpgrades[oldVersion] = func(*Snapshot) error { return nil }
+	t.Cleanup(func() {
+		delete(segmentUpgrades, oldVersion)
+		delete(snapshotUpgrades, oldVersion)
+	})
+
+	upgrades, err = UpgradeReplicaArtefacts(context.Background(), []Replica{replica})
+	require.NoError(t, err)
+	require.Equal(t, 1, upgraded)
+	require.Equal(t, ArtefactUpgrade{Replica: replica.Name(), Generation: state.Generation, Segments: 1}, upgrades[0])
+
+	data, err = os.ReadFile(segmentPath)
+	require.NoError(t, err)
+	segment, err = decodeSegmentFile(data)
+	require.NoError(t, err)
+	require.Equal(t, segmentVersion, segment.Header.Version)
+	require.False(t, segment.upgraded)
+
+	t.Log("An outdated snapshot is rewritten along with every segment")
+	snapshotPath := filepath.Join(replicaPath, filepath.FromSlash(state.Snapshot.Name))
+	data, err = os.ReadFile(snapshotPath)
+	require.NoError(t, err)
+	snapshot, err := decodeSnapshotFile(data)
+	require.NoError(t, err)
+	snapshot.Header.Version = oldVersion
+	require.NoError(t, writeSnapshotFile(snapshotPath, snapshot, 0o644))
+
+	upgrades, err = UpgradeReplicaArtefacts(context.Background(), []Replica{replica})
+	require.NoError(t, err)
+	require.Equal(t, 1, upgrades[0].Snapshots)
+	require.Equal(t, len(state.Segments), upgrades[0].Segments)
+
+	after, err := replica.LatestState(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, state.Snapshot.Name, after.Snapshot.Name)
+	require.Len(t, after.Segments, len(state.Segments))
+	data, err = os.ReadFile(snapshotPath)
+	require.NoError(t, err)
+	snapshot, err = decodeSnapshotFile(data)
+	require.NoError(t, err)
+	require.Equal(t, segmentVersion, snapshot.Header.Version)
+
+	delete(segmentUpgrades, oldVersion)
+	delete(snapshotUpgrades, oldVersion)
+	require.Equal(t, want, restoreBytes(t, &FileReplicaConfig{Path: replicaPath}))
+}
@@ -0,0 +1,91 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+)
+
+// VerifyResult reports whether the head snapshot of a replica and the
+// segments listed after it form a restorable chain.
+type VerifyResult struct {
+	Replica    string
+	Generation string
+	// SnapshotTxID is the TxID of the head snapshot.
+	SnapshotTxID uint64
+	Segments     int
+	// HeadTxID is the last TxID reachable from the snapshot through verified,
+	// contiguous segments.
+	HeadTxID uint64
+	// Errors lists unreadable or corrupt artefacts and breaks in the TxID
+	// chain. An empty list means a restore reaches HeadTxID.
+	Errors []error
+}
+
+// OK reports whether the replica verified without errors.
+func (r VerifyResult) OK() bool {
+	return len(r.Errors) == 0
+}
+
+// VerifyReplica downloads the head snapshot and every segment in the
+// replica's state manifest, checks their checksums and confirms each segment
+// starts at the TxID after the previous one ends. It never modifies the
+// replica. The error result is reserved for context cancellation.
+func VerifyReplica(ctx context.Context, replica Replica) (VerifyResult, error) {
+	result := VerifyResult{Replica: replica.Name()}
+	state, err := replica.LatestState(ctx)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return result, ctxErr
+		}
+		result.Errors = append(result.Errors, fmt.Errorf("%s: %w", stateFileName, err))
+		return result, nil
+	}
+	if state == nil || state.Snapshot == nil {
+		result.Errors = append(result.Errors, fmt.Errorf("%s: no snapshot to restore from", stateFileName))
+		return result, nil
+	}
+	result.Generation = state.Generation
+	result.Segments = len(state.Segments)
+
+	snapshot, err := replica.FetchSnapshot(ctx, state.Generation, state.Snapshot)
+	if err == nil {
+		err = verifySnapshotChecksum(snapshot)
+	}
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return result, ctxErr
+		}
+		result.Errors = append(result.Errors, fmt.Errorf("%s: %w", state.Snapshot.Name, err))
+		return result, nil
+	}
+	result.SnapshotTxID = snapshot.Header.TxID
+	result.HeadTxID = snapshot.Header.TxID
+
+	// The chain stays intact until the first break; later segments are still
+	// checked so every problem is reported in one pass.
+	intact := true
+	next := snapshot.Header.TxID + 1
+	for _, desc := range state.Segments {
+		segment, err := replica.FetchSegment(ctx, state.Generation, desc)
+		if err == nil {
+			err = verifySegmentChecksum(segment)
+		}
+		if err == nil && (segment.Header.ParentTxID+1 != desc.FirstTxID || segment.Header.TxID != desc.LastTxID) {
+			err = fmt.Errorf("header covers txid %d-%d, manifest lists %d-%d", segment.Header.ParentTxID+1, segment.Header.TxID, desc.FirstTxID, desc.LastTxID)
+		}
+		if err == nil && desc.FirstTxID != next {
+			err = fmt.Errorf("chain gap: expected txid %d, segment covers %d-%d", next, desc.FirstTxID, desc.LastTxID)
+		}
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return result, ctxErr
+			}
+			result.Errors = append(result.Errors, fmt.Errorf("%s: %w", desc.Name, err))
+			intact = false
+		} else if intact {
+			result.HeadTxID = desc.LastTxID
+		}
+		next = desc.LastTxID + 1
+	}
+	return result, nil
+}
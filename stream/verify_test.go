@@ -0,0 +1,73 @@
+package stream
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/delaneyj/witchbolt"
+)
+
+func TestVerifyReplica(t *testing.T) {
+	db, _, replicaPath := openReplicatedDB(t, Config{})
+	putKeys(t, db, "widgets", 4)
+	var txid uint64
+	require.NoError(t, db.View(func(tx *witchbolt.Tx) error {
+		txid = uint64(tx.ID())
+		return nil
+	}))
+
+	replica, err := NewFileReplica(&FileReplicaConfig{Path: replicaPath})
+	require.NoError(t, err)
+	state, err := replica.LatestState(context.Background())
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, len(state.Segments), 3)
+
+	result, err := VerifyReplica(context.Background(), replica)
+	require.NoError(t, err)
+	require.True(t, result.OK(), "errors: %v", result.Errors)
+	require.Equal(t, state.Generation, result.Generation)
+	require.Equal(t, state.Segments[0].FirstTxID-1, result.SnapshotTxID)
+	require.Equal(t, len(state.Segments), result.Segments)
+	require.Equal(t, txid, result.HeadTxID)
+
+	t.Log("Dropping a segment from the manifest breaks the chain")
+	gapped := *state
+	gapped.Segments = slices.Delete(slices.Clone(state.Segments), 1, 2)
+	require.NoError(t, replica.writeState(&gapped))
+	result, err = VerifyReplica(context.Background(), replica)
+	require.NoError(t, err)
+	require.False(t, result.OK())
+	require.Len(t, result.Errors, 1)
+	require.ErrorContains(t, result.Errors[0], "chain gap")
+	require.Equal(t, state.Segments[0].LastTxID, result.HeadTxID, "the head stops before the gap")
+
+	t.Log("Corrupting a segment is reported by name")
+	require.NoError(t, replica.writeState(state))
+	target := state.Segments[len(state.Segments)-1]
+	targetPath := filepath.Join(replicaPath, filepath.FromSlash(target.Name))
+	data, err := os.ReadFile(targetPath)
+	require.NoError(t, err)
+	segment, err := decodeSegmentFile(data)
+	require.NoError(t, err)
+	segment.Data[len(segment.Data)/2] ^= 0xff
+	require.NoError(t, writeSegmentFile(targetPath, segment, 0o644))
+	result, err = VerifyReplica(context.Background(), replica)
+	require.NoError(t, err)
+	require.Len(t, result.Errors, 1)
+	require.ErrorContains(t, result.Errors[0], target.Name)
+	require.Equal(t, state.Segments[len(state.Segments)-2].LastTxID, result.HeadTxID)
+}
+
+func TestVerifyReplicaWithoutSnapshot(t *testing.T) {
+	replica, err := NewFileReplica(&FileReplicaConfig{Path: t.TempDir()})
+	require.NoError(t, err)
+	result, err := VerifyReplica(context.Background(), replica)
+	require.NoError(t, err)
+	require.False(t, result.OK())
+	require.ErrorContains(t, result.Errors[0], "no snapshot")
+}
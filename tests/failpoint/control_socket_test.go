@@ -0,0 +1,86 @@
+//go:build failpoint
+
+package failpoint
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/delaneyj/witchbolt"
+	"github.com/delaneyj/witchbolt/internal/btesting"
+	fp "github.com/delaneyj/witchbolt/internal/failpoint"
+)
+
+// TestFailpoint_ControlSocket toggles a failpoint through the control socket
+// while a write transaction is open, as an integration test driving another
+// process would.
+func TestFailpoint_ControlSocket(t *testing.T) {
+	// Unix socket paths are limited to about 100 bytes, which t.TempDir can
+	// exceed.
+	dir, err := os.MkdirTemp("", "fp")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "control.sock")
+	l, err := fp.ListenAndServe(socket)
+	require.NoError(t, err)
+	defer l.Close()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+	do := func(method, name, body string) (int, string) {
+		req, err := http.NewRequest(method, "http://failpoint/"+name, strings.NewReader(body))
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(data)
+	}
+
+	db := btesting.MustCreateDB(t)
+	tx, err := db.Begin(true)
+	require.NoError(t, err)
+	b, err := tx.CreateBucket([]byte("data"))
+	require.NoError(t, err)
+	require.NoError(t, b.Put([]byte("key"), []byte("value")))
+
+	t.Log("Enabling lackOfDiskSpace before the open transaction commits")
+	status, _ := do(http.MethodPut, "lackOfDiskSpace", `return("grow failed over the socket")`)
+	require.Equal(t, http.StatusNoContent, status)
+	status, body := do(http.MethodGet, "", "")
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "lackOfDiskSpace=return(\"grow failed over the socket\")\n", body)
+
+	require.ErrorContains(t, tx.Commit(), "grow failed over the socket")
+
+	t.Log("Disabling the failpoint lets the next transaction commit")
+	status, _ = do(http.MethodDelete, "lackOfDiskSpace", "")
+	require.Equal(t, http.StatusNoContent, status)
+	status, _ = do(http.MethodGet, "lackOfDiskSpace", "")
+	require.Equal(t, http.StatusNotFound, status)
+
+	require.NoError(t, db.Update(func(tx *witchbolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("data"))
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte("key"), []byte("value"))
+	}))
+
+	t.Log("Rejecting an invalid action")
+	status, body = do(http.MethodPut, "lackOfDiskSpace", "explode")
+	require.Equal(t, http.StatusBadRequest, status)
+	require.Contains(t, body, "unsupported action")
+}
@@ -129,6 +129,25 @@ func TestFailpoint_ResizeFileFail(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestFailpoint_LackOfDiskSpaceCount(t *testing.T) {
+	db := btesting.MustCreateDB(t)
+
+	require.NoError(t, fp.Enable("lackOfDiskSpace", `return("grow somehow failed")->count(1)`))
+	defer func() {
+		require.NoError(t, fp.Disable("lackOfDiskSpace"))
+	}()
+
+	tx, err := db.Begin(true)
+	require.NoError(t, err)
+	err = tx.Commit()
+	require.ErrorContains(t, err, "grow somehow failed")
+
+	// The failpoint is spent after firing once, so it needn't be disabled.
+	tx, err = db.Begin(true)
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+}
+
 func TestFailpoint_LackOfDiskSpace(t *testing.T) {
 	db := btesting.MustCreateDB(t)
 
@@ -1,6 +1,7 @@
 package witchbolt
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -14,6 +15,7 @@ import (
 
 	berrors "github.com/delaneyj/witchbolt/errors"
 	"github.com/delaneyj/witchbolt/internal/common"
+	"github.com/delaneyj/witchbolt/internal/compress"
 	fp "github.com/delaneyj/witchbolt/internal/failpoint"
 )
 
@@ -35,6 +37,20 @@ type Tx struct {
 	stats          TxStats
 	commitHandlers []func()
 
+	// pageVisited, if set, is called for every page the consistency walk
+	// visits. The freelist reconstruction and WithProgress use it to report
+	// progress.
+	pageVisited func(p *common.Page)
+
+	// detachedBuckets records the top-level buckets deleted or moved by the
+	// transaction, which leave no cached Bucket for PageFlushInfo.Buckets.
+	detachedBuckets map[string]struct{}
+
+	// ctx is the context given to DB.ViewContext or DB.UpdateContext, if
+	// any. The iteration helpers and Check stop with its error once it is
+	// done.
+	ctx context.Context
+
 	// WriteFlag specifies the flag for write-related methods like WriteTo().
 	// Tx opens the database file with the specified flag to copy the data.
 	//
@@ -113,6 +129,13 @@ func (tx *Tx) Bucket(name []byte) *Bucket {
 	return tx.root.Bucket(name)
 }
 
+// BucketExists returns true if the bucket path exists, where each element of
+// path names a bucket nested inside the previous one.
+// Returns false if path is empty.
+func (tx *Tx) BucketExists(path ...[]byte) bool {
+	return tx.root.Exists(path...)
+}
+
 // CreateBucket creates a new bucket.
 // Returns an error if the bucket already exists, if the bucket name is blank, or if the bucket name is too long.
 // The bucket instance is only valid for the lifetime of the transaction.
@@ -137,7 +160,8 @@ func (tx *Tx) DeleteBucket(name []byte) error {
 // Returns an error if
 //  1. the sub-bucket cannot be found in the source bucket;
 //  2. or the key already exists in the destination bucket;
-//  3. the key represents a non-bucket value.
+//  3. the key represents a non-bucket value;
+//  4. or dst is the child bucket itself or one of its descendants.
 //
 // If src is nil, it means moving a top level bucket into the target bucket.
 // If dst is nil, it means converting the child bucket into a top level bucket.
@@ -160,6 +184,38 @@ func (tx *Tx) ForEach(fn func(name []byte, b *Bucket) error) error {
 	})
 }
 
+// Context returns the context the transaction was started with by
+// DB.ViewContext or DB.UpdateContext, or context.Background for any other
+// transaction.
+func (tx *Tx) Context() context.Context {
+	if tx.ctx == nil {
+		return context.Background()
+	}
+	return tx.ctx
+}
+
+// ctxErr returns the error of the transaction's context, or nil if it has
+// none or it isn't done.
+func (tx *Tx) ctxErr() error {
+	if tx.ctx == nil {
+		return nil
+	}
+	return tx.ctx.Err()
+}
+
+// ctxCheckInterval is how many keys the iteration helpers visit between
+// checks of the transaction's context.
+const ctxCheckInterval = 256
+
+// iterErr is ctxErr for the i-th key of an iteration, which only looks at
+// the context on every ctxCheckInterval-th key, starting with the first.
+func (tx *Tx) iterErr(i int) error {
+	if tx.ctx == nil || i%ctxCheckInterval != 0 {
+		return nil
+	}
+	return tx.ctx.Err()
+}
+
 // OnCommit adds a handler function to be executed after the transaction successfully commits.
 func (tx *Tx) OnCommit(fn func()) {
 	tx.commitHandlers = append(tx.commitHandlers, fn)
@@ -189,6 +245,10 @@ func (tx *Tx) Commit() (err error) {
 		return berrors.ErrTxNotWritable
 	}
 
+	// A commit under way is not interrupted by the context of UpdateContext,
+	// nor is the StrictMode check below.
+	tx.ctx = nil
+
 	// TODO(benbjohnson): Use vectorized I/O to write out dirty pages.
 
 	// Rebalance nodes which have had deletions.
@@ -468,6 +528,52 @@ func (tx *Tx) WriteTo(w io.Writer) (n int64, err error) {
 	return n, nil
 }
 
+// CompressionType selects the codec of Tx.WriteToCompressed. The stream
+// package uses the same type for segments and snapshots.
+type CompressionType string
+
+const (
+	// CompressionNone writes the pages as they are.
+	CompressionNone CompressionType = compress.None
+	// CompressionZSTD compresses with Zstandard.
+	CompressionZSTD CompressionType = compress.ZSTD
+	// CompressionS2 compresses with the S2 (Snappy-compatible) framed stream
+	// format, trading ratio for much lower CPU usage than Zstandard.
+	CompressionS2 CompressionType = compress.S2
+)
+
+// WriteToCompressed writes the entire database to a writer, like WriteTo, as
+// a single stream compressed with codec. level is the codec-agnostic 1-11
+// quality scale of stream's CompressionConfig; 0 keeps the codec default.
+// It returns the number of compressed bytes written to w.
+func (tx *Tx) WriteToCompressed(w io.Writer, codec CompressionType, level int) (n int64, err error) {
+	cw := &countWriter{w: w}
+	zw, err := compress.NewWriter(cw, string(codec), level, 0)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.WriteTo(zw); err != nil {
+		_ = zw.Close()
+		return cw.n, err
+	}
+	if err := zw.Close(); err != nil {
+		return cw.n, fmt.Errorf("%s close: %w", codec, err)
+	}
+	return cw.n, nil
+}
+
+// countWriter counts the bytes written through it.
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
 func sameFile(f1, f2 *os.File) (bool, error) {
 	fi1, err := f1.Stat()
 	if err != nil {
@@ -530,6 +636,7 @@ func (tx *Tx) write() (err error) {
 	sort.Sort(pages)
 
 	// Capture page frames before writing so we can run observers post-commit.
+	tx.prepareTxCommit(pages)
 	if err := tx.preparePageFlush(pages); err != nil {
 		return err
 	}
@@ -569,9 +676,9 @@ func (tx *Tx) write() (err error) {
 	}
 
 	// Ignore file sync if flag is set on DB.
-	if !tx.db.NoSync || common.IgnoreNoSync {
+	if tx.db.syncEnabled() {
 		fp.InjectStruct("beforeSyncDataPages")
-		if err = fdatasync(tx.db); err != nil {
+		if err = tx.db.ops.fdatasync(); err != nil {
 			lg.Errorf("[GOOS: %s, GOARCH: %s] fdatasync failed: %v", runtime.GOOS, runtime.GOARCH, err)
 			return err
 		}
@@ -609,7 +716,14 @@ func (tx *Tx) preparePageFlush(pages common.Pages) error {
 		parentTxID = currentTxID - 1
 	}
 
-	frames := make([]PageFrame, 0, len(pages))
+	// The meta page is written after the dirty pages but is part of the
+	// commit; without it a replayed flush would leave the old root in place.
+	metaBuf := make([]byte, tx.db.pageSize)
+	metaPage := tx.db.pageInBuffer(metaBuf, 0)
+	tx.meta.Write(metaPage)
+
+	frames := make([]PageFrame, 0, len(pages)+1)
+	frames = append(frames, PageFrame{ID: uint64(metaPage.Id()), Data: metaBuf})
 	for _, page := range pages {
 		frame := PageFrame{
 			ID:       uint64(page.Id()),
@@ -631,23 +745,47 @@ func (tx *Tx) preparePageFlush(pages common.Pages) error {
 		HighWaterMark: uint64(tx.meta.Pgid()),
 		Timestamp:     time.Now(),
 		Frames:        frames,
+		Buckets:       tx.flushedBuckets(),
+	}
+	for _, id := range tx.db.freelist.PendingPageIDs(tx.meta.Txid()) {
+		info.FreedPages = append(info.FreedPages, uint64(id))
 	}
 
 	db := tx.db
-	for _, observer := range observers {
-		obs := observer
-		tx.OnCommit(func() {
-			if err := obs.OnPageFlush(info); err != nil {
-				if db != nil {
-					db.Logger().Errorf("page flush observer error: %v", err)
-				}
-			}
-		})
-	}
+	tx.OnCommit(func() {
+		if err := notifyPageFlush(observers, info, db.StopPageFlushOnError); err != nil {
+			db.Logger().Errorf("page flush observer error: %v", err)
+		}
+	})
 
 	return nil
 }
 
+// flushedBuckets returns the sorted names of the top-level buckets the
+// transaction changed. It runs after spill, which materializes the root node
+// of every bucket written to, directly or through a nested bucket.
+func (tx *Tx) flushedBuckets() []string {
+	var names []string
+	for name := range tx.detachedBuckets {
+		names = append(names, name)
+	}
+	for name, child := range tx.root.buckets {
+		if _, ok := tx.detachedBuckets[name]; !ok && child.rootNode != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// detachBucket records that the top-level bucket name was deleted or moved.
+func (tx *Tx) detachBucket(name []byte) {
+	if tx.detachedBuckets == nil {
+		tx.detachedBuckets = make(map[string]struct{})
+	}
+	tx.detachedBuckets[string(name)] = struct{}{}
+}
+
 func copyPagePayload(p *common.Page, pageSize int) []byte {
 	length := (int(p.Overflow()) + 1) * pageSize
 	src := common.UnsafeByteSlice(unsafe.Pointer(p), 0, 0, length)
@@ -676,9 +814,9 @@ func (tx *Tx) writeMeta() error {
 		return err
 	}
 	tx.db.metalock.Unlock()
-	if !tx.db.NoSync || common.IgnoreNoSync {
+	if tx.db.syncEnabled() {
 		fp.InjectStruct("beforeSyncMetaPage")
-		if err := fdatasync(tx.db); err != nil {
+		if err := tx.db.ops.fdatasync(); err != nil {
 			lg.Errorf("[GOOS: %s, GOARCH: %s] fdatasync failed: %w", runtime.GOOS, runtime.GOARCH, err)
 			return err
 		}
@@ -731,6 +869,9 @@ func (tx *Tx) forEachPageInternal(pgidstack []common.Pgid, fn func(*common.Page,
 
 // Page returns page information for a given page number.
 // This is only safe for concurrent use when used by a writable transaction.
+// Without a loaded freelist it returns ErrFreePagesNotLoaded, unless the
+// database was opened with SkipFreelistLoad, in which case free pages are
+// reported by the type in their header.
 func (tx *Tx) Page(id int) (*common.PageInfo, error) {
 	if tx.db == nil {
 		return nil, berrors.ErrTxClosed
@@ -738,7 +879,7 @@ func (tx *Tx) Page(id int) (*common.PageInfo, error) {
 		return nil, nil
 	}
 
-	if tx.db.freelist == nil {
+	if tx.db.freelist == nil && !tx.db.skipFreelistLoad {
 		return nil, berrors.ErrFreePagesNotLoaded
 	}
 
@@ -751,7 +892,7 @@ func (tx *Tx) Page(id int) (*common.PageInfo, error) {
 	}
 
 	// Determine the type (or if it's free).
-	if tx.db.freelist.Freed(common.Pgid(id)) {
+	if tx.db.freelist != nil && tx.db.freelist.Freed(common.Pgid(id)) {
 		info.Type = "free"
 	} else {
 		info.Type = p.Typ()
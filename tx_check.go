@@ -1,6 +1,7 @@
 package witchbolt
 
 import (
+	"bytes"
 	"encoding/hex"
 	"fmt"
 
@@ -16,6 +17,9 @@ import (
 // transaction, however, it is not safe to execute other writer transactions at
 // the same time.
 //
+// In a transaction started by DB.ViewContext or DB.UpdateContext, the check
+// stops early once the context is done and sends the context's error last.
+//
 // It also allows users to provide a customized `KVStringer` implementation,
 // so that bolt can generate human-readable diagnostic messages.
 func (tx *Tx) Check(options ...CheckOption) <-chan error {
@@ -60,10 +64,20 @@ func (tx *Tx) check(cfg checkConfig, ch chan error) {
 		}
 	}
 
+	if cfg.progress != nil {
+		defer tx.reportCheckProgress(cfg.progress, uint64(len(reachable)+len(freed)))()
+	}
+
 	if cfg.pageId == 0 {
 		// Check the whole db file, starting from the root bucket and
 		// recursively check all child buckets.
 		tx.recursivelyCheckBucket(&tx.root, reachable, freed, cfg.kvStringer, ch)
+		// A cancelled walk leaves pages unvisited, which would be reported
+		// as unreachable below.
+		if err := tx.ctxErr(); err != nil {
+			ch <- err
+			return
+		}
 
 		// Ensure all pages below high water mark are either reachable or freed.
 		for i := common.Pgid(0); i < tx.meta.Pgid(); i++ {
@@ -80,6 +94,38 @@ func (tx *Tx) check(cfg checkConfig, ch chan error) {
 		}
 
 		tx.recursivelyCheckPage(common.Pgid(cfg.pageId), reachable, freed, cfg.kvStringer, ch)
+		if err := tx.ctxErr(); err != nil {
+			ch <- err
+		}
+	}
+}
+
+// checkProgressInterval is how many checked pages pass between calls to the
+// WithProgress callback.
+const checkProgressInterval = 1024
+
+// reportCheckProgress hooks progress into the page walk, starting the count
+// of checked pages at done. The returned function unhooks it and makes the
+// final call.
+func (tx *Tx) reportCheckProgress(progress func(done, total uint64), done uint64) func() {
+	total := uint64(tx.meta.Pgid())
+	report := func() { progress(min(done, total), total) }
+	report()
+
+	prev, reported := tx.pageVisited, done
+	tx.pageVisited = func(p *common.Page) {
+		if prev != nil {
+			prev(p)
+		}
+		done += uint64(p.Overflow()) + 1
+		if done-reported >= checkProgressInterval {
+			reported = done
+			report()
+		}
+	}
+	return func() {
+		tx.pageVisited = prev
+		report()
 	}
 }
 
@@ -91,6 +137,9 @@ func (tx *Tx) recursivelyCheckPage(pageId common.Pgid, reachable map[common.Pgid
 
 func (tx *Tx) recursivelyCheckBucketInPage(pageId common.Pgid, reachable map[common.Pgid]*common.Page, freed map[common.Pgid]bool,
 	kvStringer KVStringer, ch chan error) {
+	if tx.ctxErr() != nil {
+		return
+	}
 	p := tx.page(pageId)
 
 	switch {
@@ -123,7 +172,7 @@ func (tx *Tx) recursivelyCheckBucketInPage(pageId common.Pgid, reachable map[com
 func (tx *Tx) recursivelyCheckBucket(b *Bucket, reachable map[common.Pgid]*common.Page, freed map[common.Pgid]bool,
 	kvStringer KVStringer, ch chan error) {
 	// Ignore inline buckets.
-	if b.RootPage() == 0 {
+	if b.RootPage() == 0 || tx.ctxErr() != nil {
 		return
 	}
 
@@ -141,6 +190,9 @@ func (tx *Tx) recursivelyCheckBucket(b *Bucket, reachable map[common.Pgid]*commo
 func (tx *Tx) checkInvariantProperties(pageId common.Pgid, reachable map[common.Pgid]*common.Page, freed map[common.Pgid]bool,
 	kvStringer KVStringer, ch chan error) {
 	tx.forEachPage(pageId, func(p *common.Page, _ int, stack []common.Pgid) {
+		if tx.pageVisited != nil {
+			tx.pageVisited(p)
+		}
 		verifyPageReachable(p, tx.meta.Pgid(), stack, reachable, freed, ch)
 	})
 
@@ -220,6 +272,30 @@ func (tx *Tx) recursivelyCheckPageKeyOrderInternal(
 	return maxKeyInSubtree
 }
 
+// PageKeyOrderError is sent by Tx.Check for a key on a branch or leaf page
+// that is not greater than the key before it on the same page: a duplicated
+// key when Duplicate is set, and an out-of-order one otherwise.
+type PageKeyOrderError struct {
+	PageID      uint64
+	PageType    string // "branch" or "leaf"
+	Index       int    // index of Key among the page elements
+	Key         []byte
+	PreviousKey []byte
+	Duplicate   bool
+
+	keyToString func([]byte) string
+	stack       []common.Pgid
+}
+
+func (e *PageKeyOrderError) Error() string {
+	found := "<"
+	if e.Duplicate {
+		found = "="
+	}
+	return fmt.Sprintf("key[%d]=(hex)%s on %s page(%d) needs to be > (found %s) than previous element (hex)%s. Stack: %v",
+		e.Index, e.keyToString(e.Key), e.PageType, e.PageID, found, e.keyToString(e.PreviousKey), e.stack)
+}
+
 /***
  * verifyKeyOrder checks whether an entry with given #index on pgId (pageType: "branch|leaf") that has given "key",
  * is within range determined by (previousKey..maxKeyOpen) and reports found violations to the channel (ch).
@@ -230,14 +306,17 @@ func verifyKeyOrder(pgId common.Pgid, pageType string, index int, key []byte, pr
 			index, keyToString(key), pageType, pgId, keyToString(previousKey), pagesStack)
 	}
 	if index > 0 {
-		cmpRet := compareKeys(previousKey, key)
-		if cmpRet > 0 {
-			ch <- fmt.Errorf("key[%d]=(hex)%s on %s page(%d) needs to be > (found <) than previous element (hex)%s. Stack: %v",
-				index, keyToString(key), pageType, pgId, keyToString(previousKey), pagesStack)
-		}
-		if cmpRet == 0 {
-			ch <- fmt.Errorf("key[%d]=(hex)%s on %s page(%d) needs to be > (found =) than previous element (hex)%s. Stack: %v",
-				index, keyToString(key), pageType, pgId, keyToString(previousKey), pagesStack)
+		if cmpRet := compareKeys(previousKey, key); cmpRet >= 0 {
+			ch <- &PageKeyOrderError{
+				PageID:      uint64(pgId),
+				PageType:    pageType,
+				Index:       index,
+				Key:         bytes.Clone(key),
+				PreviousKey: bytes.Clone(previousKey),
+				Duplicate:   cmpRet == 0,
+				keyToString: keyToString,
+				stack:       append([]common.Pgid(nil), pagesStack...),
+			}
 		}
 	}
 	if maxKeyOpen != nil && compareKeys(key, maxKeyOpen) >= 0 {
@@ -251,6 +330,7 @@ func verifyKeyOrder(pgId common.Pgid, pageType string, index int, key []byte, pr
 type checkConfig struct {
 	kvStringer KVStringer
 	pageId     uint64
+	progress   func(done, total uint64)
 }
 
 type CheckOption func(options *checkConfig)
@@ -268,6 +348,15 @@ func WithPageId(pageId uint64) CheckOption {
 	}
 }
 
+// WithProgress sets a callback that is periodically given the number of pages
+// checked so far and the high water mark of the database. It is called from
+// the goroutine running the check, once more when the check completes.
+func WithProgress(fn func(done, total uint64)) CheckOption {
+	return func(c *checkConfig) {
+		c.progress = fn
+	}
+}
+
 // KVStringer allows to prepare human-readable diagnostic messages.
 type KVStringer interface {
 	KeyToString([]byte) string
@@ -120,6 +120,43 @@ func TestTx_Check_WithNestBucket(t *testing.T) {
 	db.MustClose()
 }
 
+func TestTx_Check_WithProgress(t *testing.T) {
+	db := btesting.MustCreateDBWithOption(t, &witchbolt.Options{PageSize: 4096})
+
+	t.Log("Populating enough pages for intermediate progress reports")
+	err := db.Update(func(tx *witchbolt.Tx) error {
+		b, bErr := tx.CreateBucket([]byte("data"))
+		if bErr != nil {
+			return bErr
+		}
+		for i := 0; i < 2000; i++ {
+			if pErr := b.Put([]byte(fmt.Sprintf("%04d", i)), make([]byte, 3000)); pErr != nil {
+				return pErr
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	err = db.View(func(tx *witchbolt.Tx) error {
+		var done []uint64
+		var total uint64
+		for cErr := range tx.Check(witchbolt.WithProgress(func(d, tot uint64) {
+			done = append(done, d)
+			total = tot
+		})) {
+			require.NoError(t, cErr)
+		}
+
+		require.Equal(t, uint64(tx.Size())/4096, total, "the total is the high water mark")
+		require.Greater(t, len(done), 2)
+		require.IsNonDecreasing(t, done)
+		require.Equal(t, total, done[len(done)-1], "every page is accounted for in a consistent database")
+		return nil
+	})
+	require.NoError(t, err)
+}
+
 // corruptRandomLeafPage corrupts one random leaf page.
 func corruptRandomLeafPageInBucket(t testing.TB, db *witchbolt.DB, bucketName []byte) (victimPageId common.Pgid, validPageIds []common.Pgid) {
 	bucketRootPageId := mustGetBucketRootPage(t, db, bucketName)
@@ -4,12 +4,15 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"runtime"
 	"testing"
 	"time"
 
+	"github.com/klauspost/compress/s2"
+	"github.com/klauspost/compress/zstd"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -207,6 +210,36 @@ func TestTx_Bucket(t *testing.T) {
 	}
 }
 
+// Ensure that a Tx can check for nested bucket paths.
+func TestTx_BucketExists(t *testing.T) {
+	db := btesting.MustCreateDB(t)
+	err := db.Update(func(tx *witchbolt.Tx) error {
+		widgets, err := tx.CreateBucket([]byte("widgets"))
+		require.NoError(t, err)
+		gadgets, err := widgets.CreateBucket([]byte("gadgets"))
+		require.NoError(t, err)
+		require.NoError(t, gadgets.Put([]byte("foo"), []byte("bar")))
+		return nil
+	})
+	require.NoError(t, err)
+
+	err = db.View(func(tx *witchbolt.Tx) error {
+		require.True(t, tx.BucketExists([]byte("widgets")))
+		require.True(t, tx.BucketExists([]byte("widgets"), []byte("gadgets")))
+		require.False(t, tx.BucketExists())
+		require.False(t, tx.BucketExists([]byte("missing")))
+		require.False(t, tx.BucketExists([]byte("missing"), []byte("gadgets")))
+		require.False(t, tx.BucketExists([]byte("widgets"), []byte("missing")))
+		require.False(t, tx.BucketExists([]byte("widgets"), []byte("gadgets"), []byte("foo")), "keys are not buckets")
+
+		widgets := tx.Bucket([]byte("widgets"))
+		require.True(t, widgets.Exists([]byte("gadgets")))
+		require.False(t, widgets.Exists([]byte("widgets")))
+		return nil
+	})
+	require.NoError(t, err)
+}
+
 // Ensure that a Tx retrieving a non-existent key returns nil.
 func TestTx_Get_NotFound(t *testing.T) {
 	db := btesting.MustCreateDB(t)
@@ -641,6 +674,79 @@ func TestTx_CopyFile_Error_Normal(t *testing.T) {
 	}
 }
 
+// Ensure that WriteToCompressed writes a compressed stream of the WriteTo copy
+// and returns its size.
+func TestTx_WriteToCompressed(t *testing.T) {
+	db := btesting.MustCreateDB(t)
+	err := db.Update(func(tx *witchbolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("widgets"))
+		require.NoError(t, err)
+		for i := 0; i < 1000; i++ {
+			require.NoError(t, b.Put([]byte(fmt.Sprintf("key-%04d", i)), bytes.Repeat([]byte("value"), 20)))
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	var raw bytes.Buffer
+	require.NoError(t, db.View(func(tx *witchbolt.Tx) error {
+		_, err := tx.WriteTo(&raw)
+		return err
+	}))
+
+	decoders := map[witchbolt.CompressionType]func(r io.Reader) io.Reader{
+		witchbolt.CompressionNone: func(r io.Reader) io.Reader { return r },
+		witchbolt.CompressionZSTD: func(r io.Reader) io.Reader {
+			d, err := zstd.NewReader(r)
+			require.NoError(t, err)
+			t.Cleanup(d.Close)
+			return d
+		},
+		witchbolt.CompressionS2: func(r io.Reader) io.Reader { return s2.NewReader(r) },
+	}
+	for codec, decode := range decoders {
+		for _, level := range []int{0, 1, 11} {
+			t.Run(fmt.Sprintf("%s/%d", codec, level), func(t *testing.T) {
+				var out bytes.Buffer
+				var n int64
+				require.NoError(t, db.View(func(tx *witchbolt.Tx) (err error) {
+					n, err = tx.WriteToCompressed(&out, codec, level)
+					return err
+				}))
+				require.Equal(t, int64(out.Len()), n)
+				if codec != witchbolt.CompressionNone {
+					require.Less(t, n, int64(raw.Len())/2)
+				}
+
+				data, err := io.ReadAll(decode(&out))
+				require.NoError(t, err)
+				require.Equal(t, raw.Bytes(), data)
+			})
+		}
+	}
+}
+
+// Ensure that WriteToCompressed reports unknown codecs and write errors.
+func TestTx_WriteToCompressed_Error(t *testing.T) {
+	db := btesting.MustCreateDB(t)
+	err := db.Update(func(tx *witchbolt.Tx) error {
+		_, err := tx.CreateBucket([]byte("widgets"))
+		return err
+	})
+	require.NoError(t, err)
+
+	err = db.View(func(tx *witchbolt.Tx) error {
+		_, err := tx.WriteToCompressed(io.Discard, "lz4", 0)
+		require.EqualError(t, err, "unknown compression codec: lz4")
+
+		n, err := tx.WriteToCompressed(&failWriter{After: 10}, witchbolt.CompressionZSTD, 0)
+		require.ErrorIs(t, err, failWriterError{})
+		require.Equal(t, int64(10), n)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
 // TestTx_Rollback ensures there is no error when tx rollback whether we sync freelist or not.
 func TestTx_Rollback(t *testing.T) {
 	for _, isSyncFreelist := range []bool{false, true} {
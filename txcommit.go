@@ -0,0 +1,119 @@
+package witchbolt
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/delaneyj/witchbolt/internal/common"
+)
+
+// TxCommitObserver is notified of every committed read-write transaction,
+// whether it was committed by Update, Batch or Tx.Commit. It is a lighter
+// signal than PageFlushObserver: it gets a summary of the commit instead of
+// copies of its pages.
+type TxCommitObserver interface {
+	// OnTxCommit runs on the committing goroutine once the commit succeeds
+	// and the writer lock has been released, after the Tx.OnCommit handlers
+	// of the transaction and before the OnPageFlush observers of the same
+	// commit. Commits of successive transactions may therefore be delivered
+	// concurrently and out of TxID order. The observers of one commit are
+	// called one at a time in registration order, share info, and must not
+	// modify it. A returned error is logged; it cannot undo the commit.
+	OnTxCommit(info TxCommitInfo) error
+}
+
+// TxCommitInfo summarizes a committed transaction.
+type TxCommitInfo struct {
+	TxID      uint64
+	Timestamp time.Time
+	// Buckets lists, sorted, the top-level buckets the commit created,
+	// deleted, moved or wrote to, including through nested buckets, as
+	// PageFlushInfo.Buckets does.
+	Buckets []string
+	// PageCount is the number of pages the commit wrote, including its meta
+	// page, and BytesWritten their size in bytes.
+	PageCount    int
+	BytesWritten int64
+}
+
+// RegisterTxCommitObserver registers an observer that is notified of every
+// committed transaction. Observers are notified in registration order;
+// registering one twice notifies it twice. Passing nil clears all observers.
+func (db *DB) RegisterTxCommitObserver(observer TxCommitObserver) {
+	db.flushMu.Lock()
+	defer db.flushMu.Unlock()
+	if observer == nil {
+		db.commitObservers = nil
+		return
+	}
+	db.commitObservers = append(db.commitObservers, observer)
+}
+
+// UnregisterTxCommitObserver removes a previously registered observer,
+// leaving the others in order. An observer registered more than once loses
+// its earliest registration. Observers are compared with ==, so their dynamic
+// type must be comparable.
+func (db *DB) UnregisterTxCommitObserver(observer TxCommitObserver) {
+	if observer == nil {
+		return
+	}
+	db.flushMu.Lock()
+	defer db.flushMu.Unlock()
+	for i, obs := range db.commitObservers {
+		if obs == observer {
+			db.commitObservers = append(db.commitObservers[:i], db.commitObservers[i+1:]...)
+			break
+		}
+	}
+}
+
+func (db *DB) getTxCommitObservers() []TxCommitObserver {
+	db.flushMu.RLock()
+	defer db.flushMu.RUnlock()
+	if len(db.commitObservers) == 0 {
+		return nil
+	}
+	observers := make([]TxCommitObserver, len(db.commitObservers))
+	copy(observers, db.commitObservers)
+	return observers
+}
+
+// prepareTxCommit summarizes the commit of the dirty pages, for the commit
+// observers to be notified once the commit succeeds. It must be called
+// before preparePageFlush, which registers its own commit handler.
+func (tx *Tx) prepareTxCommit(pages common.Pages) {
+	observers := tx.db.getTxCommitObservers()
+	if len(observers) == 0 {
+		return
+	}
+
+	info := TxCommitInfo{
+		TxID:         uint64(tx.meta.Txid()),
+		Timestamp:    time.Now(),
+		Buckets:      tx.flushedBuckets(),
+		PageCount:    len(pages) + 1,
+		BytesWritten: int64(tx.db.pageSize),
+	}
+	for _, p := range pages {
+		info.BytesWritten += int64(p.Overflow()+1) * int64(tx.db.pageSize)
+	}
+
+	db := tx.db
+	tx.OnCommit(func() {
+		if err := notifyTxCommit(observers, info); err != nil {
+			db.Logger().Errorf("tx commit observer error: %v", err)
+		}
+	})
+}
+
+// notifyTxCommit calls each observer in turn, returning their errors joined.
+func notifyTxCommit(observers []TxCommitObserver, info TxCommitInfo) error {
+	var errs []error
+	for i, o := range observers {
+		if err := o.OnTxCommit(info); err != nil {
+			errs = append(errs, fmt.Errorf("tx commit observer %d (%T): %w", i, o, err))
+		}
+	}
+	return errors.Join(errs...)
+}
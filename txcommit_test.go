@@ -0,0 +1,117 @@
+package witchbolt_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/delaneyj/witchbolt"
+	"github.com/delaneyj/witchbolt/internal/btesting"
+)
+
+type recordingCommitObserver struct {
+	infos []witchbolt.TxCommitInfo
+	err   error
+	order *[]string
+}
+
+func (o *recordingCommitObserver) OnTxCommit(info witchbolt.TxCommitInfo) error {
+	o.infos = append(o.infos, info)
+	if o.order != nil {
+		*o.order = append(*o.order, "commit")
+	}
+	return o.err
+}
+
+type orderFlushObserver struct {
+	order *[]string
+	infos []witchbolt.PageFlushInfo
+}
+
+func (o *orderFlushObserver) OnPageFlush(info witchbolt.PageFlushInfo) error {
+	o.infos = append(o.infos, info)
+	*o.order = append(*o.order, "flush")
+	return nil
+}
+
+func TestDB_RegisterTxCommitObserver(t *testing.T) {
+	db := btesting.MustCreateDB(t)
+
+	var order []string
+	observer := &recordingCommitObserver{order: &order}
+	flush := &orderFlushObserver{order: &order}
+	db.RegisterTxCommitObserver(observer)
+	db.RegisterPageFlushObserver(flush)
+
+	var txid int
+	require.NoError(t, db.Update(func(tx *witchbolt.Tx) error {
+		txid = tx.ID()
+		tx.OnCommit(func() { order = append(order, "handler") })
+		b, err := tx.CreateBucket([]byte("widgets"))
+		if err != nil {
+			return err
+		}
+		g, err := tx.CreateBucket([]byte("gadgets"))
+		if err != nil {
+			return err
+		}
+		if err := g.Put([]byte("foo"), []byte("bar")); err != nil {
+			return err
+		}
+		return b.Put([]byte("foo"), make([]byte, 10000))
+	}))
+
+	require.Len(t, observer.infos, 1)
+	info := observer.infos[0]
+	require.Equal(t, uint64(txid), info.TxID)
+	require.Equal(t, []string{"gadgets", "widgets"}, info.Buckets)
+	require.False(t, info.Timestamp.IsZero())
+
+	t.Log("The summary matches the pages the flush observer receives")
+	require.Len(t, flush.infos, 1)
+	require.Equal(t, flush.infos[0].PageCount, info.PageCount)
+	var written int64
+	for _, frame := range flush.infos[0].Frames {
+		written += int64(len(frame.Data))
+	}
+	require.Equal(t, written, info.BytesWritten)
+
+	t.Log("Commit handlers run first, then commit observers, then flush observers")
+	require.Equal(t, []string{"handler", "commit", "flush"}, order)
+
+	t.Log("Rolled back and read-only transactions are not observed")
+	require.Error(t, db.Update(func(tx *witchbolt.Tx) error {
+		if err := tx.Bucket([]byte("widgets")).Put([]byte("bar"), []byte("baz")); err != nil {
+			return err
+		}
+		return errors.New("rollback")
+	}))
+	require.NoError(t, db.View(func(tx *witchbolt.Tx) error { return nil }))
+	require.Len(t, observer.infos, 1)
+
+	t.Log("An observer error does not fail the commit")
+	failing := &recordingCommitObserver{err: errors.New("observer failed")}
+	db.RegisterTxCommitObserver(failing)
+	require.NoError(t, db.Update(func(tx *witchbolt.Tx) error {
+		return tx.Bucket([]byte("gadgets")).Put([]byte("bar"), []byte("baz"))
+	}))
+	require.Len(t, observer.infos, 2)
+	require.Len(t, failing.infos, 1)
+	require.Equal(t, []string{"gadgets"}, observer.infos[1].Buckets)
+
+	t.Log("Unregistered observers are no longer notified")
+	db.UnregisterTxCommitObserver(observer)
+	require.NoError(t, db.Update(func(tx *witchbolt.Tx) error {
+		return tx.DeleteBucket([]byte("gadgets"))
+	}))
+	require.Len(t, observer.infos, 2)
+	require.Len(t, failing.infos, 2)
+	require.Equal(t, []string{"gadgets"}, failing.infos[1].Buckets)
+
+	db.RegisterTxCommitObserver(nil)
+	require.NoError(t, db.Update(func(tx *witchbolt.Tx) error {
+		return tx.Bucket([]byte("widgets")).Put([]byte("bar"), []byte("baz"))
+	}))
+	require.Len(t, failing.infos, 2)
+}